@@ -0,0 +1,358 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// nameProblem returns the message saying why a name can't be used for a
+// character, or an empty string if it can.
+func nameProblem(name string) string {
+	if len(name) < 3 {
+		return "login.name_short"
+	}
+	for _, r := range name {
+		if !unicode.IsLetter(r) {
+			return "login.name_letters"
+		}
+	}
+	return ""
+}
+
+// nameKey is the key a character's connection is kept under in the world's
+// connections, so that a name is the same whatever its case.
+func nameKey(name string) string {
+	return strings.ToLower(name)
+}
+
+// onlineAs returns the playing connection for the named character, ignoring
+// case.
+func (m *mud) onlineAs(name string) *connection {
+	for _, conn := range m.conns {
+		if conn.state == statePlaying && strings.EqualFold(conn.name, name) {
+			return conn
+		}
+	}
+	return nil
+}
+
+// deleteCharacter permanently deletes the player's own character once they
+// confirm with their name and password.
+func (m *mud) deleteCharacter(c *connection, args []string) {
+	p := c.player
+	if len(args) != 2 || !strings.EqualFold(args[0], c.name) {
+		c.write(c.tr("delete.warning"))
+		return
+	}
+	if !p.checkPassword(args[1]) {
+		c.write(c.tr("login.password_wrong"))
+		return
+	}
+	if err := m.store.remove(c.name); err != nil {
+		log.Printf("error deleting %s: %v", c.name, err)
+		c.write(c.tr("delete.failed"))
+		return
+	}
+
+	name := c.name
+	m.everyWorld(func(w *mud) { w.dropName(name) })
+	for _, f := range append([]*npc(nil), p.followers...) {
+		m.dismiss(c, f)
+	}
+	if t := p.jailTask; t != nil {
+		m.scheduler.cancel(t.id)
+	}
+	m.goldDestroyed("deletion", p.gold)
+
+	c.write(c.tr("delete.done"))
+	m.forget(c)
+	c.stopRecording()
+	c.close()
+	c.state = stateDead
+	m.send(everyone(), rendered((*connection).locale, func(conn *connection) string {
+		return conn.tr("delete.others", "name", c.name)
+	}).asAside(nil))
+}
+
+// dropName lets go of everything in the world tied to a deleted
+// character's name.
+func (m *mud) dropName(name string) {
+	m.cancelBounties(name)
+	kept := m.bounties[:0]
+	for _, b := range m.bounties {
+		if b.PostedBy != name {
+			kept = append(kept, b)
+		}
+	}
+	m.bounties = kept
+	m.saveBounties()
+	if h := m.houseOwnedBy(name); h != nil {
+		m.detachHouse(h)
+	}
+	for _, h := range m.houses {
+		h.Allowed = removeName(h.Allowed, name)
+	}
+	m.saveHouses()
+}
+
+// removeName returns the names without the given one, ignoring case.
+func removeName(names []string, name string) []string {
+	kept := names[:0]
+	for _, n := range names {
+		if !strings.EqualFold(n, name) {
+			kept = append(kept, n)
+		}
+	}
+	return kept
+}
+
+// renameCharacter lets staff rename an offline character, updating every
+// saved reference to the old name.
+func (m *mud) renameCharacter(c *connection, args []string) {
+	if !c.player.admin {
+		c.write(c.tr("command.unknown"))
+		return
+	}
+	if len(args) != 2 {
+		c.write(c.tr("rename.usage"))
+		return
+	}
+	oldName, newName := args[0], args[1]
+	if problem := nameProblem(newName); problem != "" {
+		c.write(c.tr(problem) + "\n")
+		return
+	}
+	if m.host.playingIn(oldName) != nil {
+		c.write(c.tr("rename.online", "name", capitalize(oldName)))
+		return
+	}
+	if _, err := m.store.load(oldName); err != nil {
+		c.write(c.tr("rename.no_character"))
+		return
+	}
+	if _, err := m.store.load(newName); !os.IsNotExist(err) || m.host.playingIn(newName) != nil {
+		c.write(c.tr("rename.taken", "name", newName))
+		return
+	}
+
+	// rename the character in every record that mentions them: this
+	// world's state, the mail and deaths of everyone offline, the season
+	// archives, and last the character's own record. All of it is staged
+	// before any file is replaced, and if anything fails the world is put
+	// back as it was
+	recs, err := m.store.list()
+	if err != nil {
+		log.Printf("error renaming %s: %v", oldName, err)
+		c.write(c.tr("rename.failed"))
+		return
+	}
+	var own *characterRecord
+	var changed []*characterRecord
+	for _, rec := range recs {
+		if m.host.playingIn(rec.Name) != nil {
+			continue
+		}
+		if strings.EqualFold(rec.Name, oldName) {
+			rec.Name, own = newName, rec
+		}
+		if renameInRecord(rec, oldName, newName) && rec != own {
+			changed = append(changed, rec)
+		}
+	}
+	if own == nil {
+		c.write(c.tr("rename.no_character"))
+		return
+	}
+	m.renameInWorld(oldName, newName)
+	var st staging
+	err = m.stageNamedState(&st)
+	var archived []string
+	if err == nil {
+		archived, err = m.stageArchives(&st, oldName, newName)
+	}
+	for _, rec := range append(changed, own) {
+		if err == nil {
+			err = st.write(m.store.path(rec.Name), rec, 0600)
+		}
+	}
+	if err == nil {
+		err = st.commit()
+	}
+	if err != nil {
+		st.discard()
+		m.renameInWorld(newName, oldName)
+		if err := m.saveNamedState(); err != nil {
+			log.Printf("error restoring the world after renaming %s: %v", oldName, err)
+		}
+		log.Printf("error renaming %s: %v", oldName, err)
+		c.write(c.tr("rename.failed"))
+		return
+	}
+	for _, path := range append(archived, m.store.path(oldName)) {
+		if err := os.Remove(path); err != nil {
+			log.Printf("error renaming %s: %v", oldName, err)
+		}
+	}
+
+	// then the other worlds
+	m.everyWorld(func(w *mud) {
+		if w == m {
+			return
+		}
+		w.renameInWorld(oldName, newName)
+		if err := w.saveNamedState(); err != nil {
+			log.Printf("error renaming %s: %v", oldName, err)
+		}
+	})
+	c.write(c.tr("rename.done", "old", capitalize(oldName), "new", newName))
+}
+
+// renamed returns name with the old name replaced by the new one, written
+// with a capital if it was, and whether it was the old name at all.
+func renamed(name, oldName, newName string) (string, bool) {
+	if !strings.EqualFold(name, oldName) {
+		return name, false
+	}
+	if name != "" && unicode.IsUpper(rune(name[0])) {
+		return capitalize(newName), true
+	}
+	return newName, true
+}
+
+// renameInRecord renames the character in the mail and deaths of an
+// offline character's record, reporting whether anything changed.
+func renameInRecord(rec *characterRecord, oldName, newName string) bool {
+	touched := false
+	for i := range rec.Mail {
+		var ok bool
+		if rec.Mail[i].From, ok = renamed(rec.Mail[i].From, oldName, newName); ok {
+			touched = true
+		}
+	}
+	if renameInDeaths(rec.DeathLog, oldName, newName) {
+		touched = true
+	}
+	return touched
+}
+
+// renameInDeaths renames the character wherever they killed or died in the
+// deaths, reporting whether any mentioned them.
+func renameInDeaths(deaths []death, oldName, newName string) bool {
+	touched := false
+	for i := range deaths {
+		var killer, victim bool
+		deaths[i].Killer, killer = renamed(deaths[i].Killer, oldName, newName)
+		deaths[i].Victim, victim = renamed(deaths[i].Victim, oldName, newName)
+		touched = touched || killer || victim
+	}
+	return touched
+}
+
+// renameInWorld updates the live players and the world's state for a
+// character's new name, without saving it.
+func (m *mud) renameInWorld(oldName, newName string) {
+	for _, conn := range m.conns {
+		if conn.state != statePlaying {
+			continue
+		}
+		for i := range conn.player.mail {
+			conn.player.mail[i].From, _ = renamed(conn.player.mail[i].From, oldName, newName)
+		}
+		renameInDeaths(conn.player.deaths, oldName, newName)
+	}
+	for _, h := range m.houses {
+		if strings.EqualFold(h.Owner, oldName) {
+			h.Owner = newName
+			if t := m.houseTemplates[h.Template]; t != nil {
+				h.room.name = fmt.Sprintf(t.Name, newName)
+			}
+		}
+		for i := range h.Allowed {
+			h.Allowed[i], _ = renamed(h.Allowed[i], oldName, newName)
+		}
+	}
+	for _, b := range m.bounties {
+		if b.Player && strings.EqualFold(b.Target, oldName) {
+			b.Target, b.Name = newName, capitalize(newName)
+		}
+		b.PostedBy, _ = renamed(b.PostedBy, oldName, newName)
+	}
+	renameInDeaths(m.deaths, oldName, newName)
+	for _, r := range m.reports {
+		r.Player, _ = renamed(r.Player, oldName, newName)
+		r.Assignee, _ = renamed(r.Assignee, oldName, newName)
+	}
+	for _, s := range m.snapshots {
+		s.Player, _ = renamed(s.Player, oldName, newName)
+	}
+	for _, past := range m.season.Past {
+		for _, entries := range past.Champions {
+			for i := range entries {
+				entries[i].Name, _ = renamed(entries[i].Name, oldName, newName)
+			}
+		}
+	}
+	m.leaderboards.mu.Lock()
+	m.leaderboards.boards = nil
+	m.leaderboards.mu.Unlock()
+}
+
+// stageNamedState stages the world's state files that name characters.
+func (m *mud) stageNamedState(st *staging) error {
+	m.recordHouseItems()
+	for _, f := range []struct {
+		file string
+		v    interface{}
+	}{
+		{housesFile, m.houses},
+		{bountiesFile, m.bounties},
+		{deathsFile, m.deaths},
+		{reportsFile, m.reports},
+		{snapshotsFile, m.snapshots},
+		{seasonFile, m.season},
+	} {
+		if err := st.write(m.statePath(f.file), f.v, 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// saveNamedState writes the world's state files that name characters
+// together.
+func (m *mud) saveNamedState() error {
+	var st staging
+	if err := m.stageNamedState(&st); err != nil {
+		st.discard()
+		return err
+	}
+	return st.commit()
+}
+
+// stageArchives stages the character's record in each past season's
+// archive under the new name, returning the old records to remove once
+// the rename is committed.
+func (m *mud) stageArchives(st *staging, oldName, newName string) ([]string, error) {
+	var old []string
+	for _, past := range m.season.Past {
+		archive := newStore(filepath.Join(seasonsDir, strconv.Itoa(past.Number), filepath.Base(m.store.dir)))
+		rec, err := archive.load(oldName)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		rec.Name = newName
+		if err := st.write(archive.path(newName), rec, 0600); err != nil {
+			return nil, err
+		}
+		old = append(old, archive.path(oldName))
+	}
+	return old, nil
+}
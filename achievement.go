@@ -0,0 +1,122 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// achievement is a milestone awarded when a player stat reaches a goal.
+type achievement struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Event       string `json:"event"`
+	Stat        string `json:"stat"`
+	Goal        int    `json:"goal"`
+	Rare        bool   `json:"rare"`
+
+	// Reward is an item given with the achievement, such as a cosmetic
+	Reward string `json:"reward,omitempty"`
+}
+
+// achievementStat returns the current value of a stat tracked by achievements.
+func (m *mud) achievementStat(p *player, stat string) int {
+	switch stat {
+	case "kills":
+		return p.kills
+	case "rooms":
+		return len(p.visited)
+	case "explored":
+		return m.explorationPercent(p)
+	case "level":
+		return p.level
+	case "gold":
+		return p.gold
+	}
+	return 0
+}
+
+// registerAchievements subscribes the achievement engine to every event kind
+// used by an achievement.
+func (m *mud) registerAchievements() {
+	kinds := make(map[string]bool)
+	for _, a := range m.achievements {
+		kinds[a.Event] = true
+	}
+	for kind := range kinds {
+		m.events.subscribe(kind, m.checkAchievements)
+	}
+}
+
+// checkAchievements awards any achievements for the event's kind whose goal
+// the player has reached.
+func (m *mud) checkAchievements(e event) {
+	c := e.conn
+	if c == nil || c.player == nil {
+		return
+	}
+	p := c.player
+	unlocked := false
+	for _, a := range m.achievements {
+		if a.Event != e.kind {
+			continue
+		}
+		if _, ok := p.achievements[a.ID]; ok {
+			continue
+		}
+		if m.achievementStat(p, a.Stat) < a.Goal {
+			continue
+		}
+		p.achievements[a.ID] = time.Now()
+		unlocked = true
+		c.write(c.tr("achievements.achievement_unlocked", "name", a.Name))
+		if t, ok := m.itemTemplates[a.Reward]; ok {
+			it := newItem(t)
+			p.pickUp(it)
+			c.write(c.tr("achievements.awarded", "item", it.displayName(c)))
+		}
+		if a.Rare {
+			m.send(everyone().except(c), rendered((*connection).locale, func(conn *connection) string {
+				return conn.tr("achievements.rare_others", "name", c.name, "achievement", a.Name)
+			}).asAside(c))
+		}
+	}
+	if unlocked {
+		m.savePlayer(c)
+	}
+}
+
+// showAchievements lists the achievements the player has earned and their
+// progress towards the rest.
+func (m *mud) showAchievements(c *connection) {
+	p := c.player
+	earned := make([]*achievement, 0, len(p.achievements))
+	var pending []*achievement
+	for _, a := range m.achievements {
+		if _, ok := p.achievements[a.ID]; ok {
+			earned = append(earned, a)
+		} else {
+			pending = append(pending, a)
+		}
+	}
+	sort.Slice(earned, func(i, j int) bool {
+		return p.achievements[earned[i].ID].Before(p.achievements[earned[j].ID])
+	})
+
+	c.write(c.tr("achievements.achievements_earned"))
+	if len(earned) == 0 {
+		c.write(c.tr("achievements.none_yet"))
+	}
+	for _, a := range earned {
+		c.write(fmt.Sprintf("  %s - %s (%s)\n", a.Name, a.Description, p.achievements[a.ID].Format("2006-01-02")))
+	}
+	c.write(c.tr("achievements.progress"))
+	for _, a := range pending {
+		progress := m.achievementStat(p, a.Stat)
+		if progress > a.Goal {
+			progress = a.Goal
+		}
+		c.write(fmt.Sprintf("  %s - %s [%d/%d]\n", a.Name, a.Description, progress, a.Goal))
+	}
+}
@@ -0,0 +1,272 @@
+package main
+
+import "strings"
+
+// pronouns are the words that stand in for someone's name.
+type pronouns struct {
+	Subject    string `json:"subject"`
+	Object     string `json:"object"`
+	Possessive string `json:"possessive"`
+}
+
+// neutralPronouns are used for anyone who hasn't been given others.
+var neutralPronouns = pronouns{Subject: "they", Object: "them", Possessive: "their"}
+
+// pronounSets are the pronouns that can be chosen by name, with the
+// genders that go by them.
+var pronounSets = map[string]pronouns{
+	"he":   {Subject: "he", Object: "him", Possessive: "his"},
+	"she":  {Subject: "she", Object: "her", Possessive: "her"},
+	"they": neutralPronouns,
+	"it":   {Subject: "it", Object: "it", Possessive: "its"},
+	"xe":   {Subject: "xe", Object: "xem", Possessive: "xyr"},
+	"ze":   {Subject: "ze", Object: "zir", Possessive: "zir"},
+}
+
+// genders name the pronoun set each gender goes by.
+var genders = map[string]string{
+	"male":      "he",
+	"female":    "she",
+	"neutral":   "they",
+	"nonbinary": "they",
+	"agender":   "they",
+}
+
+// parsePronouns reads a set of pronouns given by name, by gender, or
+// written out in full as subject/object/possessive.
+func parsePronouns(s string) (pronouns, bool) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if set, ok := genders[s]; ok {
+		s = set
+	}
+	if p, ok := pronounSets[s]; ok {
+		return p, true
+	}
+	parts := strings.Split(s, "/")
+	if len(parts) == 2 {
+		// he/him, she/her and the like, but not a mix such as he/her
+		p, ok := pronounSets[parts[0]]
+		return p, ok && parts[1] == p.Object
+	}
+	if len(parts) != 3 {
+		return pronouns{}, false
+	}
+	for _, part := range parts {
+		if part == "" || strings.IndexFunc(part, func(r rune) bool { return r < 'a' || r > 'z' }) >= 0 {
+			return pronouns{}, false
+		}
+	}
+	return pronouns{Subject: parts[0], Object: parts[1], Possessive: parts[2]}, true
+}
+
+// String writes the pronouns out as subject/object/possessive.
+func (p pronouns) String() string {
+	return p.Subject + "/" + p.Object + "/" + p.Possessive
+}
+
+// pronounChoices lists the pronoun sets that can be chosen by name.
+func pronounChoices() string {
+	return strings.Join(sortedKeys(pronounSets), ", ")
+}
+
+// pronounsCommand shows the player's pronouns, or changes them.
+func (m *mud) pronounsCommand(c *connection, args []string) {
+	if len(args) == 0 {
+		c.write(c.tr("pronouns.current", "pronouns", c.player.pronouns, "choices", pronounChoices()))
+		return
+	}
+	set := strings.Join(args, " ")
+	p, ok := parsePronouns(set)
+	if !ok && strings.Count(set, "/") == 1 {
+		c.write(c.tr("pronouns.mixed", "set", set))
+		return
+	}
+	if !ok {
+		c.write(c.tr("pronouns.choose", "choices", pronounChoices()))
+		return
+	}
+	c.player.pronouns = p
+	c.write(c.tr("pronouns.changed", "pronouns", p))
+	m.savePlayer(c)
+}
+
+// actor is a player or NPC an act message is about, in the room where it
+// happens.
+type actor struct {
+	conn *connection
+	npc  *npc
+	room string
+}
+
+// playerActor is the player as the subject of an act message, in the room
+// they are in.
+func playerActor(c *connection) actor {
+	return actor{conn: c, room: c.player.room}
+}
+
+// npcActor is the NPC as the subject of an act message, in the room.
+func npcActor(n *npc, r *room) actor {
+	a := actor{npc: n}
+	if r != nil {
+		a.room = r.id
+	}
+	return a
+}
+
+// nameFor returns the name the listener knows the actor by.
+func (a actor) nameFor(listener *connection) string {
+	switch {
+	case a.conn != nil:
+		return a.conn.nameFor(listener)
+	case a.npc != nil:
+		return a.npc.name
+	}
+	return "someone"
+}
+
+// pronouns returns the pronouns for the actor, they/them/their for
+// anyone without their own.
+func (a actor) pronouns() pronouns {
+	var p pronouns
+	switch {
+	case a.conn != nil && a.conn.player != nil:
+		p = a.conn.player.pronouns
+	case a.npc != nil:
+		p = a.npc.pronouns
+	}
+	if p.Subject == "" {
+		return neutralPronouns
+	}
+	return p
+}
+
+// who an act message is shown to
+const (
+	// toActor shows it to the actor alone
+	toActor = iota
+	// toVictim shows it to the victim alone
+	toVictim
+	// toRoom shows it to everyone in the room but the actor
+	toRoom
+	// toNotVictim shows it to everyone in the room but the actor and the
+	// victim
+	toNotVictim
+)
+
+// actText fills in an act message as the listener sees it. The codes are
+//
+//	$n $N  the name of the actor or the victim
+//	$e $E  they, he, she: the actor's or victim's subject pronoun
+//	$m $M  them, him, her: their object pronoun
+//	$s $S  their, his, her: their possessive pronoun
+//	$p     the item the message is about
+//	$t     the text given with the message
+//	$$     a dollar sign
+//
+// and the message starts with a capital. Since "they" takes a different
+// verb than "he" or "she", a message shouldn't follow $e with a verb.
+func actText(format string, ch, vict actor, obj *item, text string, listener *connection) string {
+	var b strings.Builder
+	for i := 0; i < len(format); i++ {
+		if format[i] != '$' || i+1 == len(format) {
+			b.WriteByte(format[i])
+			continue
+		}
+		i++
+		switch format[i] {
+		case 'n':
+			b.WriteString(ch.nameFor(listener))
+		case 'N':
+			b.WriteString(vict.nameFor(listener))
+		case 'e':
+			b.WriteString(ch.pronouns().Subject)
+		case 'E':
+			b.WriteString(vict.pronouns().Subject)
+		case 'm':
+			b.WriteString(ch.pronouns().Object)
+		case 'M':
+			b.WriteString(vict.pronouns().Object)
+		case 's':
+			b.WriteString(ch.pronouns().Possessive)
+		case 'S':
+			b.WriteString(vict.pronouns().Possessive)
+		case 'p':
+			if obj != nil {
+				b.WriteString(obj.displayName(listener))
+			} else {
+				b.WriteString(listener.tr("act.something"))
+			}
+		case 't':
+			b.WriteString(text)
+		case '$':
+			b.WriteByte('$')
+		default:
+			b.WriteByte('$')
+			b.WriteByte(format[i])
+		}
+	}
+	return capitalize(b.String())
+}
+
+// act shows a message about what the actor does, to the victim if there
+// is one, and ended with a newline. The message is a catalog key, whose
+// text in each listener's locale has its params filled in and then its
+// codes as actText does. The room is the actor's, or the victim's if the
+// actor has none. Listeners other than the actor see it as an aside.
+func (m *mud) act(to int, key string, ch, vict actor, text string, params ...interface{}) {
+	m.actObj(to, key, ch, vict, nil, text, params...)
+}
+
+// actObj is act for a message about an item as well.
+func (m *mud) actObj(to int, key string, ch, vict actor, obj *item, text string, params ...interface{}) {
+	if a := actAudience(to, ch, vict); a != nil {
+		m.actTo(a, key, ch, vict, obj, text, params...)
+	}
+}
+
+// actAudience returns who an act message goes to, or nil if there is no
+// one.
+func actAudience(to int, ch, vict actor) audience {
+	room := ch.room
+	if room == "" {
+		room = vict.room
+	}
+	switch to {
+	case toActor:
+		if ch.conn != nil {
+			return only(ch.conn)
+		}
+	case toVictim:
+		if vict.conn != nil {
+			return only(vict.conn)
+		}
+	case toRoom:
+		return inRoom(room).except(ch.conn)
+	case toNotVictim:
+		return inRoom(room).except(ch.conn).except(vict.conn)
+	}
+	return nil
+}
+
+// actTo shows an act message to whoever the audience picks, wherever they
+// are.
+func (m *mud) actTo(a audience, key string, ch, vict actor, obj *item, text string, params ...interface{}) {
+	m.actFormat(a, func(conn *connection) string {
+		return conn.tr(key, params...)
+	}, ch, vict, obj, text)
+}
+
+// actFormat shows an act message whose format is given for each listener,
+// such as one written in the world's data rather than the catalog.
+func (m *mud) actFormat(a audience, format func(conn *connection) string, ch, vict actor, obj *item, text string) {
+	var view func(conn *connection) string
+	if obj == nil {
+		// an item's name may show differently to each listener
+		view = func(conn *connection) string {
+			return conn.locale() + "\x00" + ch.nameFor(conn) + "\x00" + vict.nameFor(conn)
+		}
+	}
+	m.send(a, rendered(view, func(conn *connection) string {
+		return actText(format(conn), ch, vict, obj, text, conn) + "\n"
+	}).asAside(ch.conn))
+}
@@ -0,0 +1,26 @@
+package main
+
+import "testing"
+
+func TestParsePronouns(t *testing.T) {
+	for _, tc := range []struct {
+		in   string
+		want string
+		ok   bool
+	}{
+		{"he", "he/him/his", true},
+		{"Female", "she/her/her", true},
+		{"she/her", "she/her/her", true},
+		{"they/them", "they/them/their", true},
+		{"he/her", "", false},
+		{"she/him", "", false},
+		{"fae/faer", "", false},
+		{"he/her/his", "he/her/his", true},
+		{"x/y/z/w", "", false},
+	} {
+		p, ok := parsePronouns(tc.in)
+		if ok != tc.ok || ok && p.String() != tc.want {
+			t.Errorf("parsePronouns(%q) = %v, %v; want %s, %v", tc.in, p, ok, tc.want, tc.ok)
+		}
+	}
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"math/rand"
+	"strings"
+)
+
+// affix is a named stat modifier applied to a generated magic item.
+type affix struct {
+	Name  string         `json:"name"`
+	Stats map[string]int `json:"stats"`
+	Slots []string       `json:"slots"`
+}
+
+// rarity is a tier of generated item with a drop weight, affix count, and
+// the color used to display item names of that tier.
+type rarity struct {
+	Name       string `json:"name"`
+	Weight     int    `json:"weight"`
+	Affixes    int    `json:"affixes"`
+	Multiplier int    `json:"multiplier"`
+	Color      string `json:"color"`
+}
+
+// affixData holds the rarity tiers and affixes loaded from the affix data file.
+type affixData struct {
+	Rarities []rarity `json:"rarities"`
+	Prefixes []affix  `json:"prefixes"`
+	Suffixes []affix  `json:"suffixes"`
+}
+
+// allows reports whether the affix may be applied to an item in the given slot.
+func (a *affix) allows(slot string) bool {
+	if len(a.Slots) == 0 {
+		return true
+	}
+	for _, s := range a.Slots {
+		if s == slot {
+			return true
+		}
+	}
+	return false
+}
+
+// rollRarity picks a rarity tier by weight.
+func (d *affixData) rollRarity() *rarity {
+	total := 0
+	for _, r := range d.Rarities {
+		total += r.Weight
+	}
+	if total == 0 {
+		return nil
+	}
+	n := rand.Intn(total)
+	for i := range d.Rarities {
+		if n < d.Rarities[i].Weight {
+			return &d.Rarities[i]
+		}
+		n -= d.Rarities[i].Weight
+	}
+	return nil
+}
+
+// pickAffix returns a random affix from the list that fits the given slot.
+func pickAffix(affixes []affix, slot string) *affix {
+	var fits []*affix
+	for i := range affixes {
+		if affixes[i].allows(slot) {
+			fits = append(fits, &affixes[i])
+		}
+	}
+	if len(fits) == 0 {
+		return nil
+	}
+	return fits[rand.Intn(len(fits))]
+}
+
+// generateMagicItem creates an item from the template with the given id and
+// rolls a rarity tier for it, adding prefix and suffix affixes that modify
+// its stats and name.
+func (m *mud) generateMagicItem(id string) *item {
+	it := m.spawnItem(id)
+	if it == nil || it.slot == "" {
+		return it
+	}
+	tier := m.affixes.rollRarity()
+	if tier == nil {
+		return it
+	}
+	it.rarity = tier.Name
+	it.color = tier.Color
+
+	// choose which affixes to apply
+	var prefix, suffix *affix
+	switch {
+	case tier.Affixes >= 2:
+		prefix = pickAffix(m.affixes.Prefixes, it.slot)
+		suffix = pickAffix(m.affixes.Suffixes, it.slot)
+	case tier.Affixes == 1 && rand.Intn(2) == 0:
+		prefix = pickAffix(m.affixes.Prefixes, it.slot)
+	case tier.Affixes == 1:
+		suffix = pickAffix(m.affixes.Suffixes, it.slot)
+	}
+	if prefix == nil && suffix == nil {
+		return it
+	}
+
+	// apply the affix stats, scaled by the tier multiplier
+	mult := tier.Multiplier
+	if mult < 1 {
+		mult = 1
+	}
+	stats := make(map[string]int)
+	for k, v := range it.stats {
+		stats[k] = v
+	}
+	name := []string{it.baseName}
+	for _, a := range []*affix{prefix, suffix} {
+		if a == nil {
+			continue
+		}
+		for k, v := range a.Stats {
+			stats[k] += v * mult
+		}
+	}
+	if prefix != nil {
+		name = append([]string{prefix.Name}, name...)
+		it.keywords = append(it.keywords, strings.ToLower(prefix.Name))
+	}
+	if suffix != nil {
+		name = append(name, suffix.Name)
+	}
+	it.stats = stats
+	it.name = strings.Join(name, " ")
+	it.value *= 1 + mult
+	return it
+}
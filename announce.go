@@ -0,0 +1,105 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// announcementConfig is a recurring announcement defined in the config file.
+type announcementConfig struct {
+	Message string `json:"message"`
+	Every   int    `json:"every"`
+}
+
+// announceNow broadcasts a server announcement, returning how many it
+// reached.
+func (m *mud) announceNow(msg string) int {
+	return m.send(everyone(), rendered((*connection).locale, func(conn *connection) string {
+		return colorize(conn.tr("announce.announcement", "message", msg), "cyan") + "\n"
+	}).asAside(nil))
+}
+
+// scheduleAnnouncements registers the recurring announcements from the config.
+func (m *mud) scheduleAnnouncements() {
+	for _, a := range m.config.Announcements {
+		if a.Every <= 0 {
+			continue
+		}
+		msg := a.Message
+		m.scheduler.every("announce: "+msg, time.Duration(a.Every)*time.Minute, func() {
+			m.announceNow(msg)
+		})
+	}
+}
+
+// announce lets staff broadcast a message now, schedule one for later or on
+// a recurring interval, and list or cancel scheduled announcements.
+func (m *mud) announce(c *connection, args []string) {
+	if !c.player.admin {
+		c.write(c.tr("command.unknown"))
+		return
+	}
+	usage := c.tr("announce.usage")
+	if len(args) == 0 {
+		c.write(usage)
+		return
+	}
+
+	switch args[0] {
+	case "in", "every":
+		if len(args) < 3 {
+			c.write(usage)
+			return
+		}
+		minutes, err := strconv.Atoi(args[1])
+		if err != nil || minutes <= 0 {
+			c.write(c.tr("announce.delay_must_positive"))
+			return
+		}
+		msg := strings.Join(args[2:], " ")
+		d := time.Duration(minutes) * time.Minute
+		var t *task
+		if args[0] == "in" {
+			t = m.scheduler.after("announce: "+msg, d, func() { m.announceNow(msg) })
+		} else {
+			t = m.scheduler.every("announce: "+msg, d, func() { m.announceNow(msg) })
+		}
+		c.write(c.tr("announce.announcement_scheduled", "id", t.id))
+	case "list":
+		c.write(c.tr("announce.scheduled_announcements"))
+		for _, t := range m.scheduler.list() {
+			if !strings.HasPrefix(t.name, "announce: ") {
+				continue
+			}
+			msg := strings.TrimPrefix(t.name, "announce: ")
+			in := time.Until(t.next).Round(time.Second)
+			if t.interval > 0 {
+				c.write(c.tr("announce.scheduled_every", "id", t.id, "message", msg, "in", in, "every", t.interval))
+			} else {
+				c.write(c.tr("announce.scheduled_once", "id", t.id, "message", msg, "in", in))
+			}
+		}
+	case "cancel":
+		if len(args) < 2 {
+			c.write(usage)
+			return
+		}
+		id, err := strconv.Atoi(args[1])
+		if err != nil || !strings.HasPrefix(m.taskName(id), "announce: ") || !m.scheduler.cancel(id) {
+			c.write(c.tr("announce.no_such_announcement"))
+			return
+		}
+		c.write(c.tr("announce.announcement_cancelled", "id", id))
+	default:
+		m.announceNow(strings.Join(args, " "))
+	}
+}
+
+// taskName returns the name of the scheduled task with the given id.
+func (m *mud) taskName(id int) string {
+	if t, ok := m.scheduler.tasks[id]; ok {
+		return t.name
+	}
+	return ""
+}
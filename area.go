@@ -0,0 +1,189 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+)
+
+// area is a self-contained part of the world kept in its own data file,
+// such as one converted from another MUD. Its rooms are off the map and
+// reached through links from the rest of the world.
+type area struct {
+	Name    string                `json:"name"`
+	Credits string                `json:"credits,omitempty"`
+	Rooms   []*areaRoom           `json:"rooms"`
+	NPCs    []*npcTemplate        `json:"npcs,omitempty"`
+	Items   []*itemTemplate       `json:"items,omitempty"`
+	Loot    map[string]*lootTable `json:"loot,omitempty"`
+	Shops   []*shopConfig         `json:"shops,omitempty"`
+	Links   []areaLink            `json:"links,omitempty"`
+	Starts  []startRule           `json:"starts,omitempty"`
+}
+
+// areaRoom is a room in an area. Exits map directions to room IDs, which
+// may belong to other areas. A room without a UID is given one derived
+// from the world's name and its ID. A room with a position is placed on the map;
+// cliffs are the exits that need climbing.
+type areaRoom struct {
+	ID          string            `json:"id"`
+	UID         string            `json:"uid,omitempty"`
+	Name        string            `json:"name"`
+	Description string            `json:"description"`
+	Position    *[2]int           `json:"position,omitempty"`
+	Exits       map[string]string `json:"exits,omitempty"`
+	Doors       []areaDoor        `json:"doors,omitempty"`
+	Cliffs      []string          `json:"cliffs,omitempty"`
+	Flags       []string          `json:"flags,omitempty"`
+	Items       []string          `json:"items,omitempty"`
+	Capacity    int               `json:"capacity,omitempty"`
+}
+
+// areaDoor is a door on one of a room's exits. A door need only be given
+// on one side.
+type areaDoor struct {
+	Dir    string `json:"dir"`
+	Name   string `json:"name"`
+	Key    string `json:"key,omitempty"`
+	Closed bool   `json:"closed,omitempty"`
+	Locked bool   `json:"locked,omitempty"`
+	Hidden bool   `json:"hidden,omitempty"`
+}
+
+// areaLink joins a room elsewhere in the world to a room in the area. The
+// return exit is the opposite direction, or "out" for a named exit.
+type areaLink struct {
+	From roomRef `json:"from"`
+	Dir  string  `json:"dir"`
+	To   string  `json:"to"`
+}
+
+// loadAreas adds the areas in the given directory to the world. Every
+// area's rooms are placed before any exits are made, so exits can lead
+// from one area into another.
+func (m *mud) loadAreas(dir string) error {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return err
+	}
+	var areas []*area
+	for _, path := range paths {
+		a := &area{}
+		if err := loadJSON(path, a); err != nil {
+			return fmt.Errorf("%s: %v", path, err)
+		}
+		if err := m.addAreaDefinitions(a); err != nil {
+			return fmt.Errorf("%s: %v", path, err)
+		}
+		areas = append(areas, a)
+	}
+	if err := m.buildAreas(areas); err != nil {
+		return err
+	}
+	m.areas = append(m.areas, areas...)
+	m.addZones(areas, paths)
+	return nil
+}
+
+// buildAreas makes the exits, doors, items, and links of areas whose rooms
+// have been placed.
+func (m *mud) buildAreas(areas []*area) error {
+	for _, a := range areas {
+		for _, ar := range a.Rooms {
+			r := m.rooms[ar.ID]
+			for dir, to := range ar.Exits {
+				if _, ok := m.rooms[to]; ok {
+					r.exits[dir] = to
+				} else {
+					m.danglingExits = append(m.danglingExits, graphEdge{from: ar.ID, to: to, dir: dir})
+				}
+			}
+			for _, dir := range ar.Cliffs {
+				r.cliffs[dir] = true
+			}
+		}
+	}
+	for _, a := range areas {
+		for _, ar := range a.Rooms {
+			r := m.rooms[ar.ID]
+			for _, d := range ar.Doors {
+				// area files describe a door from both sides
+				if _, ok := r.doors[d.Dir]; ok {
+					continue
+				}
+				m.addDoor(r, d.Dir, d.Name, d.Key)
+				if door, ok := r.doors[d.Dir]; ok {
+					door.closed, door.locked, door.hidden = d.Closed, d.Locked, d.Hidden
+				}
+			}
+			for _, id := range ar.Items {
+				it := m.spawnItem(id)
+				if it == nil {
+					continue
+				}
+				for _, cid := range m.itemTemplates[id].Contents {
+					if ct := m.spawnItem(cid); ct != nil {
+						it.contents = append(it.contents, ct)
+					}
+				}
+				r.items = append(r.items, it)
+			}
+		}
+		for _, l := range a.Links {
+			from, to := m.roomFor(l.From), m.rooms[l.To]
+			if from == nil || to == nil {
+				return fmt.Errorf("area %s: bad link from %v to %s", a.Name, l.From, l.To)
+			}
+			if _, ok := reverseDirections[l.Dir]; ok {
+				m.linkRooms(from, l.Dir, to)
+			} else {
+				m.linkNamed(from, l.Dir, to)
+			}
+		}
+	}
+	return nil
+}
+
+// addAreaDefinitions adds an area's templates, loot tables, shops, and
+// rooms, refusing any whose IDs are already taken.
+func (m *mud) addAreaDefinitions(a *area) error {
+	for _, t := range a.Items {
+		if _, ok := m.itemTemplates[t.ID]; ok {
+			return fmt.Errorf("item %s is already defined", t.ID)
+		}
+		m.itemTemplates[t.ID] = t
+	}
+	for _, t := range a.NPCs {
+		if _, ok := m.npcTemplates[t.ID]; ok {
+			return fmt.Errorf("NPC %s is already defined", t.ID)
+		}
+		m.npcTemplates[t.ID] = t
+	}
+	for id, t := range a.Loot {
+		if _, ok := m.lootTables[id]; ok {
+			return fmt.Errorf("loot table %s is already defined", id)
+		}
+		m.lootTables[id] = t
+	}
+	m.shopConfigs = append(m.shopConfigs, a.Shops...)
+	for _, ar := range a.Rooms {
+		if _, ok := m.rooms[ar.ID]; ok {
+			return fmt.Errorf("room %s is already defined", ar.ID)
+		}
+		m.addAreaRoom(ar)
+	}
+	return nil
+}
+
+// addAreaRoom creates an area's room, on the map if it has a position.
+func (m *mud) addAreaRoom(ar *areaRoom) {
+	r := newRoom(ar.Name, ar.Description)
+	r.uid, r.capacity = ar.UID, ar.Capacity
+	for _, flag := range ar.Flags {
+		r.flags[flag] = true
+	}
+	if ar.Position != nil {
+		m.addRoom(ar.ID, ar.Position[0], ar.Position[1], r)
+	} else {
+		m.placeRoom(ar.ID, r)
+	}
+}
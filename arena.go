@@ -0,0 +1,40 @@
+package main
+
+import "math"
+
+// flagArena marks a room where players may fight anyone, for a rating
+const flagArena = "arena"
+
+// the rating a character starts the arena with, and the most one fight can
+// move it
+const (
+	arenaStartRating = 1000
+	arenaMaxSwing    = 32
+)
+
+// inArena reports whether the two players are together in an arena room.
+func (m *mud) inArena(c, other *connection) bool {
+	r := m.rooms[c.player.room]
+	return r != nil && r.flags[flagArena] && other.player.room == r.id
+}
+
+// rateArenaFight moves rating from the loser to the winner, more the less
+// the win was expected, and returns how much moved.
+func rateArenaFight(winner, loser *player) int {
+	expected := 1 / (1 + math.Pow(10, float64(loser.arenaRating-winner.arenaRating)/400))
+	points := int(math.Round(arenaMaxSwing * (1 - expected)))
+	if points < 1 {
+		points = 1
+	}
+	winner.arenaRating += points
+	loser.arenaRating -= points
+	return points
+}
+
+// arenaRatingOf returns a saved character's arena rating.
+func arenaRatingOf(r *characterRecord) int {
+	if r.ArenaRating == 0 {
+		return arenaStartRating
+	}
+	return r.ArenaRating
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"math/rand"
+)
+
+// offhandSlot is the equipment slot for a second weapon, held in the
+// player's other hand.
+const offhandSlot = "offhand"
+
+// maxAttacks is the most blows a player can strike with their main hand
+// in one combat round.
+const maxAttacks = 4
+
+// attackLevels is how many levels a player gains between each extra blow
+// per round.
+const attackLevels = 10
+
+// offhandPenalty is how much harder it is to land a blow with the off
+// hand, before practice.
+const offhandPenalty = 30
+
+// status effects that quicken or slow a player's blows
+const (
+	effectHaste = "haste"
+	effectSlow  = "slow"
+)
+
+// baseAttacks returns how many blows the player strikes with their main
+// hand each combat round: one more for every so many levels, and one more
+// or less while hasted or slowed.
+func (p *player) baseAttacks() int {
+	n := 1 + p.level/attackLevels
+	if p.affected(effectHaste) {
+		n++
+	}
+	if p.affected(effectSlow) {
+		n--
+	}
+	if n < 1 {
+		n = 1
+	}
+	if n > maxAttacks {
+		n = maxAttacks
+	}
+	return n
+}
+
+// attacks returns how many blows the player strikes with their main hand
+// this combat round. The flurry skill sometimes adds one more.
+func (m *mud) attacks(c *connection) int {
+	p := c.player
+	n := p.baseAttacks()
+	if n < maxAttacks && rand.Intn(100) < 10+p.skills["flurry"]/2 {
+		n++
+		m.improveSkill(c, "flurry")
+	}
+	return n
+}
+
+// offhandDamage returns the largest amount of damage the player deals
+// with one blow of their off hand.
+func (p *player) offhandDamage() int {
+	dmg := playerDamage / 2
+	if off := p.equipment[offhandSlot]; off != nil {
+		dmg += off.stats["damage"]
+	}
+	return dmg
+}
+
+// canOffhand reports whether an item can be held in the off hand: any
+// weapon but a launcher, which needs both hands.
+func (m *mud) canOffhand(it *item) bool {
+	t := m.itemTemplates[it.id]
+	return it.slot == "weapon" && (t == nil || t.Ranged == "")
+}
+
+// offhandStrike has the player swing the weapon in their off hand, which
+// lands less often until they have practiced with it. It reports whether
+// the NPC died.
+func (m *mud) offhandStrike(c *connection, r *room, n *npc) bool {
+	p := c.player
+	weapon := p.equipment[offhandSlot]
+	if weapon == nil {
+		return false
+	}
+	attack := p.fightingStance()
+	attack.hit -= offhandPenalty - p.skills["offhand"]/4
+	dmg := swing(p.offhandDamage(), attack, stance{})
+	me, foe := playerActor(c), npcActor(n, r)
+	if dmg == 0 {
+		m.act(toActor, "combat.miss_with", me, foe, weapon.displayName(c))
+		m.act(toRoom, "combat.misses_with", me, foe, weapon.name)
+		return false
+	}
+	n.health -= dmg
+	n.addThreat(c, dmg)
+	m.act(toActor, "combat.hit_with", me, foe, weapon.displayName(c), "damage", dmg)
+	m.act(toRoom, "combat.hits_with", me, foe, weapon.name)
+	m.improveSkill(c, "offhand")
+	if n.health <= 0 {
+		m.npcDeath(c, r, n)
+		return true
+	}
+	return false
+}
@@ -0,0 +1,231 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// the primary attributes, in the order they are shown
+var attributeNames = []string{"str", "dex", "int", "wis", "con"}
+
+// attributeTitles are the attributes' full names.
+var attributeTitles = map[string]string{
+	"str": "Strength",
+	"dex": "Dexterity",
+	"int": "Intelligence",
+	"wis": "Wisdom",
+	"con": "Constitution",
+}
+
+// the score an attribute starts at, the lowest and highest it can be
+// bought to, and the points a new character has to spend raising them.
+// Lowering an attribute below the start gives its points back.
+const (
+	baseAttribute  = 10
+	minAttribute   = 8
+	maxAttribute   = 18
+	pointBuyPoints = 10
+)
+
+// baseCarry is how many items a player can carry before their strength is
+// counted.
+const baseCarry = 10
+
+// newAttributes returns the attributes a character starts with.
+func newAttributes() map[string]int {
+	attrs := make(map[string]int)
+	for _, name := range attributeNames {
+		attrs[name] = baseAttribute
+	}
+	return attrs
+}
+
+// attribute returns the player's score in the named attribute, including
+// equipment bonuses.
+func (p *player) attribute(name string) int {
+	return p.attributes[name] + p.stat(name)
+}
+
+// attributeBonus returns what an attribute adds to the stats it feeds,
+// one for every two points above the start, and taking one away for every
+// two below it.
+func (p *player) attributeBonus(name string) int {
+	diff := p.attribute(name) - baseAttribute
+	if diff < 0 {
+		return -((1 - diff) / 2)
+	}
+	return diff / 2
+}
+
+// carryLimit returns how many items the player can carry, more the
+// stronger they are.
+func (p *player) carryLimit() int {
+	return baseCarry + p.attribute("str")
+}
+
+// canCarry reports whether the player has room for the item, telling them
+// if not. Gold takes up no room.
+func (p *player) canCarry(c *connection, it *item) bool {
+	if it.id == goldItemID || len(p.inventory) < p.carryLimit() {
+		return true
+	}
+	c.write(c.tr("carry.cant_carry_any"))
+	return false
+}
+
+// spellPower returns the percentage the player's spells work at, more the
+// more intelligent they are and for talents and gear that focus them.
+func (p *player) spellPower() int {
+	return 100 + 10*p.attributeBonus("int") + p.stat("spellpower")
+}
+
+// pointsLeft returns how many of a new character's points they have yet
+// to spend.
+func (p *player) pointsLeft() int {
+	left := pointBuyPoints
+	for _, name := range attributeNames {
+		left -= p.attributes[name] - baseAttribute
+	}
+	return left
+}
+
+// attributeLine shows the player's attributes on one line.
+func (p *player) attributeLine() string {
+	var parts []string
+	for _, name := range attributeNames {
+		parts = append(parts, fmt.Sprintf("%s %d", capitalize(name), p.attribute(name)))
+	}
+	return strings.Join(parts, "  ")
+}
+
+// startCreation has a new character set their attributes and pronouns
+// before they enter the world.
+func (m *mud) startCreation(c *connection) {
+	c.state = stateCreating
+	c.write(c.tr("creation.intro", "base", baseAttribute, "points", pointBuyPoints, "max", maxAttribute, "min", minAttribute))
+	m.showCreation(c)
+}
+
+// showCreation shows a new character their attributes and how to change
+// them.
+func (m *mud) showCreation(c *connection) {
+	p := c.player
+	for _, name := range attributeNames {
+		c.write(fmt.Sprintf("  %-12s %s %2d\n", attributeTitles[name], capitalize(name), p.attributes[name]))
+	}
+	if c.rolled {
+		c.write(c.tr("creation.attributes_were_rolled"))
+	} else {
+		c.write(c.tr("creation.points_left", "points", p.pointsLeft()))
+	}
+	c.write(c.tr("creation.pronouns", "pronouns", p.pronouns, "genders", strings.Join(sortedKeys(genders), "|"), "choices", pronounChoices()))
+	if len(m.classes) > 0 {
+		if class := m.className(p); class != "" {
+			c.write(c.tr("creation.class", "class", class))
+		} else {
+			c.write(c.tr("creation.class_none", "classes", m.classIDs()))
+		}
+	}
+	c.write(c.tr("creation.help"))
+}
+
+// handleCreation runs a new character's attribute, pronoun, and class
+// commands.
+func (m *mud) handleCreation(c *connection, cmd string, args []string) {
+	p := c.player
+	switch cmd = strings.ToLower(cmd); cmd {
+	case "raise", "lower":
+		if c.rolled {
+			c.write(c.tr("creation.rolled_reset"))
+			break
+		}
+		if len(args) == 0 {
+			c.write(c.tr("creation.usage_n", "command", cmd, "attributes", strings.Join(attributeNames, "|")))
+			break
+		}
+		name := strings.ToLower(args[0])
+		if len(name) > 3 {
+			name = name[:3]
+		}
+		if _, ok := attributeTitles[name]; !ok {
+			c.write(c.tr("creation.no_such_attribute", "attributes", strings.Join(attributeNames, ", ")))
+			break
+		}
+		n := 1
+		if len(args) > 1 {
+			var err error
+			if n, err = strconv.Atoi(args[1]); err != nil || n < 1 {
+				c.write(c.tr("creation.usage_attribute_n", "command", cmd))
+				break
+			}
+		}
+		if cmd == "lower" {
+			n = -n
+		}
+		switch score := p.attributes[name] + n; {
+		case score > maxAttribute:
+			c.write(c.tr("creation.cant_go_above", "attribute", attributeTitles[name], "max", maxAttribute))
+		case score < minAttribute:
+			c.write(c.tr("creation.cant_go_below", "attribute", attributeTitles[name], "min", minAttribute))
+		case n > p.pointsLeft():
+			c.write(c.tr("creation.dont_have_enough"))
+		default:
+			p.attributes[name] = score
+		}
+	case "reroll":
+		p.rollAttributes()
+		c.rolled = true
+	case "reset":
+		p.attributes, c.rolled = newAttributes(), false
+	case "class":
+		if len(args) == 0 {
+			for _, cl := range m.classes {
+				c.write(fmt.Sprintf("  %-8s %s\n", cl.ID, cl.Description))
+			}
+			break
+		}
+		if cl := m.findClass(args[0]); cl != nil {
+			p.class = cl.ID
+		} else {
+			c.write(c.tr("common.no_such_class", "classes", m.classIDs()))
+		}
+	case "pronouns", "gender":
+		if len(args) == 0 {
+			break
+		}
+		if pr, ok := parsePronouns(strings.Join(args, " ")); ok {
+			p.pronouns = pr
+		} else {
+			c.write(c.tr("creation.those_arent_pronouns", "choices", pronounChoices()))
+		}
+	case "done":
+		if !c.rolled && p.pointsLeft() > 0 {
+			c.write(c.tr("creation.still_have_points", "count", p.pointsLeft()))
+			break
+		}
+		if len(m.classes) > 0 && p.class == "" {
+			c.write(c.tr("creation.still_have_choose"))
+			break
+		}
+		// the first level's practice sessions, for the class's first skills
+		p.gainPractices()
+		m.savePlayer(c)
+		c.write("\n")
+		m.admit(c, true)
+		return
+	}
+	m.showCreation(c)
+}
+
+// rollAttributes rolls each of the player's attributes as the best three
+// of four six-sided dice.
+func (p *player) rollAttributes() {
+	for _, name := range attributeNames {
+		dice := []int{1 + rand.Intn(6), 1 + rand.Intn(6), 1 + rand.Intn(6), 1 + rand.Intn(6)}
+		sort.Ints(dice)
+		p.attributes[name] = dice[1] + dice[2] + dice[3]
+	}
+}
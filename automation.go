@@ -0,0 +1,277 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// limits on player automation
+const (
+	maxAliases       = 20
+	maxTriggers      = 10
+	maxPatternLength = 100
+	// maxAliasDepth is how deeply aliases may expand into other aliases.
+	maxAliasDepth = 3
+	// maxAliasCommands is how many commands one alias may expand into.
+	maxAliasCommands = 10
+	// triggers may fire triggerLimit times per triggerWindow before they
+	// are switched off as a runaway loop
+	triggerLimit  = 5
+	triggerWindow = 10 * time.Second
+)
+
+// playerTrigger runs a command when output matching its pattern is sent to
+// the player.
+type playerTrigger struct {
+	Pattern string `json:"pattern"`
+	Command string `json:"command"`
+
+	re *regexp.Regexp
+}
+
+// automation holds a player's aliases and triggers and the state that
+// keeps them in check.
+type automation struct {
+	aliases  map[string]string
+	triggers []*playerTrigger
+
+	muted   bool
+	paused  bool
+	queue   []string
+	firings []time.Time
+	depth   int
+}
+
+// compileTrigger builds a trigger, matching its pattern without regard to
+// case.
+func compileTrigger(pattern, command string) (*playerTrigger, error) {
+	re, err := regexp.Compile("(?i)" + pattern)
+	if err != nil {
+		return nil, err
+	}
+	return &playerTrigger{Pattern: pattern, Command: command, re: re}, nil
+}
+
+// expandAlias queues the player's alias for the command, if they have one,
+// reporting whether it did. Each part of the alias separated by a semicolon
+// runs in turn ahead of anything else queued, with $* replaced by the
+// arguments.
+func (m *mud) expandAlias(c *connection, cmd string, args []string) bool {
+	a := &c.player.automation
+	body, ok := a.aliases[strings.ToLower(cmd)]
+	if !ok {
+		return false
+	}
+	if a.depth >= maxAliasDepth {
+		c.write(c.tr("alias.alias_nests_too", "command", cmd))
+		c.writePrompt()
+		return true
+	}
+	var lines []string
+	for _, part := range strings.Split(body, ";") {
+		part = strings.TrimSpace(strings.ReplaceAll(part, "$*", strings.Join(args, " ")))
+		if part != "" {
+			lines = append(lines, part)
+		}
+	}
+	if len(lines) > maxAliasCommands {
+		c.write(c.tr("alias.alias_runs_too", "command", cmd))
+		c.writePrompt()
+		return true
+	}
+	m.enqueueFront(c, lines, a.depth+1)
+	return true
+}
+
+// watchOutput queues the commands of the player's triggers that match text
+// sent to them.
+func (a *automation) watchOutput(msg string) {
+	if a.muted || a.paused {
+		return
+	}
+	for _, t := range a.triggers {
+		if t.re != nil && t.re.MatchString(msg) {
+			a.queue = append(a.queue, t.Command)
+		}
+	}
+}
+
+// runTriggers queues the commands of every player's triggers that have
+// fired. Triggers that fire too often, such as one set off by its own
+// output, are switched off.
+func (m *mud) runTriggers() {
+	for _, conn := range m.conns {
+		if conn.state != statePlaying {
+			continue
+		}
+		a := &conn.player.automation
+		for len(a.queue) > 0 && conn.state == statePlaying && !a.paused {
+			cmd := a.queue[0]
+			a.queue = a.queue[1:]
+			now := time.Now()
+			recent := a.firings[:0]
+			for _, t := range a.firings {
+				if now.Sub(t) < triggerWindow {
+					recent = append(recent, t)
+				}
+			}
+			a.firings = recent
+			if len(a.firings) >= triggerLimit {
+				a.paused = true
+				a.queue = nil
+				conn.aside(conn.tr("trigger.too_fast"))
+				break
+			}
+			a.firings = append(a.firings, now)
+			a.muted = true
+			conn.write(conn.tr("trigger.trigger", "command", cmd))
+			a.muted = false
+			m.enqueue(conn, cmd, 0)
+		}
+	}
+}
+
+// aliasCommand lists, shows, or sets the player's aliases.
+func (m *mud) aliasCommand(c *connection, args []string) {
+	a := &c.player.automation
+	a.muted = true
+	defer func() { a.muted = false }()
+	switch len(args) {
+	case 0:
+		if len(a.aliases) == 0 {
+			c.write(c.tr("alias.have_no_aliases"))
+			return
+		}
+		names := make([]string, 0, len(a.aliases))
+		for name := range a.aliases {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		c.write(c.tr("alias.aliases"))
+		for _, name := range names {
+			c.write(c.tableRow("  %-10s %s\n", []string{"", "runs"}, name, a.aliases[name]))
+		}
+	case 1:
+		if body, ok := a.aliases[strings.ToLower(args[0])]; ok {
+			c.write(fmt.Sprintf("%s: %s\n", args[0], body))
+		} else {
+			c.write(c.tr("alias.have_no_such"))
+		}
+	default:
+		name := strings.ToLower(args[0])
+		if name == "alias" || name == "unalias" {
+			c.write(c.tr("alias.cant_redefine"))
+			return
+		}
+		if _, ok := a.aliases[name]; !ok && len(a.aliases) >= maxAliases {
+			c.write(c.tr("alias.can_have_most", "max", maxAliases))
+			return
+		}
+		a.aliases[name] = strings.Join(args[1:], " ")
+		c.write(c.tr("alias.alias_set", "name", name))
+	}
+}
+
+// unalias removes one of the player's aliases.
+func (m *mud) unalias(c *connection, args []string) {
+	a := &c.player.automation
+	if len(args) == 0 {
+		c.write(c.tr("alias.remove_which_alias"))
+		return
+	}
+	name := strings.ToLower(args[0])
+	if _, ok := a.aliases[name]; !ok {
+		c.write(c.tr("alias.have_no_such"))
+		return
+	}
+	delete(a.aliases, name)
+	c.write(c.tr("alias.alias_removed", "name", name))
+}
+
+// triggerCommand lists, adds, removes, and switches on or off the player's
+// triggers.
+func (m *mud) triggerCommand(c *connection, args []string) {
+	a := &c.player.automation
+	a.muted = true
+	defer func() { a.muted = false }()
+	sub := "list"
+	if len(args) > 0 {
+		sub = strings.ToLower(args[0])
+	}
+	switch sub {
+	case "list":
+		if len(a.triggers) == 0 {
+			c.write(c.tr("trigger.have_no_triggers"))
+			return
+		}
+		status := "on"
+		if a.paused {
+			status = "off"
+		}
+		c.write(c.tr("trigger.list", "status", status))
+		for i, t := range a.triggers {
+			c.write(fmt.Sprintf("  %d. \"%s\" -> %s\n", i+1, t.Pattern, t.Command))
+		}
+	case "add":
+		pattern, command := splitPattern(strings.Join(args[1:], " "))
+		if pattern == "" || command == "" {
+			c.write(c.tr("trigger.usage_add"))
+			return
+		}
+		if len(pattern) > maxPatternLength {
+			c.write(c.tr("trigger.patterns_can_most", "max", maxPatternLength))
+			return
+		}
+		if len(a.triggers) >= maxTriggers {
+			c.write(c.tr("trigger.can_have_most", "max", maxTriggers))
+			return
+		}
+		t, err := compileTrigger(pattern, command)
+		if err != nil {
+			c.write(c.tr("trigger.pattern_invalid", "error", err))
+			return
+		}
+		a.triggers = append(a.triggers, t)
+		c.write(c.tr("trigger.trigger_added", "number", len(a.triggers)))
+	case "remove":
+		i := 0
+		if len(args) > 1 {
+			i, _ = strconv.Atoi(args[1])
+		}
+		if i < 1 || i > len(a.triggers) {
+			c.write(c.tr("trigger.have_no_such"))
+			return
+		}
+		a.triggers = append(a.triggers[:i-1], a.triggers[i:]...)
+		c.write(c.tr("trigger.trigger_removed", "number", i))
+	case "on":
+		a.paused = false
+		a.firings = nil
+		c.write(c.tr("trigger.on"))
+	case "off":
+		a.paused = true
+		a.queue = nil
+		c.write(c.tr("trigger.off"))
+	default:
+		c.write(c.tr("trigger.usage"))
+	}
+}
+
+// splitPattern splits trigger text into its pattern, which is quoted if it
+// has spaces, and the command after it.
+func splitPattern(text string) (string, string) {
+	text = strings.TrimSpace(text)
+	if strings.HasPrefix(text, "\"") {
+		end := strings.Index(text[1:], "\"")
+		if end < 0 {
+			return "", ""
+		}
+		return text[1 : end+1], strings.TrimSpace(text[end+2:])
+	}
+	pattern, command, _ := strings.Cut(text, " ")
+	return pattern, strings.TrimSpace(command)
+}
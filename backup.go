@@ -0,0 +1,168 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// backupsDir holds one timestamped directory per backup.
+const backupsDir = "backups"
+
+// backupStamp names each backup after the time it was taken.
+const backupStamp = "20060102-150405"
+
+// backup saves everything, then copies the character store and world state
+// into a new timestamped backup, pruning the oldest beyond the retention
+// limit. It returns the backup's name.
+func (m *mud) backup() (string, error) {
+	for _, conn := range m.conns {
+		if conn.state == statePlaying {
+			m.savePlayer(conn)
+		}
+	}
+	m.saveHouses()
+	m.saveBounties()
+	m.saveLedger()
+
+	name := time.Now().Format(backupStamp)
+	dest := filepath.Join(backupsDir, name)
+	for _, dir := range []string{m.store.dir, m.stateDir} {
+		if err := copyDir(dir, filepath.Join(dest, filepath.Base(dir))); err != nil {
+			return "", err
+		}
+	}
+	return name, m.pruneBackups()
+}
+
+// scheduledBackup takes a backup, logging any failure.
+func (m *mud) scheduledBackup() {
+	if _, err := m.backup(); err != nil {
+		log.Printf("error backing up: %v", err)
+	}
+}
+
+// copyDir copies the JSON files in a directory into another, creating it.
+// A missing source directory is copied as empty.
+func copyDir(src, dest string) error {
+	if err := os.MkdirAll(dest, 0755); err != nil {
+		return err
+	}
+	paths, err := filepath.Glob(filepath.Join(src, "*.json"))
+	if err != nil {
+		return err
+	}
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(filepath.Join(dest, filepath.Base(path)), data, 0600); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// backups returns the names of the existing backups, oldest first.
+func backups() ([]string, error) {
+	entries, err := os.ReadDir(backupsDir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, e := range entries {
+		if _, err := time.Parse(backupStamp, e.Name()); e.IsDir() && err == nil {
+			names = append(names, e.Name())
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// pruneBackups removes the oldest backups beyond the number to keep.
+func (m *mud) pruneBackups() error {
+	names, err := backups()
+	if err != nil {
+		return err
+	}
+	for len(names) > m.config.BackupKeep {
+		if err := os.RemoveAll(filepath.Join(backupsDir, names[0])); err != nil {
+			return err
+		}
+		names = names[1:]
+	}
+	return nil
+}
+
+// restoreCharacter copies a character's record out of a backup and back
+// into the store, replacing the current one.
+func (s *store) restoreCharacter(backup, name string) error {
+	if _, err := time.Parse(backupStamp, backup); err != nil {
+		return fmt.Errorf("no backup named %q", backup)
+	}
+	if nameProblem(name) != "" {
+		return fmt.Errorf("bad character name %q", name)
+	}
+	backupStore := newStore(filepath.Join(backupsDir, backup, filepath.Base(s.dir)))
+	rec, err := backupStore.load(name)
+	if err != nil {
+		return fmt.Errorf("%s is not in backup %s", name, backup)
+	}
+	return s.save(rec)
+}
+
+// backupCommand lets staff take a backup now, list the backups, and
+// restore an offline character from one.
+func (m *mud) backupCommand(c *connection, args []string) {
+	if !c.player.admin {
+		c.write(c.tr("command.unknown"))
+		return
+	}
+	sub := ""
+	if len(args) > 0 {
+		sub = strings.ToLower(args[0])
+	}
+	switch {
+	case sub == "now":
+		name, err := m.backup()
+		if err != nil {
+			c.write(c.tr("backup.backup_failed", "error", err))
+			return
+		}
+		c.write(c.tr("backup.backup_taken", "name", name))
+	case sub == "list":
+		names, err := backups()
+		if err != nil {
+			c.write(c.tr("backup.backups_could_not", "error", err))
+			return
+		}
+		if len(names) == 0 {
+			c.write(c.tr("backup.no_backups"))
+			return
+		}
+		c.write(c.tr("backup.backups_keeping_last", "count", m.config.BackupKeep))
+		for _, name := range names {
+			c.write(fmt.Sprintf("  %s\n", name))
+		}
+	case sub == "restore" && len(args) == 3:
+		if m.host.playingIn(args[2]) != nil {
+			c.write(c.tr("rename.online", "name", capitalize(args[2])))
+			return
+		}
+		if err := m.store.restoreCharacter(args[1], args[2]); err != nil {
+			c.write(c.tr("backup.restore_failed", "error", err))
+			return
+		}
+		c.write(c.tr("backup.has_been_restored", "name", capitalize(args[2]), "backup", args[1]))
+	default:
+		c.write(c.tr("backup.usage"))
+	}
+}
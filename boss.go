@@ -0,0 +1,149 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"strconv"
+	"strings"
+)
+
+// bossScript is how a boss fights, beyond trading blows: abilities it uses
+// every so many combat rounds, and phases it enters as its health drops.
+// Both run script steps, as runScript describes them.
+type bossScript struct {
+	Abilities []*bossAbility `json:"abilities"`
+	Phases    []*bossPhase   `json:"phases"`
+}
+
+// bossAbility is a script a boss runs every so many rounds of a fight.
+type bossAbility struct {
+	Every  int      `json:"every"`
+	Script []string `json:"script"`
+}
+
+// bossPhase is a script a boss runs once, when its health first drops to
+// the given percentage. Phases are listed from the highest health down.
+type bossPhase struct {
+	Health int      `json:"health"`
+	Script []string `json:"script"`
+}
+
+// bossFor returns the boss script of the NPC, or nil if it isn't a boss.
+func (m *mud) bossFor(n *npc) *bossScript {
+	if t, ok := m.npcTemplates[n.id]; ok {
+		return t.Boss
+	}
+	return nil
+}
+
+// checkBoss describes the problems with a boss's script: unknown steps,
+// summons of NPCs that don't exist, abilities that are never used, and
+// phases out of order.
+func (m *mud) checkBoss(id string, b *bossScript) []string {
+	var problems []string
+	check := func(what string, script []string) {
+		if err := checkScript(script); err != nil {
+			problems = append(problems, fmt.Sprintf("NPC %s %s: %v", id, what, err))
+		}
+		for _, line := range script {
+			if f := strings.Fields(line); len(f) > 1 && f[0] == "summon" && m.npcTemplates[f[1]] == nil {
+				problems = append(problems, fmt.Sprintf("NPC %s %s summons missing NPC %s", id, what, f[1]))
+			}
+		}
+	}
+	for i, a := range b.Abilities {
+		if a.Every < 1 {
+			problems = append(problems, fmt.Sprintf("NPC %s ability %d is never used", id, i+1))
+		}
+		check(fmt.Sprintf("ability %d", i+1), a.Script)
+	}
+	for i, p := range b.Phases {
+		if i > 0 && p.Health >= b.Phases[i-1].Health {
+			problems = append(problems, fmt.Sprintf("NPC %s phase %d isn't at lower health than the one before", id, i+1))
+		}
+		check(fmt.Sprintf("phase %d", i+1), p.Script)
+	}
+	return problems
+}
+
+// bossPhases enters each phase of a boss fight whose health threshold the
+// boss has dropped to, with c the player it is fighting.
+func (m *mud) bossPhases(r *room, n *npc, c *connection) {
+	b := m.bossFor(n)
+	if b == nil {
+		return
+	}
+	for n.phase < len(b.Phases) && n.health*100 <= b.Phases[n.phase].Health*n.maxHealth {
+		phase := b.Phases[n.phase]
+		n.phase++
+		m.runScript(c, r, n, phase.Script)
+	}
+}
+
+// bossAbilities counts off a round of a boss fight and uses each of the
+// boss's abilities that is due, with c the player it is fighting.
+func (m *mud) bossAbilities(r *room, n *npc, c *connection) {
+	b := m.bossFor(n)
+	if b == nil {
+		return
+	}
+	n.rounds++
+	for _, a := range b.Abilities {
+		if a.Every > 0 && n.rounds%a.Every == 0 {
+			m.runScript(c, r, n, a.Script)
+		}
+	}
+}
+
+// resetBoss restores a boss whose fight has ended without its death, so
+// that the next group to take it on faces the whole encounter again.
+func (m *mud) resetBoss(r *room, n *npc) {
+	t, ok := m.npcTemplates[n.id]
+	if !ok || t.Boss == nil {
+		return
+	}
+	n.health, n.damage = n.maxHealth, t.Damage
+	n.phase, n.rounds = 0, 0
+	m.dismissAdds(r, n)
+}
+
+// blast hits every player in the room for up to the given damage, such as
+// with a boss's sweeping attack. source is the NPC behind it, if any.
+func (m *mud) blast(r *room, source *npc, maxDamage int) {
+	killer := "the blast"
+	if source != nil {
+		killer = source.name
+	}
+	for _, conn := range m.playersInRoom(r.id) {
+		dmg := 1 + rand.Intn(maxDamage)
+		conn.player.health -= dmg
+		m.act(toVictim, "boss.blast_hits", npcActor(source, r), playerActor(conn), strconv.Itoa(dmg))
+		if conn.player.health <= 0 {
+			m.playerDeath(conn, killer)
+		}
+	}
+}
+
+// summon brings NPCs into the room as adds of a boss, if there is one,
+// setting them on the player it is fighting.
+func (m *mud) summon(r *room, summoner *npc, c *connection, t *npcTemplate, count int) {
+	for i := 0; i < count; i++ {
+		add := newNPC(t)
+		add.summoner = summoner
+		if c.state == statePlaying && c.player.room == r.id {
+			add.addThreat(c, 1)
+		}
+		r.npcs = append(r.npcs, add)
+	}
+}
+
+// dismissAdds sends off the NPCs a boss summoned once it has fallen or its
+// fight is over.
+func (m *mud) dismissAdds(r *room, boss *npc) {
+	for _, n := range append([]*npc(nil), r.npcs...) {
+		if n.summoner == boss {
+			r.removeNPC(n)
+			m.act(toRoom, "boss.add_flees", npcActor(n, r), actor{}, "")
+		}
+	}
+}
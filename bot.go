@@ -0,0 +1,181 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"log"
+	"math/rand"
+	"net"
+	"regexp"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// botPassword is the password every load testing bot logs in with, so
+// their characters can be reused from one run to the next.
+const botPassword = "botpass1"
+
+// botDirections are the directions bots wander in.
+var botDirections = []string{"north", "east", "south", "west", "northeast", "northwest", "southeast", "southwest", "up", "down"}
+
+// botSpeech matches the lines bots say, each numbered so its round trip can
+// be timed.
+var botSpeech = regexp.MustCompile(`says: load test (\d+)`)
+
+// botStats gathers the timings of a load test. Command latency is how long
+// a bot waits to hear its own speech; broadcast latency is how long the
+// other bots within earshot take to hear it.
+type botStats struct {
+	mu        sync.Mutex
+	said      map[int]botLine
+	next      int
+	commands  []time.Duration
+	broadcast []time.Duration
+	sent      int
+	failed    int
+}
+
+// botLine is a line a bot said, and when.
+type botLine struct {
+	bot  int
+	sent time.Time
+}
+
+// runBots connects the given number of bots to the server at addr, has them
+// wander and chat for the given time, then logs the latencies they saw.
+func runBots(n int, addr string, d time.Duration) {
+	stats := &botStats{said: make(map[int]botLine)}
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if err := runBot(i, addr, d, stats); err != nil {
+				log.Printf("bot %d: %v", i, err)
+				stats.mu.Lock()
+				stats.failed++
+				stats.mu.Unlock()
+			}
+		}(i)
+		// stagger the logins rather than arriving all at once
+		time.Sleep(20 * time.Millisecond)
+	}
+	wg.Wait()
+
+	log.Printf("%d bots, %d failed, %d commands sent", n, stats.failed, stats.sent)
+	logLatencies("command", stats.commands)
+	logLatencies("broadcast", stats.broadcast)
+}
+
+// botName returns a name for the bot with the given number, made only of
+// letters as character names must be.
+func botName(i int) string {
+	name := ""
+	for {
+		name = string(rune('a'+i%26)) + name
+		i /= 26
+		if i == 0 {
+			break
+		}
+	}
+	return "bot" + name
+}
+
+// runBot logs one bot in and has it act until the time is up.
+func runBot(i int, addr string, d time.Duration, stats *botStats) error {
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	out := bufio.NewWriter(conn)
+	send := func(line string) error {
+		out.WriteString(line + "\n")
+		return out.Flush()
+	}
+
+	// read everything the server sends, timing the lines bots said
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		scanner := bufio.NewScanner(conn)
+		for scanner.Scan() {
+			match := botSpeech.FindStringSubmatch(scanner.Text())
+			if match == nil {
+				continue
+			}
+			id, _ := strconv.Atoi(match[1])
+			stats.heard(i, id)
+		}
+	}()
+
+	if err := send(botName(i)); err != nil {
+		return err
+	}
+	if err := send(botPassword); err != nil {
+		return err
+	}
+	end := time.Now().Add(d)
+	for time.Now().Before(end) {
+		time.Sleep(time.Duration(500+rand.Intn(1000)) * time.Millisecond)
+		line := botDirections[rand.Intn(len(botDirections))]
+		if rand.Intn(3) == 0 {
+			line = fmt.Sprintf("say load test %d", stats.say(i))
+		}
+		if err := send(line); err != nil {
+			return err
+		}
+		stats.mu.Lock()
+		stats.sent++
+		stats.mu.Unlock()
+	}
+	// give the server a moment to answer before hanging up
+	send("quit")
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	<-done
+	return nil
+}
+
+// say numbers a line the bot is about to say and notes when it was sent.
+func (s *botStats) say(bot int) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.next++
+	s.said[s.next] = botLine{bot: bot, sent: time.Now()}
+	return s.next
+}
+
+// heard records a bot hearing a numbered line.
+func (s *botStats) heard(bot, id int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	line, ok := s.said[id]
+	if !ok {
+		return
+	}
+	if line.bot == bot {
+		s.commands = append(s.commands, time.Since(line.sent))
+	} else {
+		s.broadcast = append(s.broadcast, time.Since(line.sent))
+	}
+}
+
+// logLatencies logs the count, mean, and percentiles of a set of timings.
+func logLatencies(name string, times []time.Duration) {
+	if len(times) == 0 {
+		log.Printf("%s latency: no samples", name)
+		return
+	}
+	sort.Slice(times, func(i, j int) bool { return times[i] < times[j] })
+	var total time.Duration
+	for _, t := range times {
+		total += t
+	}
+	pct := func(p int) time.Duration {
+		return times[(len(times)-1)*p/100]
+	}
+	log.Printf("%s latency: %d samples, mean %v, p50 %v, p95 %v, p99 %v, max %v",
+		name, len(times), total/time.Duration(len(times)), pct(50), pct(95), pct(99), times[len(times)-1])
+}
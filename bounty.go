@@ -0,0 +1,313 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"math/rand"
+	"os"
+	"strconv"
+	"time"
+)
+
+// bountiesFile is where the bounty board is saved, in the state directory.
+const bountiesFile = "bounties.json"
+
+// securityPoster is the name shown on bounties the game posts itself.
+const securityPoster = "Mall Security"
+
+// minBounty is the smallest reward a player may post.
+const minBounty = 10
+
+// bounty is a reward posted on the bounty board for killing an NPC or a
+// wanted player.
+type bounty struct {
+	Target   string    `json:"target"`
+	Name     string    `json:"name"`
+	Player   bool      `json:"player"`
+	Reward   int       `json:"reward"`
+	PostedBy string    `json:"postedBy"`
+	Posted   time.Time `json:"posted"`
+}
+
+// loadBounties reads the saved bounty board, if there is one.
+func (m *mud) loadBounties() error {
+	err := loadJSON(m.statePath(bountiesFile), &m.bounties)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+// saveBounties writes the bounty board to disk.
+func (m *mud) saveBounties() {
+	data, err := json.MarshalIndent(m.bounties, "", "  ")
+	if err == nil {
+		err = os.MkdirAll(m.stateDir, 0755)
+	}
+	if err == nil {
+		err = os.WriteFile(m.statePath(bountiesFile), data, 0644)
+	}
+	if err != nil {
+		log.Printf("error saving bounties: %v", err)
+	}
+}
+
+// postBounty adds a bounty to the board.
+func (m *mud) postBounty(b *bounty) {
+	b.Posted = time.Now()
+	m.bounties = append(m.bounties, b)
+	m.saveBounties()
+}
+
+// bountyCommand handles the bounty board: listing bounties and posting new
+// ones. It only works in a room with a board.
+func (m *mud) bountyCommand(c *connection, args []string) {
+	p := c.player
+	if r := m.rooms[p.room]; r == nil || !r.flags["bounties"] {
+		c.write(c.tr("bounty.no_bounty_board"))
+		return
+	}
+	if len(args) == 0 || args[0] == "list" {
+		m.listBounties(c)
+		return
+	}
+	if args[0] != "post" || len(args) != 3 {
+		c.write(c.tr("bounty.usage"))
+		return
+	}
+	reward, err := strconv.Atoi(args[2])
+	if err != nil || reward < minBounty {
+		c.write(c.tr("bounty.bounty_must_least", "min", minBounty))
+		return
+	}
+	if p.gold < reward {
+		c.write(c.tr("bounty.dont_have_much"))
+		return
+	}
+
+	b := &bounty{Reward: reward, PostedBy: c.name}
+	if t := m.findNPCTemplate(args[1]); t != nil {
+		b.Target, b.Name = t.ID, t.Name
+	} else {
+		wanted := false
+		if conn, ok := m.conns[nameKey(args[1])]; ok && conn.state == statePlaying {
+			wanted = conn.player.wanted()
+		} else if rec, err := m.store.load(args[1]); err == nil {
+			wanted = rec.Bounty > 0
+		}
+		if !wanted {
+			c.write(c.tr("bounty.bounties_can_only"))
+			return
+		}
+		if args[1] == c.name {
+			c.write(c.tr("bounty.cant_post_bounty"))
+			return
+		}
+		b.Target, b.Name, b.Player = args[1], capitalize(args[1]), true
+	}
+	p.gold -= reward
+	m.goldDestroyed("bounties", reward)
+	m.postBounty(b)
+	c.write(c.tr("bounty.post_bounty_gold", "reward", reward, "name", b.Name))
+}
+
+// listBounties shows every bounty on the board.
+func (m *mud) listBounties(c *connection) {
+	if len(m.bounties) == 0 {
+		c.write(c.tr("bounty.bounty_board_empty"))
+		return
+	}
+	c.write(c.tr("bounty.bounties"))
+	for i, b := range m.bounties {
+		c.write(c.tableRow("  %d. %-24s %5d gold  posted by %s\n", []string{"", "", "gold", "posted by"}, i+1, b.Name, b.Reward, b.PostedBy))
+	}
+}
+
+// findNPCTemplate returns the NPC template matching the keyword.
+func (m *mud) findNPCTemplate(keyword string) *npcTemplate {
+	for _, t := range m.npcTemplates {
+		if matchKeywords(t.Keywords, keyword) {
+			return t
+		}
+	}
+	return nil
+}
+
+// payBounties pays out every bounty on a slain NPC or player to the killer,
+// except those the killer posted themselves.
+func (m *mud) payBounties(e event) {
+	c := e.conn
+	target := ""
+	switch {
+	case e.npc != nil:
+		target = e.npc.id
+	case e.victim != nil:
+		target = e.victim.name
+	}
+	kept := m.bounties[:0]
+	paid := false
+	for _, b := range m.bounties {
+		if b.Target != target || b.Player != (e.victim != nil) || b.PostedBy == c.name {
+			kept = append(kept, b)
+			continue
+		}
+		c.player.gold += b.Reward
+		m.goldCreated("bounties", b.Reward)
+		c.write(colorize(c.tr("bounty.collect_gold_bounty", "reward", b.Reward, "name", b.Name), "yellow"))
+		if conn, ok := m.conns[nameKey(b.PostedBy)]; ok {
+			m.actTo(only(conn), "bounty.collected_yours", playerActor(c), actor{}, nil, b.Name)
+		}
+		paid = true
+	}
+	m.bounties = kept
+	if paid {
+		m.saveBounties()
+	}
+}
+
+// cancelBounties removes the bounties on a player who is no longer wanted,
+// refunding the players who posted them.
+func (m *mud) cancelBounties(name string) {
+	kept := m.bounties[:0]
+	removed := false
+	for _, b := range m.bounties {
+		if !b.Player || b.Target != name {
+			kept = append(kept, b)
+			continue
+		}
+		removed = true
+		if b.PostedBy == securityPoster {
+			continue
+		}
+		reward := b.Reward
+		err := m.withCharacter(b.PostedBy, func(p *player) {
+			p.gold += reward
+		})
+		m.goldCreated("bounties", reward)
+		if err != nil {
+			log.Printf("error refunding bounty to %s: %v", b.PostedBy, err)
+		}
+	}
+	m.bounties = kept
+	if removed {
+		m.saveBounties()
+	}
+}
+
+// postSecurityBounty has mall security post a bounty on a random creature
+// that isn't already on the board.
+func (m *mud) postSecurityBounty() {
+	var candidates []*npcTemplate
+	for _, t := range m.npcTemplates {
+		if len(t.Spawns) > 0 && !t.Guard && t.Wage == 0 && !m.isShopkeeper(t.ID) && !m.hasBounty(t.ID) {
+			candidates = append(candidates, t)
+		}
+	}
+	if len(candidates) == 0 {
+		return
+	}
+	t := candidates[rand.Intn(len(candidates))]
+	m.postBounty(&bounty{Target: t.ID, Name: t.Name, Reward: 25 * t.Level, PostedBy: securityPoster})
+	m.send(everyone(), rendered((*connection).locale, func(conn *connection) string {
+		return conn.tr("bounty.security_posted", "reward", 25*t.Level, "name", t.Name)
+	}).asAside(nil))
+}
+
+// hasBounty reports whether there is a bounty on the target.
+func (m *mud) hasBounty(target string) bool {
+	for _, b := range m.bounties {
+		if b.Target == target {
+			return true
+		}
+	}
+	return false
+}
+
+// fightPlayer attacks a player wanted by mall security, or anyone in an
+// arena. The attacker lands the first blow, and the two trade blows each
+// combat round until one side falls or flees.
+func (m *mud) fightPlayer(c, target *connection) {
+	if !target.player.wanted() && !m.inArena(c, target) {
+		c.write(c.tr("bounty.isnt_wanted_mall", "name", capitalize(target.nameFor(c))))
+		return
+	}
+	p, t := c.player, target.player
+	if p.rival == target {
+		c.write(c.tr("bounty.already_fighting", "name", target.nameFor(c)))
+		return
+	}
+	if !m.requireStanding(c) {
+		return
+	}
+	t.position, t.furniture = positionStanding, nil
+	m.stopRun(target)
+	me, them := playerActor(c), playerActor(target)
+	m.act(toActor, "combat.attack", me, them, "")
+	m.act(toVictim, "combat.attacks_you", me, them, "")
+	m.act(toNotVictim, "combat.attacks", me, them, "")
+	m.fightNoise(m.rooms[p.room])
+	p.rival = target
+	if m.rival(target) == nil {
+		t.rival = c
+	}
+	m.strikePlayer(c, target)
+}
+
+// rival returns the player the player is fighting, as long as they are
+// still playing here and in the same room.
+func (m *mud) rival(c *connection) *connection {
+	p := c.player
+	r := p.rival
+	if r == nil {
+		return nil
+	}
+	if m.conns[nameKey(r.name)] != r || r.state != statePlaying || r.player.room != p.room || r.player.health <= 0 {
+		p.rival = nil
+		return nil
+	}
+	return r
+}
+
+// inFight reports whether the player is fighting an NPC or another player.
+func (m *mud) inFight(c *connection) bool {
+	return m.opponent(c) != nil || m.rival(c) != nil
+}
+
+// strikePlayer has the player hit the player they are fighting with each
+// of their blows for the round, reporting whether the other fell.
+func (m *mud) strikePlayer(c, target *connection) bool {
+	p, t := c.player, target.player
+	me, them := playerActor(c), playerActor(target)
+	for i := m.attacks(c); i > 0; i-- {
+		dmg := swing(p.damage(), p.fightingStance(), t.fightingStance())
+		if dmg == 0 {
+			m.act(toActor, "combat.miss", me, them, "")
+			m.act(toVictim, "combat.misses_you", me, them, "")
+			m.act(toNotVictim, "combat.misses", me, them, "")
+			continue
+		}
+		t.health -= dmg
+		m.act(toActor, "combat.hit", me, them, strconv.Itoa(dmg))
+		m.act(toVictim, "combat.hits_you", me, them, strconv.Itoa(dmg))
+		m.act(toNotVictim, "combat.hits", me, them, "")
+		if t.health <= 0 {
+			m.defeatPlayer(c, target)
+			return true
+		}
+	}
+	return false
+}
+
+// defeatPlayer ends a fight between players, sending the loser back to
+// where they respawn. A fight in the arena changes both players' ratings.
+func (m *mud) defeatPlayer(winner, loser *connection) {
+	r := m.rooms[loser.player.room]
+	m.act(toActor, "combat.slain", playerActor(winner), playerActor(loser), "")
+	if m.inArena(winner, loser) {
+		points := rateArenaFight(winner.player, loser.player)
+		winner.write(winner.tr("arena.rating_up", "points", points, "rating", winner.player.arenaRating))
+		loser.write(loser.tr("arena.rating_down", "points", points, "rating", loser.player.arenaRating))
+	}
+	m.playerDeath(loser, winner.nameFor(loser))
+	m.events.publish(event{kind: eventKill, conn: winner, victim: loser, room: r})
+}
@@ -0,0 +1,132 @@
+package main
+
+// audience picks who among the players a broadcast reaches.
+type audience func(conn *connection) bool
+
+// only reaches the one player.
+func only(c *connection) audience {
+	return func(conn *connection) bool { return conn == c }
+}
+
+// everyone reaches every player in the world.
+func everyone() audience {
+	return func(*connection) bool { return true }
+}
+
+// inRoom reaches the players in the room with the given ID.
+func inRoom(id string) audience {
+	return func(conn *connection) bool { return conn.player.room == id }
+}
+
+// inZone reaches the players in the same zone as the room with the given
+// ID, or, for a room in no area, everyone else outside the areas.
+func (m *mud) inZone(id string) audience {
+	z := m.roomZones[id]
+	return func(conn *connection) bool { return m.roomZones[conn.player.room] == z }
+}
+
+// inGroup reaches the members of the group.
+func inGroup(g *party) audience {
+	return func(conn *connection) bool { return g != nil && conn.player.party == g }
+}
+
+// onChannel reaches the players listening to the channel.
+func onChannel(name string) audience {
+	return func(conn *connection) bool { return conn.player.channels[name] }
+}
+
+// staff reaches the staff.
+func staff() audience {
+	return func(conn *connection) bool { return conn.player.admin }
+}
+
+// helpers reaches the players who answer questions.
+func helpers() audience {
+	return func(conn *connection) bool { return conn.player.helper }
+}
+
+// except leaves one connection out of the audience.
+func (a audience) except(c *connection) audience {
+	return func(conn *connection) bool { return conn != c && a(conn) }
+}
+
+// and narrows the audience to those the other also picks.
+func (a audience) and(b audience) audience {
+	return func(conn *connection) bool { return a(conn) && b(conn) }
+}
+
+// broadcast is a message to many players. Listeners that view it the same
+// way share one rendering of it, so a message that reads the same to all is
+// rendered only once. A broadcast without a view is rendered for each
+// listener, for text such as speech in a language each understands
+// differently.
+type broadcast struct {
+	render func(conn *connection) string
+	view   func(conn *connection) string
+
+	// an aside arrives between the listener's own commands, so it starts
+	// on a fresh line and is followed by their prompt; by, whose command
+	// caused it, sees it as part of that command's output
+	aside bool
+	by    *connection
+}
+
+// plain is a broadcast that reads the same to everyone.
+func plain(msg string) broadcast {
+	return broadcast{
+		render: func(*connection) string { return msg },
+		view:   func(*connection) string { return "" },
+	}
+}
+
+// rendered is a broadcast whose text depends on the listener. Listeners
+// with the same view, if one is given, share its rendering.
+func rendered(view, render func(conn *connection) string) broadcast {
+	return broadcast{render: render, view: view}
+}
+
+// asAside makes the broadcast an aside to everyone but the player whose
+// command caused it, who may be nil.
+func (b broadcast) asAside(by *connection) broadcast {
+	b.aside, b.by = true, by
+	return b
+}
+
+// send renders a broadcast for everyone the audience picks and queues it on
+// their connections, which send it on without holding up the game. It
+// returns how many it reached.
+func (m *mud) send(a audience, b broadcast) int {
+	reached := 0
+	renderings := make(map[string]string)
+	for _, conn := range m.conns {
+		if conn.state != statePlaying || !a(conn) {
+			continue
+		}
+		var msg string
+		if b.view == nil {
+			msg = b.render(conn)
+		} else {
+			key := b.view(conn)
+			var ok bool
+			if msg, ok = renderings[key]; !ok {
+				msg = b.render(conn)
+				renderings[key] = msg
+			}
+		}
+		reached++
+		if b.aside && conn != b.by {
+			conn.aside(msg)
+			continue
+		}
+		conn.write(msg)
+	}
+	return reached
+}
+
+// seenBy is the view of a broadcast about the player that depends only on
+// who the listener sees them as, and the listener's language.
+func seenBy(c *connection) func(conn *connection) string {
+	return func(conn *connection) string {
+		return conn.locale() + "\x00" + c.nameFor(conn)
+	}
+}
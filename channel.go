@@ -0,0 +1,137 @@
+package main
+
+import (
+	"sort"
+	"strings"
+)
+
+// newbieChannel is the channel for new players and the helpers assisting them.
+const newbieChannel = "newbie"
+
+// channels lists the chat channels players can join.
+var channels = []string{newbieChannel}
+
+// isChannel reports whether the given name is a known channel.
+func isChannel(name string) bool {
+	for _, ch := range channels {
+		if ch == name {
+			return true
+		}
+	}
+	return false
+}
+
+// autoJoinChannels adds newbies and helpers to the newbie channel at login.
+func (m *mud) autoJoinChannels(c *connection) {
+	p := c.player
+	if p.level < m.config.NewbieLevel || p.helper {
+		if !p.channels[newbieChannel] {
+			p.channels[newbieChannel] = true
+			c.write(c.tr("channel.have_joined_newbie"))
+		}
+	}
+}
+
+// channelSay sends a message to every playing member of the channel.
+func (m *mud) channelSay(c *connection, channel string, args []string) {
+	if !c.player.channels[channel] {
+		c.write(c.tr("channel.not_channel", "channel", channel))
+		return
+	}
+	if len(args) == 0 {
+		c.write(c.tr("channel.say_what"))
+		return
+	}
+	msg := strings.Join(args, " ")
+	m.send(onChannel(channel), rendered((*connection).locale, func(conn *connection) string {
+		return conn.tr("channel.message", "channel", channel, "name", c.name, "message", msg)
+	}).asAside(c))
+}
+
+// showChannels lists the channels and whether the player is on each.
+func (m *mud) showChannels(c *connection) {
+	c.write(c.tr("channel.channels"))
+	for _, ch := range channels {
+		status := c.tr("channel.off")
+		if c.player.channels[ch] {
+			status = c.tr("channel.on")
+		}
+		c.write(c.tableRow("  %-10s %s\n", nil, ch, status))
+	}
+}
+
+// joinChannel adds the player to a channel.
+func (m *mud) joinChannel(c *connection, args []string) {
+	if len(args) == 0 || !isChannel(args[0]) {
+		c.write(c.tr("channel.join_which_channel"))
+		return
+	}
+	c.player.channels[args[0]] = true
+	c.write(c.tr("channel.join_channel", "channel", args[0]))
+}
+
+// leaveChannel removes the player from a channel.
+func (m *mud) leaveChannel(c *connection, args []string) {
+	if len(args) == 0 || !c.player.channels[args[0]] {
+		c.write(c.tr("channel.leave_which_channel"))
+		return
+	}
+	delete(c.player.channels, args[0])
+	c.write(c.tr("channel.leave_channel", "channel", args[0]))
+}
+
+// channelList returns the player's channels in sorted order.
+func (p *player) channelList() []string {
+	list := make([]string, 0, len(p.channels))
+	for ch := range p.channels {
+		list = append(list, ch)
+	}
+	sort.Strings(list)
+	return list
+}
+
+// ask routes a question to every online helper.
+func (m *mud) ask(c *connection, args []string) {
+	if len(args) == 0 {
+		c.write(c.tr("ask.ask_what"))
+		return
+	}
+	msg := strings.Join(args, " ")
+	reached := m.send(helpers().except(c), rendered((*connection).locale, func(conn *connection) string {
+		return conn.tr("ask.asks", "name", c.name, "question", msg)
+	}).asAside(c))
+	if reached == 0 {
+		c.write(c.tr("ask.no_helpers_online"))
+		return
+	}
+	c.write(c.tr("ask.question_was_sent", "count", reached))
+}
+
+// grantHelper toggles the helper flag on an online player. Only staff may
+// use it.
+func (m *mud) grantHelper(c *connection, args []string) {
+	if !c.player.admin {
+		c.write(c.tr("command.unknown"))
+		return
+	}
+	if len(args) == 0 {
+		c.write(c.tr("helper.toggle_helper_whom"))
+		return
+	}
+	conn, ok := m.conns[nameKey(args[0])]
+	if !ok || conn.state != statePlaying {
+		c.write(c.tr("helper.they_not_online"))
+		return
+	}
+	p := conn.player
+	p.helper = !p.helper
+	if p.helper {
+		p.channels[newbieChannel] = true
+		c.write(c.tr("helper.helper", "name", conn.name))
+		m.send(only(conn), plain(conn.tr("helper.made_helper")).asAside(c))
+	} else {
+		c.write(c.tr("helper.no_longer_helper", "name", conn.name))
+		m.send(only(conn), plain(conn.tr("helper.no_longer_you")).asAside(c))
+	}
+	m.savePlayer(conn)
+}
@@ -0,0 +1,235 @@
+package main
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// The character sets the server can send in. Output is UTF-8 unless the
+// client negotiates otherwise over TELNET CHARSET or the player picks
+// one; for the others, whatever can't be shown is transliterated.
+const (
+	charsetUTF8  = "UTF-8"
+	charsetCP437 = "CP437"
+	charsetASCII = "ASCII"
+)
+
+// TELNET CHARSET subnegotiation commands.
+const (
+	charsetRequest  = 1
+	charsetAccepted = 2
+	charsetRejected = 3
+)
+
+// charsetNames maps the names clients use for character sets to the ones
+// the server supports.
+var charsetNames = map[string]string{
+	"UTF-8": charsetUTF8, "UTF8": charsetUTF8,
+	"CP437": charsetCP437, "IBM437": charsetCP437, "437": charsetCP437,
+	"ASCII": charsetASCII, "US-ASCII": charsetASCII, "ANSI_X3.4-1968": charsetASCII,
+}
+
+// charsetOffer is the list of character sets the server asks the client
+// to pick from, in the order it prefers them.
+var charsetOffer = []string{"UTF-8", "IBM437", "CP437", "US-ASCII"}
+
+// cp437High is code page 437 from 0x80 to 0xFE. 0xFF, a non-breaking
+// space, is left out, as it would be taken for a telnet IAC.
+const cp437High = "ÇüéâäàåçêëèïîìÄÅÉæÆôöòûùÿÖÜ¢£¥₧ƒáíóúñÑªº¿⌐¬½¼¡«»░▒▓│┤╡╢╖╕╣║╗╝╜╛┐└┴┬├─┼╞╟╚╔╩╦╠═╬╧╨╤╥╙╘╒╓╫╪┘┌█▄▌▐▀αßΓπΣσµτΦΘΩδ∞φε∩≡±≥≤⌠⌡÷≈°∙·√ⁿ²■"
+
+// cp437Bytes maps runes to their bytes in code page 437, and cp437Runes
+// back.
+var (
+	cp437Bytes = make(map[rune]byte)
+	cp437Runes []rune
+)
+
+func init() {
+	cp437Runes = []rune(cp437High)
+	for i, r := range cp437Runes {
+		cp437Bytes[r] = byte(0x80 + i)
+	}
+}
+
+// asciiAccented and asciiPlain transliterate accented letters to ASCII,
+// letter for letter.
+const (
+	asciiAccented = "ÀÁÂÃÄÅàáâãäåÇçÈÉÊËèéêëÌÍÎÏìíîïÑñÒÓÔÕÖØòóôõöøÙÚÛÜùúûüÝýÿ"
+	asciiPlain    = "AAAAAAaaaaaaCcEEEEeeeeIIIIiiiiNnOOOOOOooooooUUUUuuuuYyy"
+)
+
+// asciiSubstitutes transliterates other runes to ASCII.
+var asciiSubstitutes = map[rune]string{
+	'‘': "'", '’': "'", '“': "\"", '”': "\"", '«': "\"", '»': "\"",
+	'–': "-", '—': "-", '…': "...", '¡': "!", '¿': "?", '•': "*", '·': "*",
+	'×': "x", '÷': "/", '°': " deg", 'ß': "ss", 'Æ': "AE", 'æ': "ae",
+	'\u00a0': " ", '╱': "/", '╲': "\\", '╳': "X",
+}
+
+func init() {
+	plain := []rune(asciiPlain)
+	for i, r := range []rune(asciiAccented) {
+		asciiSubstitutes[r] = string(plain[i])
+	}
+}
+
+// asciiRune transliterates a rune to ASCII, with "?" for one it can't.
+func asciiRune(r rune) string {
+	if s, ok := asciiSubstitutes[r]; ok {
+		return s
+	}
+	switch {
+	case r >= 0x2500 && r <= 0x257f:
+		return boxASCII(r)
+	case r >= 0x2580 && r <= 0x259f:
+		// block elements
+		return "#"
+	}
+	return "?"
+}
+
+// boxASCII draws a box drawing character in ASCII: lines as - and |, and
+// corners and junctions as +.
+func boxASCII(r rune) string {
+	switch r {
+	case '─', '━', '┄', '┅', '┈', '┉', '╌', '╍', '╴', '╶', '╸', '╺', '╼', '╾':
+		return "-"
+	case '═':
+		return "="
+	case '│', '┃', '┆', '┇', '┊', '┋', '╎', '╏', '║', '╵', '╷', '╹', '╻', '╽', '╿':
+		return "|"
+	}
+	return "+"
+}
+
+// encodeOutput converts UTF-8 output to the character set.
+func encodeOutput(msg, charset string) string {
+	if charset == charsetUTF8 {
+		return msg
+	}
+	ascii := true
+	for i := 0; i < len(msg); i++ {
+		if msg[i] >= utf8.RuneSelf {
+			ascii = false
+			break
+		}
+	}
+	if ascii {
+		return msg
+	}
+	var b strings.Builder
+	for _, r := range msg {
+		switch {
+		case r < utf8.RuneSelf:
+			b.WriteRune(r)
+		case charset == charsetCP437 && cp437Bytes[r] != 0:
+			b.WriteByte(cp437Bytes[r])
+		default:
+			b.WriteString(asciiRune(r))
+		}
+	}
+	return b.String()
+}
+
+// decodeInput converts input in the character set to UTF-8.
+func decodeInput(line, charset string) string {
+	if charset != charsetCP437 {
+		return line
+	}
+	var b strings.Builder
+	for i := 0; i < len(line); i++ {
+		if c := line[i]; c >= 0x80 && int(c-0x80) < len(cp437Runes) {
+			b.WriteRune(cp437Runes[c-0x80])
+		} else {
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+// charset returns the character set the connection's output is sent in:
+// the player's choice, or else the one their client negotiated.
+func (c *connection) charset() string {
+	if c.player != nil && c.player.charset != "" {
+		return c.player.charset
+	}
+	if c.clientCharset != "" {
+		return c.clientCharset
+	}
+	return charsetUTF8
+}
+
+// requestCharset asks the client to pick a character set from those the
+// server can send in.
+func (c *connection) requestCharset() {
+	cmd := []byte{telnetIAC, telnetSB, optCharset, charsetRequest}
+	for _, name := range charsetOffer {
+		cmd = append(append(cmd, ';'), name...)
+	}
+	c.telnet(append(cmd, telnetIAC, telnetSE)...)
+}
+
+// charsetSubnegotiation handles the client's side of TELNET CHARSET: its
+// pick from the server's offer, or an offer of its own.
+func (c *connection) charsetSubnegotiation(data []byte) {
+	if len(data) == 0 {
+		return
+	}
+	switch data[0] {
+	case charsetAccepted:
+		if name, ok := charsetNames[strings.ToUpper(string(data[1:]))]; ok {
+			c.clientCharset = name
+		}
+	case charsetRejected:
+		// the client can't take any of the server's character sets, so
+		// send it nothing it might mangle
+		c.clientCharset = charsetASCII
+	case charsetRequest:
+		offer := string(data[1:])
+		if strings.HasPrefix(offer, "[TTABLE]") && len(offer) > 9 {
+			// skip the translation table version
+			offer = offer[9:]
+		}
+		if len(offer) < 2 {
+			c.telnet(telnetIAC, telnetSB, optCharset, charsetRejected, telnetIAC, telnetSE)
+			return
+		}
+		// the first byte separates the names
+		for _, name := range strings.Split(offer[1:], offer[:1]) {
+			if cs, ok := charsetNames[strings.ToUpper(name)]; ok {
+				c.clientCharset = cs
+				c.telnet(append(append([]byte{telnetIAC, telnetSB, optCharset, charsetAccepted}, name...), telnetIAC, telnetSE)...)
+				return
+			}
+		}
+		c.telnet(telnetIAC, telnetSB, optCharset, charsetRejected, telnetIAC, telnetSE)
+	}
+}
+
+// charsetCommand shows the character set the player's output is sent in,
+// or picks one, overriding what their client negotiated.
+func (m *mud) charsetCommand(c *connection, args []string) {
+	p := c.player
+	if len(args) == 0 {
+		how := "as your client negotiated"
+		switch {
+		case p.charset != "":
+			how = "as you chose"
+		case c.clientCharset == "":
+			how = "by default"
+		}
+		c.write(c.tr("charset.current", "charset", c.charset(), "how", how))
+		c.write(c.tr("charset.use_charset_utf"))
+		return
+	}
+	if strings.EqualFold(args[0], "auto") {
+		p.charset = ""
+	} else if name, ok := charsetNames[strings.ToUpper(args[0])]; ok {
+		p.charset = name
+	} else {
+		c.write(c.tr("charset.usage_charset_utf"))
+		return
+	}
+	m.savePlayer(c)
+	c.write(c.tr("charset.changed", "charset", c.charset()))
+}
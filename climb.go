@@ -0,0 +1,108 @@
+package main
+
+import (
+	"math/rand"
+	"time"
+)
+
+// effectLevitating keeps a player from falling.
+const effectLevitating = "levitating"
+
+// fallInterval is how often players in open air are checked for falling.
+const fallInterval = 5 * time.Second
+
+// fallDamage is the damage taken for each room fallen through.
+const fallDamage = 10
+
+// linkRooms joins two rooms with an exit in the given direction and its
+// return exit, whatever their positions. It is used for exits such as up
+// and down that don't follow the map grid, and for rooms off the map.
+func (m *mud) linkRooms(r *room, dir string, r2 *room) {
+	r.exits[dir] = r2.id
+	r2.exits[reverseDirections[dir]] = r.id
+}
+
+// climbRoll makes a climbing check, which improves the skill either way.
+func (m *mud) climbRoll(c *connection) bool {
+	ok := rand.Intn(100) < 40+c.player.skills["climbing"]
+	m.improveSkill(c, "climbing")
+	return ok
+}
+
+// climb makes the climbing check for a cliff exit. It reports whether the
+// player can go on; a player who slips falls from where they are.
+func (m *mud) climb(c *connection, r *room, dir string) bool {
+	if !r.cliffs[dir] || c.player.affected(effectLevitating) {
+		return true
+	}
+	if m.climbRoll(c) {
+		c.write(c.tr("climb.climb", "dir", dir))
+		return true
+	}
+	c.write(c.tr("climb.lose_grip_slip"))
+	m.fall(c, r, 1)
+	return false
+}
+
+// checkFall makes a player who enters an air room without levitating fall,
+// as does entering a cliff room without knowing how to climb.
+func (m *mud) checkFall(e event) {
+	p := e.conn.player
+	if p.affected(effectLevitating) {
+		return
+	}
+	if e.room.flags["air"] || (e.room.flags["cliff"] && p.skills["climbing"] == 0) {
+		m.fall(e.conn, e.room, 0)
+	}
+}
+
+// fallAll drops the players left in air rooms, such as those whose
+// levitation has worn off.
+func (m *mud) fallAll() {
+	for _, conn := range m.conns {
+		if conn.state != statePlaying || conn.player.affected(effectLevitating) {
+			continue
+		}
+		if r := m.rooms[conn.player.room]; r != nil && r.flags["air"] {
+			m.fall(conn, r, 0)
+			conn.writePrompt()
+		}
+	}
+}
+
+// fall drops the player down through the down exits until they land in a
+// room that isn't open air. They take damage for each room fallen, plus
+// any extra rooms already fallen.
+func (m *mud) fall(c *connection, r *room, fallen int) {
+	p := c.player
+	c.write(colorize(c.tr("climb.fall"), "red"))
+	for {
+		key, ok := r.exits["down"]
+		if !ok {
+			break
+		}
+		next, ok := m.rooms[key]
+		if !ok {
+			break
+		}
+		r = next
+		fallen++
+		if !r.flags["air"] {
+			break
+		}
+		c.write(c.tr("climb.tumble_past", "room", r.name))
+	}
+	p.room = r.id
+	p.visited[r.id] = true
+	if fallen == 0 {
+		return
+	}
+	damage := fallDamage * fallen
+	p.health -= damage
+	c.write(c.tr("climb.land_hard_taking", "damage", damage))
+	if p.health <= 0 {
+		m.playerDeath(c, "the fall")
+		return
+	}
+	m.glance(c)
+}
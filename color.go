@@ -0,0 +1,25 @@
+package main
+
+// ansiReset restores the default terminal color.
+const ansiReset = "\x1b[0m"
+
+// ansiColors maps color names used in data files to ANSI escape sequences.
+var ansiColors = map[string]string{
+	"red":     "\x1b[31m",
+	"green":   "\x1b[32m",
+	"yellow":  "\x1b[33m",
+	"blue":    "\x1b[34m",
+	"magenta": "\x1b[35m",
+	"cyan":    "\x1b[36m",
+	"white":   "\x1b[37m",
+}
+
+// colorize wraps the string in the named ANSI color, or returns it unchanged
+// if the color is unknown.
+func colorize(s, color string) string {
+	code, ok := ansiColors[color]
+	if !ok {
+		return s
+	}
+	return code + s + ansiReset
+}
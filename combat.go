@@ -0,0 +1,539 @@
+package main
+
+import (
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// combatRound is how often those in a fight trade blows.
+const combatRound = 2 * time.Second
+
+// baseHitChance is the percent chance of a blow landing, before stances.
+const baseHitChance = 85
+
+// fleeChance is the percent chance of getting away from a fight, before
+// luck.
+const fleeChance = 60
+
+// how much threat a player adds by a successful maneuver, and how far a
+// rescue puts them ahead of everyone else
+const (
+	tacticThreat = 5
+	rescueThreat = 10
+)
+
+// the ways a player can fight
+const (
+	stanceBalanced  = "balanced"
+	stanceOffensive = "offensive"
+	stanceDefensive = "defensive"
+)
+
+// stance is a way of fighting, trading defense for offense or back again.
+// Each field is a percentage: added to the chance of landing a blow, to
+// the damage it does, and taken from the chance of being hit.
+type stance struct {
+	hit, damage, armor int
+}
+
+// stances are the ways of fighting a player can choose between.
+var stances = map[string]stance{
+	stanceBalanced:  {},
+	stanceOffensive: {hit: 10, damage: 25, armor: -10},
+	stanceDefensive: {hit: -10, damage: -25, armor: 15},
+}
+
+// fightingStance returns the modifiers for the way the player fights,
+// with their dexterity making them likelier to land blows and dodge them.
+func (p *player) fightingStance() stance {
+	s := stances[p.stance]
+	dex := 2 * p.attributeBonus("dex")
+	s.hit += dex + p.stat("hit")
+	s.armor += dex + p.stat("armor")
+	if p.affected(effectWarded) {
+		s.armor += wardArmor
+	}
+	return s
+}
+
+// swing rolls one blow of up to the given damage, returning the damage
+// done, or 0 for a miss.
+func swing(maxDamage int, attack, defense stance) int {
+	if rand.Intn(100) >= baseHitChance+attack.hit-defense.armor {
+		return 0
+	}
+	dmg := 1 + rand.Intn(maxDamage)
+	dmg += dmg * attack.damage / 100
+	if dmg < 1 {
+		dmg = 1
+	}
+	return dmg
+}
+
+// stanceCommand shows or changes the way the player fights.
+func (m *mud) stanceCommand(c *connection, args []string) {
+	p := c.player
+	if len(args) == 0 {
+		c.write(c.tr("stance.fighting_stance", "stance", p.stance))
+		return
+	}
+	name := strings.ToLower(args[0])
+	if _, ok := stances[name]; !ok {
+		c.write(c.tr("stance.usage_stance_offensive"))
+		return
+	}
+	p.stance = name
+	m.savePlayer(c)
+	switch name {
+	case stanceOffensive:
+		c.write(c.tr("stance.take_offensive_stance"))
+	case stanceDefensive:
+		c.write(c.tr("stance.take_defensive_stance"))
+	default:
+		c.write(c.tr("stance.take_balanced_stance"))
+	}
+}
+
+// combatRoll is a skill check for a combat maneuver against an NPC, which
+// is harder the tougher the NPC is.
+func (p *player) combatRoll(skill string, n *npc) bool {
+	return rand.Intn(100) < 40+p.skills[skill]/2-2*n.level
+}
+
+// maxDamage returns the most damage the NPC can do with a blow, which is
+// halved while it is disarmed.
+func (n *npc) maxDamage() int {
+	if time.Now().Before(n.disarmedUntil) {
+		return (n.damage + 1) / 2
+	}
+	return n.damage
+}
+
+// addThreat makes the player more of a threat in the NPC's eyes, so that
+// it is more likely to fight them.
+func (n *npc) addThreat(c *connection, amount int) {
+	if n.threat == nil {
+		n.threat = make(map[*connection]int)
+	}
+	n.threat[c] += amount
+}
+
+// topThreat returns the most threat any player poses the NPC.
+func (n *npc) topThreat() int {
+	top := 0
+	for _, t := range n.threat {
+		if t > top {
+			top = t
+		}
+	}
+	return top
+}
+
+// pickTarget turns the NPC on whoever in its room is the most threat to
+// it, forgetting those who have gone, and returns them. It keeps fighting
+// the same player unless someone else is more of a threat.
+func (m *mud) pickTarget(r *room, n *npc) *connection {
+	names := make([]string, 0, len(n.threat))
+	for c := range n.threat {
+		if m.conns[nameKey(c.name)] != c || c.state != statePlaying || c.player.room != r.id {
+			delete(n.threat, c)
+			continue
+		}
+		names = append(names, c.name)
+	}
+	sort.Strings(names)
+	target := n.fighting
+	if _, ok := n.threat[target]; !ok {
+		target = nil
+	}
+	for _, name := range names {
+		c := m.conns[nameKey(name)]
+		if target == nil || n.threat[c] > n.threat[target] {
+			target = c
+		}
+	}
+	n.fighting = target
+	return target
+}
+
+// opponent returns the NPC the player is fighting. If that one is no
+// longer there to fight, the player turns to one attacking them, if any.
+func (m *mud) opponent(c *connection) *npc {
+	p := c.player
+	r := m.rooms[p.room]
+	if r == nil {
+		p.fighting = nil
+		return nil
+	}
+	if n := p.fighting; n != nil && n.health > 0 && r.hasNPC(n) {
+		return n
+	}
+	p.fighting = nil
+	for _, n := range r.npcs {
+		if n.fighting == c {
+			p.fighting = n
+			return n
+		}
+	}
+	return nil
+}
+
+// engage starts the player fighting the NPC. The NPC turns on the player
+// unless it is already fighting someone else.
+func (m *mud) engage(c *connection, n *npc) {
+	c.player.fighting = n
+	n.addThreat(c, 1)
+	if n.fighting == nil {
+		n.fighting = c
+	}
+}
+
+// disengage takes the player out of every fight in their room. NPCs they
+// were fighting turn on whoever else is a threat to them next round, and
+// a boss with nobody left to fight resets. A player they were fighting
+// stops fighting them too.
+func (m *mud) disengage(c *connection) {
+	c.player.fighting = nil
+	if r := c.player.rival; r != nil {
+		if r.player.rival == c {
+			r.player.rival = nil
+		}
+		c.player.rival = nil
+	}
+	r := m.rooms[c.player.room]
+	if r == nil {
+		return
+	}
+	for _, n := range append([]*npc(nil), r.npcs...) {
+		if _, ok := n.threat[c]; !ok && n.fighting != c {
+			continue
+		}
+		delete(n.threat, c)
+		if n.fighting == c {
+			n.fighting = nil
+		}
+		if len(n.threat) == 0 {
+			m.resetBoss(r, n)
+		}
+	}
+}
+
+// groupFoe returns an NPC that a member of the player's group in the same
+// room is fighting, for the player to join in against if they autoassist.
+func (m *mud) groupFoe(c *connection) *npc {
+	g := c.player.party
+	if g == nil || !c.player.has(flagAutoAssist) {
+		return nil
+	}
+	for _, member := range g.members {
+		if member != c && member.player.room == c.player.room {
+			if n := m.opponent(member); n != nil {
+				return n
+			}
+		}
+	}
+	return nil
+}
+
+// strike has the player hit the NPC they are fighting with each of their
+// blows for the round, then their off hand, and then their followers. It
+// reports whether the NPC died.
+func (m *mud) strike(c *connection, r *room, n *npc) bool {
+	p := c.player
+	me, foe := playerActor(c), npcActor(n, r)
+	for i := m.attacks(c); i > 0; i-- {
+		dmg := swing(p.damage(), p.fightingStance(), stance{})
+		if dmg == 0 {
+			m.act(toActor, "combat.miss", me, foe, "")
+			m.act(toRoom, "combat.misses", me, foe, "")
+			continue
+		}
+		n.health -= dmg
+		n.addThreat(c, dmg)
+		m.act(toActor, "combat.hit", me, foe, strconv.Itoa(dmg))
+		m.act(toRoom, "combat.hits", me, foe, "")
+		if n.health <= 0 {
+			m.npcDeath(c, r, n)
+			return true
+		}
+	}
+	if m.offhandStrike(c, r, n) {
+		return true
+	}
+	return m.followersAssist(c, r, n)
+}
+
+// strikeBack has an NPC hit the player it is fighting.
+func (m *mud) strikeBack(n *npc, c *connection) {
+	p := c.player
+	foe, me := npcActor(n, m.rooms[p.room]), playerActor(c)
+	dmg := swing(n.maxDamage(), stance{}, p.fightingStance())
+	if dmg == 0 {
+		m.act(toVictim, "combat.misses_you", foe, me, "")
+		m.act(toNotVictim, "combat.misses", foe, me, "")
+		return
+	}
+	p.health -= dmg
+	m.act(toVictim, "combat.hits_you", foe, me, strconv.Itoa(dmg))
+	m.act(toNotVictim, "combat.hits", foe, me, "")
+	if p.health <= 0 {
+		m.playerDeath(c, n.name)
+	}
+}
+
+// combatRounds has everyone in a fight trade blows: first the players and
+// their followers, then the NPCs, each at whoever is the most threat to it.
+// Players who aren't fighting join in on their group's fights, and bosses
+// use their abilities.
+func (m *mud) combatRounds() {
+	fought := make(map[*connection]bool)
+	notice := func(c *connection) {
+		if !fought[c] {
+			c.write("\n")
+			fought[c] = true
+		}
+	}
+	for _, name := range sortedKeys(m.conns) {
+		c := m.conns[name]
+		if c.state != statePlaying || c.player.position != positionStanding {
+			continue
+		}
+		n := m.opponent(c)
+		if n == nil {
+			if rival := m.rival(c); rival != nil {
+				notice(c)
+				notice(rival)
+				m.strikePlayer(c, rival)
+				continue
+			}
+		}
+		assist := n == nil
+		if assist {
+			n = m.groupFoe(c)
+		}
+		if n == nil {
+			continue
+		}
+		notice(c)
+		if assist {
+			m.act(toActor, "combat.join_fight", playerActor(c), npcActor(n, nil), "")
+			m.engage(c, n)
+		}
+		m.strike(c, m.rooms[c.player.room], n)
+	}
+
+	now := time.Now()
+	for _, r := range m.rooms {
+		for _, n := range append([]*npc(nil), r.npcs...) {
+			if (n.fighting == nil && len(n.threat) == 0) || !r.hasNPC(n) {
+				continue
+			}
+			was := n.fighting
+			c := m.pickTarget(r, n)
+			if c == nil {
+				// everyone it was fighting has fled or fallen
+				m.resetBoss(r, n)
+				continue
+			}
+			notice(c)
+			foe := npcActor(n, r)
+			if c != was {
+				m.act(toVictim, "combat.turns_on_you", foe, playerActor(c), "")
+			}
+			if m.bossFor(n) != nil {
+				// the whole room sees what a boss does
+				for _, conn := range m.playersInRoom(r.id) {
+					notice(conn)
+				}
+				if m.bossPhases(r, n, c); c.player.room != r.id {
+					continue
+				}
+			}
+			if now.Before(n.trippedUntil) {
+				m.act(toRoom, "combat.gets_up", foe, actor{}, "")
+				continue
+			}
+			m.strikeBack(n, c)
+			m.bossAbilities(r, n, c)
+		}
+	}
+	for c := range fought {
+		c.writePrompt()
+	}
+}
+
+// foe finds the NPC a tactical command is aimed at: the one named, or
+// else the one the player is fighting.
+func (m *mud) foe(c *connection, args []string) *npc {
+	p := c.player
+	if len(args) == 0 {
+		return m.opponent(c)
+	}
+	if r := m.rooms[p.room]; r != nil {
+		return r.findNPC(args[0])
+	}
+	return nil
+}
+
+// tactic checks that the player can use a combat maneuver on the NPC,
+// telling them why not if they can't.
+func (m *mud) tactic(c *connection, n *npc) bool {
+	if m.opponent(c) != n {
+		m.act(toActor, "combat.not_fighting", playerActor(c), npcActor(n, nil), "")
+		return false
+	}
+	return m.requireStanding(c)
+}
+
+// disarmCommand knocks the weapon from an NPC the player is fighting,
+// weakening its blows for a while. Anything else is taken to be a trap.
+func (m *mud) disarmCommand(c *connection, args []string) {
+	n := m.foe(c, args)
+	if n == nil {
+		m.disarm(c, args)
+		return
+	}
+	if !m.tactic(c, n) {
+		return
+	}
+	me, foe := playerActor(c), npcActor(n, nil)
+	if time.Now().Before(n.disarmedUntil) {
+		m.act(toActor, "disarm.already", me, foe, "")
+		return
+	}
+	c.lag(2 * combatRound)
+	defer m.improveSkill(c, "disarm")
+	if !c.player.combatRoll("disarm", n) {
+		m.act(toActor, "disarm.fail", me, foe, "")
+		m.act(toRoom, "disarm.fail_others", me, foe, "")
+		return
+	}
+	n.disarmedUntil = time.Now().Add(3 * combatRound)
+	n.addThreat(c, tacticThreat)
+	m.act(toActor, "disarm.success", me, foe, "")
+	m.act(toRoom, "disarm.success_others", me, foe, "")
+}
+
+// trip knocks an NPC the player is fighting off its feet, so that it
+// can't strike back for a round or two. A clumsy trip leaves the player
+// off balance for longer.
+func (m *mud) trip(c *connection, args []string) {
+	n := m.foe(c, args)
+	if n == nil {
+		if len(args) == 0 {
+			c.write(c.tr("trip.trip_whom"))
+		} else {
+			c.write(c.tr("common.they_arent_here"))
+		}
+		return
+	}
+	if !m.tactic(c, n) {
+		return
+	}
+	me, foe := playerActor(c), npcActor(n, nil)
+	if time.Now().Before(n.trippedUntil) {
+		m.act(toActor, "trip.already_down", me, foe, "")
+		return
+	}
+	defer m.improveSkill(c, "trip")
+	if !c.player.combatRoll("trip", n) {
+		c.lag(3 * combatRound)
+		m.act(toActor, "trip.fail", me, foe, "")
+		m.act(toRoom, "trip.fail_others", me, foe, "")
+		return
+	}
+	c.lag(2 * combatRound)
+	n.trippedUntil = time.Now().Add(2 * combatRound)
+	n.addThreat(c, tacticThreat)
+	m.act(toActor, "trip.success", me, foe, "")
+	m.act(toRoom, "trip.success_others", me, foe, "")
+}
+
+// rescue steps in front of another player, drawing the attacks of the NPCs
+// fighting them onto the rescuer by making them the greatest threat.
+func (m *mud) rescue(c *connection, args []string) {
+	if len(args) == 0 {
+		c.write(c.tr("rescue.rescue_whom"))
+		return
+	}
+	target := m.findPlayerNear(c, args[0])
+	if target == nil {
+		c.write(c.tr("common.they_arent_here"))
+		return
+	}
+	var attackers []*npc
+	for _, n := range m.rooms[c.player.room].npcs {
+		if n.fighting == target {
+			attackers = append(attackers, n)
+		}
+	}
+	me, them := playerActor(c), playerActor(target)
+	if len(attackers) == 0 {
+		m.act(toActor, "rescue.nobody_attacking", me, them, "")
+		return
+	}
+	if !m.requireStanding(c) {
+		return
+	}
+	c.lag(combatRound)
+	defer m.improveSkill(c, "rescue")
+	if !c.player.combatRoll("rescue", attackers[0]) {
+		m.act(toActor, "rescue.fail", me, them, attackers[0].name)
+		return
+	}
+	for _, n := range attackers {
+		n.addThreat(c, n.topThreat()-n.threat[c]+rescueThreat)
+		n.fighting = c
+	}
+	if m.opponent(c) == nil {
+		c.player.fighting = attackers[0]
+	}
+	m.act(toActor, "rescue.success", me, them, "")
+	m.act(toVictim, "rescue.success_you", me, them, "")
+	m.act(toNotVictim, "rescue.success_others", me, them, "")
+}
+
+// flee tries to escape a fight through a random way out of the room. A
+// player who gets away leaves the NPCs or player they were fighting
+// behind.
+func (m *mud) flee(c *connection) {
+	if !m.inFight(c) {
+		c.write(c.tr("flee.arent_fighting_anyone"))
+		return
+	}
+	if !m.requireStanding(c) {
+		return
+	}
+	p := c.player
+	r := m.rooms[p.room]
+	var ways []string
+	for _, dir := range sortedKeys(r.exits) {
+		if d := r.doors[dir]; d != nil && (d.closed || d.concealed()) {
+			continue
+		}
+		ways = append(ways, dir)
+	}
+	if len(ways) == 0 {
+		c.write(c.tr("flee.theres_nowhere_run"))
+		return
+	}
+	c.lag(combatRound)
+	if rand.Intn(100) >= fleeChance+p.totalLuck() {
+		c.write(c.tr("flee.panic_cant_get"))
+		return
+	}
+	dir := ways[rand.Intn(len(ways))]
+	m.act(toActor, "flee.flee", playerActor(c), actor{}, dir)
+	m.act(toRoom, "flee.others", playerActor(c), actor{}, dir)
+	m.disengage(c)
+	m.move(c, dir)
+}
+
+// tellOthers shows everyone in the player's room but them an act message
+// about what they did.
+func (m *mud) tellOthers(c *connection, key string) {
+	m.act(toRoom, key, playerActor(c), actor{}, "")
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// config holds the server settings loaded from the config file.
+type config struct {
+	NewbieLevel  int      `json:"newbieLevel"`
+	Admins       []string `json:"admins"`
+	GreetingFile string   `json:"greetingFile"`
+	MOTDFile     string   `json:"motdFile"`
+	LockerRent   int      `json:"lockerRent"`
+	LockerDays   int      `json:"lockerDays"`
+	LockerSlots  int      `json:"lockerSlots"`
+	StealFine    int      `json:"stealFine"`
+	JailSeconds  int      `json:"jailSeconds"`
+	SalesTax     int      `json:"salesTax"`
+	APIToken     string   `json:"apiToken"`
+	BackupHours  int      `json:"backupHours"`
+	BackupKeep   int      `json:"backupKeep"`
+	WorldFile    string   `json:"worldFile"`
+	Locale       string   `json:"locale"`
+
+	RecordSessions bool `json:"recordSessions"`
+
+	SeasonCarryover []string `json:"seasonCarryover"`
+
+	// TransferKey signs the character transfer files this server exports
+	// and checks those it imports; servers trading characters share it.
+	// TransferItems lists the IDs of the items an imported character may
+	// bring along.
+	TransferKey   string   `json:"transferKey"`
+	TransferItems []string `json:"transferItems"`
+
+	Announcements []announcementConfig `json:"announcements"`
+
+	// ZoneIdleMinutes is how long an area goes without visitors before
+	// its descriptions, NPCs, and items are paged out of memory. With 0,
+	// everything stays loaded.
+	ZoneIdleMinutes int `json:"zoneIdleMinutes"`
+
+	// TrustedProxies lists the addresses, or CIDR ranges, of the load
+	// balancers in front of the server. Connections from them must begin
+	// with a PROXY protocol header (version 1 or 2) naming the client,
+	// whose address is then the one banned, throttled, and logged.
+	// BannedAddrs lists addresses and ranges refused outright, and
+	// ConnectionsPerMinute caps how often one address may connect; with
+	// 0 there is no cap.
+	TrustedProxies       []string `json:"trustedProxies"`
+	BannedAddrs          []string `json:"bannedAddrs"`
+	ConnectionsPerMinute int      `json:"connectionsPerMinute"`
+
+	// GeoIPFile is a MaxMind DB file, such as GeoLite2 Country or City,
+	// for telling staff where connections come from. CountryPolicies sets,
+	// by ISO country code, whether to "deny" connections from a country,
+	// refuse "no-new" characters from it, or "watch" it, flagging its
+	// logins to staff.
+	GeoIPFile       string            `json:"geoipFile"`
+	CountryPolicies map[string]string `json:"countryPolicies"`
+
+	// MaxPerAddress caps how many characters may play at once from one
+	// address, across all the worlds; with 0 there is no cap.
+	// NoSameAddressGroups keeps characters from one address out of each
+	// other's groups. Neither applies to the addresses and ranges in
+	// MultiplayExempt, or to characters staff allow to multiplay.
+	MaxPerAddress       int      `json:"maxPerAddress"`
+	NoSameAddressGroups bool     `json:"noSameAddressGroups"`
+	MultiplayExempt     []string `json:"multiplayExempt"`
+
+	// Webhooks lists the URLs to post game events to, for community
+	// sites and chat integrations.
+	Webhooks []webhookConfig `json:"webhooks"`
+
+	// MaxPlayers caps how many characters may play at once in each
+	// world; with 0 there is no cap. Players logging in to a full world
+	// wait in line for a place, though staff never do.
+	MaxPlayers int `json:"maxPlayers"`
+
+	// ResumeMinutes is how long a character whose connection drops waits
+	// in the world for the player to reconnect, with the resume token
+	// they were given at login or their password. With 0, they quit at
+	// once.
+	ResumeMinutes int `json:"resumeMinutes"`
+
+	// ScrollbackLines is how many lines of each player's output the
+	// server keeps, for replay and for catching up after reconnecting.
+	ScrollbackLines int `json:"scrollbackLines"`
+
+	// Worlds lists the worlds the server hosts, each on its own address.
+	// With none, it hosts the mall alone.
+	Worlds []worldConfig `json:"worlds"`
+}
+
+// defaultConfig returns the settings used when no config file is present.
+func defaultConfig() *config {
+	return &config{
+		NewbieLevel:  5,
+		GreetingFile: "data/greeting.txt",
+		MOTDFile:     "data/motd.txt",
+		LockerRent:   50,
+		LockerDays:   7,
+		LockerSlots:  20,
+		StealFine:    100,
+		JailSeconds:  120,
+		SalesTax:     5,
+		BackupHours:  6,
+		BackupKeep:   7,
+		Locale:       defaultLocale,
+
+		ResumeMinutes:   5,
+		ScrollbackLines: 200,
+
+		SeasonCarryover: []string{carryAchievements, carryCosmetics},
+	}
+}
+
+// loadConfig reads the config file at the given path, falling back to the
+// defaults for a missing file or any unset fields.
+func loadConfig(path string) (*config, error) {
+	cfg := defaultConfig()
+	err := loadJSON(path, cfg)
+	if os.IsNotExist(err) {
+		return cfg, nil
+	}
+	return cfg, err
+}
+
+// worlds returns the worlds the server hosts, filling in the directories
+// a world leaves out. The first keeps its state where a server hosting
+// the mall alone does, and the others under worlds/ by name.
+func (cfg *config) worlds() ([]worldConfig, error) {
+	if len(cfg.Worlds) == 0 {
+		return []worldConfig{defaultWorld}, nil
+	}
+	seen := make(map[string]bool)
+	worlds := make([]worldConfig, len(cfg.Worlds))
+	for i, w := range cfg.Worlds {
+		switch {
+		case w.Name == "" || w.Addr == "":
+			return nil, fmt.Errorf("world %d needs a name and an address", i+1)
+		case seen[strings.ToLower(w.Name)]:
+			return nil, fmt.Errorf("there are two worlds named %s", w.Name)
+		}
+		seen[strings.ToLower(w.Name)] = true
+		if w.Data == "" {
+			w.Data = defaultWorld.Data
+		}
+		if w.State == "" && i == 0 {
+			w.State = defaultWorld.State
+		} else if w.State == "" {
+			w.State = filepath.Join("worlds", w.Name)
+		}
+		worlds[i] = w
+	}
+	return worlds, nil
+}
+
+// isAdmin reports whether the named character is listed as staff.
+func (cfg *config) isAdmin(name string) bool {
+	for _, admin := range cfg.Admins {
+		if strings.EqualFold(admin, name) {
+			return true
+		}
+	}
+	return false
+}
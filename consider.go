@@ -0,0 +1,105 @@
+package main
+
+import (
+	"fmt"
+	"math"
+)
+
+// difficulty is how hard a fight against someone is, judged by how many
+// levels above the player they are.
+type difficulty struct {
+	upTo    int
+	name    string
+	color   string
+	verdict string
+}
+
+// difficulties run from the easiest to the hardest, each covering those up
+// to its number of levels above the player.
+var difficulties = []difficulty{
+	{-5, "trivial", "blue", "%s is no match for you."},
+	{-2, "easy", "green", "%s should be easy."},
+	{1, "even", "yellow", "%s would be a fair fight."},
+	{4, "hard", "red", "%s would be a tough fight."},
+	{math.MaxInt32, "deadly", "magenta", "%s would probably kill you."},
+}
+
+// difficultyOf returns how hard a fight the player would have against
+// someone of the given level.
+func (p *player) difficultyOf(level int) difficulty {
+	for _, d := range difficulties {
+		if level-p.level <= d.upTo {
+			return d
+		}
+	}
+	return difficulties[len(difficulties)-1]
+}
+
+// npcLine is how an NPC appears in a room to the player, colored by how
+// hard a fight it would be. Screen reader users get the difficulty named.
+func (c *connection) npcLine(n *npc) string {
+	line := n.roomLine(c)
+	if n.master != "" {
+		return line
+	}
+	d := c.player.difficultyOf(n.level)
+	if c.screenReader() {
+		return line + " (" + d.name + ")"
+	}
+	return colorize(line, d.color)
+}
+
+// expectedDamage returns the damage a blow of up to the given damage does
+// on average, allowing for misses.
+func expectedDamage(maxDamage int, attack, defense stance) float64 {
+	hit := float64(baseHitChance+attack.hit-defense.armor) / 100
+	return hit * float64(1+maxDamage) / 2 * float64(100+attack.damage) / 100
+}
+
+// roundsToKill estimates how many combat rounds it takes to wear down the
+// given health at the given damage a round.
+func roundsToKill(health int, perRound float64) int {
+	if perRound <= 0 {
+		return math.MaxInt32
+	}
+	return int(math.Ceil(float64(health) / perRound))
+}
+
+// consider sizes up an NPC or player in the room, judging how hard a fight
+// against them would be.
+func (m *mud) consider(c *connection, args []string) {
+	if len(args) == 0 {
+		c.write(c.tr("consider.consider_whom"))
+		return
+	}
+	p := c.player
+	r := m.rooms[p.room]
+	n := r.findNPC(args[0])
+	if n == nil {
+		target := m.findPlayerNear(c, args[0])
+		if target == nil {
+			c.write(c.tr("common.they_arent_here"))
+			return
+		}
+		name := capitalize(target.nameFor(c))
+		d := p.difficultyOf(target.player.level)
+		c.write(c.tr("consider.level", "name", name, "level", target.player.level, "yours", p.level))
+		c.write(fmt.Sprintf(d.verdict+"\n", name))
+		return
+	}
+
+	name := capitalize(n.name)
+	d := p.difficultyOf(n.level)
+	c.write(c.tr("consider.level", "name", name, "level", n.level, "yours", p.level))
+	c.write(colorize(fmt.Sprintf(d.verdict, name), d.color) + "\n")
+
+	// estimate the fight from both sides' damage, hits, and health
+	yours := float64(p.baseAttacks()) * expectedDamage(p.damage(), p.fightingStance(), stance{})
+	if p.equipment[offhandSlot] != nil {
+		attack := p.fightingStance()
+		attack.hit -= offhandPenalty - p.skills["offhand"]/4
+		yours += expectedDamage(p.offhandDamage(), attack, stance{})
+	}
+	theirs := expectedDamage(n.maxDamage(), stance{}, p.fightingStance())
+	c.write(c.tr("consider.rounds", "yours", roundsToKill(n.health, yours), "name", n.name, "theirs", roundsToKill(p.health, theirs)))
+}
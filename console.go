@@ -0,0 +1,79 @@
+package main
+
+import (
+	"io"
+	"log"
+	"net"
+	"os"
+	"time"
+)
+
+// consoleName is the staff character the operator plays from the server's
+// terminal. No one can log in as it over the network.
+const consoleName = "console"
+
+// consoleAddr is the address the console connection reports.
+type consoleAddr struct{}
+
+func (consoleAddr) Network() string { return "console" }
+func (consoleAddr) String() string  { return "console" }
+
+// consoleConn is the server's own stdin and stdout, standing in for a
+// network connection. Closing it leaves them open.
+type consoleConn struct {
+	in  io.Reader
+	out io.Writer
+}
+
+func (c *consoleConn) Read(b []byte) (int, error)       { return c.in.Read(b) }
+func (c *consoleConn) Write(b []byte) (int, error)      { return c.out.Write(b) }
+func (c *consoleConn) Close() error                     { return nil }
+func (c *consoleConn) LocalAddr() net.Addr              { return consoleAddr{} }
+func (c *consoleConn) RemoteAddr() net.Addr             { return consoleAddr{} }
+func (c *consoleConn) SetDeadline(time.Time) error      { return nil }
+func (c *consoleConn) SetReadDeadline(time.Time) error  { return nil }
+func (c *consoleConn) SetWriteDeadline(time.Time) error { return nil }
+
+// interactive reports whether the server's stdin is a terminal.
+func interactive() bool {
+	info, err := os.Stdin.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// runConsole plays the console character from the given input and output,
+// as staff, until the input runs out. The character is created the first
+// time, with a random password, as it never logs in over the network.
+func (m *mud) runConsole(in io.Reader, out io.Writer) {
+	c := newConnection(m, &consoleConn{in: in, out: out})
+	c.console = true
+	c.name = consoleName
+	ok := false
+	m.locked(func() {
+		if _, taken := m.conns[consoleName]; taken || !m.host.claim(consoleName, m) {
+			log.Printf("the console character is already playing")
+			return
+		}
+		rec, err := m.store.load(consoleName)
+		created := os.IsNotExist(err)
+		switch {
+		case err == nil:
+			c.player = playerFromRecord(rec)
+		case created:
+			c.player = newPlayer()
+			// nobody logs in as the console, so its password is never known
+			c.player.setPassword(newSalt())
+			m.savePlayer(c)
+		default:
+			log.Printf("error loading the console character: %v", err)
+			m.host.release(consoleName, m)
+			return
+		}
+		m.conns[consoleName] = c
+		m.enterGame(c, created)
+		c.writePrompt()
+		ok = true
+	})
+	if ok {
+		m.readInput(c)
+	}
+}
@@ -0,0 +1,329 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// controlService is the gRPC control API, described for clients in
+// control.proto. Every call takes and returns a google.protobuf.Struct,
+// so the server needs no generated code and clients need only the
+// well-known types.
+var controlService = grpc.ServiceDesc{
+	ServiceName: "mud.Control",
+	HandlerType: (*interface{})(nil),
+	Methods: []grpc.MethodDesc{
+		{MethodName: "Players", Handler: controlMethod((*host).controlPlayers)},
+		{MethodName: "Player", Handler: controlMethod((*host).controlPlayer)},
+		{MethodName: "Announce", Handler: controlMethod((*host).controlAnnounce)},
+		{MethodName: "Spawn", Handler: controlMethod((*host).controlSpawn)},
+		{MethodName: "Give", Handler: controlMethod((*host).controlGive)},
+		{MethodName: "Kick", Handler: controlMethod((*host).controlKick)},
+	},
+	Streams: []grpc.StreamDesc{
+		{StreamName: "Subscribe", Handler: controlSubscribe, ServerStreams: true},
+	},
+	Metadata: "control.proto",
+}
+
+// serveGRPC starts the gRPC control API on the given address. It answers
+// for every world the host runs, and only to callers presenting the
+// config's API token.
+func (h *host) serveGRPC(addr string) error {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	server := grpc.NewServer(
+		grpc.UnaryInterceptor(func(ctx context.Context, req interface{}, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+			if err := h.controlAuth(ctx); err != nil {
+				return nil, err
+			}
+			return handler(ctx, req)
+		}),
+		grpc.StreamInterceptor(func(srv interface{}, ss grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+			if err := h.controlAuth(ss.Context()); err != nil {
+				return err
+			}
+			return handler(srv, ss)
+		}),
+	)
+	server.RegisterService(&controlService, h)
+	return server.Serve(listener)
+}
+
+// controlAuth checks that a call presents the API token, as the
+// authorization metadata "Bearer <token>".
+func (h *host) controlAuth(ctx context.Context) error {
+	token := h.worlds[0].config.APIToken
+	md, _ := metadata.FromIncomingContext(ctx)
+	for _, v := range md.Get("authorization") {
+		v = strings.TrimPrefix(v, "Bearer ")
+		if token != "" && subtle.ConstantTimeCompare([]byte(v), []byte(token)) == 1 {
+			return nil
+		}
+	}
+	return status.Error(codes.PermissionDenied, "a valid API token is required")
+}
+
+// controlMethod adapts a control call to gRPC's unary handler.
+func controlMethod(call func(h *host, args map[string]interface{}) (interface{}, error)) func(interface{}, context.Context, func(interface{}) error, grpc.UnaryServerInterceptor) (interface{}, error) {
+	return func(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+		in := new(structpb.Struct)
+		if err := dec(in); err != nil {
+			return nil, err
+		}
+		run := func(ctx context.Context, req interface{}) (interface{}, error) {
+			out, err := call(srv.(*host), req.(*structpb.Struct).AsMap())
+			if err != nil {
+				return nil, err
+			}
+			return toStruct(out)
+		}
+		if interceptor == nil {
+			return run(ctx, in)
+		}
+		return interceptor(ctx, in, &grpc.UnaryServerInfo{Server: srv}, run)
+	}
+}
+
+// toStruct converts anything that encodes as a JSON object to a Struct.
+func toStruct(v interface{}) (*structpb.Struct, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	fields := make(map[string]interface{})
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return structpb.NewStruct(fields)
+}
+
+// stringArg returns the named string argument of a call, or "".
+func stringArg(args map[string]interface{}, name string) string {
+	s, _ := args[name].(string)
+	return s
+}
+
+// controlWorld returns the world a call names, or the first if it names
+// none.
+func (h *host) controlWorld(args map[string]interface{}) (*mud, error) {
+	name := stringArg(args, "world")
+	if name == "" {
+		return h.worlds[0], nil
+	}
+	if w := h.world(name); w != nil {
+		return w, nil
+	}
+	return nil, status.Errorf(codes.NotFound, "no world named %s", name)
+}
+
+// controlPlaying returns the world the named character is playing in.
+func (h *host) controlPlaying(name string) (*mud, error) {
+	if w := h.playingIn(name); w != nil {
+		return w, nil
+	}
+	return nil, status.Errorf(codes.NotFound, "%s isn't playing", name)
+}
+
+// controlPlayerInfo is a character playing, as Players lists them.
+type controlPlayerInfo struct {
+	Name      string `json:"name"`
+	World     string `json:"world"`
+	Room      string `json:"room"`
+	Level     int    `json:"level"`
+	Health    int    `json:"health"`
+	MaxHealth int    `json:"maxHealth"`
+	Gold      int    `json:"gold"`
+	Address   string `json:"address"`
+}
+
+// controlPlayers lists the characters playing in every world, or the one
+// named.
+func (h *host) controlPlayers(args map[string]interface{}) (interface{}, error) {
+	worlds := h.worlds
+	if stringArg(args, "world") != "" {
+		w, err := h.controlWorld(args)
+		if err != nil {
+			return nil, err
+		}
+		worlds = []*mud{w}
+	}
+	players := []controlPlayerInfo{}
+	for _, w := range worlds {
+		w.locked(func() {
+			for _, key := range sortedKeys(w.conns) {
+				conn := w.conns[key]
+				if conn.state != statePlaying || key != nameKey(conn.name) {
+					continue
+				}
+				p := conn.player
+				players = append(players, controlPlayerInfo{
+					Name: conn.name, World: w.name, Room: p.room, Level: p.level,
+					Health: p.health, MaxHealth: p.totalMaxHealth(), Gold: p.gold,
+					Address: conn.conn.RemoteAddr().String(),
+				})
+			}
+		})
+	}
+	return map[string]interface{}{"players": players}, nil
+}
+
+// controlPlayer returns a character's record, as it stands if they are
+// playing or as last saved if not.
+func (h *host) controlPlayer(args map[string]interface{}) (interface{}, error) {
+	name := stringArg(args, "name")
+	if nameProblem(name) != "" {
+		return nil, status.Errorf(codes.InvalidArgument, "bad character name %q", name)
+	}
+	if w := h.playingIn(name); w != nil {
+		var rec *characterRecord
+		w.locked(func() {
+			if conn := w.onlineAs(name); conn != nil {
+				rec = conn.player.record(conn.name)
+			}
+		})
+		if rec != nil {
+			return rec, nil
+		}
+	}
+	rec, err := h.worlds[0].store.load(name)
+	if err != nil {
+		return nil, status.Errorf(codes.NotFound, "no character named %s", name)
+	}
+	return rec, nil
+}
+
+// controlAnnounce makes a server announcement in every world, or the one
+// named.
+func (h *host) controlAnnounce(args map[string]interface{}) (interface{}, error) {
+	msg := stringArg(args, "message")
+	if msg == "" {
+		return nil, status.Error(codes.InvalidArgument, "message is required")
+	}
+	worlds := h.worlds
+	if stringArg(args, "world") != "" {
+		w, err := h.controlWorld(args)
+		if err != nil {
+			return nil, err
+		}
+		worlds = []*mud{w}
+	}
+	reached := 0
+	for _, w := range worlds {
+		w.locked(func() { reached += w.announceNow(msg) })
+	}
+	return map[string]int{"reached": reached}, nil
+}
+
+// controlSpawn spawns an NPC in a room of the world named.
+func (h *host) controlSpawn(args map[string]interface{}) (interface{}, error) {
+	w, err := h.controlWorld(args)
+	if err != nil {
+		return nil, err
+	}
+	var n *npc
+	w.locked(func() { n = w.spawnNPCIn(stringArg(args, "npc"), stringArg(args, "room")) })
+	if n == nil {
+		return nil, status.Error(codes.NotFound, "no such NPC or room")
+	}
+	return map[string]interface{}{}, nil
+}
+
+// controlGive makes an item and gives it to a character playing.
+func (h *host) controlGive(args map[string]interface{}) (interface{}, error) {
+	name := stringArg(args, "player")
+	w, err := h.controlPlaying(name)
+	if err != nil {
+		return nil, err
+	}
+	w.locked(func() {
+		conn := w.onlineAs(name)
+		if conn == nil {
+			err = fmt.Errorf("%s isn't playing", name)
+			return
+		}
+		it := w.spawnItem(stringArg(args, "item"))
+		if it == nil {
+			err = fmt.Errorf("no item %s", stringArg(args, "item"))
+			return
+		}
+		conn.player.inventory = append(conn.player.inventory, it)
+		conn.aside(conn.tr("quest.receive", "item", it.name))
+	})
+	if err != nil {
+		return nil, status.Error(codes.NotFound, err.Error())
+	}
+	return map[string]interface{}{}, nil
+}
+
+// controlKick disconnects a character playing, with an optional message.
+func (h *host) controlKick(args map[string]interface{}) (interface{}, error) {
+	name := stringArg(args, "player")
+	w, err := h.controlPlaying(name)
+	if err != nil {
+		return nil, err
+	}
+	w.locked(func() {
+		conn := w.onlineAs(name)
+		if conn == nil {
+			err = status.Errorf(codes.NotFound, "%s isn't playing", name)
+			return
+		}
+		if msg := stringArg(args, "message"); msg != "" {
+			conn.write(msg + "\n")
+		}
+		conn.close()
+	})
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{}, nil
+}
+
+// controlSubscribe streams the game's events to the caller until it hangs
+// up, limited to the kinds in the request's "kinds" list if it has one.
+func controlSubscribe(srv interface{}, stream grpc.ServerStream) error {
+	in := new(structpb.Struct)
+	if err := stream.RecvMsg(in); err != nil {
+		return err
+	}
+	kinds := make(map[string]bool)
+	if list, ok := in.AsMap()["kinds"].([]interface{}); ok {
+		for _, k := range list {
+			if s, ok := k.(string); ok {
+				kinds[s] = true
+			}
+		}
+	}
+	events, stop := srv.(*host).feed.watch()
+	defer stop()
+	for {
+		select {
+		case <-stream.Context().Done():
+			return nil
+		case e := <-events:
+			if len(kinds) > 0 && !kinds[e.Kind] {
+				continue
+			}
+			out, err := toStruct(e)
+			if err != nil {
+				return err
+			}
+			if err := stream.SendMsg(out); err != nil {
+				return err
+			}
+		}
+	}
+}
@@ -0,0 +1,238 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// dyedName returns an item name with a dye's color worked into it, after
+// its article if it has one, or in place of the color it was dyed before.
+func dyedName(name, old, dye string) string {
+	if old != "" {
+		if i := strings.Index(strings.ToLower(name), strings.ToLower(old)); i >= 0 {
+			if name[i] >= 'A' && name[i] <= 'Z' {
+				dye = capitalize(dye)
+			}
+			return fixArticle(name[:i] + dye + name[i+len(old):])
+		}
+	}
+	for _, lead := range []string{"a pair of ", "an ", "a ", "the ", "some "} {
+		if strings.HasPrefix(name, lead) {
+			return fixArticle(lead + dye + " " + name[len(lead):])
+		}
+	}
+	if name != "" && name[0] >= 'A' && name[0] <= 'Z' {
+		dye = capitalize(dye)
+	}
+	return dye + " " + name
+}
+
+// fixArticle makes an indefinite article at the start of a name agree
+// with the word after it.
+func fixArticle(name string) string {
+	var rest string
+	switch {
+	case strings.HasPrefix(name, "a "):
+		rest = name[2:]
+	case strings.HasPrefix(name, "an "):
+		rest = name[3:]
+	default:
+		return name
+	}
+	if rest != "" && strings.ContainsRune("aeiouAEIOU", rune(rest[0])) {
+		return "an " + rest
+	}
+	return "a " + rest
+}
+
+// dye recolors a piece of gear the player has, using up a dye.
+func (m *mud) dye(c *connection, args []string) {
+	if len(args) < 2 {
+		c.write(c.tr("dye.usage_dye_item"))
+		return
+	}
+	p := c.player
+	it := p.findGear(args[0])
+	if it == nil {
+		c.write(c.tr("common.dont_have"))
+		return
+	}
+	if it.slot == "" && it.cosmetic == "" {
+		c.write(c.tr("dye.can_only_dye"))
+		return
+	}
+	i := findItem(p.inventory, args[1])
+	if i < 0 {
+		c.write(c.tr("dye.dont_have_dye"))
+		return
+	}
+	bottle := p.inventory[i]
+	t := m.itemTemplates[bottle.id]
+	if bottle == it || t == nil || t.Dye == "" {
+		c.write(c.tr("dye.isnt_dye", "dye", capitalize(bottle.name)))
+		return
+	}
+	if strings.EqualFold(it.dyed, t.Dye) {
+		c.write(c.tr("dye.already", "item", capitalize(it.name), "color", t.Dye))
+		return
+	}
+	p.inventory = removeItem(p.inventory, i)
+	old := it.displayName(c)
+	it.name = dyedName(it.name, it.dyed, t.Dye)
+	it.dyed = t.Dye
+	if t.DyeColor != "" {
+		it.color = t.DyeColor
+	}
+	c.write(c.tr("dye.dye", "old", old, "new", it.displayName(c)))
+	m.actObj(toRoom, "dye.others", playerActor(c), actor{}, it, "")
+	m.savePlayer(c)
+}
+
+// findGear returns the item with the keyword the player is carrying,
+// wearing, or has on for their appearance.
+func (p *player) findGear(keyword string) *item {
+	if i := findItem(p.inventory, keyword); i >= 0 {
+		return p.inventory[i]
+	}
+	for _, slot := range sortedKeys(p.equipment) {
+		if matchKeywords(p.equipment[slot].keywords, keyword) {
+			return p.equipment[slot]
+		}
+	}
+	for _, slot := range sortedKeys(p.appearance) {
+		if matchKeywords(p.appearance[slot].keywords, keyword) {
+			return p.appearance[slot]
+		}
+	}
+	return nil
+}
+
+// appearanceCommand shows how the player or another in the room looks, or
+// puts on or takes off a cosmetic item.
+func (m *mud) appearanceCommand(c *connection, args []string) {
+	if len(args) == 0 {
+		m.showAppearance(c, c)
+		return
+	}
+	switch strings.ToLower(args[0]) {
+	case "wear":
+		if len(args) < 2 {
+			c.write(c.tr("appearance.wear_which_cosmetic"))
+			return
+		}
+		m.wearCosmetic(c, args[1])
+		return
+	case "remove":
+		if len(args) < 2 {
+			c.write(c.tr("appearance.remove_which_cosmetic"))
+			return
+		}
+		m.removeCosmetic(c, args[1])
+		return
+	}
+	target := m.findPlayerNear(c, args[0])
+	if target == nil {
+		c.write(c.tr("common.they_arent_here"))
+		return
+	}
+	m.showAppearance(c, target)
+}
+
+// wearCosmetic puts on a cosmetic item from the player's inventory,
+// taking off any already in its slot.
+func (m *mud) wearCosmetic(c *connection, keyword string) {
+	p := c.player
+	i := findItem(p.inventory, keyword)
+	if i < 0 {
+		c.write(c.tr("common.dont_have"))
+		return
+	}
+	it := p.inventory[i]
+	if it.cosmetic == "" {
+		c.write(c.tr("appearance.isnt_cosmetic_equip", "item", capitalize(it.name)))
+		return
+	}
+	p.inventory = removeItem(p.inventory, i)
+	if old, ok := p.appearance[it.cosmetic]; ok {
+		p.inventory = append(p.inventory, old)
+		c.write(c.tr("appearance.take_off", "item", old.displayName(c)))
+	}
+	p.appearance[it.cosmetic] = it
+	c.write(c.tr("appearance.put", "item", it.displayName(c)))
+	m.actObj(toRoom, "appearance.puts_on_others", playerActor(c), actor{}, it, "")
+}
+
+// removeCosmetic takes off a cosmetic item, named by its keyword or slot.
+func (m *mud) removeCosmetic(c *connection, keyword string) {
+	p := c.player
+	for _, slot := range sortedKeys(p.appearance) {
+		it := p.appearance[slot]
+		if strings.EqualFold(slot, keyword) || matchKeywords(it.keywords, keyword) {
+			delete(p.appearance, slot)
+			p.inventory = append(p.inventory, it)
+			c.write(c.tr("appearance.take_off", "item", it.displayName(c)))
+			return
+		}
+	}
+	c.write(c.tr("appearance.arent_wearing"))
+}
+
+// looks returns what shows in each slot when others look the player over:
+// their equipment, with any cosmetics worn over it.
+func (p *player) looks() map[string]*item {
+	shown := make(map[string]*item, len(p.equipment)+len(p.appearance))
+	for slot, it := range p.equipment {
+		shown[slot] = it
+	}
+	for slot, it := range p.appearance {
+		shown[slot] = it
+	}
+	return shown
+}
+
+// showAppearance shows the viewer how a player looks. Players looking at
+// themselves also see the gear their cosmetics cover.
+func (m *mud) showAppearance(c, target *connection) {
+	p := target.player
+	self := c == target
+	if self {
+		c.write(c.tr("appearance.look_like_others"))
+	} else {
+		c.write(fmt.Sprintf("%s\n", p.roomLine(target.nameFor(c))))
+	}
+	shown := p.looks()
+	if len(shown) == 0 {
+		c.write(c.tr("appearance.nothing_special"))
+	}
+	slots := make([]string, 0, len(shown))
+	for slot := range shown {
+		slots = append(slots, slot)
+	}
+	sort.Strings(slots)
+	for _, slot := range slots {
+		line := fmt.Sprintf("  <%s> %s", slot, shown[slot].displayName(c))
+		if eq := p.equipment[slot]; self && eq != nil && eq != shown[slot] {
+			line += fmt.Sprintf(", over %s", eq.displayName(c))
+		}
+		c.write(line + "\n")
+	}
+}
+
+// checkCosmetics reports achievements rewarding items that don't exist,
+// and dyes of colors that can't be shown.
+func (m *mud) checkCosmetics() []string {
+	var problems []string
+	for _, a := range m.achievements {
+		if _, ok := m.itemTemplates[a.Reward]; a.Reward != "" && !ok {
+			problems = append(problems, fmt.Sprintf("achievement %s rewards missing item %s", a.ID, a.Reward))
+		}
+	}
+	for _, id := range sortedKeys(m.itemTemplates) {
+		t := m.itemTemplates[id]
+		if _, ok := ansiColors[t.DyeColor]; t.DyeColor != "" && !ok {
+			problems = append(problems, fmt.Sprintf("dye %s has unknown color %s", id, t.DyeColor))
+		}
+	}
+	return problems
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// recipe turns a set of ingredient items into a new item.
+type recipe struct {
+	ID          string         `json:"id"`
+	Result      string         `json:"result"`
+	Ingredients map[string]int `json:"ingredients"`
+}
+
+// countItems returns how many of the player's inventory items have the given id.
+func (p *player) countItems(id string) int {
+	n := 0
+	for _, it := range p.inventory {
+		if it.id == id {
+			n++
+		}
+	}
+	return n
+}
+
+// takeItems removes count items with the given id from the player's inventory.
+func (p *player) takeItems(id string, count int) {
+	kept := p.inventory[:0]
+	for _, it := range p.inventory {
+		if it.id == id && count > 0 {
+			count--
+			continue
+		}
+		kept = append(kept, it)
+	}
+	p.inventory = kept
+}
+
+// describeIngredients lists a recipe's ingredients by name.
+func (m *mud) describeIngredients(r *recipe) string {
+	ids := make([]string, 0, len(r.Ingredients))
+	for id := range r.Ingredients {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	parts := make([]string, 0, len(ids))
+	for _, id := range ids {
+		name := id
+		if t, ok := m.itemTemplates[id]; ok {
+			name = t.Name
+		}
+		parts = append(parts, fmt.Sprintf("%d x %s", r.Ingredients[id], name))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// craft lists the known recipes or crafts one from the player's inventory.
+func (m *mud) craft(c *connection, args []string) {
+	if len(args) == 0 {
+		c.write(c.tr("craft.recipes"))
+		for _, r := range m.recipes {
+			c.write(c.tableRow("  %-14s %s\n", []string{"", "needs"}, r.ID, m.describeIngredients(r)))
+		}
+		return
+	}
+
+	var r *recipe
+	for _, candidate := range m.recipes {
+		if candidate.ID == args[0] {
+			r = candidate
+		}
+	}
+	if r == nil {
+		c.write(c.tr("craft.dont_know_how"))
+		return
+	}
+	p := c.player
+	for id, count := range r.Ingredients {
+		if p.countItems(id) < count {
+			c.write(c.tr("craft.need", "ingredients", m.describeIngredients(r)))
+			return
+		}
+	}
+	it := m.spawnItem(r.Result)
+	if it == nil {
+		c.write(c.tr("craft.something_went_wrong"))
+		return
+	}
+	for id, count := range r.Ingredients {
+		p.takeItems(id, count)
+	}
+	p.inventory = append(p.inventory, it)
+	c.write(c.tr("craft.craft", "item", it.displayName(c)))
+}
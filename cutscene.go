@@ -0,0 +1,155 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// cutscene is a piece of narrative shown a line at a time, as defined in
+// the cutscene data file. Each line waits its delay, in seconds, before it
+// is shown, and a line that pauses waits after it for the player to go on.
+// Played to a room, a cutscene never pauses.
+type cutscene struct {
+	ID    string         `json:"id"`
+	Lines []cutsceneLine `json:"lines"`
+}
+
+// cutsceneLine is one line of a cutscene.
+type cutsceneLine struct {
+	Text  string  `json:"text"`
+	Delay float64 `json:"delay,omitempty"`
+	Pause bool    `json:"pause,omitempty"`
+}
+
+// scenePlay is a cutscene being played, to a player or to everyone in a
+// room. queued are the cutscenes the player is to watch after it.
+type scenePlay struct {
+	scene  *cutscene
+	conn   *connection
+	room   *room
+	next   int
+	waited bool
+	paused bool
+	task   *task
+	queued []*cutscene
+}
+
+// findCutscene returns the cutscene with the given ID.
+func (m *mud) findCutscene(id string) *cutscene {
+	for _, s := range m.cutscenes {
+		if s.ID == id {
+			return s
+		}
+	}
+	return nil
+}
+
+// playCutscene plays a cutscene to the player, or queues it to follow the
+// one they are watching.
+func (m *mud) playCutscene(c *connection, id string) {
+	s := m.findCutscene(id)
+	if s == nil {
+		return
+	}
+	if c.scene != nil {
+		c.scene.queued = append(c.scene.queued, s)
+		return
+	}
+	c.scene = &scenePlay{scene: s, conn: c}
+	m.runScene(c.scene)
+}
+
+// playRoomCutscene plays a cutscene to everyone in the room.
+func (m *mud) playRoomCutscene(r *room, id string) {
+	if s := m.findCutscene(id); s != nil {
+		m.runScene(&scenePlay{scene: s, room: r})
+	}
+}
+
+// runScene shows the next lines of a cutscene, until one has a delay to
+// wait out first or pauses for the player.
+func (m *mud) runScene(sp *scenePlay) {
+	if sp.conn != nil && (sp.conn.world.Load() != m || sp.conn.scene != sp || sp.conn.state != statePlaying) {
+		return
+	}
+	for sp.next < len(sp.scene.Lines) {
+		line := sp.scene.Lines[sp.next]
+		if line.Delay > 0 && !sp.waited {
+			sp.waited = true
+			sp.task = m.scheduler.after("cutscene "+sp.scene.ID, time.Duration(line.Delay*float64(time.Second)), func() {
+				m.runScene(sp)
+			})
+			return
+		}
+		sp.waited = false
+		sp.next++
+		if sp.room != nil {
+			m.send(inRoom(sp.room.id), plain(line.Text+"\n").asAside(nil))
+			continue
+		}
+		sp.conn.aside(line.Text + "\n")
+		if line.Pause && sp.next < len(sp.scene.Lines) {
+			sp.paused = true
+			sp.conn.aside("[Type 'next' to go on, or 'skip' to skip the rest.]\n")
+			return
+		}
+	}
+	if sp.conn == nil {
+		return
+	}
+	sp.conn.scene = nil
+	if len(sp.queued) > 0 {
+		sp.conn.scene = &scenePlay{scene: sp.queued[0], conn: sp.conn, queued: sp.queued[1:]}
+		m.runScene(sp.conn.scene)
+	}
+}
+
+// stopCutscene cuts short the cutscene the player is watching, and any
+// queued after it.
+func (m *mud) stopCutscene(c *connection) {
+	if sp := c.scene; sp != nil {
+		if sp.task != nil {
+			m.scheduler.cancel(sp.task.id)
+		}
+		c.scene = nil
+	}
+}
+
+// watchCutscene takes the player's word to go on with or skip the
+// cutscene they are watching, reporting whether the command was one.
+// Other commands run as usual while a cutscene plays.
+func (m *mud) watchCutscene(c *connection, cmd string) bool {
+	sp := c.scene
+	if sp == nil {
+		return false
+	}
+	switch strings.ToLower(cmd) {
+	case "skip":
+		m.stopCutscene(c)
+		c.write(c.tr("cutscene.skip_ahead"))
+		return true
+	case "next", "continue":
+		if !sp.paused {
+			return false
+		}
+		sp.paused = false
+		m.runScene(sp)
+		return true
+	}
+	return false
+}
+
+// checkCutscenes reports the cutscenes quests and start rules name that
+// don't exist.
+func (m *mud) checkCutscenes() []string {
+	var problems []string
+	for _, q := range m.quests {
+		for _, id := range []string{q.Intro, q.Outro} {
+			if id != "" && m.findCutscene(id) == nil {
+				problems = append(problems, fmt.Sprintf("quest %s plays missing cutscene %s", q.ID, id))
+			}
+		}
+	}
+	return problems
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+)
+
+// loadJSON decodes the JSON file at the given path into v.
+func loadJSON(path string, v interface{}) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// loadData loads the item, NPC, affix, achievement, world event, minigame,
+// gathering, language, recipe, housing, class, quest, cutscene, holiday, and loot table definitions from the
+// given directory, followed by the world file if one is set, the areas in
+// its areas directory, the message catalogs in its locales directory, and
+// the script commands.
+func (m *mud) loadData(dir string) error {
+	var items []*itemTemplate
+	if err := loadJSON(filepath.Join(dir, "items.json"), &items); err != nil {
+		return err
+	}
+	for _, t := range items {
+		m.itemTemplates[t.ID] = t
+	}
+
+	var npcs []*npcTemplate
+	if err := loadJSON(filepath.Join(dir, "npcs.json"), &npcs); err != nil {
+		return err
+	}
+	for _, t := range npcs {
+		m.npcTemplates[t.ID] = t
+	}
+
+	if err := loadJSON(filepath.Join(dir, "affixes.json"), &m.affixes); err != nil {
+		return err
+	}
+
+	if err := loadJSON(filepath.Join(dir, "achievements.json"), &m.achievements); err != nil {
+		return err
+	}
+
+	if err := loadJSON(filepath.Join(dir, "events.json"), &m.worldEvents); err != nil {
+		return err
+	}
+
+	if err := loadJSON(filepath.Join(dir, "games.json"), &m.games); err != nil {
+		return err
+	}
+
+	if err := loadJSON(filepath.Join(dir, "gathering.json"), &m.gatherSkills); err != nil {
+		return err
+	}
+
+	if err := loadJSON(filepath.Join(dir, "languages.json"), &m.languages); err != nil {
+		return err
+	}
+
+	if err := loadJSON(filepath.Join(dir, "recipes.json"), &m.recipes); err != nil {
+		return err
+	}
+
+	if err := loadJSON(filepath.Join(dir, "housing.json"), &m.houseTemplates); err != nil {
+		return err
+	}
+
+	if err := loadJSON(filepath.Join(dir, "shops.json"), &m.shopConfigs); err != nil {
+		return err
+	}
+
+	if err := loadJSON(filepath.Join(dir, "speech.json"), &m.roomTriggers); err != nil {
+		return err
+	}
+
+	if err := loadJSON(filepath.Join(dir, "traps.json"), &m.trapConfigs); err != nil {
+		return err
+	}
+
+	if err := loadJSON(filepath.Join(dir, "vehicles.json"), &m.vehicleConfigs); err != nil {
+		return err
+	}
+
+	if err := loadJSON(filepath.Join(dir, "furniture.json"), &m.furnitureConfigs); err != nil {
+		return err
+	}
+
+	if err := loadJSON(filepath.Join(dir, "roomflags.json"), &m.roomFlagConfigs); err != nil {
+		return err
+	}
+
+	if err := loadJSON(filepath.Join(dir, "loot.json"), &m.lootTables); err != nil {
+		return err
+	}
+
+	if err := loadJSON(filepath.Join(dir, "classes.json"), &m.classes); err != nil {
+		return err
+	}
+
+	if err := loadJSON(filepath.Join(dir, "quests.json"), &m.quests); err != nil {
+		return err
+	}
+
+	if err := loadJSON(filepath.Join(dir, "cutscenes.json"), &m.cutscenes); err != nil {
+		return err
+	}
+
+	if err := loadJSON(filepath.Join(dir, "holidays.json"), &m.holidays); err != nil {
+		return err
+	}
+	if m.config.WorldFile != "" {
+		if err := m.loadWorld(m.config.WorldFile); err != nil {
+			return err
+		}
+	}
+	if err := m.loadAreas(filepath.Join(dir, "areas")); err != nil {
+		return err
+	}
+	if err := m.loadCatalogs(filepath.Join(dir, "locales")); err != nil {
+		return err
+	}
+	m.commandsPath = filepath.Join(dir, "commands.json")
+	cmds, err := loadScriptCommands(m.commandsPath)
+	if err != nil {
+		return err
+	}
+	m.scriptCommands = cmds
+	return m.compileTriggers()
+}
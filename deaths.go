@@ -0,0 +1,155 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+)
+
+// deathsFile is where the death log is saved, in the state directory.
+const deathsFile = "deaths.json"
+
+// how many deaths the log and each character keep, and how many notable
+// kills players see when they log in
+const (
+	deathLogSize    = 200
+	deathHistory    = 20
+	recentKillsFeed = 3
+)
+
+// notableLevel is the level from which an NPC's death is worth noting.
+const notableLevel = 5
+
+// death is an entry in the death log: who died, who or what killed them,
+// and where and when.
+type death struct {
+	Killer  string    `json:"killer"`
+	Victim  string    `json:"victim"`
+	Room    string    `json:"room"`
+	Time    time.Time `json:"time"`
+	Notable bool      `json:"notable,omitempty"`
+}
+
+// loadDeaths reads the saved death log, if there is one.
+func (m *mud) loadDeaths() error {
+	err := loadJSON(m.statePath(deathsFile), &m.deaths)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+// saveDeaths writes the death log to disk.
+func (m *mud) saveDeaths() {
+	data, err := json.MarshalIndent(m.deaths, "", "  ")
+	if err == nil {
+		err = os.MkdirAll(m.stateDir, 0755)
+	}
+	if err == nil {
+		err = os.WriteFile(m.statePath(deathsFile), data, 0644)
+	}
+	if err != nil {
+		log.Printf("error saving the death log: %v", err)
+	}
+}
+
+// logDeath adds a death to the log, dropping the oldest once it is full.
+func (m *mud) logDeath(d death) {
+	d.Time = time.Now()
+	m.deaths = append(m.deaths, d)
+	if len(m.deaths) > deathLogSize {
+		m.deaths = m.deaths[len(m.deaths)-deathLogSize:]
+	}
+	m.saveDeaths()
+}
+
+// notable reports whether an NPC's death is worth noting: a boss's, or
+// that of one tough enough.
+func (m *mud) notable(n *npc) bool {
+	return n.level >= notableLevel || m.bossFor(n) != nil
+}
+
+// recordPlayerDeath logs a player's death and adds it to their history.
+// A player killed by another player is a notable kill.
+func (m *mud) recordPlayerDeath(c *connection, killer string, r *room) {
+	d := death{Killer: killer, Victim: c.name}
+	if r != nil {
+		d.Room = r.name
+	}
+	if k, ok := m.conns[nameKey(killer)]; ok && k.state == statePlaying {
+		d.Notable = true
+	}
+	m.logDeath(d)
+	p := c.player
+	p.deaths = append(p.deaths, m.deaths[len(m.deaths)-1])
+	if len(p.deaths) > deathHistory {
+		p.deaths = p.deaths[len(p.deaths)-deathHistory:]
+	}
+	p.deathCount++
+}
+
+// recordNPCDeath logs the death of a notable NPC at the player's hands.
+func (m *mud) recordNPCDeath(c *connection, r *room, n *npc) {
+	if m.notable(n) {
+		m.logDeath(death{Killer: c.name, Victim: n.name, Room: r.name, Notable: true})
+	}
+}
+
+// deathsCommand lists the recent deaths of the player or another
+// character.
+func (m *mud) deathsCommand(c *connection, args []string) {
+	name := c.name
+	p := c.player
+	if len(args) > 0 && !strings.EqualFold(args[0], c.name) {
+		name = args[0]
+		if conn := m.onlineAs(name); conn != nil {
+			name, p = conn.name, conn.player
+		} else {
+			rec, err := m.store.load(name)
+			if os.IsNotExist(err) {
+				c.write(c.tr("common.no_such_player"))
+				return
+			}
+			if err != nil {
+				c.write(c.tr("deaths.players_records_could"))
+				return
+			}
+			p = playerFromRecord(rec)
+			name = rec.Name
+		}
+	}
+	if p.deathCount == 0 {
+		c.write(c.tr("deaths.has_never_died", "name", capitalize(name)))
+		return
+	}
+	times := fmt.Sprintf("%d times", p.deathCount)
+	if p.deathCount == 1 {
+		times = "once"
+	}
+	c.write(c.tr("deaths.has_died_most", "name", capitalize(name), "times", times))
+	for i := len(p.deaths) - 1; i >= 0; i-- {
+		d := p.deaths[i]
+		c.write(c.tr("deaths.ago_slain", "duration", formatDuration(time.Since(d.Time)), "killer", d.Killer, "room", d.Room))
+	}
+}
+
+// showRecentKills tells a player logging in about the latest notable
+// kills.
+func (m *mud) showRecentKills(c *connection) {
+	var recent []death
+	for i := len(m.deaths) - 1; i >= 0 && len(recent) < recentKillsFeed; i-- {
+		if m.deaths[i].Notable {
+			recent = append(recent, m.deaths[i])
+		}
+	}
+	if len(recent) == 0 {
+		return
+	}
+	c.write(c.tr("deaths.recent_notable_kills"))
+	for _, d := range recent {
+		c.write(c.tr("deaths.slew_ago", "killer", capitalize(d.Killer), "victim", d.Victim, "room", d.Room, "duration", formatDuration(time.Since(d.Time))))
+	}
+}
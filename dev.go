@@ -0,0 +1,247 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// devPassword is the password of every test account dev mode creates.
+const devPassword = "test1"
+
+// devAccounts are the test accounts dev mode creates. The first is staff.
+var devAccounts = []string{"wizard", "alice", "bob"}
+
+// devSetup prepares a dev mode run, which hosts the mall alone, from the
+// default config rather than the config file, in a scratch directory so
+// that nothing it saves outlives it. The data it reads is found from
+// where the server was started, except the MOTD, which staff can edit and
+// so is copied. It returns the config and the scratch directory, which is
+// now the working directory.
+func devSetup() (*config, string, error) {
+	cfg := defaultConfig()
+	cfg.Admins = devAccounts[:1]
+	cfg.BackupHours = 0
+	world := defaultWorld
+	var err error
+	for _, path := range []*string{&world.Data, &cfg.GreetingFile, &cfg.MOTDFile} {
+		if *path, err = filepath.Abs(*path); err != nil {
+			return nil, "", err
+		}
+	}
+	scratch, err := os.MkdirTemp("", "mud-dev-")
+	if err != nil {
+		return nil, "", err
+	}
+	motd, err := os.ReadFile(cfg.MOTDFile)
+	if err == nil {
+		cfg.MOTDFile = filepath.Join(scratch, "motd.txt")
+		err = os.WriteFile(cfg.MOTDFile, motd, 0644)
+	}
+	if err != nil && !os.IsNotExist(err) {
+		return nil, "", err
+	}
+	cfg.Worlds = []worldConfig{world}
+	return cfg, scratch, os.Chdir(scratch)
+}
+
+// createDevAccounts saves the test accounts, fresh, into the world's
+// character store.
+func (m *mud) createDevAccounts() error {
+	for _, name := range devAccounts {
+		p := newPlayer()
+		p.setPassword(devPassword)
+		if err := m.store.save(p.record(name)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// devREPL reads commands for poking at the game's state from in until it
+// runs out or is told to quit, writing what they show to out. Each runs
+// under the world's lock, between ticks and commands.
+func (m *mud) devREPL(in io.Reader, out io.Writer) {
+	fmt.Fprintf(out, "Dev mode: test accounts %s, password %s. Type 'help' for commands.\n",
+		strings.Join(devAccounts, ", "), devPassword)
+	scanner := bufio.NewScanner(in)
+	for fmt.Fprint(out, "dev> "); scanner.Scan(); fmt.Fprint(out, "dev> ") {
+		args := strings.Fields(scanner.Text())
+		if len(args) == 0 {
+			continue
+		}
+		if strings.ToLower(args[0]) == "quit" {
+			return
+		}
+		m.locked(func() { m.devCommand(out, strings.ToLower(args[0]), args[1:]) })
+	}
+}
+
+// devCommand runs one of the dev REPL's commands.
+func (m *mud) devCommand(out io.Writer, cmd string, args []string) {
+	switch cmd {
+	case "help":
+		fmt.Fprint(out, `who                        who is connected, and where
+rooms [text]               list rooms, or those whose ID or name has text
+room <id>                  show a room's exits, NPCs, items, and players
+player <name>              show a character's record, as it would be saved
+set <name> <field> <value> set health, mana, gold, level, xp, or room
+spawn <npc> [room]         spawn an NPC, in the start room by default
+load <item> <name|room>    give a character an item, or drop it in a room
+tasks                      list the scheduled tasks
+quit                       shut down, throwing away everything saved
+`)
+	case "who":
+		for _, key := range sortedKeys(m.conns) {
+			if conn := m.conns[key]; conn.state == statePlaying {
+				p := conn.player
+				fmt.Fprintf(out, "%-12s level %-3d %3d/%-3d hp  %s\n", conn.name, p.level, p.health, p.totalMaxHealth(), p.room)
+			}
+		}
+	case "rooms":
+		filter := strings.ToLower(strings.Join(args, " "))
+		for _, id := range sortedKeys(m.rooms) {
+			r := m.rooms[id]
+			if strings.Contains(strings.ToLower(id+" "+r.name), filter) {
+				fmt.Fprintf(out, "%-24s %s\n", id, r.name)
+			}
+		}
+	case "room":
+		m.devRoom(out, args)
+	case "player":
+		if len(args) != 1 {
+			fmt.Fprintln(out, "Usage: player <name>")
+			return
+		}
+		rec, err := m.store.load(args[0])
+		if conn := m.onlineAs(args[0]); conn != nil {
+			rec, err = conn.player.record(conn.name), nil
+		}
+		if err != nil {
+			fmt.Fprintf(out, "No character named %s.\n", args[0])
+			return
+		}
+		data, _ := json.MarshalIndent(rec, "", "  ")
+		fmt.Fprintln(out, string(data))
+	case "set":
+		m.devSet(out, args)
+	case "spawn":
+		m.devSpawn(out, args)
+	case "load":
+		m.devLoad(out, args)
+	case "tasks":
+		for _, t := range m.scheduler.list() {
+			fmt.Fprintf(out, "%4d %-24s next %s\n", t.id, t.name, t.next.Format("15:04:05"))
+		}
+	default:
+		fmt.Fprintf(out, "Unknown command %q; type 'help'.\n", cmd)
+	}
+}
+
+// devRoom shows what is in a room.
+func (m *mud) devRoom(out io.Writer, args []string) {
+	if len(args) != 1 || m.rooms[args[0]] == nil {
+		fmt.Fprintln(out, "Usage: room <id>, for one of the IDs 'rooms' lists")
+		return
+	}
+	m.wakeRoom(args[0])
+	r := m.rooms[args[0]]
+	fmt.Fprintf(out, "%s (%s) at %d,%d\n", r.name, r.id, r.x, r.y)
+	for _, dir := range sortedKeys(r.exits) {
+		fmt.Fprintf(out, "  exit %-10s %s\n", dir, r.exits[dir])
+	}
+	for _, n := range r.npcs {
+		fmt.Fprintf(out, "  npc  %-20s %d/%d hp\n", n.id, n.health, n.maxHealth)
+	}
+	for _, it := range r.items {
+		fmt.Fprintf(out, "  item %s\n", it.id)
+	}
+	for _, conn := range m.playersInRoom(r.id) {
+		fmt.Fprintf(out, "  player %s\n", conn.name)
+	}
+}
+
+// devSet sets one of an online character's stats.
+func (m *mud) devSet(out io.Writer, args []string) {
+	if len(args) != 3 {
+		fmt.Fprintln(out, "Usage: set <name> <field> <value>")
+		return
+	}
+	conn := m.onlineAs(args[0])
+	if conn == nil {
+		fmt.Fprintf(out, "%s isn't playing.\n", args[0])
+		return
+	}
+	p := conn.player
+	if strings.ToLower(args[1]) == "room" {
+		r := m.rooms[args[2]]
+		if r == nil {
+			fmt.Fprintf(out, "No room %s.\n", args[2])
+			return
+		}
+		m.wakeRoom(r.id)
+		p.room = r.id
+		p.visited[r.id] = true
+		m.glance(conn)
+		fmt.Fprintf(out, "%s is now in %s.\n", conn.name, r.name)
+		return
+	}
+	fields := map[string]*int{"health": &p.health, "mana": &p.mana, "gold": &p.gold, "level": &p.level, "xp": &p.xp}
+	field, ok := fields[strings.ToLower(args[1])]
+	n, err := strconv.Atoi(args[2])
+	if !ok || err != nil {
+		names := sortedKeys(fields)
+		fmt.Fprintf(out, "Set one of %s to a number, or room to a room ID.\n", strings.Join(append(names, "room"), ", "))
+		return
+	}
+	*field = n
+	fmt.Fprintf(out, "%s's %s is now %d.\n", conn.name, strings.ToLower(args[1]), n)
+}
+
+// devSpawn spawns an NPC.
+func (m *mud) devSpawn(out io.Writer, args []string) {
+	if len(args) == 0 || m.npcTemplates[args[0]] == nil {
+		ids := sortedKeys(m.npcTemplates)
+		fmt.Fprintf(out, "Usage: spawn <npc> [room], where npc is one of: %s\n", strings.Join(ids, ", "))
+		return
+	}
+	id := m.entrance
+	if len(args) > 1 {
+		id = args[1]
+	}
+	n := m.spawnNPCIn(args[0], id)
+	if n == nil {
+		fmt.Fprintf(out, "No room %s.\n", id)
+		return
+	}
+	fmt.Fprintf(out, "Spawned %s in %s.\n", n.name, m.rooms[id].name)
+}
+
+// devLoad makes an item and gives it to a character or drops it in a
+// room.
+func (m *mud) devLoad(out io.Writer, args []string) {
+	if len(args) != 2 || m.itemTemplates[args[0]] == nil {
+		ids := sortedKeys(m.itemTemplates)
+		fmt.Fprintf(out, "Usage: load <item> <name|room>, where item is one of: %s\n", strings.Join(ids, ", "))
+		return
+	}
+	it := m.spawnItem(args[0])
+	if conn := m.onlineAs(args[1]); conn != nil {
+		conn.player.inventory = append(conn.player.inventory, it)
+		fmt.Fprintf(out, "Gave %s to %s.\n", it.name, conn.name)
+		return
+	}
+	r := m.rooms[args[1]]
+	if r == nil {
+		fmt.Fprintf(out, "%s is neither playing nor a room.\n", args[1])
+		return
+	}
+	m.wakeRoom(r.id)
+	r.items = append(r.items, it)
+	fmt.Fprintf(out, "Dropped %s in %s.\n", it.name, r.name)
+}
@@ -0,0 +1,231 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// dialogue is a conversation an NPC can have with players, a tree of nodes
+// starting from the one named Start.
+type dialogue struct {
+	Start string                   `json:"start"`
+	Nodes map[string]*dialogueNode `json:"nodes"`
+}
+
+// dialogueNode is what the NPC says at one point in a conversation, and
+// the replies the player may give.
+type dialogueNode struct {
+	Text    string            `json:"text"`
+	Options []*dialogueOption `json:"options"`
+}
+
+// dialogueOption is a reply the player can choose by its number or
+// keyword. If is a condition for offering it:
+//
+//	open:<quest>          the player may take the quest
+//	active:<quest>        the player is on the quest
+//	ready:<quest>         the player is on the quest and has done it all
+//	done:<quest>[/choice] the player has finished the quest
+//	level:<n>             the player is at least the level
+//
+// Action is what choosing it does: "shop" or "train" to do business with
+// the NPC, "accept <quest>" or "complete <quest> [choice]" to take up or
+// hand in a quest. The conversation goes on to the Next node, or ends if
+// there is none.
+type dialogueOption struct {
+	Keyword string `json:"keyword,omitempty"`
+	Text    string `json:"text"`
+	If      string `json:"if,omitempty"`
+	Action  string `json:"action,omitempty"`
+	Next    string `json:"next,omitempty"`
+}
+
+// conversation is a player's place in a dialogue with an NPC.
+type conversation struct {
+	npc  *npc
+	node *dialogueNode
+}
+
+// talk starts a conversation with an NPC in the player's room.
+func (m *mud) talk(c *connection, args []string) {
+	if len(args) == 0 {
+		if c.conversation != nil {
+			m.showNode(c)
+			return
+		}
+		c.write(c.tr("talk.talk_whom"))
+		return
+	}
+	r := m.rooms[c.player.room]
+	n := r.findNPC(args[0])
+	if n == nil {
+		c.write(c.tr("common.they_arent_here"))
+		return
+	}
+	t := m.npcTemplates[n.id]
+	if t == nil || t.Dialogue == nil || t.Dialogue.Nodes[t.Dialogue.Start] == nil || n.fighting != nil {
+		c.write(c.tr("talk.has_nothing_say", "name", capitalize(n.name)))
+		return
+	}
+	c.conversation = &conversation{npc: n, node: t.Dialogue.Nodes[t.Dialogue.Start]}
+	m.showNode(c)
+}
+
+// showNode shows the player what the NPC says, and the replies they can
+// give.
+func (m *mud) showNode(c *connection) {
+	conv := c.conversation
+	c.write(c.tr("talk.says", "name", capitalize(conv.npc.name), "text", conv.node.Text))
+	for i, o := range m.replies(c.player, conv.node) {
+		c.write(fmt.Sprintf("  %d) %s\n", i+1, o.Text))
+	}
+	c.write(c.tr("talk.reply_number_bye"))
+}
+
+// replies returns the options of a node the player may choose.
+func (m *mud) replies(p *player, node *dialogueNode) []*dialogueOption {
+	var opts []*dialogueOption
+	for _, o := range node.Options {
+		if m.dialogueAllows(p, o.If) {
+			opts = append(opts, o)
+		}
+	}
+	return opts
+}
+
+// dialogueAllows reports whether the player meets a dialogue option's
+// condition.
+func (m *mud) dialogueAllows(p *player, cond string) bool {
+	if cond == "" {
+		return true
+	}
+	kind, arg, _ := strings.Cut(cond, ":")
+	switch kind {
+	case "open":
+		if q := m.findQuest(arg); q != nil {
+			ok, _, _ := m.questOpen(p, q)
+			return ok
+		}
+	case "active":
+		_, ok := p.quests[arg]
+		return ok
+	case "ready":
+		if q := m.findQuest(arg); q != nil && p.quests[arg] != nil {
+			return m.questReady(p, q)
+		}
+	case "done":
+		id, choice, _ := strings.Cut(arg, "/")
+		done, ok := p.questsDone[id]
+		return ok && (choice == "" || done.Choice == choice)
+	case "level":
+		n, err := strconv.Atoi(arg)
+		return err == nil && p.level >= n
+	}
+	return false
+}
+
+// converse takes the player's reply in a conversation, reporting whether
+// the command was one. Anything else is left to run as a command, and
+// the conversation ends once the NPC is gone.
+func (m *mud) converse(c *connection, cmd string) bool {
+	conv := c.conversation
+	if conv == nil {
+		return false
+	}
+	r := m.rooms[c.player.room]
+	if r == nil || !r.hasNPC(conv.npc) || conv.npc.fighting != nil {
+		c.conversation = nil
+		return false
+	}
+	if strings.EqualFold(cmd, "bye") {
+		c.conversation = nil
+		c.write(c.tr("talk.take_leave", "name", conv.npc.name))
+		return true
+	}
+	opts := m.replies(c.player, conv.node)
+	var chosen *dialogueOption
+	if i, err := strconv.Atoi(cmd); err == nil {
+		if i < 1 || i > len(opts) {
+			c.write(c.tr("talk.isnt_one_replies"))
+			return true
+		}
+		chosen = opts[i-1]
+	} else {
+		for _, o := range opts {
+			if o.Keyword != "" && strings.EqualFold(o.Keyword, cmd) {
+				chosen = o
+			}
+		}
+	}
+	if chosen == nil {
+		return false
+	}
+	c.write(c.tr("talk.say", "text", chosen.Text))
+	m.dialogueAction(c, conv.npc, chosen.Action)
+	next := m.npcTemplates[conv.npc.id].Dialogue.Nodes[chosen.Next]
+	if next == nil || c.conversation != conv {
+		c.conversation = nil
+		return true
+	}
+	conv.node = next
+	m.showNode(c)
+	return true
+}
+
+// dialogueAction does what a chosen reply asks for.
+func (m *mud) dialogueAction(c *connection, n *npc, action string) {
+	fields := strings.Fields(action)
+	if len(fields) == 0 {
+		return
+	}
+	switch fields[0] {
+	case "shop":
+		m.listStock(c)
+	case "train":
+		m.train(c, nil)
+	case "accept", "complete":
+		if len(fields) < 2 {
+			return
+		}
+		q := m.findQuest(fields[1])
+		if q == nil {
+			return
+		}
+		if fields[0] == "accept" {
+			m.acceptQuest(c, q)
+		} else {
+			m.completeQuest(c, q, fields[2:])
+		}
+	}
+}
+
+// checkDialogue reports the problems with an NPC's dialogue: a missing
+// start, replies leading to missing nodes, and quests that don't exist.
+func (m *mud) checkDialogue(id string, d *dialogue) []string {
+	var problems []string
+	if d.Nodes[d.Start] == nil {
+		problems = append(problems, fmt.Sprintf("NPC %s's dialogue starts at missing node %q", id, d.Start))
+	}
+	for _, name := range sortedKeys(d.Nodes) {
+		for _, o := range d.Nodes[name].Options {
+			if o.Next != "" && d.Nodes[o.Next] == nil {
+				problems = append(problems, fmt.Sprintf("NPC %s's dialogue node %s leads to missing node %q", id, name, o.Next))
+			}
+			quests := []string{}
+			if kind, arg, ok := strings.Cut(o.If, ":"); ok && kind != "level" {
+				quests = append(quests, arg)
+			}
+			if f := strings.Fields(o.Action); len(f) > 1 {
+				quests = append(quests, f[1])
+			}
+			for _, q := range quests {
+				q, _, _ = strings.Cut(q, "/")
+				if m.findQuest(q) == nil {
+					problems = append(problems, fmt.Sprintf("NPC %s's dialogue node %s refers to missing quest %s", id, name, q))
+				}
+			}
+		}
+	}
+	return problems
+}
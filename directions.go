@@ -0,0 +1,88 @@
+package main
+
+// directionOffsets maps each direction to its change in position.
+var directionOffsets = map[string][2]int{
+	"north":     {0, 1},
+	"east":      {1, 0},
+	"south":     {0, -1},
+	"west":      {-1, 0},
+	"northeast": {1, 1},
+	"northwest": {-1, 1},
+	"southeast": {1, -1},
+	"southwest": {-1, -1},
+}
+
+// reverseDirections maps each direction to its opposite.
+var reverseDirections = map[string]string{
+	"north":     "south",
+	"east":      "west",
+	"south":     "north",
+	"west":      "east",
+	"northeast": "southwest",
+	"northwest": "southeast",
+	"southeast": "northwest",
+	"southwest": "northeast",
+	"up":        "down",
+	"down":      "up",
+}
+
+// directionAbbreviations maps the short forms players can type to the
+// directions they stand for.
+var directionAbbreviations = map[string]string{
+	"n":  "north",
+	"e":  "east",
+	"s":  "south",
+	"w":  "west",
+	"ne": "northeast",
+	"nw": "northwest",
+	"se": "southeast",
+	"sw": "southwest",
+	"u":  "up",
+	"d":  "down",
+}
+
+// outExit is the name of the way back from a named exit.
+const outExit = "out"
+
+// expandDirection returns the direction an abbreviation stands for, or the
+// text unchanged if it isn't one.
+func expandDirection(s string) string {
+	if dir, ok := directionAbbreviations[s]; ok {
+		return dir
+	}
+	return s
+}
+
+// linkNamed joins two rooms with a named exit, such as "store", which is
+// taken with "enter store". The return exit is "out".
+func (m *mud) linkNamed(r *room, name string, r2 *room) {
+	r.exits[name] = r2.id
+	r2.exits[outExit] = r.id
+}
+
+// moveMessage describes the player taking the exit in the given direction.
+func moveMessage(c *connection, dir string) string {
+	if _, ok := reverseDirections[dir]; ok {
+		return c.tr("move.direction", "dir", dir)
+	}
+	if dir == outExit {
+		return c.tr("move.out")
+	}
+	return c.tr("move.enter", "exit", dir)
+}
+
+// enter takes a named exit from the player's room, or else boards a
+// vehicle waiting there.
+func (m *mud) enter(c *connection, args []string) {
+	if len(args) > 0 {
+		if r := m.rooms[c.player.room]; r != nil {
+			if _, ok := r.exits[args[0]]; ok {
+				m.move(c, args[0])
+				return
+			}
+		}
+	}
+	if m.requireStanding(c) {
+		m.enterVehicle(c, args)
+	}
+}
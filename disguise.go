@@ -0,0 +1,107 @@
+package main
+
+import (
+	"strings"
+	"time"
+)
+
+// effectDisguised hides the player's name behind their disguise.
+const effectDisguised = "disguised"
+
+// trueSightLevel is the level at which players see through disguises.
+const trueSightLevel = 20
+
+// the mana cost and duration of the illusion spell
+const (
+	illusionCost     = 30
+	illusionDuration = 2 * time.Minute
+)
+
+// disguised reports whether the player is in disguise, dropping the
+// disguise once it has worn off.
+func (p *player) disguised() bool {
+	if p.disguise == "" {
+		return false
+	}
+	if !p.affected(effectDisguised) {
+		p.disguise = ""
+		return false
+	}
+	return true
+}
+
+// trueSight reports whether the player sees through disguises.
+func (p *player) trueSight() bool {
+	return p.admin || p.level >= trueSightLevel
+}
+
+// nameFor returns the name the viewer sees for the player: their disguise,
+// unless the viewer is the player themselves or has true sight.
+func (c *connection) nameFor(viewer *connection) string {
+	switch {
+	case !c.player.disguised() || viewer == c:
+		return c.name
+	case viewer.player.trueSight():
+		return viewer.tr("disguise.disguised_as", "name", c.name, "disguise", c.player.disguise)
+	}
+	return c.player.disguise
+}
+
+// disguiseAs puts the player in disguise for the given duration.
+func (p *player) disguiseAs(appearance string, d time.Duration) {
+	p.disguise = appearance
+	p.addEffect(effectDisguised, d)
+}
+
+// cast casts one of the spells the player knows.
+func (m *mud) cast(c *connection, args []string) {
+	if len(args) == 0 {
+		c.write(c.tr("disguise.cast_what"))
+		return
+	}
+	if !m.canCast(c) {
+		return
+	}
+	name := strings.ToLower(args[0])
+	if m.taughtSpell(name) && c.player.skills[name] == 0 {
+		c.write(c.tr("disguise.havent_learned_spell"))
+		return
+	}
+	switch name {
+	case "illusion":
+		m.illusion(c, args[1:])
+	case "heal":
+		m.heal(c, args[1:])
+	case "ward":
+		m.ward(c)
+	default:
+		c.write(c.tr("disguise.dont_know_spell"))
+	}
+}
+
+// illusion cloaks the player in a false appearance.
+func (m *mud) illusion(c *connection, args []string) {
+	if len(args) == 0 {
+		c.write(c.tr("disguise.cast_illusion_what"))
+		return
+	}
+	p := c.player
+	if p.mana < illusionCost {
+		c.write(c.tr("common.dont_have_enough"))
+		return
+	}
+	p.mana -= illusionCost
+	p.disguiseAs(strings.Join(args, " "), illusionDuration*time.Duration(p.spellPower())/100)
+	c.write(c.tr("disguise.air_shimmers_around", "disguise", p.disguise))
+}
+
+// undisguise drops the player's disguise.
+func (m *mud) undisguise(c *connection) {
+	if !c.player.disguised() {
+		c.write(c.tr("disguise.arent_disguised"))
+		return
+	}
+	c.player.disguise = ""
+	delete(c.player.effects, effectDisguised)
+	c.write(c.tr("disguise.drop_disguise"))
+}
@@ -0,0 +1,124 @@
+package main
+
+// door is a door on an exit. Both sides of the exit share the same door.
+type door struct {
+	name   string
+	closed bool
+	locked bool
+	key    string
+	hidden bool
+}
+
+// concealed reports whether the door is a closed secret door, which can't be
+// seen or used until something opens it.
+func (d *door) concealed() bool {
+	return d.hidden && d.closed
+}
+
+// addDoor places a closed door on the exit in the given direction and its
+// return exit. A door with a key starts locked.
+func (m *mud) addDoor(r *room, dir, name, key string) {
+	exit, ok := r.exits[dir]
+	if !ok {
+		return
+	}
+	d := &door{
+		name:   name,
+		closed: true,
+		locked: key != "",
+		key:    key,
+	}
+	r.doors[dir] = d
+	if r2, ok := m.rooms[exit]; ok {
+		r2.doors[reverseDirections[dir]] = d
+	}
+}
+
+// addSecretDoor places a hidden door on the exit in the given direction.
+// While closed the exit looks like a wall.
+func (m *mud) addSecretDoor(r *room, dir, name string) {
+	m.addDoor(r, dir, name, "")
+	if d, ok := r.doors[dir]; ok {
+		d.hidden = true
+	}
+}
+
+// findDoor returns the door in the given direction from the player's room,
+// telling them if there isn't one.
+func (m *mud) findDoor(c *connection, args []string) *door {
+	if len(args) == 0 {
+		c.write(c.tr("door.which_direction"))
+		return nil
+	}
+	r := m.rooms[c.player.room]
+	if r == nil {
+		c.write(c.tr("door.no_door"))
+		return nil
+	}
+	d, ok := r.doors[args[0]]
+	if !ok || d.concealed() {
+		c.write(c.tr("door.no_door"))
+		return nil
+	}
+	return d
+}
+
+// openDoor opens a closed, unlocked door.
+func (m *mud) openDoor(c *connection, args []string) {
+	d := m.findDoor(c, args)
+	switch {
+	case d == nil:
+	case !d.closed:
+		c.write(c.tr("door.already_open"))
+	case d.locked:
+		c.write(c.tr("door.locked", "door", capitalize(d.name)))
+	default:
+		d.closed = false
+		c.write(c.tr("door.open", "door", d.name))
+	}
+}
+
+// closeDoor closes an open door.
+func (m *mud) closeDoor(c *connection, args []string) {
+	d := m.findDoor(c, args)
+	switch {
+	case d == nil:
+	case d.closed:
+		c.write(c.tr("door.already_closed"))
+	default:
+		d.closed = true
+		c.write(c.tr("door.close", "door", d.name))
+	}
+}
+
+// lockDoor locks a closed door with one of the player's keys.
+func (m *mud) lockDoor(c *connection, args []string) {
+	d := m.findDoor(c, args)
+	switch {
+	case d == nil:
+	case !d.closed:
+		c.write(c.tr("door.have_close_first"))
+	case d.locked:
+		c.write(c.tr("door.already_locked"))
+	case d.key == "" || c.player.findKey(d.key) == nil:
+		c.write(c.tr("door.none_keys_fit"))
+	default:
+		d.locked = true
+		c.write(c.tr("door.lock", "door", d.name, "key", c.player.findKey(d.key).displayName(c)))
+	}
+}
+
+// unlockDoor unlocks a locked door, trying every key the player carries.
+func (m *mud) unlockDoor(c *connection, args []string) {
+	d := m.findDoor(c, args)
+	switch {
+	case d == nil:
+	case !d.locked:
+		c.write(c.tr("door.isnt_locked"))
+	case d.key == "" || c.player.findKey(d.key) == nil:
+		c.write(c.tr("door.none_keys_fit"))
+	default:
+		d.locked = false
+		c.write(c.tr("door.unlock", "door", d.name, "key", c.player.findKey(d.key).displayName(c)))
+	}
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"math/rand"
+	"strings"
+	"time"
+)
+
+// soberInterval is how often a player's intoxication wears off by a point.
+const soberInterval = 20 * time.Second
+
+// intoxication levels at which speech slurs, movement sways, and the player
+// can drink no more
+const (
+	tipsyLevel  = 3
+	drunkLevel  = 8
+	maxAlcohol  = 15
+	swayPercent = 5
+)
+
+// drink consumes a drink from the player's inventory.
+func (m *mud) drink(c *connection, args []string) {
+	if len(args) == 0 {
+		c.write(c.tr("drink.drink_what"))
+		return
+	}
+	p := c.player
+	i := findItem(p.inventory, args[0])
+	if i < 0 {
+		c.write(c.tr("common.dont_have"))
+		return
+	}
+	it := p.inventory[i]
+	t, ok := m.itemTemplates[it.id]
+	if !ok || !t.Drink {
+		c.write(c.tr("drink.cant_drink", "item", it.displayName(c)))
+		return
+	}
+	if t.Alcohol > 0 && p.intoxication >= maxAlcohol {
+		c.write(c.tr("drink.couldnt_possibly_drink"))
+		return
+	}
+	p.inventory = removeItem(p.inventory, i)
+	c.write(c.tr("drink.drink", "item", it.displayName(c)))
+	if t.Alcohol == 0 {
+		return
+	}
+	before := p.intoxication
+	p.intoxication += t.Alcohol
+	if p.intoxication > maxAlcohol {
+		p.intoxication = maxAlcohol
+	}
+	switch {
+	case before < drunkLevel && p.intoxication >= drunkLevel:
+		c.write(c.tr("drink.room_starts_spin"))
+	case before < tipsyLevel && p.intoxication >= tipsyLevel:
+		c.write(c.tr("drink.warm_glow_spreads"))
+	}
+}
+
+// sober wears off a point of intoxication from every player.
+func (m *mud) sober() {
+	for _, conn := range m.conns {
+		if conn.state != statePlaying || conn.player.intoxication == 0 {
+			continue
+		}
+		p := conn.player
+		p.intoxication--
+		switch p.intoxication {
+		case 0:
+			conn.aside(conn.tr("drink.sober"))
+		case drunkLevel - 1:
+			conn.aside(conn.tr("drink.stops_spinning"))
+		}
+	}
+}
+
+// slur garbles speech according to how intoxicated the player is.
+func (p *player) slur(msg string) string {
+	if p.intoxication < tipsyLevel {
+		return msg
+	}
+	chance := p.intoxication * 4
+	words := strings.Fields(msg)
+	for i, w := range words {
+		if rand.Intn(100) >= chance {
+			continue
+		}
+		switch rand.Intn(3) {
+		case 0:
+			w = strings.ReplaceAll(w, "s", "sh")
+		case 1:
+			if j := strings.IndexAny(w, "aeiou"); j >= 0 {
+				w = w[:j+1] + strings.Repeat(w[j:j+1], 2) + w[j+1:]
+			}
+		case 2:
+			w += " *hic*"
+		}
+		words[i] = w
+	}
+	return strings.Join(words, " ")
+}
+
+// sway sends a drunk player stumbling off in a random direction now and
+// then, returning the direction they actually go.
+func (m *mud) sway(c *connection, dir string) string {
+	p := c.player
+	if p.intoxication < drunkLevel || rand.Intn(100) >= (p.intoxication-drunkLevel+1)*swayPercent {
+		return dir
+	}
+	r := m.rooms[p.room]
+	var dirs []string
+	for d := range r.exits {
+		if door := r.doors[d]; door == nil || !door.concealed() {
+			dirs = append(dirs, d)
+		}
+	}
+	if len(dirs) == 0 {
+		return dir
+	}
+	stagger := dirs[rand.Intn(len(dirs))]
+	if stagger != dir {
+		c.write(c.tr("drink.stagger_off_course"))
+	}
+	return stagger
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"time"
+)
+
+// economyFile is where the gold ledger is saved, in the state directory.
+const economyFile = "economy.json"
+
+// economyTopHolders is how many of the richest characters the economy
+// report lists.
+const economyTopHolders = 5
+
+// ledger tallies the gold entering and leaving the game by source and sink.
+type ledger struct {
+	Since     time.Time      `json:"since"`
+	Created   map[string]int `json:"created"`
+	Destroyed map[string]int `json:"destroyed"`
+}
+
+// newLedger creates an empty ledger starting now.
+func newLedger() *ledger {
+	return &ledger{
+		Since:     time.Now(),
+		Created:   make(map[string]int),
+		Destroyed: make(map[string]int),
+	}
+}
+
+// loadLedger reads the saved gold ledger, if there is one.
+func (m *mud) loadLedger() error {
+	err := loadJSON(m.statePath(economyFile), m.ledger)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+// saveLedger writes the gold ledger to disk.
+func (m *mud) saveLedger() {
+	data, err := json.MarshalIndent(m.ledger, "", "  ")
+	if err == nil {
+		err = os.MkdirAll(m.stateDir, 0755)
+	}
+	if err == nil {
+		err = os.WriteFile(m.statePath(economyFile), data, 0644)
+	}
+	if err != nil {
+		log.Printf("error saving economy ledger: %v", err)
+	}
+}
+
+// goldCreated records gold entering the game from the given source.
+func (m *mud) goldCreated(source string, amount int) {
+	if amount > 0 {
+		m.ledger.Created[source] += amount
+	}
+}
+
+// goldDestroyed records gold leaving the game through the given sink.
+func (m *mud) goldDestroyed(sink string, amount int) {
+	if amount > 0 {
+		m.ledger.Destroyed[sink] += amount
+	}
+}
+
+// tax returns the sales tax due on the given price, rounded to the nearest
+// gold piece.
+func (m *mud) tax(price int) int {
+	return (price*m.config.SalesTax + 50) / 100
+}
+
+// economy shows staff the money supply, where gold is coming from and going
+// to, and who holds the most of it.
+func (m *mud) economy(c *connection) {
+	if !c.player.admin {
+		c.write(c.tr("command.unknown"))
+		return
+	}
+	recs, err := m.store.list()
+	if err != nil {
+		log.Printf("error listing characters: %v", err)
+		c.write(c.tr("economy.economy_report_unavailable"))
+		return
+	}
+
+	// saved records may be behind the players who are online
+	var entries []leaderboardEntry
+	supply := 0
+	for _, rec := range recs {
+		gold := rec.Gold
+		if conn, ok := m.conns[nameKey(rec.Name)]; ok && conn.state == statePlaying {
+			gold = conn.player.gold
+		}
+		supply += gold
+		entries = append(entries, leaderboardEntry{Name: rec.Name, Value: gold})
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Value > entries[j].Value
+	})
+
+	l := m.ledger
+	hours := time.Since(l.Since).Hours()
+	if hours < 1 {
+		hours = 1
+	}
+	c.write(c.tr("economy.money_supply_gold", "supply", supply, "count", len(recs)))
+	c.write(c.tr("economy.since", "since", l.Since.Format("2006-01-02 15:04")))
+	created, destroyed := 0, 0
+	for _, n := range l.Created {
+		created += n
+	}
+	for _, n := range l.Destroyed {
+		destroyed += n
+	}
+	c.write(c.tr("economy.created", "created", fmt.Sprintf("%8d", created), "created_rate", fmt.Sprintf("%.0f", float64(created)/hours)))
+	writeLedgerLines(c, l.Created, hours)
+	c.write(c.tr("economy.destroyed", "destroyed", fmt.Sprintf("%8d", destroyed), "destroyed_rate", fmt.Sprintf("%.0f", float64(destroyed)/hours)))
+	writeLedgerLines(c, l.Destroyed, hours)
+	c.write(c.tr("economy.top_holders"))
+	for i, e := range entries {
+		if i == economyTopHolders {
+			break
+		}
+		c.write(c.tableRow("%2d. %-20s %d\n", []string{"", "", "gold"}, i+1, e.Name, e.Value))
+	}
+}
+
+// writeLedgerLines writes each source or sink of a ledger column in order.
+func writeLedgerLines(c *connection, amounts map[string]int, hours float64) {
+	names := make([]string, 0, len(amounts))
+	for name := range amounts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		rate := fmt.Sprintf("%.0f", float64(amounts[name])/hours)
+		c.write(c.tableRow("    %-10s %8d (%s/hour)\n", []string{"", "gold", "per hour"}, name, amounts[name], rate))
+	}
+}
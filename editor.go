@@ -0,0 +1,38 @@
+package main
+
+import "strings"
+
+// editor collects multi-line text from a connection until it is finished
+// or aborted.
+type editor struct {
+	lines []string
+	done  func(text string)
+}
+
+// startEditor puts the connection into the editing state. The done function
+// is called with the collected text once the player finishes.
+func (m *mud) startEditor(c *connection, done func(text string)) {
+	c.editor = &editor{done: done}
+	c.state = stateEditing
+	c.write(c.tr("editor.enter_text_end"))
+}
+
+// handleEditing processes a line of input from a connection in the editing state.
+func (m *mud) handleEditing(c *connection, line string) {
+	e := c.editor
+	switch line {
+	case ".":
+		c.editor = nil
+		c.state = statePlaying
+		e.done(strings.Join(e.lines, "\n"))
+	case "~q":
+		c.editor = nil
+		c.state = statePlaying
+		c.write(c.tr("editor.aborted"))
+	default:
+		e.lines = append(e.lines, line)
+		c.write("] ")
+		return
+	}
+	c.writePrompt()
+}
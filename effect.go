@@ -0,0 +1,28 @@
+package main
+
+import "time"
+
+// status effects that can be placed on a player
+const (
+	effectStuck   = "stuck"
+	effectBlinded = "blinded"
+)
+
+// addEffect places a status effect on the player for the given duration.
+func (p *player) addEffect(name string, d time.Duration) {
+	p.effects[name] = time.Now().Add(d)
+}
+
+// affected reports whether the player is under the named status effect,
+// clearing it once it has worn off.
+func (p *player) affected(name string) bool {
+	until, ok := p.effects[name]
+	if !ok {
+		return false
+	}
+	if time.Now().After(until) {
+		delete(p.effects, name)
+		return false
+	}
+	return true
+}
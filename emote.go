@@ -0,0 +1,127 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// playersInRoom returns the playing connections whose players are in the
+// room with the given ID.
+func (m *mud) playersInRoom(id string) []*connection {
+	var conns []*connection
+	for _, conn := range m.conns {
+		if conn.state == statePlaying && conn.player.room == id {
+			conns = append(conns, conn)
+		}
+	}
+	return conns
+}
+
+// findPlayerNear returns the other player in the connection's room with the
+// given name.
+func (m *mud) findPlayerNear(c *connection, name string) *connection {
+	for _, conn := range m.playersInRoom(c.player.room) {
+		if conn != c && strings.EqualFold(conn.name, name) {
+			return conn
+		}
+	}
+	return nil
+}
+
+// possessive returns the possessive form of a name.
+func possessive(name string) string {
+	if strings.HasSuffix(name, "s") {
+		return name + "'"
+	}
+	return name + "'s"
+}
+
+// emote shows a freeform action to everyone in the room, with the codes of
+// an act message: $n is the actor and $N the target, and $e, $m and $s or
+// $E, $M and $S their pronouns. If the text does not name the actor, their
+// name is placed in front. A first word starting with @ names a player or
+// NPC in the room as the target, who sees "you" in place of their name.
+func (m *mud) emote(c *connection, args []string) {
+	if len(args) == 0 {
+		c.write(c.tr("emote.emote_what"))
+		return
+	}
+	p := c.player
+
+	// find the target, if one was given
+	var targetConn *connection
+	var target actor
+	if strings.HasPrefix(args[0], "@") {
+		keyword := strings.TrimPrefix(args[0], "@")
+		args = args[1:]
+		for _, conn := range m.playersInRoom(p.room) {
+			if strings.EqualFold(conn.name, keyword) {
+				targetConn = conn
+				target = playerActor(conn)
+			}
+		}
+		if targetConn == nil {
+			if r := m.rooms[p.room]; r != nil {
+				if n := r.findNPC(keyword); n != nil {
+					target = npcActor(n, r)
+				}
+			}
+		}
+		if target.conn == nil && target.npc == nil {
+			c.write(c.tr("common.they_arent_here"))
+			return
+		}
+	}
+	if len(args) == 0 {
+		c.write(c.tr("emote.emote_what"))
+		return
+	}
+	text := strings.Join(args, " ")
+	if target.conn == nil && target.npc == nil && mentionsVictim(text) {
+		c.write(c.tr("emote.emote_needs_target"))
+		return
+	}
+	if !strings.Contains(text, "$n") {
+		text = "$n " + text
+	}
+
+	me := playerActor(c)
+	if targetConn != nil {
+		r := strings.NewReplacer("$$", "$$", "$N", "you", "$E", "you", "$M", "you", "$S", "your")
+		toTarget := r.Replace(text)
+		m.actFormat(only(targetConn), func(*connection) string { return toTarget }, me, target, nil, "")
+	}
+	m.actFormat(inRoom(p.room).except(targetConn), func(*connection) string { return text }, me, target, nil, "")
+}
+
+// mentionsVictim reports whether act text uses any of the victim's codes.
+func mentionsVictim(text string) bool {
+	text = strings.ReplaceAll(text, "$$", "")
+	for _, code := range []string{"$N", "$E", "$M", "$S"} {
+		if strings.Contains(text, code) {
+			return true
+		}
+	}
+	return false
+}
+
+// pose sets the text shown after the player's name when others look at the
+// room, or clears it when no text is given.
+func (m *mud) pose(c *connection, args []string) {
+	if len(args) == 0 {
+		c.player.pose = ""
+		c.write(c.tr("pose.clear_pose"))
+		return
+	}
+	c.player.pose = strings.Join(args, " ")
+	c.write(c.tr("pose.others_see", "name", c.name, "pose", c.player.pose))
+}
+
+// roomLine returns the line describing the player to others in the room.
+func (p *player) roomLine(name string) string {
+	pose := p.pose
+	if pose == "" || p.position != positionStanding {
+		pose = p.positionLine()
+	}
+	return fmt.Sprintf("%s %s", capitalize(name), pose)
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// stat returns the total of the named stat across the player's equipment.
+func (p *player) stat(name string) int {
+	total := p.talentStats[name]
+	for _, it := range p.equipment {
+		total += it.stats[name]
+	}
+	return total
+}
+
+// damage returns the largest amount of damage the player deals with one
+// hit, more the stronger they are.
+func (p *player) damage() int {
+	dmg := playerDamage + p.stat("damage") + p.attributeBonus("str")
+	if off := p.equipment[offhandSlot]; off != nil {
+		// the off hand's weapon strikes on its own
+		dmg -= off.stats["damage"]
+	}
+	if dmg < 1 {
+		dmg = 1
+	}
+	return dmg
+}
+
+// totalLuck returns the player's luck including equipment bonuses.
+func (p *player) totalLuck() int {
+	return p.luck + p.stat("luck")
+}
+
+// totalMaxHealth returns the player's maximum health including equipment
+// bonuses and their constitution.
+func (p *player) totalMaxHealth() int {
+	return p.maxHealth + p.stat("health") + 5*p.attributeBonus("con")
+}
+
+// equip moves an item from the player's inventory into its equipment slot,
+// or a weapon into the player's off hand.
+func (m *mud) equip(c *connection, args []string) {
+	if len(args) == 0 {
+		c.write(c.tr("equip.equip_what"))
+		return
+	}
+	p := c.player
+	i := findItem(p.inventory, args[0])
+	if i < 0 {
+		c.write(c.tr("common.dont_have"))
+		return
+	}
+	it := p.inventory[i]
+	if it.slot == "" {
+		c.write(c.tr("equip.cant_equip"))
+		return
+	}
+	slot := it.slot
+	if len(args) > 1 && strings.EqualFold(args[1], offhandSlot) {
+		if !m.canOffhand(it) {
+			c.write(c.tr("equip.can_only_hold"))
+			return
+		}
+		slot = offhandSlot
+	}
+	p.inventory = removeItem(p.inventory, i)
+	if old, ok := p.equipment[slot]; ok {
+		p.inventory = append(p.inventory, old)
+		c.write(c.tr("equip.remove", "item", old.displayName(c)))
+	}
+	p.equipment[slot] = it
+	c.write(c.tr("equip.equip", "item", it.displayName(c)))
+}
+
+// unequip moves an equipped item back into the player's inventory.
+func (m *mud) unequip(c *connection, args []string) {
+	if len(args) == 0 {
+		c.write(c.tr("equip.remove_what"))
+		return
+	}
+	p := c.player
+	for slot, it := range p.equipment {
+		if matchKeywords(it.keywords, args[0]) {
+			delete(p.equipment, slot)
+			p.inventory = append(p.inventory, it)
+			if p.health > p.totalMaxHealth() {
+				p.health = p.totalMaxHealth()
+			}
+			c.write(c.tr("equip.remove", "item", it.displayName(c)))
+			return
+		}
+	}
+	c.write(c.tr("equip.arent_wearing"))
+}
+
+// showEquipment displays the player's equipped items by slot.
+func (m *mud) showEquipment(c *connection) {
+	p := c.player
+	c.write(c.tr("equipment.using"))
+	if len(p.equipment) == 0 {
+		c.write(c.tr("common.nothing"))
+		return
+	}
+	slots := make([]string, 0, len(p.equipment))
+	for slot := range p.equipment {
+		slots = append(slots, slot)
+	}
+	sort.Strings(slots)
+	for _, slot := range slots {
+		c.write(fmt.Sprintf("  <%s> %s\n", slot, p.equipment[slot].displayName(c)))
+	}
+}
+
+// examine displays the details of an item carried or equipped by the player.
+func (m *mud) examine(c *connection, args []string) {
+	if len(args) == 0 {
+		c.write(c.tr("examine.examine_what"))
+		return
+	}
+	p := c.player
+	var it *item
+	if i := findItem(p.inventory, args[0]); i >= 0 {
+		it = p.inventory[i]
+	}
+	for _, eq := range p.equipment {
+		if it == nil && matchKeywords(eq.keywords, args[0]) {
+			it = eq
+		}
+	}
+	if it == nil {
+		c.write(c.tr("common.dont_have"))
+		return
+	}
+
+	c.write(fmt.Sprintf("%s\n", it.displayName(c)))
+	if it.rarity != "" {
+		c.write(c.tr("examine.rarity", "rarity", it.rarity))
+	}
+	if it.slot != "" {
+		c.write(c.tr("examine.slot", "slot", it.slot))
+	}
+	stats := make([]string, 0, len(it.stats))
+	for k := range it.stats {
+		stats = append(stats, k)
+	}
+	sort.Strings(stats)
+	for _, k := range stats {
+		c.write(fmt.Sprintf("  %+d %s\n", it.stats[k], k))
+	}
+	c.write(c.tr("examine.value_gold", "value", it.value))
+	if it.treasure != "" {
+		m.describeMap(c, it)
+	}
+}
@@ -0,0 +1,48 @@
+package main
+
+// event kinds published on the event bus
+const (
+	eventKill      = "kill"
+	eventEnterRoom = "enterRoom"
+	eventLevelUp   = "levelUp"
+	eventGold      = "gold"
+	eventDeath     = "death"
+	eventRareDrop  = "rareDrop"
+	eventLogin     = "login"
+)
+
+// event describes something that happened in the game.
+type event struct {
+	kind   string
+	conn   *connection
+	npc    *npc
+	victim *connection
+	room   *room
+	amount int
+	item   *item
+	killer string
+}
+
+// eventBus dispatches published events to the handlers subscribed to their kind.
+type eventBus struct {
+	handlers map[string][]func(event)
+}
+
+// newEventBus creates a new event bus with no subscribers.
+func newEventBus() *eventBus {
+	return &eventBus{
+		handlers: make(map[string][]func(event)),
+	}
+}
+
+// subscribe registers a handler to be called for every event of the given kind.
+func (b *eventBus) subscribe(kind string, h func(event)) {
+	b.handlers[kind] = append(b.handlers[kind], h)
+}
+
+// publish calls every handler subscribed to the event's kind.
+func (b *eventBus) publish(e event) {
+	for _, h := range b.handlers[e.kind] {
+		h(e)
+	}
+}
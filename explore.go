@@ -0,0 +1,242 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// mapRadius is how many rooms the map shows in each direction from the
+// player.
+const mapRadius = 4
+
+// mapConnectors are the characters drawn between two rooms joined by an
+// exit in each direction, and where they go relative to the room's cell.
+var mapConnectors = map[string]struct {
+	col, row int
+	ch       byte
+}{
+	"east":      {3, 0, '-'},
+	"west":      {-1, 0, '-'},
+	"north":     {1, -1, '|'},
+	"south":     {1, 1, '|'},
+	"northeast": {3, -1, '/'},
+	"southwest": {-1, 1, '/'},
+	"northwest": {-1, -1, '\\'},
+	"southeast": {3, 1, '\\'},
+}
+
+// explorableRooms returns the rooms a player can count towards exploring
+// the world: those reachable from a start room, less players' houses.
+func (m *mud) explorableRooms() map[string]bool {
+	rooms := m.reachableRooms(m.startRooms()...)
+	for _, h := range m.houses {
+		if h.room != nil {
+			delete(rooms, h.room.id)
+		}
+	}
+	return rooms
+}
+
+// exploration returns how many of the explorable rooms the player has
+// visited, and how many there are.
+func (m *mud) exploration(p *player) (seen, total int) {
+	rooms := m.explorableRooms()
+	for id := range rooms {
+		if p.visited[id] {
+			seen++
+		}
+	}
+	return seen, len(rooms)
+}
+
+// explorationPercent returns how much of the world the player has
+// explored, as a whole percentage.
+func (m *mud) explorationPercent(p *player) int {
+	return percentOf(m.exploration(p))
+}
+
+// percentOf returns n as a whole percentage of total.
+func percentOf(n, total int) int {
+	if total == 0 {
+		return 0
+	}
+	return n * 100 / total
+}
+
+// knownExit returns the room an exit of a visited room leads to, if the
+// exit can be seen and the room is where the exit's direction says.
+func (m *mud) knownExit(r *room, dir string) *room {
+	off, ok := directionOffsets[dir]
+	if !ok || !r.mapped {
+		return nil
+	}
+	if d := r.doors[dir]; d != nil && d.concealed() {
+		return nil
+	}
+	to := m.rooms[r.exits[dir]]
+	if to == nil || !to.mapped || to.x != r.x+off[0] || to.y != r.y+off[1] {
+		return nil
+	}
+	return to
+}
+
+// mapCommand draws the rooms around the player that they have explored,
+// with the exits they have seen leading out of them.
+func (m *mud) mapCommand(c *connection) {
+	p := c.player
+	here := m.rooms[p.room]
+	if here == nil || !here.mapped {
+		c.write(c.tr("map.cant_get_bearings"))
+		return
+	}
+	if c.screenReader() {
+		m.listExplored(c, here)
+	} else {
+		m.drawMap(c, here)
+	}
+	seen, total := m.exploration(p)
+	c.write(c.tr("map.have_explored_rooms", "seen", seen, "total", total, "percent", percentOf(seen, total)))
+}
+
+// drawMap draws the explored rooms around the given room as ASCII art.
+func (m *mud) drawMap(c *connection, here *room) {
+	c.write(strings.Join(m.mapLines(c.player, here), "\n") + "\n")
+	c.write(c.tr("map.unexplored_v_exits"))
+}
+
+// mapLines returns the lines of the ASCII art map of the rooms the player
+// has explored around the given room. Each room is a three character
+// cell, with exits drawn between them.
+func (m *mud) mapLines(p *player, here *room) []string {
+	size := 2*mapRadius + 1
+	grid := make([][]byte, 2*size-1)
+	for i := range grid {
+		grid[i] = []byte(strings.Repeat(" ", 4*size-1))
+	}
+	set := func(col, row int, ch byte) {
+		if row < 0 || row >= len(grid) || col < 0 || col >= len(grid[row]) {
+			return
+		}
+		// crossing diagonals share a gap
+		if (grid[row][col] == '/' && ch == '\\') || (grid[row][col] == '\\' && ch == '/') {
+			ch = 'X'
+		}
+		grid[row][col] = ch
+	}
+	cell := func(r *room) (col, row int) {
+		return (r.x - here.x + mapRadius) * 4, (here.y - r.y + mapRadius) * 2
+	}
+	for dx := -mapRadius; dx <= mapRadius; dx++ {
+		for dy := -mapRadius; dy <= mapRadius; dy++ {
+			r := m.getRoomByPosition(here.x+dx, here.y+dy)
+			if r == nil || !p.visited[r.id] {
+				continue
+			}
+			col, row := cell(r)
+			mark := byte(' ')
+			_, up := r.exits["up"]
+			_, down := r.exits["down"]
+			switch {
+			case r == here:
+				mark = '@'
+			case up && down:
+				mark = '+'
+			case up:
+				mark = '^'
+			case down:
+				mark = 'v'
+			}
+			set(col, row, '[')
+			set(col+1, row, mark)
+			set(col+2, row, ']')
+			for dir, conn := range mapConnectors {
+				to := m.knownExit(r, dir)
+				if to == nil {
+					continue
+				}
+				set(col+conn.col, row+conn.row, conn.ch)
+				if !p.visited[to.id] {
+					tc, tr := cell(to)
+					set(tc, tr, '[')
+					set(tc+1, tr, '?')
+					set(tc+2, tr, ']')
+				}
+			}
+		}
+	}
+
+	// trim the empty rows and the space right of the rooms
+	lines := make([]string, 0, len(grid))
+	for _, row := range grid {
+		lines = append(lines, strings.TrimRight(string(row), " "))
+	}
+	for len(lines) > 0 && lines[0] == "" {
+		lines = lines[1:]
+	}
+	for len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// listExplored describes the explored rooms around the given room in
+// words, nearest first, for screen reader users.
+func (m *mud) listExplored(c *connection, here *room) {
+	p := c.player
+	type nearby struct {
+		r        *room
+		distance int
+	}
+	var rooms []nearby
+	unexplored := make(map[string]bool)
+	for dx := -mapRadius; dx <= mapRadius; dx++ {
+		for dy := -mapRadius; dy <= mapRadius; dy++ {
+			r := m.getRoomByPosition(here.x+dx, here.y+dy)
+			if r == nil || r == here || !p.visited[r.id] {
+				continue
+			}
+			rooms = append(rooms, nearby{r, dx*dx + dy*dy})
+			for dir := range mapConnectors {
+				if to := m.knownExit(r, dir); to != nil && !p.visited[to.id] {
+					unexplored[to.id] = true
+				}
+			}
+		}
+	}
+	sort.Slice(rooms, func(i, j int) bool {
+		if rooms[i].distance != rooms[j].distance {
+			return rooms[i].distance < rooms[j].distance
+		}
+		return rooms[i].r.name < rooms[j].r.name
+	})
+	if len(rooms) == 0 {
+		c.write(c.tr("map.havent_explored_around"))
+	} else {
+		c.write(c.tr("map.explored_rooms_nearby"))
+	}
+	for _, n := range rooms {
+		c.write(fmt.Sprintf("- %s, %s\n", n.r.name, relativePosition(n.r.x-here.x, n.r.y-here.y)))
+	}
+	if len(unexplored) > 0 {
+		c.write(c.tr("map.exits_lead_unexplored", "count", len(unexplored)))
+	}
+}
+
+// relativePosition says how far away a room is, such as "2 north, 1 east".
+func relativePosition(dx, dy int) string {
+	var parts []string
+	switch {
+	case dy > 0:
+		parts = append(parts, fmt.Sprintf("%d north", dy))
+	case dy < 0:
+		parts = append(parts, fmt.Sprintf("%d south", -dy))
+	}
+	switch {
+	case dx > 0:
+		parts = append(parts, fmt.Sprintf("%d east", dx))
+	case dx < 0:
+		parts = append(parts, fmt.Sprintf("%d west", -dx))
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// dataExport is everything stored about a character, as handed over by the
+// export command and the admin API. The mail they have received and their
+// own death history are part of the character record.
+type dataExport struct {
+	Exported   time.Time           `json:"exported"`
+	Character  *characterRecord    `json:"character"`
+	House      *house              `json:"house,omitempty"`
+	Bounties   []*bounty           `json:"bounties,omitempty"`
+	MailSent   []sentMail          `json:"mailSent,omitempty"`
+	Reports    []*report           `json:"reports,omitempty"`
+	Deaths     []death             `json:"deaths,omitempty"`
+	Snapshots  []*snapshot         `json:"snapshots,omitempty"`
+	Recordings []exportedRecording `json:"recordings,omitempty"`
+}
+
+// sentMail is a message the character sent, still waiting in someone's
+// mailbox.
+type sentMail struct {
+	To string `json:"to"`
+	mailMessage
+}
+
+// exportedRecording is one of the character's session recordings.
+type exportedRecording struct {
+	Name string `json:"name"`
+	Text string `json:"text"`
+}
+
+// exportData gathers the stored data for the named character. Password
+// hashes are left out.
+func (m *mud) exportData(name string) (*dataExport, error) {
+	var rec *characterRecord
+	if conn := m.onlineAs(name); conn != nil {
+		rec = conn.player.record(conn.name)
+	} else {
+		var err error
+		if rec, err = m.store.load(name); err != nil {
+			return nil, err
+		}
+	}
+	rec.PasswordHash, rec.Salt = "", ""
+	export := &dataExport{Exported: time.Now(), Character: rec}
+	if h := m.houseOwnedBy(rec.Name); h != nil {
+		hc := *h
+		hc.Items = nil
+		for _, it := range h.room.items {
+			hc.Items = append(hc.Items, it.record())
+		}
+		export.House = &hc
+	}
+	for _, b := range m.bounties {
+		if strings.EqualFold(b.PostedBy, rec.Name) || (b.Player && strings.EqualFold(b.Target, rec.Name)) {
+			export.Bounties = append(export.Bounties, b)
+		}
+	}
+	if err := m.exportMailSent(export, rec.Name); err != nil {
+		return nil, err
+	}
+	for _, r := range m.reports {
+		if strings.EqualFold(r.Player, rec.Name) {
+			export.Reports = append(export.Reports, r)
+		}
+	}
+	for _, d := range m.deaths {
+		if strings.EqualFold(d.Killer, rec.Name) || strings.EqualFold(d.Victim, rec.Name) {
+			export.Deaths = append(export.Deaths, d)
+		}
+	}
+	for _, s := range m.snapshots {
+		if strings.EqualFold(s.Player, rec.Name) {
+			export.Snapshots = append(export.Snapshots, s)
+		}
+	}
+	if err := exportRecordings(export, rec.Name); err != nil {
+		return nil, err
+	}
+	return export, nil
+}
+
+// exportMailSent adds the mail the named character has sent to the export,
+// from the mailboxes of everyone online and saved.
+func (m *mud) exportMailSent(export *dataExport, name string) error {
+	recs, err := m.store.list()
+	if err != nil {
+		return err
+	}
+	boxes := make(map[string][]mailMessage)
+	for _, rec := range recs {
+		boxes[strings.ToLower(rec.Name)] = rec.Mail
+	}
+	for _, conn := range m.conns {
+		if conn.state == statePlaying {
+			boxes[strings.ToLower(conn.name)] = conn.player.mail
+		}
+	}
+	for _, to := range sortedKeys(boxes) {
+		for _, msg := range boxes[to] {
+			if strings.EqualFold(msg.From, name) {
+				export.MailSent = append(export.MailSent, sentMail{To: to, mailMessage: msg})
+			}
+		}
+	}
+	return nil
+}
+
+// exportRecordings adds the named character's session recordings to the
+// export.
+func exportRecordings(export *dataExport, name string) error {
+	names, err := recordings(name)
+	if err != nil {
+		return err
+	}
+	for _, n := range names {
+		data, err := os.ReadFile(filepath.Join(recordingDir, n+".log"))
+		if err != nil {
+			return err
+		}
+		export.Recordings = append(export.Recordings, exportedRecording{Name: n, Text: string(data)})
+	}
+	return nil
+}
+
+// exportCommand shows the player everything stored about their character
+// as JSON, for them to keep a copy.
+func (m *mud) exportCommand(c *connection) {
+	m.savePlayer(c)
+	export, err := m.exportData(c.name)
+	if err == nil {
+		var data []byte
+		data, err = json.MarshalIndent(export, "", "  ")
+		if err == nil {
+			c.write(c.tr("export.data_export_follows"))
+			c.write(string(data) + "\n")
+			return
+		}
+	}
+	c.write(c.tr("export.data_could_not", "error", err))
+}
+
+// lockedExport gathers a character's data export under the game lock.
+func (m *mud) lockedExport(name string) (*dataExport, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.exportData(name)
+}
+
+// handleExport serves a character's data export to staff tools, which must
+// present the configured API token.
+func (m *mud) handleExport(w http.ResponseWriter, r *http.Request) {
+	if !m.staffRequest(r) {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
+	}
+	name := r.URL.Query().Get("player")
+	if nameProblem(name) != "" {
+		http.Error(w, "no such character", http.StatusNotFound)
+		return
+	}
+	export, err := m.lockedExport(name)
+	if err != nil {
+		http.Error(w, "no such character", http.StatusNotFound)
+		return
+	}
+	writeJSON(w, export)
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// feedBuffer is how many events a watcher may fall behind by before it
+// starts missing them.
+const feedBuffer = 256
+
+// feedKinds are the kinds of event the feed passes on.
+var feedKinds = []string{eventKill, eventEnterRoom, eventLevelUp, eventGold, eventDeath, eventRareDrop, eventLogin}
+
+// feedEvent is an event from the bus as tools outside the server see it.
+type feedEvent struct {
+	Kind   string    `json:"kind"`
+	World  string    `json:"world"`
+	Time   time.Time `json:"time"`
+	Player string    `json:"player,omitempty"`
+	NPC    string    `json:"npc,omitempty"`
+	Victim string    `json:"victim,omitempty"`
+	Room   string    `json:"room,omitempty"`
+	Amount int       `json:"amount,omitempty"`
+	Item   string    `json:"item,omitempty"`
+	Killer string    `json:"killer,omitempty"`
+}
+
+// feed passes the events of every world the host runs on to the tools
+// watching them. A watcher that falls behind misses events rather than
+// holding up the game.
+type feed struct {
+	mu       sync.Mutex
+	watchers map[chan feedEvent]bool
+}
+
+// watch returns a channel of the events from now on, and a function to
+// stop watching.
+func (f *feed) watch() (<-chan feedEvent, func()) {
+	ch := make(chan feedEvent, feedBuffer)
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.watchers == nil {
+		f.watchers = make(map[chan feedEvent]bool)
+	}
+	f.watchers[ch] = true
+	return ch, func() {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		delete(f.watchers, ch)
+	}
+}
+
+// pass hands an event to every watcher with room for it.
+func (f *feed) pass(e feedEvent) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for ch := range f.watchers {
+		select {
+		case ch <- e:
+		default:
+		}
+	}
+}
+
+// feedEvents subscribes the host's feed to every kind of event the world
+// publishes.
+func (m *mud) feedEvents() {
+	for _, kind := range feedKinds {
+		m.events.subscribe(kind, func(e event) { m.host.feed.pass(m.feedEvent(e)) })
+	}
+}
+
+// feedEvent returns an event from the bus as tools outside the server see
+// it.
+func (m *mud) feedEvent(e event) feedEvent {
+	fe := feedEvent{Kind: e.kind, World: m.name, Time: time.Now(), Amount: e.amount, Killer: e.killer}
+	if e.conn != nil {
+		fe.Player = e.conn.name
+	}
+	if e.npc != nil {
+		fe.NPC = e.npc.id
+	}
+	if e.victim != nil {
+		fe.Victim = e.victim.name
+	}
+	if e.room != nil {
+		fe.Room = e.room.id
+	}
+	if e.item != nil {
+		fe.Item = e.item.name
+	}
+	return fe
+}
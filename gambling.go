@@ -0,0 +1,255 @@
+package main
+
+import (
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// gameConfig holds the house settings for a minigame from the games data file.
+type gameConfig struct {
+	HouseEdge float64   `json:"houseEdge"`
+	MinBet    int       `json:"minBet"`
+	MaxBet    int       `json:"maxBet"`
+	Rooms     []roomRef `json:"rooms"`
+}
+
+// availableAt reports whether the game can be played in the given room.
+func (g *gameConfig) availableAt(r *room) bool {
+	for _, ref := range g.Rooms {
+		if ref.is(r) {
+			return true
+		}
+	}
+	return false
+}
+
+// wager is a bet placed on a minigame. Games that take more than one step
+// keep their progress in state.
+type wager struct {
+	game  string
+	bet   int
+	state int
+}
+
+// minigame is a gambling game played for gold.
+type minigame interface {
+	// play resolves a wager whose bet has already been taken from the player.
+	// It returns the total amount paid back, zero on a loss, and a
+	// description of what happened, in the player's locale. A game waiting
+	// on another decision returns pending and is called again with the
+	// player's next arguments.
+	play(c *connection, w *wager, args []string, edge float64) (payout int, msg string, pending bool)
+}
+
+// minigames maps game names to their implementations.
+var minigames = map[string]minigame{
+	"dice":    diceGame{},
+	"highlow": highLowGame{},
+	"slots":   slotGame{},
+}
+
+// edgePayout returns the total payout on a win that gives the house the
+// given edge, for a game won with probability win and pushed with
+// probability push. A win always pays at least a gold more than the bet.
+func edgePayout(bet int, edge, win, push float64) int {
+	payout := int(float64(bet) * ((1 - edge) - push) / win)
+	if payout <= bet {
+		payout = bet + 1
+	}
+	return payout
+}
+
+// diceGame rolls two dice for the player and two for the house. The higher
+// total wins and a tie returns the bet.
+type diceGame struct{}
+
+func (diceGame) play(c *connection, w *wager, args []string, edge float64) (int, string, bool) {
+	player := 2 + rand.Intn(6) + rand.Intn(6)
+	house := 2 + rand.Intn(6) + rand.Intn(6)
+	msg := c.tr("gamble.dice_roll", "player", player, "house", house)
+	switch {
+	case player > house:
+		return edgePayout(w.bet, edge, 575.0/1296, 146.0/1296), msg, false
+	case player == house:
+		return w.bet, msg + c.tr("gamble.push"), false
+	}
+	return 0, msg, false
+}
+
+// highLowGame deals a card, then pays if the player correctly guesses
+// whether the next card is higher or lower. Equal cards return the bet.
+type highLowGame struct{}
+
+// faceCards names the card ranks that aren't numbers, by catalog key.
+var faceCards = map[int]string{1: "gamble.ace", 11: "gamble.jack", 12: "gamble.queen", 13: "gamble.king"}
+
+// cardName names a card rank from 1 to 13 in the player's locale.
+func cardName(c *connection, rank int) string {
+	if key, ok := faceCards[rank]; ok {
+		return c.tr(key)
+	}
+	return strconv.Itoa(rank)
+}
+
+func (highLowGame) play(c *connection, w *wager, args []string, edge float64) (int, string, bool) {
+	if w.state == 0 {
+		// never an ace or king, which would leave a guess that can't lose
+		w.state = 2 + rand.Intn(11)
+		return 0, c.tr("gamble.dealer_shows", "card", cardName(c, w.state)), true
+	}
+	if len(args) == 0 || (args[0] != "higher" && args[0] != "lower") {
+		return 0, c.tr("gamble.guess_higher_lower", "card", cardName(c, w.state)), true
+	}
+
+	// count the ranks that win for this guess
+	wins := 13 - w.state
+	if args[0] == "lower" {
+		wins = w.state - 1
+	}
+	next := 1 + rand.Intn(13)
+	msg := c.tr("gamble.next_card", "card", cardName(c, next))
+	switch {
+	case next == w.state:
+		return w.bet, msg + c.tr("gamble.push"), false
+	case (next > w.state) == (args[0] == "higher"):
+		return edgePayout(w.bet, edge, float64(wins)/13, 1.0/13), msg, false
+	}
+	return 0, msg, false
+}
+
+// slotGame spins three reels. Three matching symbols pay by symbol, and two
+// cherries pay a small prize. The pay table is scaled to the house edge.
+type slotGame struct{}
+
+// slotSymbols lists the reel symbols, by the catalog key of their names,
+// and the relative multiplier for three of each.
+var slotSymbols = []struct {
+	name string
+	pays float64
+}{
+	{"gamble.cherry", 10},
+	{"gamble.lemon", 20},
+	{"gamble.bell", 40},
+	{"gamble.bar", 80},
+	{"gamble.seven", 200},
+}
+
+// slotReturn is the expected return of the unscaled pay table per unit bet.
+func slotReturn() float64 {
+	n := float64(len(slotSymbols))
+	total := 0.0
+	for _, s := range slotSymbols {
+		total += s.pays / (n * n * n)
+	}
+	// exactly two cherries
+	total += 2 * 3 * (n - 1) / (n * n * n)
+	return total
+}
+
+func (slotGame) play(c *connection, w *wager, args []string, edge float64) (int, string, bool) {
+	reels := make([]int, 3)
+	names := make([]string, 3)
+	cherries := 0
+	for i := range reels {
+		reels[i] = rand.Intn(len(slotSymbols))
+		names[i] = c.tr(slotSymbols[reels[i]].name)
+		if reels[i] == 0 {
+			cherries++
+		}
+	}
+	msg := c.tr("gamble.reels_show", "symbols", strings.Join(names, " | "))
+	scale := (1 - edge) / slotReturn()
+	switch {
+	case reels[0] == reels[1] && reels[1] == reels[2]:
+		return int(float64(w.bet) * slotSymbols[reels[0]].pays * scale), msg + c.tr("gamble.jackpot"), false
+	case cherries == 2:
+		return int(float64(w.bet) * 2 * scale), msg, false
+	}
+	return 0, msg, false
+}
+
+// play handles the play command: list the games in the room, place a bet,
+// or continue a game waiting on a decision.
+func (m *mud) play(c *connection, args []string) {
+	p := c.player
+	var here []string
+	for name, cfg := range m.games {
+		if cfg.availableAt(m.rooms[p.room]) {
+			here = append(here, name)
+		}
+	}
+	sort.Strings(here)
+	if len(args) == 0 {
+		if len(here) == 0 {
+			c.write(c.tr("gamble.no_games_play"))
+			return
+		}
+		c.write(c.tr("gamble.games_here_usage", "games", strings.Join(here, ", ")))
+		return
+	}
+
+	name := args[0]
+	game, ok := minigames[name]
+	cfg := m.games[name]
+	if !ok || cfg == nil || !cfg.availableAt(m.rooms[p.room]) {
+		c.write(c.tr("gamble.cant_play_here"))
+		return
+	}
+
+	// continue a game in progress
+	if p.wager != nil && p.wager.game == name {
+		m.settleWager(c, game, cfg, args[1:])
+		return
+	}
+	if p.wager != nil {
+		c.write(c.tr("gamble.finish_game_first", "game", p.wager.game))
+		return
+	}
+
+	// place a new bet
+	if len(args) < 2 {
+		c.write(c.tr("gamble.usage_play_bet", "name", name))
+		return
+	}
+	bet, err := strconv.Atoi(args[1])
+	if err != nil || bet < cfg.MinBet || (cfg.MaxBet > 0 && bet > cfg.MaxBet) {
+		c.write(c.tr("gamble.bets_must_between", "name", name, "min", cfg.MinBet, "max", cfg.MaxBet))
+		return
+	}
+	if bet > p.gold {
+		c.write(c.tr("gamble.dont_have_much"))
+		return
+	}
+	p.gold -= bet
+	p.wager = &wager{game: name, bet: bet}
+	m.settleWager(c, game, cfg, args[2:])
+}
+
+// settleWager plays the player's wager and pays out once the game finishes.
+func (m *mud) settleWager(c *connection, game minigame, cfg *gameConfig, args []string) {
+	p := c.player
+	w := p.wager
+	payout, msg, pending := game.play(c, w, args, cfg.HouseEdge)
+	c.write(msg + "\n")
+	if pending {
+		return
+	}
+	p.wager = nil
+	p.gold += payout
+	if payout > w.bet {
+		m.goldCreated("gambling", payout-w.bet)
+	} else {
+		m.goldDestroyed("gambling", w.bet-payout)
+	}
+	switch {
+	case payout > w.bet:
+		c.write(c.tr("gamble.win_gold", "winnings", payout-w.bet))
+	case payout == w.bet:
+		c.write(c.tr("gamble.get_bet_back"))
+	default:
+		c.write(c.tr("gamble.lose_gold", "losses", w.bet-payout))
+	}
+	m.events.publish(event{kind: eventGold, conn: c, amount: payout - w.bet})
+}
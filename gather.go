@@ -0,0 +1,122 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"time"
+)
+
+// gatherResource is an item a gathering skill can yield once the player's
+// skill reaches MinSkill.
+type gatherResource struct {
+	Item     string `json:"item"`
+	Weight   int    `json:"weight"`
+	MinSkill int    `json:"minSkill"`
+}
+
+// gatherSkill is a gathering profession such as fishing, usable in rooms
+// with the matching flag.
+type gatherSkill struct {
+	Skill     string           `json:"skill"`
+	Flag      string           `json:"flag"`
+	Duration  int              `json:"duration"`
+	Chance    float64          `json:"chance"`
+	Start     string           `json:"start"`
+	Failure   string           `json:"failure"`
+	Resources []gatherResource `json:"resources"`
+}
+
+// maxSkill is the highest level a skill can reach.
+const maxSkill = 100
+
+// gather starts a timed gathering attempt with the skill for the given
+// command. The attempt resolves on the tick after the skill's duration.
+func (m *mud) gather(c *connection, verb string) {
+	g := m.gatherSkills[verb]
+	p := c.player
+	r := m.rooms[p.room]
+	if r == nil || !r.flags[g.Flag] {
+		c.write(c.tr("gather.cant_here", "verb", verb))
+		return
+	}
+	if p.gathering != nil {
+		c.write(c.tr("gather.already_busy"))
+		return
+	}
+
+	c.write(g.Start + "\n")
+	at := p.room
+	p.gathering = m.scheduler.after(fmt.Sprintf("gather: %s %s", c.name, verb), time.Duration(g.Duration)*time.Second, func() {
+		if c.world.Load() != m {
+			// the player has gone to another world, which has them now
+			return
+		}
+		p.gathering = nil
+		if c.state != statePlaying {
+			return
+		}
+		if p.room != at {
+			c.write(c.tr("gather.give_up_trying", "verb", verb))
+			return
+		}
+		m.finishGathering(c, g)
+		c.writePrompt()
+	})
+}
+
+// finishGathering resolves a gathering attempt, awarding a resource and a
+// chance to improve the skill on success.
+func (m *mud) finishGathering(c *connection, g *gatherSkill) {
+	p := c.player
+	skill := p.skills[g.Skill]
+	if rand.Float64() >= g.Chance+float64(skill)/200 {
+		c.write(g.Failure + "\n")
+		return
+	}
+
+	// pick a resource the player is skilled enough to find
+	total := 0
+	for _, res := range g.Resources {
+		if skill >= res.MinSkill {
+			total += res.Weight
+		}
+	}
+	if total == 0 {
+		c.write(g.Failure + "\n")
+		return
+	}
+	n := rand.Intn(total)
+	for _, res := range g.Resources {
+		if skill < res.MinSkill {
+			continue
+		}
+		if n < res.Weight {
+			if it := m.spawnItem(res.Item); it != nil {
+				p.inventory = append(p.inventory, it)
+				c.write(c.tr("gather.find", "item", it.displayName(c)))
+			}
+			break
+		}
+		n -= res.Weight
+	}
+	m.improveSkill(c, g.Skill)
+}
+
+// showSkills lists the player's skill levels.
+func (m *mud) showSkills(c *connection) {
+	p := c.player
+	c.write(c.tr("skills.skills"))
+	if len(p.skills) == 0 {
+		c.write(c.tr("common.none"))
+		return
+	}
+	names := make([]string, 0, len(p.skills))
+	for name := range p.skills {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		c.write(c.tableRow("  %-12s %3d\n", nil, name, p.skills[name]))
+	}
+}
@@ -0,0 +1,372 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"os"
+	"strings"
+	"time"
+)
+
+// country policies, which the config sets for countries by ISO code
+const (
+	// policyDeny refuses connections from the country
+	policyDeny = "deny"
+	// policyNoNew lets existing characters in but refuses new ones
+	policyNoNew = "no-new"
+	// policyWatch lets everyone in but flags their logins to staff
+	policyWatch = "watch"
+)
+
+// mmdbMetadataMarker begins the metadata at the end of a MaxMind DB file.
+var mmdbMetadataMarker = []byte("\xab\xcd\xefMaxMind.com")
+
+// mmdbMaxDepth is how deeply values in a MaxMind DB may nest.
+const mmdbMaxDepth = 32
+
+// geoInfo is where a connection comes from, as far as the GeoIP database
+// knows.
+type geoInfo struct {
+	Country     string `json:"country,omitempty"`
+	CountryName string `json:"countryName,omitempty"`
+	City        string `json:"city,omitempty"`
+}
+
+// String describes the location for staff, such as "Berlin, Germany".
+func (g geoInfo) String() string {
+	place := g.CountryName
+	if place == "" {
+		place = g.Country
+	}
+	if g.City != "" && place != "" {
+		place = g.City + ", " + place
+	}
+	if place == "" {
+		return "unknown"
+	}
+	return place
+}
+
+// geoDB is a GeoIP database in the MaxMind DB format, such as GeoLite2
+// Country or City, read whole into memory. It is only ever read, so the
+// host's worlds share one.
+type geoDB struct {
+	data       []byte
+	nodeCount  uint
+	recordSize uint
+	ipVersion  uint
+	dataStart  uint
+	ipv4Start  uint
+}
+
+// openGeoDB reads the MaxMind DB file at the path.
+func openGeoDB(path string) (*geoDB, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	at := bytes.LastIndex(data, mmdbMetadataMarker)
+	if at < 0 {
+		return nil, errors.New("not a MaxMind DB file")
+	}
+	meta := data[at+len(mmdbMetadataMarker):]
+	v, _, err := decodeMMDB(meta, 0, 0)
+	if err != nil {
+		return nil, fmt.Errorf("bad metadata: %v", err)
+	}
+	fields, _ := v.(map[string]interface{})
+	db := &geoDB{
+		data:       data,
+		nodeCount:  mmdbUint(fields["node_count"]),
+		recordSize: mmdbUint(fields["record_size"]),
+		ipVersion:  mmdbUint(fields["ip_version"]),
+	}
+	switch db.recordSize {
+	case 24, 28, 32:
+	default:
+		return nil, fmt.Errorf("unsupported record size %d", db.recordSize)
+	}
+	db.dataStart = db.nodeCount*db.recordSize/4 + 16
+	if db.dataStart > uint(at) {
+		return nil, errors.New("the search tree runs past the end of the file")
+	}
+	// IPv4 addresses live 96 zero bits down an IPv6 tree
+	if db.ipVersion == 6 {
+		for i := 0; i < 96 && db.ipv4Start < db.nodeCount; i++ {
+			db.ipv4Start = db.record(db.ipv4Start, 0)
+		}
+	}
+	return db, nil
+}
+
+// record returns the left (0) or right (1) record of a search tree node.
+func (db *geoDB) record(node, bit uint) uint {
+	b := db.data[node*db.recordSize/4:]
+	switch db.recordSize {
+	case 24:
+		b = b[bit*3:]
+		return uint(b[0])<<16 | uint(b[1])<<8 | uint(b[2])
+	case 28:
+		if bit == 0 {
+			return uint(b[3]&0xf0)<<20 | uint(b[0])<<16 | uint(b[1])<<8 | uint(b[2])
+		}
+		return uint(b[3]&0x0f)<<24 | uint(b[4])<<16 | uint(b[5])<<8 | uint(b[6])
+	}
+	return uint(binary.BigEndian.Uint32(b[bit*4:]))
+}
+
+// lookup returns where the address is, or nothing if the database doesn't
+// know.
+func (db *geoDB) lookup(ip net.IP) geoInfo {
+	if db == nil || ip == nil {
+		return geoInfo{}
+	}
+	addr, node := ip.To4(), db.ipv4Start
+	if addr == nil {
+		if db.ipVersion != 6 {
+			return geoInfo{}
+		}
+		addr, node = ip.To16(), 0
+	}
+	for i := 0; i < len(addr)*8 && node < db.nodeCount; i++ {
+		bit := uint(addr[i/8]>>(7-uint(i%8))) & 1
+		node = db.record(node, bit)
+	}
+	if node < db.nodeCount+16 {
+		return geoInfo{}
+	}
+	v, _, err := decodeMMDB(db.data[db.dataStart:], node-db.nodeCount-16, 0)
+	if err != nil {
+		return geoInfo{}
+	}
+	rec, _ := v.(map[string]interface{})
+	country := mmdbMap(rec, "country")
+	if country == nil {
+		country = mmdbMap(rec, "registered_country")
+	}
+	g := geoInfo{}
+	g.Country, _ = country["iso_code"].(string)
+	g.CountryName, _ = mmdbMap(country, "names")["en"].(string)
+	g.City, _ = mmdbMap(mmdbMap(rec, "city"), "names")["en"].(string)
+	return g
+}
+
+// mmdbMap returns the map under the key, or nil.
+func mmdbMap(m map[string]interface{}, key string) map[string]interface{} {
+	v, _ := m[key].(map[string]interface{})
+	return v
+}
+
+// mmdbUint returns a decoded unsigned number, or 0 for anything else.
+func mmdbUint(v interface{}) uint {
+	n, _ := v.(uint64)
+	return uint(n)
+}
+
+// decodeMMDB decodes the value at the offset in a MaxMind DB data section,
+// returning it and the offset of what follows. Maps decode as
+// map[string]interface{}, arrays as []interface{}, numbers as uint64,
+// int64, or float64, and the rest as string, []byte, or bool. depth is how
+// deeply nested the value is, so that a damaged file can't recurse forever.
+func decodeMMDB(data []byte, offset uint, depth int) (interface{}, uint, error) {
+	if depth > mmdbMaxDepth {
+		return nil, 0, errors.New("data nested too deeply")
+	}
+	errShort := errors.New("data runs past the end of the section")
+	next := func(n uint) ([]byte, error) {
+		if offset+n > uint(len(data)) {
+			return nil, errShort
+		}
+		b := data[offset : offset+n]
+		offset += n
+		return b, nil
+	}
+	ctrl, err := next(1)
+	if err != nil {
+		return nil, 0, err
+	}
+	kind := uint(ctrl[0] >> 5)
+	if kind == 1 {
+		// a pointer to a value elsewhere in the section
+		size := uint(ctrl[0]>>3) & 3
+		b, err := next(size + 1)
+		if err != nil {
+			return nil, 0, err
+		}
+		target := uint(ctrl[0] & 7)
+		if size == 3 {
+			target = 0
+		}
+		for _, x := range b {
+			target = target<<8 | uint(x)
+		}
+		target += [4]uint{0, 2048, 526336, 0}[size]
+		v, _, err := decodeMMDB(data, target, depth+1)
+		return v, offset, err
+	}
+	if kind == 0 {
+		b, err := next(1)
+		if err != nil {
+			return nil, 0, err
+		}
+		kind = 7 + uint(b[0])
+	}
+	size := uint(ctrl[0] & 0x1f)
+	if size >= 29 {
+		b, err := next(size - 28)
+		if err != nil {
+			return nil, 0, err
+		}
+		n := uint(0)
+		for _, x := range b {
+			n = n<<8 | uint(x)
+		}
+		size = n + [3]uint{29, 285, 65821}[size-29]
+	}
+
+	switch kind {
+	case 7: // map
+		m := make(map[string]interface{})
+		for i := uint(0); i < size; i++ {
+			k, after, err := decodeMMDB(data, offset, depth+1)
+			if err != nil {
+				return nil, 0, err
+			}
+			v, after, err := decodeMMDB(data, after, depth+1)
+			if err != nil {
+				return nil, 0, err
+			}
+			key, _ := k.(string)
+			m[key], offset = v, after
+		}
+		return m, offset, nil
+	case 11: // array
+		var a []interface{}
+		for i := uint(0); i < size; i++ {
+			v, after, err := decodeMMDB(data, offset, depth+1)
+			if err != nil {
+				return nil, 0, err
+			}
+			a, offset = append(a, v), after
+		}
+		return a, offset, nil
+	case 14: // boolean, held in the size
+		return size != 0, offset, nil
+	}
+	b, err := next(size)
+	if err != nil {
+		return nil, 0, err
+	}
+	switch kind {
+	case 2:
+		return string(b), offset, nil
+	case 3:
+		if size != 8 {
+			return nil, 0, errors.New("bad double")
+		}
+		return math.Float64frombits(binary.BigEndian.Uint64(b)), offset, nil
+	case 15:
+		if size != 4 {
+			return nil, 0, errors.New("bad float")
+		}
+		return float64(math.Float32frombits(binary.BigEndian.Uint32(b))), offset, nil
+	case 4, 10:
+		// bytes, and 128-bit numbers, which nothing here needs as numbers
+		return b, offset, nil
+	case 5, 6, 9:
+		n := uint64(0)
+		for _, x := range b {
+			n = n<<8 | uint64(x)
+		}
+		return n, offset, nil
+	case 8:
+		n := int32(0)
+		for _, x := range b {
+			n = n<<8 | int32(x)
+		}
+		return int64(n), offset, nil
+	}
+	return nil, 0, fmt.Errorf("unknown data type %d", kind)
+}
+
+// parseCountryPolicies checks the config's country policies, returning
+// them keyed by upper-case ISO code.
+func parseCountryPolicies(policies map[string]string) (map[string]string, error) {
+	parsed := make(map[string]string, len(policies))
+	for code, policy := range policies {
+		switch policy {
+		case policyDeny, policyNoNew, policyWatch:
+		default:
+			return nil, fmt.Errorf("unknown policy %q for %s", policy, code)
+		}
+		parsed[strings.ToUpper(code)] = policy
+	}
+	return parsed, nil
+}
+
+// countryPolicy returns the policy for connections from where the
+// connection is, or "" if there is none.
+func (m *mud) countryPolicy(c *connection) string {
+	if c.geo.Country == "" {
+		return ""
+	}
+	return m.countryPolicies[c.geo.Country]
+}
+
+// whereFrom describes for staff where a connection comes from.
+func (c *connection) whereFrom() string {
+	return fmt.Sprintf("%s (%s)", c.ip(), c.geo)
+}
+
+// notifyLogin tells staff that a character has logged in, and from where,
+// flagging those from watched countries.
+func (m *mud) notifyLogin(c *connection, created bool) {
+	key := "login.staff_logged_in"
+	if created {
+		key = "login.staff_created"
+	}
+	watched := m.countryPolicy(c) == policyWatch
+	m.send(staff().except(c), rendered((*connection).locale, func(conn *connection) string {
+		flag := ""
+		if watched {
+			flag = conn.tr("login.staff_watched")
+		}
+		return conn.tr(key, "name", c.name, "from", c.whereFrom(), "flag", flag)
+	}).asAside(nil))
+}
+
+// userlist shows staff every connection to the world, with where it comes
+// from and how long it has been connected.
+func (m *mud) userlist(c *connection) {
+	if !c.player.admin {
+		c.write(c.tr("command.unknown"))
+		return
+	}
+	seen := make(map[*connection]bool)
+	var conns []*connection
+	for _, key := range sortedKeys(m.conns) {
+		if cc := m.conns[key]; !seen[cc] {
+			seen[cc] = true
+			conns = append(conns, cc)
+		}
+	}
+	if m.host.geoip == nil {
+		c.write(c.tr("users.no_geoip_database"))
+	}
+	for _, cc := range conns {
+		name := cc.name
+		if cc.state != statePlaying {
+			name = "(logging in)"
+		}
+		flag := ""
+		if policy := m.countryPolicy(cc); policy != "" {
+			flag = " [" + policy + "]"
+		}
+		c.write(c.tableRow("  %-14s %-24s %-28s on for %s\n", []string{"", "from", "in", ""},
+			name, cc.conn.RemoteAddr(), cc.geo.String()+flag, formatDuration(time.Since(cc.connectedAt))))
+	}
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"strings"
+)
+
+// party is a group of players adventuring together, led by the player who
+// formed it. Members in the same room join in each other's fights.
+type party struct {
+	leader  *connection
+	members []*connection
+}
+
+// groupCommand shows the player's group, invites someone to it, or
+// accepts an invitation or leaves.
+func (m *mud) groupCommand(c *connection, args []string) {
+	if len(args) == 0 {
+		m.showGroup(c)
+		return
+	}
+	switch strings.ToLower(args[0]) {
+	case "accept":
+		m.joinGroup(c)
+	case "leave":
+		if c.player.party == nil {
+			c.write(c.tr("group.arent_group"))
+			return
+		}
+		m.leaveGroup(c)
+		c.write(c.tr("group.leave_group"))
+	default:
+		m.inviteToGroup(c, args[0])
+	}
+}
+
+// showGroup lists the members of the player's group and their health.
+func (m *mud) showGroup(c *connection) {
+	g := c.player.party
+	if g == nil {
+		c.write(c.tr("group.arent_group_use"))
+		return
+	}
+	c.write(c.tr("group.group"))
+	for _, member := range g.members {
+		name := capitalize(member.nameFor(c))
+		if member == g.leader {
+			name += " (leader)"
+		}
+		p := member.player
+		c.write(c.tableRow("  %-20s %d/%d\n", []string{"", "Health"}, name, p.health, p.totalMaxHealth()))
+	}
+}
+
+// inviteToGroup asks another player to join the player's group, forming
+// one with the player as leader if they aren't in one.
+func (m *mud) inviteToGroup(c *connection, name string) {
+	target, ok := m.conns[nameKey(name)]
+	if !ok || target.state != statePlaying {
+		c.write(c.tr("group.they_arent_playing"))
+		return
+	}
+	if target == c {
+		c.write(c.tr("group.cant_group_yourself"))
+		return
+	}
+	if target.player.party != nil {
+		c.write(c.tr("group.other_in_group", "name", capitalize(target.nameFor(c))))
+		return
+	}
+	members := []*connection{c}
+	if c.player.party != nil {
+		members = c.player.party.members
+	}
+	if m.sameAddressMember(target, members) != nil {
+		c.write(c.tr("group.playing_same_address", "name", capitalize(target.nameFor(c))))
+		return
+	}
+	g := c.player.party
+	if g == nil {
+		g = &party{leader: c, members: []*connection{c}}
+		c.player.party = g
+	} else if g.leader != c {
+		c.write(c.tr("group.only_groups_leader"))
+		return
+	}
+	target.player.invite = g
+	c.write(c.tr("group.invite_join_group", "name", capitalize(target.nameFor(c))))
+	m.act(toVictim, "group.invited", playerActor(c), playerActor(target), "")
+}
+
+// joinGroup accepts the player's latest invitation to a group.
+func (m *mud) joinGroup(c *connection) {
+	p := c.player
+	g := p.invite
+	if g == nil {
+		c.write(c.tr("group.nobody_has_invited"))
+		return
+	}
+	p.invite = nil
+	if p.party != nil {
+		c.write(c.tr("group.already_in"))
+		return
+	}
+	if len(g.members) == 0 {
+		c.write(c.tr("group.group_has_broken"))
+		return
+	}
+	if other := m.sameAddressMember(c, g.members); other != nil {
+		c.write(c.tr("group.cant_join_group", "name", capitalize(other.nameFor(c))))
+		return
+	}
+	m.actTo(inGroup(g), "group.joins", playerActor(c), actor{}, nil, "")
+	g.members = append(g.members, c)
+	p.party = g
+	c.write(c.tr("group.join_group", "name", possessive(capitalize(g.leader.nameFor(c)))))
+}
+
+// leaveGroup takes the player out of their group. Someone else takes the
+// lead if the leader leaves, and a group left with one member breaks up.
+func (m *mud) leaveGroup(c *connection) {
+	g := c.player.party
+	if g == nil {
+		return
+	}
+	c.player.party = nil
+	for i, member := range g.members {
+		if member == c {
+			g.members = append(g.members[:i], g.members[i+1:]...)
+			break
+		}
+	}
+	m.actTo(inGroup(g), "group.left", playerActor(c), actor{}, nil, "")
+	if len(g.members) == 1 {
+		last := g.members[0]
+		last.player.party = nil
+		g.members = nil
+		last.aside(last.tr("group.broken_up"))
+		return
+	}
+	if g.leader == c {
+		g.leader = g.members[0]
+		leader := g.leader
+		m.send(inGroup(g), rendered(seenBy(leader), func(conn *connection) string {
+			return conn.tr("group.now_leads", "name", capitalize(leader.nameFor(conn)))
+		}).asAside(nil))
+	}
+}
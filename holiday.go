@@ -0,0 +1,282 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// holidayCheck is how often the calendar is checked for holidays starting
+// and ending.
+const holidayCheck = time.Minute
+
+// holiday is a festival on the calendar, as defined in the holiday data
+// file. From its Start date to its End date, both given as MM-DD and
+// taken in the server's time zone, the listed rooms are decorated, its
+// NPCs come to the mall, its quests are offered, and everyone who plays
+// is given its gifts once a year.
+type holiday struct {
+	ID     string        `json:"id"`
+	Name   string        `json:"name"`
+	Start  string        `json:"start"`
+	End    string        `json:"end"`
+	Begins string        `json:"begins"`
+	Ends   string        `json:"ends"`
+	Rooms  []holidayRoom `json:"rooms"`
+	NPCs   []holidayNPC  `json:"npcs"`
+	Gifts  []string      `json:"gifts"`
+}
+
+// holidayRoom is a room decorated for a holiday, and how it looks while
+// the holiday lasts.
+type holidayRoom struct {
+	Room        roomRef `json:"room"`
+	Description string  `json:"description"`
+}
+
+// holidayNPC is an NPC who comes for a holiday, and where they stay.
+type holidayNPC struct {
+	NPC  string  `json:"npc"`
+	Room roomRef `json:"room"`
+}
+
+// activeHoliday is a holiday under way, with the NPCs it brought.
+type activeHoliday struct {
+	def     *holiday
+	started time.Time
+	spawned []*npc
+}
+
+// findHoliday returns the holiday with the given ID.
+func (m *mud) findHoliday(id string) *holiday {
+	for _, h := range m.holidays {
+		if h.ID == id {
+			return h
+		}
+	}
+	return nil
+}
+
+// calendarDate reads a month and day given as MM-DD.
+func calendarDate(s string) (time.Month, int, error) {
+	t, err := time.Parse("01-02", s)
+	if err != nil {
+		return 0, 0, err
+	}
+	return t.Month(), t.Day(), nil
+}
+
+// when returns the start and end of the holiday's latest run at or before
+// now; it is on if now falls before the end. A holiday whose end comes
+// before its start in the year runs over the new year.
+func (h *holiday) when(now time.Time) (start, end time.Time, ok bool) {
+	sm, sd, err := calendarDate(h.Start)
+	if err != nil {
+		return
+	}
+	em, ed, err := calendarDate(h.End)
+	if err != nil {
+		return
+	}
+	start = time.Date(now.Year(), sm, sd, 0, 0, 0, 0, now.Location())
+	if start.After(now) {
+		start = start.AddDate(-1, 0, 0)
+	}
+	// the end date is the last whole day of the holiday
+	end = time.Date(start.Year(), em, ed, 0, 0, 0, 0, now.Location()).AddDate(0, 0, 1)
+	if !end.After(start) {
+		end = end.AddDate(1, 0, 0)
+	}
+	return start, end, true
+}
+
+// on reports whether the holiday is running at the given time.
+func (h *holiday) on(now time.Time) bool {
+	_, end, ok := h.when(now)
+	return ok && now.Before(end)
+}
+
+// updateHolidays starts the holidays the calendar has reached and ends
+// those that are over. NPCs a holiday brought who have been killed or
+// paged out come back.
+func (m *mud) updateHolidays() {
+	now := time.Now()
+	for _, h := range m.holidays {
+		a, active := m.activeHolidays[h.ID]
+		switch {
+		case h.on(now) && !active:
+			m.startHoliday(h, now)
+		case !h.on(now) && active:
+			m.endHoliday(h)
+		case active:
+			m.spawnHolidayNPCs(a)
+		}
+	}
+}
+
+// startHoliday decorates the mall for a holiday, brings its NPCs, and
+// hands out its gifts to everyone playing.
+func (m *mud) startHoliday(h *holiday, now time.Time) {
+	start, _, _ := h.when(now)
+	a := &activeHoliday{def: h, started: start}
+	m.activeHolidays[h.ID] = a
+	if h.Begins != "" {
+		m.announceNow(h.Begins)
+	}
+	m.spawnHolidayNPCs(a)
+	for _, c := range m.conns {
+		if c.state == statePlaying {
+			m.holidayGifts(c)
+		}
+	}
+}
+
+// spawnHolidayNPCs brings each of the holiday's NPCs who isn't already in
+// their room.
+func (m *mud) spawnHolidayNPCs(a *activeHoliday) {
+	var kept []*npc
+	for _, hn := range a.def.NPCs {
+		t, ok := m.npcTemplates[hn.NPC]
+		r := m.roomFor(hn.Room)
+		if !ok || r == nil {
+			continue
+		}
+		var n *npc
+		for _, s := range a.spawned {
+			if s.id == t.ID && r.hasNPC(s) {
+				n = s
+				break
+			}
+		}
+		if n == nil {
+			n = newNPC(t)
+			r.npcs = append(r.npcs, n)
+			m.send(inRoom(r.id), rendered((*connection).locale, func(conn *connection) string {
+				return conn.tr("event.arrives", "name", capitalize(t.Name))
+			}).asAside(nil))
+		}
+		kept = append(kept, n)
+	}
+	a.spawned = kept
+}
+
+// endHoliday takes down a holiday's decorations and sends its NPCs home.
+func (m *mud) endHoliday(h *holiday) {
+	a := m.activeHolidays[h.ID]
+	delete(m.activeHolidays, h.ID)
+	for _, n := range a.spawned {
+		for _, r := range m.rooms {
+			r.removeNPC(n)
+		}
+	}
+	if h.Ends != "" {
+		m.announceNow(h.Ends)
+	}
+}
+
+// holidayActive reports whether the holiday with the given ID is running.
+func (m *mud) holidayActive(id string) bool {
+	_, ok := m.activeHolidays[id]
+	return ok
+}
+
+// roomDescription returns how a room looks, decorated for any holiday
+// under way.
+func (m *mud) roomDescription(r *room) string {
+	for _, h := range m.holidays {
+		if !m.holidayActive(h.ID) {
+			continue
+		}
+		for _, hr := range h.Rooms {
+			if hr.Room.is(r) {
+				return hr.Description
+			}
+		}
+	}
+	return r.description
+}
+
+// holidayGifts gives the player the gifts of each holiday under way that
+// they haven't had this year.
+func (m *mud) holidayGifts(c *connection) {
+	p := c.player
+	given := false
+	for _, h := range m.holidays {
+		a, ok := m.activeHolidays[h.ID]
+		if !ok || len(h.Gifts) == 0 || p.holidayGifts[h.ID] >= a.started.Year() {
+			continue
+		}
+		p.holidayGifts[h.ID] = a.started.Year()
+		given = true
+		for _, id := range h.Gifts {
+			t, ok := m.itemTemplates[id]
+			if !ok {
+				continue
+			}
+			it := newItem(t)
+			p.pickUp(it)
+			c.aside(c.tr("holidays.gift", "item", it.displayName(c), "holiday", h.Name))
+		}
+	}
+	if given {
+		m.savePlayer(c)
+	}
+}
+
+// giveHolidayGifts hands out holiday gifts to a player as they log in.
+func (m *mud) giveHolidayGifts(e event) {
+	m.holidayGifts(e.conn)
+}
+
+// holidaysCommand shows the holidays on the calendar, and which are on.
+func (m *mud) holidaysCommand(c *connection) {
+	if len(m.holidays) == 0 {
+		c.write(c.tr("holidays.no_holidays_calendar"))
+		return
+	}
+	c.write(c.tr("holidays.holidays"))
+	for _, h := range m.holidays {
+		status := ""
+		if m.holidayActive(h.ID) {
+			status = "on now"
+		}
+		c.write(c.tableRow("  %-24s %s to %s  %s\n", nil, capitalize(h.Name), h.Start, h.End, status))
+	}
+}
+
+// checkHolidays reports holidays with dates that can't be read, and those
+// naming rooms, NPCs, or gifts that don't exist, as well as quests held
+// for holidays that aren't on the calendar.
+func (m *mud) checkHolidays() []string {
+	var problems []string
+	for _, h := range m.holidays {
+		for _, date := range []string{h.Start, h.End} {
+			if _, _, err := calendarDate(date); err != nil {
+				problems = append(problems, fmt.Sprintf("holiday %s has a bad date %q, want MM-DD", h.ID, date))
+			}
+		}
+		for _, hr := range h.Rooms {
+			if m.roomFor(hr.Room) == nil {
+				problems = append(problems, fmt.Sprintf("holiday %s decorates missing room %v", h.ID, hr.Room))
+			}
+		}
+		for _, hn := range h.NPCs {
+			if _, ok := m.npcTemplates[hn.NPC]; !ok {
+				problems = append(problems, fmt.Sprintf("holiday %s brings missing NPC %s", h.ID, hn.NPC))
+			}
+			if m.roomFor(hn.Room) == nil {
+				problems = append(problems, fmt.Sprintf("holiday %s brings %s to missing room %v", h.ID, hn.NPC, hn.Room))
+			}
+		}
+		for _, id := range h.Gifts {
+			if _, ok := m.itemTemplates[id]; !ok {
+				problems = append(problems, fmt.Sprintf("holiday %s gives missing item %s", h.ID, id))
+			}
+		}
+	}
+	for _, q := range m.quests {
+		if q.Holiday != "" && m.findHoliday(q.Holiday) == nil {
+			problems = append(problems, fmt.Sprintf("quest %s is held for missing holiday %s", q.ID, q.Holiday))
+		}
+	}
+	return problems
+}
@@ -0,0 +1,421 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// worldConfig is one of the worlds a server hosts: the address it listens
+// on, the directory its data is read from, the directory its state is
+// saved to, and optionally a world file to build it from instead of the
+// mall. Every world shares the character store.
+type worldConfig struct {
+	Name      string `json:"name"`
+	Addr      string `json:"addr"`
+	Data      string `json:"data"`
+	State     string `json:"state"`
+	WorldFile string `json:"worldFile,omitempty"`
+
+	// JSONAddr, if set, is a second address for the world that speaks
+	// the JSON-line protocol bots use from the moment a client connects.
+	JSONAddr string `json:"jsonAddr,omitempty"`
+
+	// StartRooms, if set, are where the world's players start, ahead of
+	// any its world file and areas give.
+	StartRooms []startRule `json:"startRooms,omitempty"`
+}
+
+// defaultWorld is the world a server hosts when the config lists none.
+var defaultWorld = worldConfig{Name: "mall", Addr: "localhost:8080", Data: "data", State: "world"}
+
+// host is the set of worlds one server runs. It knows which world each
+// character is playing in, so that a character is only ever in one.
+type host struct {
+	mu      sync.Mutex
+	worlds  []*mud
+	playing map[string]*mud
+
+	// addrs is the address each character playing comes from
+	addrs map[string]string
+
+	// geoip, if the config names a database, places connections
+	geoip *geoDB
+
+	// feed passes the worlds' events on to outside tools
+	feed feed
+}
+
+// newHost creates a host with no worlds yet.
+func newHost() *host {
+	return &host{playing: make(map[string]*mud), addrs: make(map[string]string)}
+}
+
+// world returns the named world, or nil if the host has none by that name.
+func (h *host) world(name string) *mud {
+	for _, w := range h.worlds {
+		if strings.EqualFold(w.name, name) {
+			return w
+		}
+	}
+	return nil
+}
+
+// claim reserves a character name for a connection in the given world,
+// reporting false if it is already taken, in any case, here or in another.
+func (h *host) claim(name string, m *mud) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	key := strings.ToLower(name)
+	if _, ok := h.playing[key]; ok {
+		return false
+	}
+	h.playing[key] = m
+	return true
+}
+
+// release frees a character name the given world had claimed.
+func (h *host) release(name string, m *mud) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	key := strings.ToLower(name)
+	if h.playing[key] == m {
+		delete(h.playing, key)
+		delete(h.addrs, key)
+	}
+}
+
+// playingIn returns the world the named character is in, or nil if they
+// aren't in any.
+func (h *host) playingIn(name string) *mud {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.playing[strings.ToLower(name)]
+}
+
+// busyElsewhere reports whether anyone is in a world other than the given
+// one.
+func (h *host) busyElsewhere(m *mud) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, w := range h.playing {
+		if w != m {
+			return true
+		}
+	}
+	return false
+}
+
+// statePath returns the path of a file in the world's state directory.
+func (m *mud) statePath(name string) string {
+	return filepath.Join(m.stateDir, name)
+}
+
+// locked runs fn while holding the world's lock, for work handed over
+// from another world.
+func (m *mud) locked(fn func()) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	fn()
+	m.flushOutput()
+}
+
+// everyWorld runs fn for this world now, and for each of the host's other
+// worlds as soon as it can take their lock.
+func (m *mud) everyWorld(fn func(w *mud)) {
+	fn(m)
+	for _, w := range m.host.worlds {
+		if w != m {
+			w := w
+			go w.locked(func() { fn(w) })
+		}
+	}
+}
+
+// forget drops a departing connection from the world and frees its name,
+// unless another connection here has since taken it, letting in whoever
+// is waiting for its place.
+func (m *mud) forget(c *connection) {
+	m.leaveLine(c)
+	for _, key := range []string{nameKey(c.name), c.conn.RemoteAddr().String()} {
+		if m.conns[key] == c {
+			delete(m.conns, key)
+		}
+	}
+	if _, ok := m.conns[nameKey(c.name)]; !ok {
+		m.host.release(c.name, m)
+	}
+	m.admitWaiting()
+}
+
+// worldPlace is where a character stands, is bound, and has explored in a
+// world other than the one they are in.
+type worldPlace struct {
+	Room     string   `json:"room"`
+	HomeRoom string   `json:"homeRoom,omitempty"`
+	Visited  []string `json:"visited,omitempty"`
+}
+
+// enterWorld moves the player's place to the named world, putting away
+// where they were in the last one. Characters saved before there were
+// several worlds were in the first.
+func (p *player) enterWorld(h *host, name string) {
+	if p.world == "" && len(h.worlds) > 0 {
+		p.world = h.worlds[0].name
+	}
+	if p.world == name {
+		return
+	}
+	if p.world != "" {
+		place := worldPlace{Room: p.room, HomeRoom: p.homeRoom}
+		for id := range p.visited {
+			place.Visited = append(place.Visited, id)
+		}
+		sort.Strings(place.Visited)
+		p.elsewhere[p.world] = place
+	}
+	p.world = name
+	// locate puts a newcomer to the world in their start room
+	p.room, p.homeRoom = "", ""
+	p.visited = make(map[string]bool)
+	if place, ok := p.elsewhere[name]; ok {
+		p.room, p.homeRoom = place.Room, place.HomeRoom
+		for _, id := range place.Visited {
+			p.visited[id] = true
+		}
+		delete(p.elsewhere, name)
+	}
+}
+
+// portal lists the worlds the server hosts, or steps the player through to
+// another of them.
+func (m *mud) portal(c *connection, args []string) {
+	p := c.player
+	if len(args) == 0 {
+		c.write(c.tr("portal.portals_lead_these"))
+		for _, w := range m.host.worlds {
+			here := ""
+			if w == m {
+				here = " (you are here)"
+			}
+			c.write(fmt.Sprintf("  %s%s\n", w.name, here))
+		}
+		return
+	}
+	dest := m.host.world(args[0])
+	switch {
+	case dest == nil:
+		c.write(c.tr("portal.no_portal_leads"))
+		return
+	case dest == m:
+		c.write(c.tr("common.already_there"))
+		return
+	case p.fighting != nil || m.inFight(c):
+		c.write(c.tr("portal.cant_step_through"))
+		return
+	case p.jailTask != nil:
+		c.write(c.tr("portal.mall_security_isnt"))
+		return
+	case !m.requireStanding(c):
+		return
+	}
+
+	// leave this world much as if quitting, keeping hold of the name
+	c.write(c.tr("portal.step_through_shimmering", "world", dest.name))
+	c.clearQueue()
+	m.savePlayer(c)
+	rec := p.record(c.name)
+	m.stashFollowers(c)
+	p.followers, p.followerRecords = nil, rec.Followers
+	m.leaveGroup(c)
+	m.cancelPlayerTasks(c)
+	m.tellOthers(c, "portal.step_through_others")
+	delete(m.conns, nameKey(c.name))
+	c.state = stateTravelling
+	m.admitWaiting()
+
+	// the connection now belongs to the other world, which takes over once
+	// it can; nothing here may touch it after this
+	m.host.mu.Lock()
+	m.host.playing[strings.ToLower(c.name)] = dest
+	m.host.mu.Unlock()
+	c.world.Store(dest)
+	go dest.locked(func() { dest.arrive(c) })
+}
+
+// cancelPlayerTasks cancels the tasks this world's scheduler has waiting
+// to run for the player: gathering, cutscenes, and scripts that paused.
+func (m *mud) cancelPlayerTasks(c *connection) {
+	p := c.player
+	if p.gathering != nil {
+		m.scheduler.cancel(p.gathering.id)
+		p.gathering = nil
+	}
+	m.stopCutscene(c)
+	for _, t := range c.scriptWaits {
+		m.scheduler.cancel(t.id)
+	}
+	c.scriptWaits = nil
+}
+
+// arrive brings a player stepping through a portal into the world.
+func (m *mud) arrive(c *connection) {
+	if c.state != stateTravelling {
+		return
+	}
+	if _, ok := m.conns[nameKey(c.name)]; ok {
+		// cannot happen while the host holds the name, but don't clobber
+		log.Printf("%s arrived in %s, where they were already playing", c.name, m.name)
+		c.close()
+		return
+	}
+	m.conns[nameKey(c.name)] = c
+	c.state = statePlaying
+	p := c.player
+	p.enterWorld(m.host, m.name)
+	m.applyTalents(p)
+	m.locate(p)
+	p.visited[p.room] = true
+	m.wakeRoom(p.room)
+	p.admin = m.config.isAdmin(c.name) || c.console
+	m.autoJoinChannels(c)
+	m.restoreFollowers(c)
+	m.savePlayer(c)
+	m.tellOthers(c, "portal.arrive_others")
+	c.write(c.tr("portal.arrive", "world", m.name))
+	m.look(c)
+	c.writePrompt()
+}
+
+// bootWorld builds one of the server's worlds from its data and saved
+// state, ready to start.
+func (h *host) bootWorld(cfg *config, wc worldConfig) (*mud, error) {
+	m := newMud()
+	wcfg := *cfg
+	if wc.WorldFile != "" {
+		wcfg.WorldFile = wc.WorldFile
+	}
+	m.config = &wcfg
+	m.name, m.stateDir, m.host = wc.Name, wc.State, h
+	m.greetingFile = newTextFile(wcfg.GreetingFile)
+	m.motdFile = newTextFile(wcfg.MOTDFile)
+	var err error
+	if m.proxies, err = parseNets(wcfg.TrustedProxies); err != nil {
+		return nil, fmt.Errorf("trustedProxies: %v", err)
+	}
+	if m.banned, err = parseNets(wcfg.BannedAddrs); err != nil {
+		return nil, fmt.Errorf("bannedAddrs: %v", err)
+	}
+	if m.exemptAddrs, err = parseNets(wcfg.MultiplayExempt); err != nil {
+		return nil, fmt.Errorf("multiplayExempt: %v", err)
+	}
+	if m.countryPolicies, err = parseCountryPolicies(wcfg.CountryPolicies); err != nil {
+		return nil, fmt.Errorf("countryPolicies: %v", err)
+	}
+	if h.geoip == nil && wcfg.GeoIPFile != "" {
+		if h.geoip, err = openGeoDB(wcfg.GeoIPFile); err != nil {
+			return nil, fmt.Errorf("geoipFile: %v", err)
+		}
+	}
+
+	if wcfg.WorldFile == "" {
+		m.createMap()
+	}
+	if err := m.loadData(wc.Data); err != nil {
+		return nil, err
+	}
+	if err := m.resolveStarts(wc.StartRooms); err != nil {
+		return nil, err
+	}
+	m.applyRoomFlags()
+	m.spawnNPCs()
+	m.openShops()
+	m.setTraps()
+	m.placeVehicles()
+	m.placeFurniture()
+	if err := m.loadHouses(); err != nil {
+		return nil, err
+	}
+	h.worlds = append(h.worlds, m)
+	return m, nil
+}
+
+// start loads the world's saved state, schedules its tasks, and starts its
+// game loop and listeners.
+func (m *mud) start(wc worldConfig) error {
+	for _, load := range []func() error{m.loadBounties, m.loadLedger, m.loadDeaths, m.loadReports, m.loadSeason, m.loadSnapshots} {
+		if err := load(); err != nil {
+			return err
+		}
+	}
+	m.registerAchievements()
+	m.events.subscribe(eventEnterRoom, m.checkGuards)
+	m.events.subscribe(eventKill, m.payBounties)
+	m.events.subscribe(eventKill, m.questKill)
+	m.events.subscribe(eventEnterRoom, m.questVisit)
+	m.events.subscribe(eventEnterRoom, m.enterTraps)
+	m.events.subscribe(eventEnterRoom, m.checkFall)
+	m.events.subscribe(eventLogin, m.giveHolidayGifts)
+	m.feedEvents()
+	m.jsonEvents()
+	m.scheduleAnnouncements()
+	m.scheduleWorldEvents()
+	m.updateHolidays()
+	m.scheduler.every("holidays", holidayCheck, m.updateHolidays)
+	m.scheduler.every("house upkeep", time.Hour, m.collectUpkeep)
+	m.scheduler.every("save houses", time.Minute, m.saveHouses)
+	m.scheduler.every("reclaim lockers", time.Hour, m.reclaimLockers)
+	m.scheduler.every("post bounties", time.Hour, m.postSecurityBounty)
+	m.scheduler.every("pay wages", time.Hour, m.payAllWages)
+	m.scheduler.every("restock shops", restockInterval, m.restock)
+	m.scheduler.every("save economy", time.Minute, m.saveLedger)
+	m.scheduler.every("drowning", drownInterval, m.drown)
+	m.scheduler.every("falling", fallInterval, m.fallAll)
+	m.scheduler.every("regeneration", regenInterval, m.regenerate)
+	m.scheduler.every("combat", combatRound, m.combatRounds)
+	m.scheduler.every("sobering", soberInterval, m.sober)
+	if m.config.ZoneIdleMinutes > 0 {
+		m.pageOutIdleZones()
+		m.scheduler.every("page zones", time.Minute, m.pageZones)
+	}
+	if m.config.ConnectionsPerMinute > 0 {
+		m.scheduler.every("connection throttle", time.Minute, m.forgetConnects)
+	}
+	if m.config.BackupHours > 0 {
+		m.scheduler.every("backup", time.Duration(m.config.BackupHours)*time.Hour, m.scheduledBackup)
+	}
+	go m.runTicks()
+	go m.runCommands()
+	return m.listen(wc)
+}
+
+// serve accepts connections for as long as the listener is open, riding
+// out errors such as running short of file descriptors by backing off so
+// as not to spin. Anything that could keep a connection waiting, such as
+// reading a load balancer's PROXY header, is left to its own goroutine.
+// json is set for the world's JSON address.
+func (m *mud) serve(listener net.Listener, json bool) {
+	backoff := 5 * time.Millisecond
+	for {
+		conn, err := listener.Accept()
+		if errors.Is(err, net.ErrClosed) {
+			log.Fatal(err)
+		}
+		if err != nil {
+			log.Printf("error accepting a connection: %v", err)
+			time.Sleep(backoff)
+			if backoff < time.Second {
+				backoff *= 2
+			}
+			continue
+		}
+		backoff = 5 * time.Millisecond
+		go m.handleConnection(conn, json)
+	}
+}
@@ -0,0 +1,329 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+)
+
+// housesFile is where player housing is saved, in the state directory.
+const housesFile = "houses.json"
+
+// upkeepPeriod is how often house upkeep is charged.
+const upkeepPeriod = 24 * time.Hour
+
+// houseTemplate describes a kind of player housing that can be bought.
+type houseTemplate struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Price       int    `json:"price"`
+	Upkeep      int    `json:"upkeep"`
+}
+
+// house is a player-owned room attached to a room in a housing zone.
+type house struct {
+	UID       string       `json:"uid"`
+	Owner     string       `json:"owner"`
+	Template  string       `json:"template"`
+	X         int          `json:"x"`
+	Y         int          `json:"y"`
+	HubX      int          `json:"hubX"`
+	HubY      int          `json:"hubY"`
+	Dir       string       `json:"dir"`
+	Allowed   []string     `json:"allowed"`
+	Items     []itemRecord `json:"items"`
+	PaidUntil time.Time    `json:"paidUntil"`
+
+	room *room
+}
+
+// allows reports whether the named character may enter the house.
+func (h *house) allows(name string) bool {
+	if strings.EqualFold(h.Owner, name) {
+		return true
+	}
+	for _, a := range h.Allowed {
+		if strings.EqualFold(a, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// houseIn returns the house whose room has the given ID, if any.
+func (m *mud) houseIn(id string) *house {
+	for _, h := range m.houses {
+		if h.room != nil && h.room.id == id {
+			return h
+		}
+	}
+	return nil
+}
+
+// houseOwnedBy returns the house owned by the named character, if any.
+func (m *mud) houseOwnedBy(name string) *house {
+	for _, h := range m.houses {
+		if strings.EqualFold(h.Owner, name) {
+			return h
+		}
+	}
+	return nil
+}
+
+// attachHouse creates the room for a house and links it to its hub room.
+func (m *mud) attachHouse(h *house) {
+	t := m.houseTemplates[h.Template]
+	name, description := "A Private Room", "An empty room."
+	if t != nil {
+		name = fmt.Sprintf(t.Name, h.Owner)
+		description = t.Description
+	}
+	r := newRoom(name, description)
+	if h.UID == "" {
+		h.UID = newUUID()
+	}
+	r.uid = h.UID
+	r.flags["private"] = true
+	for _, rec := range h.Items {
+		r.items = append(r.items, itemFromRecord(rec))
+	}
+	m.addRoom(fmt.Sprintf("house_%d_%d", h.X, h.Y), h.X, h.Y, r)
+	h.room = r
+
+	// link the house and its hub both ways
+	if hub := m.getRoomByPosition(h.HubX, h.HubY); hub != nil {
+		hub.exits[h.Dir] = r.id
+		r.exits[reverseDirections[h.Dir]] = hub.id
+	}
+}
+
+// detachHouse removes a house's room and unlinks it from its hub. Anything
+// left inside is moved to the hub room.
+func (m *mud) detachHouse(h *house) {
+	hub := m.getRoomByPosition(h.HubX, h.HubY)
+	if hub != nil {
+		delete(hub.exits, h.Dir)
+		hub.items = append(hub.items, h.room.items...)
+	}
+	for _, conn := range m.playersInRoom(h.room.id) {
+		conn.player.room = m.startRoom(conn.player)
+		if hub != nil {
+			conn.player.room = hub.id
+		}
+		m.send(only(conn), plain(conn.tr("house.shown_out")).asAside(nil))
+	}
+	delete(m.rooms, h.room.id)
+	delete(m.grid, positionHash(h.X, h.Y))
+	for i, other := range m.houses {
+		if other == h {
+			m.houses = append(m.houses[:i], m.houses[i+1:]...)
+			break
+		}
+	}
+}
+
+// loadHouses reads the saved houses and attaches their rooms to the world.
+func (m *mud) loadHouses() error {
+	err := loadJSON(m.statePath(housesFile), &m.houses)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	for _, h := range m.houses {
+		m.attachHouse(h)
+	}
+	return nil
+}
+
+// recordHouseItems notes the items inside each house in its record.
+func (m *mud) recordHouseItems() {
+	for _, h := range m.houses {
+		h.Items = h.Items[:0]
+		for _, it := range h.room.items {
+			h.Items = append(h.Items, it.record())
+		}
+	}
+}
+
+// saveHouses writes every house, including the items inside, to disk.
+func (m *mud) saveHouses() {
+	m.recordHouseItems()
+	data, err := json.MarshalIndent(m.houses, "", "  ")
+	if err == nil {
+		err = os.MkdirAll(m.stateDir, 0755)
+	}
+	if err == nil {
+		err = os.WriteFile(m.statePath(housesFile), data, 0644)
+	}
+	if err != nil {
+		log.Printf("error saving houses: %v", err)
+	}
+}
+
+// buyRoom buys a house of the given template next to the current room,
+// which must be in a housing zone.
+func (m *mud) buyRoom(c *connection, args []string) {
+	p := c.player
+	hub := m.rooms[p.room]
+	if hub == nil || !hub.mapped || !hub.flags["housing"] {
+		c.write(c.tr("house.no_housing_sale"))
+		return
+	}
+	if len(args) == 0 {
+		c.write(c.tr("house.rooms_sale"))
+		for _, t := range m.houseTemplates {
+			c.write(c.tableRow("  %-10s %5d gold, %d gold upkeep per day\n", []string{"", "gold", "upkeep per day"}, t.ID, t.Price, t.Upkeep))
+		}
+		c.write(c.tr("house.usage_buy_room"))
+		return
+	}
+	t, ok := m.houseTemplates[args[0]]
+	if !ok {
+		c.write(c.tr("house.no_such_room"))
+		return
+	}
+	if m.houseOwnedBy(c.name) != nil {
+		c.write(c.tr("house.already_own_room"))
+		return
+	}
+	if p.gold < t.Price {
+		c.write(c.tr("house.need_gold_buy", "price", t.Price))
+		return
+	}
+
+	// find a free space next to the hub
+	for _, dir := range []string{"north", "east", "south", "west"} {
+		if _, used := hub.exits[dir]; used {
+			continue
+		}
+		off := directionOffsets[dir]
+		x, y := hub.x+off[0], hub.y+off[1]
+		if m.getRoomByPosition(x, y) != nil {
+			continue
+		}
+		p.gold -= t.Price
+		m.goldDestroyed("housing", t.Price)
+		h := &house{
+			Owner:     c.name,
+			Template:  t.ID,
+			X:         x,
+			Y:         y,
+			HubX:      hub.x,
+			HubY:      hub.y,
+			Dir:       dir,
+			PaidUntil: time.Now().Add(upkeepPeriod),
+		}
+		m.houses = append(m.houses, h)
+		m.attachHouse(h)
+		m.saveHouses()
+		m.savePlayer(c)
+		c.write(c.tr("house.buy_door", "room", h.room.name, "dir", dir))
+		return
+	}
+	c.write(c.tr("house.no_space_left"))
+}
+
+// allow grants or revokes another character's access to the player's house.
+func (m *mud) allow(c *connection, args []string, grant bool) {
+	h := m.houseOwnedBy(c.name)
+	if h == nil {
+		c.write(c.tr("house.dont_own_room"))
+		return
+	}
+	if len(args) == 0 {
+		c.write(c.tr("house.allowed_room", "allowed", strings.Join(h.Allowed, ", ")))
+		return
+	}
+	name := args[0]
+	for i, a := range h.Allowed {
+		if strings.EqualFold(a, name) {
+			if !grant {
+				h.Allowed = append(h.Allowed[:i], h.Allowed[i+1:]...)
+				c.write(c.tr("house.may_no_longer", "name", name))
+				m.saveHouses()
+				return
+			}
+			c.write(c.tr("house.already_allowed", "name", name))
+			return
+		}
+	}
+	if !grant {
+		c.write(c.tr("house.was_not_allowed", "name", name))
+		return
+	}
+	h.Allowed = append(h.Allowed, name)
+	c.write(c.tr("house.may_enter_room", "name", name))
+	m.saveHouses()
+}
+
+// canEnter reports whether the player may move into the room with the given
+// ID, telling them why not if they can't.
+func (m *mud) canEnter(c *connection, id string) bool {
+	h := m.houseIn(id)
+	if h == nil || c.player.admin || h.allows(c.name) {
+		return true
+	}
+	c.write(c.tr("house.door_locked", "room", h.room.name))
+	return false
+}
+
+// collectUpkeep charges every house whose upkeep is due. Owners who can't
+// pay lose their room.
+func (m *mud) collectUpkeep() {
+	now := time.Now()
+	for _, h := range append([]*house(nil), m.houses...) {
+		if now.Before(h.PaidUntil) {
+			continue
+		}
+		cost := 0
+		if t, ok := m.houseTemplates[h.Template]; ok {
+			cost = t.Upkeep
+		}
+		h := h
+		// paid ahead so the next pulse doesn't charge again while this settles
+		h.PaidUntil = h.PaidUntil.Add(upkeepPeriod)
+		m.chargeGold(h.Owner, cost, func(paid bool) {
+			conn := m.onlineAs(h.Owner)
+			if paid {
+				m.goldDestroyed("housing", cost)
+				if conn != nil {
+					m.send(only(conn), plain(conn.tr("house.upkeep_paid", "amount", cost)).asAside(nil))
+				}
+				return
+			}
+			if conn != nil {
+				m.send(only(conn), plain(conn.tr("house.repossessed")).asAside(nil))
+			}
+			m.detachHouse(h)
+			m.saveHouses()
+		})
+	}
+	m.saveHouses()
+}
+
+// chargeGold takes gold from the named character, online, offline or
+// playing in another world, then calls done in this world with whether
+// they could afford it.
+func (m *mud) chargeGold(name string, amount int, done func(paid bool)) {
+	charged := false
+	err := m.withCharacter(name, func(p *player) {
+		charged = true
+		paid := p.gold >= amount
+		if paid {
+			p.gold -= amount
+		}
+		go m.locked(func() { done(paid) })
+	})
+	if err != nil {
+		log.Printf("error charging %s: %v", name, err)
+		if !charged {
+			done(false)
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+// serveHTTP starts the HTTP API on the given address.
+func (m *mud) serveHTTP(addr string) error {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/leaderboards", m.handleLeaderboards)
+	mux.HandleFunc("/api/snapshots", m.handleSnapshot)
+	mux.HandleFunc("/api/admin/export", m.handleExport)
+	mux.HandleFunc("/api/admin/map", m.handleMap)
+	mux.HandleFunc("/api/admin/metrics", m.handleMetrics)
+	return http.ListenAndServe(addr, mux)
+}
+
+// staffRequest reports whether a request presents the configured API
+// token, as staff tools must.
+func (m *mud) staffRequest(r *http.Request) bool {
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return m.config.APIToken != "" && subtle.ConstantTimeCompare([]byte(token), []byte(m.config.APIToken)) == 1
+}
+
+// writeJSON writes v as the JSON response body.
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+// handleLeaderboards serves the leaderboards. With a board query parameter
+// it returns that board, otherwise all of them keyed by name.
+func (m *mud) handleLeaderboards(w http.ResponseWriter, r *http.Request) {
+	if name := r.URL.Query().Get("board"); name != "" {
+		entries, err := m.leaderboards.get(m.store, name)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		writeJSON(w, entries)
+		return
+	}
+	boards := make(map[string][]leaderboardEntry)
+	for _, name := range leaderboardNames() {
+		entries, err := m.leaderboards.get(m.store, name)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		boards[name] = entries
+	}
+	writeJSON(w, boards)
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"path/filepath"
+	"strings"
+)
+
+// defaultLocale is the locale every message must have text in. Messages
+// missing from other locales fall back to it.
+const defaultLocale = "en"
+
+// catalog holds the text of the server's messages in one locale, keyed by
+// message. Act messages, which are templates filled in for each listener,
+// and text that comes from the world's data files are not in it.
+type catalog map[string]message
+
+// message is the text of one message. Text may hold parameters such as
+// {name}, filled in when the message is shown. A message that depends on a
+// number has a form for each plural category of its locale instead, chosen
+// by its {count} parameter.
+type message struct {
+	text  string
+	forms map[string]string
+}
+
+// UnmarshalJSON reads a message from either a string or an object of
+// plural forms such as {"one": "...", "other": "..."}.
+func (msg *message) UnmarshalJSON(data []byte) error {
+	if err := json.Unmarshal(data, &msg.text); err == nil {
+		return nil
+	}
+	if err := json.Unmarshal(data, &msg.forms); err != nil {
+		return err
+	}
+	if _, ok := msg.forms["other"]; !ok {
+		return fmt.Errorf("plural message has no other form")
+	}
+	return nil
+}
+
+// loadCatalogs reads the message catalog of each locale from the given
+// directory, one file per locale named for it, such as en.json.
+func (m *mud) loadCatalogs(dir string) error {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return err
+	}
+	m.catalogs = make(map[string]catalog)
+	for _, path := range paths {
+		var cat catalog
+		if err := loadJSON(path, &cat); err != nil {
+			return fmt.Errorf("%s: %v", path, err)
+		}
+		m.catalogs[strings.TrimSuffix(filepath.Base(path), ".json")] = cat
+	}
+	base, ok := m.catalogs[defaultLocale]
+	if !ok {
+		return fmt.Errorf("no %s message catalog in %s", defaultLocale, dir)
+	}
+	if _, ok := m.catalogs[m.config.Locale]; !ok {
+		return fmt.Errorf("no message catalog for locale %s", m.config.Locale)
+	}
+	// a key the default locale lacks is most likely a typo, and one
+	// another locale lacks is left for its translators
+	for locale, cat := range m.catalogs {
+		for key := range cat {
+			if _, ok := base[key]; !ok {
+				log.Printf("message %s in locale %s is not in %s", key, locale, defaultLocale)
+			}
+		}
+		missing := 0
+		for key := range base {
+			if _, ok := cat[key]; !ok {
+				missing++
+			}
+		}
+		if missing > 0 {
+			log.Printf("locale %s is missing %d messages", locale, missing)
+		}
+	}
+	return nil
+}
+
+// locales returns the locales with a message catalog in sorted order.
+func (m *mud) locales() []string {
+	return sortedKeys(m.catalogs)
+}
+
+// tr returns a message in the locale the player chose, or the server's
+// locale before they have logged in. params are pairs of parameter names
+// and values, such as tr("move", "dir", "north").
+func (c *connection) tr(key string, params ...interface{}) string {
+	return c.world.Load().translate(c.locale(), key, params...)
+}
+
+// locale returns the locale the connection's messages are in.
+func (c *connection) locale() string {
+	if c.player != nil && c.player.locale != "" {
+		return c.player.locale
+	}
+	return c.world.Load().config.Locale
+}
+
+// translate returns a message in the given locale, falling back to the
+// server's locale and then the default one if the locale doesn't have it.
+func (m *mud) translate(locale, key string, params ...interface{}) string {
+	var msg message
+	ok := false
+	for _, l := range []string{locale, m.config.Locale, defaultLocale} {
+		if msg, ok = m.catalogs[l][key]; ok {
+			locale = l
+			break
+		}
+	}
+	if !ok {
+		log.Printf("no message %s", key)
+		return key
+	}
+	pairs := make([]string, 0, len(params))
+	count := 0
+	for i := 0; i+1 < len(params); i += 2 {
+		name := fmt.Sprint(params[i])
+		if n, ok := params[i+1].(int); ok && name == "count" {
+			count = n
+		}
+		pairs = append(pairs, "{"+name+"}", fmt.Sprint(params[i+1]))
+	}
+	text := msg.text
+	if msg.forms != nil {
+		text, ok = msg.forms[pluralCategory(locale, count)]
+		if !ok {
+			text = msg.forms["other"]
+		}
+	}
+	return strings.NewReplacer(pairs...).Replace(text)
+}
+
+// pluralCategory returns the plural form a locale uses for a number, as
+// named in the Unicode plural rules: one, few, many, or other.
+func pluralCategory(locale string, n int) string {
+	if n < 0 {
+		n = -n
+	}
+	switch strings.SplitN(locale, "-", 2)[0] {
+	case "ja", "ko", "zh", "th", "vi":
+		return "other"
+	case "fr", "pt":
+		if n <= 1 {
+			return "one"
+		}
+	case "ru", "uk", "pl":
+		switch {
+		case n == 1 || (n%10 == 1 && n%100 != 11 && !strings.HasPrefix(locale, "pl")):
+			return "one"
+		case n%10 >= 2 && n%10 <= 4 && (n%100 < 12 || n%100 > 14):
+			return "few"
+		}
+		return "many"
+	default:
+		if n == 1 {
+			return "one"
+		}
+	}
+	return "other"
+}
+
+// localeCommand shows or changes the locale the player sees messages in.
+func (m *mud) localeCommand(c *connection, args []string) {
+	if len(args) == 0 {
+		locale := c.player.locale
+		if locale == "" {
+			locale = m.config.Locale
+		}
+		c.write(c.tr("locale.current", "locale", locale, "locales", strings.Join(m.locales(), ", ")))
+		return
+	}
+	locale := strings.ToLower(args[0])
+	if _, ok := m.catalogs[locale]; !ok {
+		c.write(c.tr("locale.unknown", "locale", locale, "locales", strings.Join(m.locales(), ", ")))
+		return
+	}
+	c.player.locale = locale
+	if locale == m.config.Locale {
+		c.player.locale = ""
+	}
+	m.savePlayer(c)
+	c.write(c.tr("locale.changed", "locale", locale))
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// startSession records the start of a new play session.
+func (p *player) startSession() {
+	p.sessions++
+	p.loginAt = time.Now()
+	p.lastLogin = p.loginAt
+}
+
+// totalPlaytime returns the player's playtime including the current session.
+func (p *player) totalPlaytime() time.Duration {
+	if p.loginAt.IsZero() {
+		return p.playtime
+	}
+	return p.playtime + time.Since(p.loginAt)
+}
+
+// formatDuration returns a short human readable form of the duration.
+func formatDuration(d time.Duration) string {
+	d = d.Round(time.Minute)
+	days := int(d / (24 * time.Hour))
+	hours := int(d/time.Hour) % 24
+	minutes := int(d/time.Minute) % 60
+	if days > 0 {
+		return fmt.Sprintf("%dd %dh %dm", days, hours, minutes)
+	}
+	return fmt.Sprintf("%dh %dm", hours, minutes)
+}
+
+// score displays the player's own character sheet.
+func (m *mud) score(c *connection) {
+	p := c.player
+	if class := m.className(p); class != "" {
+		c.write(c.tr("score.level_class", "name", c.name, "level", p.level, "class", strings.ToLower(class)))
+	} else {
+		c.write(c.tr("score.level", "name", c.name, "level", p.level))
+	}
+	c.write(c.tr("score.pronouns", "pronouns", p.pronouns))
+	switch {
+	case len(p.pastClasses) > 0:
+		c.write(c.tr("score.remorts_once", "remorts", p.remorts, "classes", strings.Join(p.pastClasses, " and ")))
+	case p.remorts > 0:
+		c.write(c.tr("score.remorts", "remorts", p.remorts))
+	}
+	c.write(c.tr("score.health_mana", "health", p.health, "max_health", p.totalMaxHealth(), "mana", p.mana, "max_mana", p.maxMana))
+	c.write(c.tr("score.experience", "xp", p.xp, "next_level", xpForLevel(p.level)))
+	c.write(p.attributeLine() + "\n")
+	c.write(c.tr("score.damage_1_luck", "damage", p.damage(), "luck", p.totalLuck()))
+	if p.equipment[offhandSlot] != nil {
+		c.write(c.tr("score.attacks_offhand", "attacks", p.baseAttacks(), "offhand", p.offhandDamage()))
+	} else {
+		c.write(c.tr("score.attacks", "attacks", p.baseAttacks()))
+	}
+	c.write(c.tr("score.spell_power_carrying", "spell_power", p.spellPower(), "count", len(p.inventory), "limit", p.carryLimit()))
+	c.write(c.tr("score.gold_kills_deaths", "gold", p.gold, "kills", p.kills, "deaths", p.deathCount))
+	c.write(c.tr("score.practice_sessions_talent", "practices", p.practices, "points", p.talentPoints()))
+	seen, total := m.exploration(p)
+	c.write(c.tr("score.explored_rooms", "seen", seen, "total", total, "percent", percentOf(seen, total)))
+	c.write(c.tr("score.playtime_over_sessions", "duration", formatDuration(p.totalPlaytime()), "sessions", p.sessions))
+}
+
+// finger displays public information about a character, whether or not
+// they are online.
+func (m *mud) finger(c *connection, args []string) {
+	if len(args) == 0 {
+		c.write(c.tr("finger.finger_whom"))
+		return
+	}
+	name := args[0]
+
+	// prefer the live character if they are playing
+	if conn, ok := m.conns[nameKey(name)]; ok && conn.state == statePlaying {
+		p := conn.player
+		c.write(c.tr("finger.level", "name", conn.name, "level", p.level))
+		c.write(c.tr("finger.online_logged_ago", "duration", formatDuration(time.Since(p.loginAt))))
+		c.write(c.tr("finger.playtime_over_sessions", "duration", formatDuration(p.totalPlaytime()), "sessions", p.sessions))
+		return
+	}
+
+	rec, err := m.store.load(name)
+	if os.IsNotExist(err) {
+		c.write(c.tr("common.no_such_player"))
+		return
+	}
+	if err != nil {
+		c.write(c.tr("finger.players_records_could"))
+		return
+	}
+	c.write(c.tr("finger.level", "name", rec.Name, "level", rec.Level))
+	if rec.LastLogin.IsZero() {
+		c.write(c.tr("finger.has_never_logged"))
+	} else {
+		c.write(c.tr("finger.last_seen", "last_login", rec.LastLogin.Format("2006-01-02 15:04 MST")))
+	}
+	c.write(c.tr("finger.playtime_over_sessions", "duration", formatDuration(time.Duration(rec.Playtime)*time.Second), "sessions", rec.Sessions))
+}
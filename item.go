@@ -0,0 +1,299 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	// goldItemID is the template id used for piles of gold coins.
+	goldItemID = "gold"
+	// corpseItemID is the template id used for corpses.
+	corpseItemID = "corpse"
+)
+
+// itemTemplate describes an item as defined in the item data file.
+type itemTemplate struct {
+	ID        string         `json:"id"`
+	Name      string         `json:"name"`
+	BaseName  string         `json:"baseName"`
+	Keywords  []string       `json:"keywords"`
+	Value     int            `json:"value"`
+	Slot      string         `json:"slot"`
+	Stats     map[string]int `json:"stats"`
+	Key       bool           `json:"key"`
+	Keyring   bool           `json:"keyring"`
+	Container bool           `json:"container"`
+	Contents  []string       `json:"contents"`
+	Boat      bool           `json:"boat"`
+	Effect    string         `json:"effect"`
+	Duration  int            `json:"duration"`
+	Seats     int            `json:"seats"`
+	Drink     bool           `json:"drink"`
+	Alcohol   int            `json:"alcohol"`
+	Disguise  string         `json:"disguise"`
+	Teaches   string         `json:"teaches"`
+	Ranged    string         `json:"ranged"`
+	Ammo      string         `json:"ammo"`
+	Thrown    bool           `json:"thrown"`
+	Treasure  string         `json:"treasure"`
+	Cosmetic  string         `json:"cosmetic"`
+	Dye       string         `json:"dye"`
+	DyeColor  string         `json:"dyeColor"`
+}
+
+// item represents an object in the MUD. uid tells this one apart from
+// every other item made from the same template.
+type item struct {
+	uid       string
+	id        string
+	name      string
+	baseName  string
+	keywords  []string
+	value     int
+	slot      string
+	stats     map[string]int
+	rarity    string
+	color     string
+	container bool
+	contents  []*item
+	isKey     bool
+	isKeyring bool
+	seats     int
+
+	// treasure is the loot table buried where a treasure map points, and
+	// treasureRoom the room it points to once it has been marked
+	treasure     string
+	treasureRoom string
+
+	// cosmetic is the appearance slot the item is worn in, apart from
+	// equipment; it gives no stats but shows over what is equipped in the
+	// slot of the same name. dyed is the color it has been dyed.
+	cosmetic string
+	dyed     string
+}
+
+// newItem creates a new item from the given template.
+func newItem(t *itemTemplate) *item {
+	baseName := t.BaseName
+	if baseName == "" {
+		baseName = t.Name
+	}
+	return &item{
+		uid:       newUUID(),
+		id:        t.ID,
+		name:      t.Name,
+		baseName:  baseName,
+		keywords:  append([]string(nil), t.Keywords...),
+		value:     t.Value,
+		slot:      t.Slot,
+		stats:     t.Stats,
+		isKey:     t.Key,
+		isKeyring: t.Keyring,
+		container: t.Keyring || t.Container,
+		seats:     t.Seats,
+		treasure:  t.Treasure,
+		cosmetic:  t.Cosmetic,
+	}
+}
+
+// displayName returns the item's name colored by its rarity, as the
+// viewer sees it.
+func (i *item) displayName(viewer *connection) string {
+	return colorize(i.name, i.color) + viewer.rarityNote(i)
+}
+
+// newGold creates a pile of the given amount of gold coins.
+func newGold(amount int) *item {
+	return &item{
+		uid:      newUUID(),
+		id:       goldItemID,
+		name:     fmt.Sprintf("%d gold coins", amount),
+		keywords: []string{"gold", "coins"},
+		value:    amount,
+	}
+}
+
+// newCorpse creates an empty corpse container for the named victim.
+func newCorpse(name string) *item {
+	return &item{
+		uid:       newUUID(),
+		id:        corpseItemID,
+		name:      fmt.Sprintf("the corpse of %s", name),
+		keywords:  []string{"corpse"},
+		container: true,
+	}
+}
+
+// spawnItem creates a new item from the template with the given id.
+func (m *mud) spawnItem(id string) *item {
+	t, ok := m.itemTemplates[id]
+	if !ok {
+		return nil
+	}
+	return newItem(t)
+}
+
+// matchKeywords reports whether any of the keywords begins with the given word.
+func matchKeywords(keywords []string, word string) bool {
+	word = strings.ToLower(word)
+	if word == "" {
+		return false
+	}
+	for _, k := range keywords {
+		if strings.HasPrefix(strings.ToLower(k), word) {
+			return true
+		}
+	}
+	return false
+}
+
+// findItem returns the index of the first item matching the keyword, or -1.
+func findItem(items []*item, keyword string) int {
+	for i, it := range items {
+		if matchKeywords(it.keywords, keyword) {
+			return i
+		}
+	}
+	return -1
+}
+
+// removeItem returns the list with the item at the given index removed.
+func removeItem(items []*item, i int) []*item {
+	return append(items[:i], items[i+1:]...)
+}
+
+// pickUp gives the item to the player, converting coins into gold and
+// putting keys on their keyring. It reports whether keys were moved onto
+// the keyring.
+func (p *player) pickUp(it *item) bool {
+	if it.id == goldItemID {
+		p.gold += it.value
+		return false
+	}
+	p.inventory = append(p.inventory, it)
+	return p.collectKeys() > 0
+}
+
+// get picks up an item from the room or from a container in the room.
+func (m *mud) get(c *connection, args []string) {
+	if len(args) == 0 {
+		c.write(c.tr("get.get_what"))
+		return
+	}
+	r := m.rooms[c.player.room]
+	if r == nil {
+		c.write(c.tr("get.nothing_here"))
+		return
+	}
+	gold := c.player.gold
+	defer func() {
+		if c.player.gold != gold {
+			m.events.publish(event{kind: eventGold, conn: c, amount: c.player.gold - gold})
+		}
+	}()
+
+	// pick the source list: the room floor or a container in the room
+	if len(args) == 3 && args[1] == "from" {
+		args = []string{args[0], args[2]}
+	}
+	source := &r.items
+	fromFloor := len(args) == 1
+	if !fromFloor {
+		i := findItem(r.items, args[1])
+		if i < 0 {
+			c.write(c.tr("common.dont_see_here"))
+			return
+		}
+		if !r.items[i].container {
+			c.write(c.tr("get.not_container"))
+			return
+		}
+		if !m.containerTrap(c, r.items[i]) {
+			return
+		}
+		source = &r.items[i].contents
+	}
+
+	if args[0] == "all" {
+		var kept []*item
+		full := false
+		for _, it := range *source {
+			if (it.id == corpseItemID || it.seats > 0) && fromFloor {
+				kept = append(kept, it)
+				continue
+			}
+			if full || !c.player.canCarry(c, it) {
+				full = true
+				kept = append(kept, it)
+				continue
+			}
+			onRing := c.player.pickUp(it)
+			c.write(c.tr("get.take", "item", it.displayName(c)))
+			if onRing {
+				c.write(c.tr("common.add_key_keyring"))
+			}
+		}
+		if len(kept) == len(*source) && !full {
+			c.write(c.tr("get.nothing_take"))
+		}
+		*source = kept
+		return
+	}
+
+	i := findItem(*source, args[0])
+	if i < 0 {
+		c.write(c.tr("common.dont_see_here"))
+		return
+	}
+	it := (*source)[i]
+	if (it.id == corpseItemID || it.seats > 0) && fromFloor {
+		c.write(c.tr("get.cant_carry"))
+		return
+	}
+	if !c.player.canCarry(c, it) {
+		return
+	}
+	*source = removeItem(*source, i)
+	onRing := c.player.pickUp(it)
+	c.write(c.tr("get.take", "item", it.displayName(c)))
+	if onRing {
+		c.write(c.tr("common.add_key_keyring"))
+	}
+}
+
+// drop drops an item from the player's inventory into the room.
+func (m *mud) drop(c *connection, args []string) {
+	if len(args) == 0 {
+		c.write(c.tr("drop.drop_what"))
+		return
+	}
+	r := m.rooms[c.player.room]
+	if r == nil {
+		c.write(c.tr("drop.cant_drop_things"))
+		return
+	}
+	p := c.player
+	i := findItem(p.inventory, args[0])
+	if i < 0 {
+		c.write(c.tr("common.dont_have"))
+		return
+	}
+	it := p.inventory[i]
+	p.inventory = removeItem(p.inventory, i)
+	r.items = append(r.items, it)
+	c.write(c.tr("drop.drop", "item", it.displayName(c)))
+}
+
+// inventory displays the items and gold carried by the player.
+func (m *mud) inventory(c *connection) {
+	p := c.player
+	c.write(c.tr("inventory.carrying"))
+	if len(p.inventory) == 0 {
+		c.write(c.tr("common.nothing"))
+	}
+	for _, it := range p.inventory {
+		c.write(fmt.Sprintf("  %s\n", c.itemLink(it, it.displayName(c), "examine")))
+	}
+	c.write(c.tr("inventory.gold", "gold", p.gold))
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"encoding/json"
+	"regexp"
+	"strings"
+)
+
+// A client in JSON mode, for bots and automation, exchanges one JSON
+// object per line with the server instead of text. It sends commands as
+// {"command": "look"}, including its name and password at login, and
+// gets back:
+//
+//	{"type": "text", "text": ...}       the game's output, without color
+//	{"type": "status", ...}             in place of the prompt
+//	{"type": "event", "kind": ...}      game events it was part of
+//	{"type": "error", "message": ...}   a line it sent that wasn't understood
+//
+// A world's JSON address speaks it from the start; on the usual address,
+// a client switches to it by making its first line a JSON object, such as
+// {"protocol": "json"}.
+
+// ansiCodes matches the color escapes stripped from text for JSON mode.
+var ansiCodes = regexp.MustCompile("\x1b\\[[0-9;]*m")
+
+// jsonText is output text, as a JSON-mode client gets it.
+type jsonText struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+// jsonStatus is the prompt, as a JSON-mode client gets it.
+type jsonStatus struct {
+	Type      string `json:"type"`
+	Name      string `json:"name"`
+	Room      string `json:"room"`
+	Health    int    `json:"health"`
+	MaxHealth int    `json:"maxHealth"`
+	Mana      int    `json:"mana"`
+	Level     int    `json:"level"`
+	XP        int    `json:"xp"`
+	Gold      int    `json:"gold"`
+}
+
+// jsonEvent is a game event, as a JSON-mode client gets it.
+type jsonEvent struct {
+	Type string `json:"type"`
+	feedEvent
+}
+
+// jsonError tells a JSON-mode client a line it sent made no sense.
+type jsonError struct {
+	Type    string `json:"type"`
+	Message string `json:"message"`
+}
+
+// jsonCommand is a line from a JSON-mode client.
+type jsonCommand struct {
+	Command string `json:"command"`
+}
+
+// writeJSON queues a message for a JSON-mode client, after the text
+// gathered for it so far.
+func (c *connection) writeJSON(v interface{}) {
+	c.queuePending()
+	data, err := json.Marshal(v)
+	if err != nil {
+		return
+	}
+	c.queueOutput(string(data) + "\n")
+}
+
+// jsonTextLine returns output text as the line a JSON-mode client gets,
+// or "" if there is nothing in it to send.
+func jsonTextLine(msg string) string {
+	text := strings.Trim(ansiCodes.ReplaceAllString(msg, ""), "\r\n")
+	if text == "" {
+		return ""
+	}
+	data, _ := json.Marshal(jsonText{Type: "text", Text: text})
+	return string(data) + "\n"
+}
+
+// status returns the player's prompt as a JSON-mode client gets it.
+func (c *connection) status() jsonStatus {
+	p := c.player
+	return jsonStatus{
+		Type: "status", Name: c.name, Room: p.room,
+		Health: p.health, MaxHealth: p.totalMaxHealth(), Mana: p.mana,
+		Level: p.level, XP: p.xp, Gold: p.gold,
+	}
+}
+
+// jsonInput decodes a line from a client in JSON mode, or one switching
+// to it, returning the command it holds, if any.
+func (c *connection) jsonInput(line string) (string, bool) {
+	var cmd jsonCommand
+	err := json.Unmarshal([]byte(line), &cmd)
+	if !c.json {
+		c.json = true
+		if err == nil && cmd.Command == "" {
+			// the client only asked to switch, and saw the name prompt
+			// before it did
+			c.write(c.tr("login.name"))
+		}
+	}
+	if err != nil {
+		c.writeJSON(jsonError{Type: "error", Message: "expected a JSON object such as {\"command\": \"look\"}"})
+		return "", false
+	}
+	cmd.Command = strings.TrimSpace(cmd.Command)
+	return cmd.Command, cmd.Command != ""
+}
+
+// jsonEvents passes the game's events on to the JSON-mode clients
+// involved in them.
+func (m *mud) jsonEvents() {
+	for _, kind := range feedKinds {
+		m.events.subscribe(kind, func(e event) {
+			for _, conn := range []*connection{e.conn, e.victim} {
+				if conn != nil && conn.json {
+					conn.writeJSON(jsonEvent{Type: "event", feedEvent: m.feedEvent(e)})
+				}
+			}
+		})
+	}
+}
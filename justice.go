@@ -0,0 +1,225 @@
+package main
+
+import (
+	"math/rand"
+	"strings"
+	"time"
+)
+
+// jailRoom is where guards take the players they arrest. No exits lead in
+// or out.
+const jailRoom = "security_office"
+
+// wanted reports whether the player has an unpaid fine on their head.
+func (p *player) wanted() bool {
+	return p.bounty > 0
+}
+
+// jailed reports whether the player is serving a sentence.
+func (p *player) jailed() bool {
+	return time.Now().Before(p.jailedUntil)
+}
+
+// consent toggles whether the player takes part in player thievery. Only
+// players who have both consented can steal from each other.
+func (m *mud) consent(c *connection, args []string) {
+	p := c.player
+	if len(args) > 0 {
+		switch args[0] {
+		case "on":
+			p.consent = true
+		case "off":
+			p.consent = false
+		default:
+			c.write(c.tr("consent.usage_consent_off"))
+			return
+		}
+	}
+	if p.consent {
+		c.write(c.tr("consent.have_consented_player"))
+	} else {
+		c.write(c.tr("consent.have_not_consented"))
+	}
+}
+
+// steal tries to take an item from another consenting player. The thief is
+// flagged as a criminal if anyone sees the attempt.
+func (m *mud) steal(c *connection, args []string) {
+	if len(args) < 3 || args[len(args)-2] != "from" {
+		c.write(c.tr("steal.usage_steal_item"))
+		return
+	}
+	p := c.player
+	if !p.consent {
+		c.write(c.tr("steal.must_consent_player"))
+		return
+	}
+	target := m.findPlayerNear(c, args[len(args)-1])
+	if target == nil {
+		c.write(c.tr("common.they_arent_here"))
+		return
+	}
+	if !target.player.consent {
+		c.write(c.tr("steal.has_not_consented", "name", capitalize(target.nameFor(c))))
+		return
+	}
+	i := findItem(target.player.inventory, strings.Join(args[:len(args)-2], " "))
+	if i < 0 {
+		c.write(c.tr("steal.isnt_carrying", "name", capitalize(target.nameFor(c))))
+		return
+	}
+	it := target.player.inventory[i]
+	me, them := playerActor(c), playerActor(target)
+
+	chance := 25 + p.skills["steal"]/2
+	c.lag(skillLag)
+	defer m.improveSkill(c, "steal")
+	if rand.Intn(100) >= chance {
+		m.actObj(toActor, "steal.caught", me, them, it, "")
+		m.actObj(toVictim, "steal.caught_victim", me, them, it, "")
+		m.crime(c, m.config.StealFine, []*connection{target})
+		return
+	}
+
+	target.player.inventory = removeItem(target.player.inventory, i)
+	m.actObj(toActor, "steal.success", me, them, it, "")
+	if p.pickUp(it) {
+		c.write(c.tr("common.add_key_keyring"))
+	}
+
+	// bystanders may still have seen it happen
+	var witnesses []*connection
+	for _, conn := range m.playersInRoom(p.room) {
+		if conn != c && conn != target && rand.Intn(100) < 50 {
+			m.actTo(only(conn), "steal.witnessed", me, them, it, "")
+			witnesses = append(witnesses, conn)
+		}
+	}
+	if len(witnesses) > 0 || m.rooms[p.room].guard() != nil {
+		m.crime(c, m.config.StealFine+it.value, witnesses)
+	}
+}
+
+// crime flags the player as wanted for a witnessed crime, adding the fine to
+// what they owe, and lets any guard present make an arrest.
+func (m *mud) crime(c *connection, fine int, witnesses []*connection) {
+	p := c.player
+	if !p.wanted() {
+		m.postBounty(&bounty{Target: c.name, Name: capitalize(c.name), Player: true, Reward: fine / 2, PostedBy: securityPoster})
+	}
+	p.bounty += fine
+	c.write(colorize(c.tr("crime.wanted_mall_security", "bounty", p.bounty), "red"))
+	for _, conn := range witnesses {
+		m.actTo(only(conn), "crime.now_wanted", playerActor(c), actor{}, nil, "")
+	}
+	if g := m.rooms[p.room].guard(); g != nil {
+		m.arrest(c, g)
+	}
+}
+
+// guard returns the first guard NPC in the room.
+func (r *room) guard() *npc {
+	for _, n := range r.npcs {
+		if n.guard {
+			return n
+		}
+	}
+	return nil
+}
+
+// checkGuards arrests wanted players who walk into a room with a guard.
+func (m *mud) checkGuards(e event) {
+	if !e.conn.player.wanted() || e.conn.player.jailed() {
+		return
+	}
+	if g := e.room.guard(); g != nil {
+		m.arrest(e.conn, g)
+	}
+}
+
+// arrest hauls a wanted player to jail. The fine is paid from their gold and
+// anything they can't pay lengthens the sentence.
+func (m *mud) arrest(c *connection, g *npc) {
+	p := c.player
+	paid := p.bounty
+	if paid > p.gold {
+		paid = p.gold
+	}
+	unpaid := p.bounty - paid
+	p.gold -= paid
+	m.goldDestroyed("fines", paid)
+	p.bounty = 0
+	m.cancelBounties(c.name)
+
+	sentence := time.Duration(m.config.JailSeconds) * time.Second
+	if unpaid > 0 {
+		sentence *= 2
+	}
+	m.act(toNotVictim, "arrest.drags_away", npcActor(g, m.rooms[p.room]), playerActor(c), "")
+	c.write(colorize(c.tr("arrest.arrests", "name", capitalize(g.name)), "red"))
+	if paid > 0 {
+		c.write(c.tr("arrest.pay_fine_gold", "paid", paid))
+	}
+	if unpaid > 0 {
+		c.write(c.tr("arrest.couldnt_pay_gold", "unpaid", unpaid))
+	}
+	c.write(c.tr("arrest.sentenced_jail", "duration", formatDuration(sentence)))
+
+	p.room = jailRoom
+	p.jailedUntil = time.Now().Add(sentence)
+	m.look(c)
+	m.scheduleRelease(c)
+}
+
+// scheduleRelease sets a timer to let the player out at the end of their
+// sentence.
+func (m *mud) scheduleRelease(c *connection) {
+	p := c.player
+	p.jailTask = m.scheduler.after("release: "+c.name, time.Until(p.jailedUntil), func() {
+		p.jailTask = nil
+		m.release(c)
+		c.writePrompt()
+	})
+}
+
+// resumeSentence puts a player who logged out in jail back into their cell,
+// or frees them if their sentence ran out while they were away.
+func (m *mud) resumeSentence(c *connection) {
+	p := c.player
+	if p.jailedUntil.IsZero() {
+		return
+	}
+	if !p.jailed() {
+		m.release(c)
+		return
+	}
+	p.room = jailRoom
+	c.write(c.tr("jail.still_have_left", "duration", formatDuration(time.Until(p.jailedUntil))))
+	m.scheduleRelease(c)
+}
+
+// release frees the player from jail at their start room.
+func (m *mud) release(c *connection) {
+	p := c.player
+	p.jailedUntil = time.Time{}
+	p.room = m.startRoom(p)
+	c.write(c.tr("jail.have_served_sentence"))
+	m.look(c)
+}
+
+// wantedList shows every player currently wanted by mall security.
+func (m *mud) wantedList(c *connection) {
+	found := false
+	for _, conn := range m.conns {
+		if conn.state == statePlaying && conn.player.wanted() {
+			if !found {
+				c.write(c.tr("wanted.wanted_mall_security"))
+				found = true
+			}
+			c.write(c.tableRow("  %-12s %d gold\n", []string{"", "gold"}, conn.name, conn.player.bounty))
+		}
+	}
+	if !found {
+		c.write(c.tr("wanted.nobody_wanted_right"))
+	}
+}
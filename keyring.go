@@ -0,0 +1,89 @@
+package main
+
+import "fmt"
+
+// keyring returns the keyring the player is carrying, if any.
+func (p *player) keyring() *item {
+	for _, it := range p.inventory {
+		if it.isKeyring {
+			return it
+		}
+	}
+	return nil
+}
+
+// keys returns every key the player carries, on their keyring or loose.
+func (p *player) keys() []*item {
+	var keys []*item
+	for _, it := range p.inventory {
+		if it.isKey {
+			keys = append(keys, it)
+		}
+		if it.isKeyring {
+			keys = append(keys, it.contents...)
+		}
+	}
+	return keys
+}
+
+// findKey returns a carried key with the given id, if any.
+func (p *player) findKey(id string) *item {
+	for _, it := range p.keys() {
+		if it.id == id {
+			return it
+		}
+	}
+	return nil
+}
+
+// collectKeys moves any loose keys in the player's inventory onto their
+// keyring, returning the number moved.
+func (p *player) collectKeys() int {
+	ring := p.keyring()
+	if ring == nil {
+		return 0
+	}
+	moved := 0
+	kept := p.inventory[:0]
+	for _, it := range p.inventory {
+		if it.isKey {
+			ring.contents = append(ring.contents, it)
+			moved++
+			continue
+		}
+		kept = append(kept, it)
+	}
+	p.inventory = kept
+	return moved
+}
+
+// keysCommand lists the keys the player carries, or takes one off the keyring.
+func (m *mud) keysCommand(c *connection, args []string) {
+	p := c.player
+	if len(args) >= 2 && args[0] == "remove" {
+		ring := p.keyring()
+		if ring == nil {
+			c.write(c.tr("keys.dont_have_keyring"))
+			return
+		}
+		i := findItem(ring.contents, args[1])
+		if i < 0 {
+			c.write(c.tr("keys.key_isnt_keyring"))
+			return
+		}
+		it := ring.contents[i]
+		ring.contents = removeItem(ring.contents, i)
+		p.inventory = append(p.inventory, it)
+		c.write(c.tr("keys.take_off_keyring", "item", it.displayName(c)))
+		return
+	}
+
+	keys := p.keys()
+	c.write(c.tr("keys.keys"))
+	if len(keys) == 0 {
+		c.write(c.tr("common.none"))
+	}
+	for _, it := range keys {
+		c.write(fmt.Sprintf("  %s\n", it.displayName(c)))
+	}
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math/rand"
+	"sort"
+	"strings"
+)
+
+// language describes a spoken language as defined in the language data
+// file. Everyone speaks the default languages fluently; the rest are
+// skills, and the syllables make up the gibberish heard by those who don't
+// understand.
+type language struct {
+	Name      string   `json:"name"`
+	Default   bool     `json:"default"`
+	Syllables []string `json:"syllables"`
+}
+
+// how much a language course raises the language skill, and the one-in-n
+// chance of a listener's skill improving when they hear the language spoken
+const (
+	studyGain   = 25
+	learnChance = 5
+)
+
+// fluency returns how well the player knows a language, from 0 to maxSkill.
+func (m *mud) fluency(p *player, id string) int {
+	if l, ok := m.languages[id]; ok && l.Default {
+		return maxSkill
+	}
+	return p.skills[id]
+}
+
+// speaking returns the id of the language the player is speaking.
+func (m *mud) speaking(p *player) string {
+	if p.language != "" {
+		return p.language
+	}
+	for _, id := range m.languageIDs() {
+		if m.languages[id].Default {
+			return id
+		}
+	}
+	return ""
+}
+
+// languageIDs returns the ids of the known languages in sorted order.
+func (m *mud) languageIDs() []string {
+	ids := make([]string, 0, len(m.languages))
+	for id := range m.languages {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// speak switches the language the player speaks, or lists the languages
+// they know.
+func (m *mud) speak(c *connection, args []string) {
+	p := c.player
+	if len(args) == 0 {
+		c.write(c.tr("language.speaking_know", "language", m.languages[m.speaking(p)].Name))
+		for _, id := range m.languageIDs() {
+			if n := m.fluency(p, id); n > 0 {
+				c.write(c.tableRow("  %-12s %3d\n", nil, m.languages[id].Name, n))
+			}
+		}
+		return
+	}
+	for _, id := range m.languageIDs() {
+		l := m.languages[id]
+		if !strings.EqualFold(args[0], id) && !strings.EqualFold(args[0], l.Name) {
+			continue
+		}
+		if m.fluency(p, id) == 0 {
+			c.write(c.tr("language.dont_know_any", "language", l.Name))
+			return
+		}
+		p.language = id
+		if l.Default {
+			p.language = ""
+		}
+		c.write(c.tr("language.speak", "language", l.Name))
+		return
+	}
+	c.write(c.tr("language.no_such_language"))
+}
+
+// study teaches the player a language from a course item they have used up.
+func (m *mud) study(c *connection, it *item, id string) {
+	p := c.player
+	l, ok := m.languages[id]
+	if !ok {
+		return
+	}
+	level := p.skills[id] + studyGain
+	if level > maxSkill {
+		level = maxSkill
+	}
+	p.skills[id] = level
+	c.write(c.tr("language.work_through_improves", "item", it.displayName(c), "language", l.Name, "level", level))
+}
+
+// translate returns the message as heard by a listener with the given
+// fluency: each word is understood with that percent chance, and otherwise
+// heard as gibberish in the language.
+func (l *language) translate(msg string, fluency int) string {
+	if fluency >= maxSkill || len(l.Syllables) == 0 {
+		return msg
+	}
+	words := strings.Fields(msg)
+	for i, w := range words {
+		if rand.Intn(maxSkill) < fluency {
+			continue
+		}
+		word := strings.TrimRight(w, ".,!?;:")
+		words[i] = l.gibberish(word) + w[len(word):]
+	}
+	return strings.Join(words, " ")
+}
+
+// gibberish returns a made-up word about as long as the given one. The same
+// word always sounds the same.
+func (l *language) gibberish(word string) string {
+	h := fnv.New32a()
+	h.Write([]byte(strings.ToLower(word)))
+	r := rand.New(rand.NewSource(int64(h.Sum32())))
+	var b strings.Builder
+	for b.Len() < len(word) {
+		b.WriteString(l.Syllables[r.Intn(len(l.Syllables))])
+	}
+	return b.String()
+}
+
+// hearLanguage returns a say as a listener hears it in the speaker's
+// language, giving listeners who don't fully understand it a chance to
+// learn.
+func (m *mud) hearLanguage(c, listener *connection, msg string) string {
+	id := m.speaking(c.player)
+	l, ok := m.languages[id]
+	if !ok || l.Default {
+		return msg
+	}
+	if listener == c {
+		return fmt.Sprintf("(in %s) %s", l.Name, msg)
+	}
+	fluency := m.fluency(listener.player, id)
+	if fluency < maxSkill && rand.Intn(learnChance) == 0 {
+		m.improveSkill(listener, id)
+	}
+	return fmt.Sprintf("(in %s) %s", l.Name, l.translate(msg, fluency))
+}
+
+// speechView is how listeners share a rendering of what the player says:
+// alike if they see the player alike, as long as the player speaks the
+// common tongue, or not at all, as each understands another language
+// differently.
+func (m *mud) speechView(c *connection) func(conn *connection) string {
+	if l, ok := m.languages[m.speaking(c.player)]; ok && !l.Default {
+		return nil
+	}
+	return seenBy(c)
+}
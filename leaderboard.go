@@ -0,0 +1,109 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// leaderboardSize is the number of entries kept on each leaderboard.
+	leaderboardSize = 10
+	// leaderboardTTL is how long computed leaderboards are cached.
+	leaderboardTTL = 5 * time.Minute
+)
+
+// leaderboardStats maps each leaderboard name to the stat it ranks.
+var leaderboardStats = map[string]func(*characterRecord) int{
+	"level": func(r *characterRecord) int { return r.Level },
+	"kills": func(r *characterRecord) int { return r.Kills },
+	"gold":  func(r *characterRecord) int { return r.Gold },
+	"playtime": func(r *characterRecord) int {
+		return int(r.Playtime / 3600)
+	},
+	"arena": arenaRatingOf,
+}
+
+// leaderboardEntry is one ranked character on a leaderboard.
+type leaderboardEntry struct {
+	Name  string `json:"name"`
+	Value int    `json:"value"`
+}
+
+// leaderboards caches the rankings computed from persisted characters. It is
+// shared with the HTTP server, so access is guarded by a mutex.
+type leaderboards struct {
+	mu      sync.Mutex
+	updated time.Time
+	boards  map[string][]leaderboardEntry
+}
+
+// leaderboardNames returns the names of all leaderboards in sorted order.
+func leaderboardNames() []string {
+	names := make([]string, 0, len(leaderboardStats))
+	for name := range leaderboardStats {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// get returns the named leaderboard, recomputing every board from the store
+// if the cache has expired.
+func (l *leaderboards) get(s *store, name string) ([]leaderboardEntry, error) {
+	if _, ok := leaderboardStats[name]; !ok {
+		return nil, fmt.Errorf("unknown leaderboard %q", name)
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.boards == nil || time.Since(l.updated) > leaderboardTTL {
+		recs, err := s.list()
+		if err != nil {
+			return nil, err
+		}
+		l.boards = computeLeaderboards(recs)
+		l.updated = time.Now()
+	}
+	return l.boards[name], nil
+}
+
+// computeLeaderboards ranks the given characters on every leaderboard.
+func computeLeaderboards(recs []*characterRecord) map[string][]leaderboardEntry {
+	boards := make(map[string][]leaderboardEntry)
+	for name, stat := range leaderboardStats {
+		entries := make([]leaderboardEntry, 0, len(recs))
+		for _, r := range recs {
+			entries = append(entries, leaderboardEntry{Name: r.Name, Value: stat(r)})
+		}
+		sort.Slice(entries, func(i, j int) bool {
+			if entries[i].Value != entries[j].Value {
+				return entries[i].Value > entries[j].Value
+			}
+			return entries[i].Name < entries[j].Name
+		})
+		if len(entries) > leaderboardSize {
+			entries = entries[:leaderboardSize]
+		}
+		boards[name] = entries
+	}
+	return boards
+}
+
+// top displays a leaderboard, or the list of leaderboards if none is given.
+func (m *mud) top(c *connection, args []string) {
+	if len(args) == 0 {
+		c.write(c.tr("top.leaderboards_usage_top", "boards", strings.Join(leaderboardNames(), ", ")))
+		return
+	}
+	entries, err := m.leaderboards.get(m.store, args[0])
+	if err != nil {
+		c.write(c.tr("top.no_leaderboard", "board", args[0]))
+		return
+	}
+	c.write(c.tr("top.top_players", "board", args[0]))
+	for i, e := range entries {
+		c.write(c.tableRow("%2d. %-20s %d\n", nil, i+1, e.Name, e.Value))
+	}
+}
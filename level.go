@@ -0,0 +1,36 @@
+package main
+
+// maxLevel is the highest level a player can reach. Players there may
+// remort to start over in a new class.
+const maxLevel = 30
+
+// xpForLevel returns the experience needed to advance past the given level.
+func xpForLevel(level int) int {
+	return level * 100
+}
+
+// gainXP awards experience to the player, advancing their level as needed.
+// Active world events may multiply the amount.
+func (m *mud) gainXP(c *connection, amount int) {
+	p := c.player
+	amount *= m.xpMultiplier()
+	p.xp += amount
+	c.write(c.tr("level.gain_experience", "amount", amount))
+	for p.level < maxLevel && p.xp >= xpForLevel(p.level) {
+		p.xp -= xpForLevel(p.level)
+		p.level++
+		p.maxHealth += 10
+		p.maxMana += 10
+		p.health = p.totalMaxHealth()
+		p.mana = p.maxMana
+		c.write(c.tr("level.have_reached_level", "level", p.level))
+		c.write(c.tr("level.gain", "sessions", sessions(p.gainPractices())))
+		m.events.publish(event{kind: eventLevelUp, conn: c, amount: p.level})
+		if p.level == maxLevel {
+			c.write(c.tr("level.have_reached_highest"))
+		}
+	}
+	if p.level == maxLevel && p.xp > xpForLevel(p.level) {
+		p.xp = xpForLevel(p.level)
+	}
+}
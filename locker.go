@@ -0,0 +1,150 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"time"
+)
+
+// locker is a rented storage locker holding a character's items.
+type locker struct {
+	items     []*item
+	paidUntil time.Time
+}
+
+// lockerRecord is the persisted form of a locker.
+type lockerRecord struct {
+	Items     []itemRecord `json:"items"`
+	PaidUntil time.Time    `json:"paidUntil"`
+}
+
+// lockerCommand handles the locker subcommands: put, get, and list. They
+// only work in rooms with lockers.
+func (m *mud) lockerCommand(c *connection, args []string) {
+	p := c.player
+	r := m.rooms[p.room]
+	if r == nil || !r.flags["lockers"] {
+		c.write(c.tr("locker.no_lockers_here"))
+		return
+	}
+	l := p.locker
+	if l == nil {
+		c.write(c.tr("locker.dont_rent_locker"))
+		return
+	}
+	if len(args) == 0 {
+		args = []string{"list"}
+	}
+
+	switch args[0] {
+	case "list":
+		c.write(c.tr("locker.locker_paid_until", "paid_until", l.paidUntil.Format("2006-01-02 15:04")))
+		if len(l.items) == 0 {
+			c.write(c.tr("locker.empty"))
+		}
+		for _, it := range l.items {
+			c.write(fmt.Sprintf("  %s\n", it.displayName(c)))
+		}
+	case "put":
+		if len(args) < 2 {
+			c.write(c.tr("locker.put_what_locker"))
+			return
+		}
+		i := findItem(p.inventory, args[1])
+		if i < 0 {
+			c.write(c.tr("common.dont_have"))
+			return
+		}
+		if len(l.items) >= m.config.LockerSlots {
+			c.write(c.tr("locker.locker_full"))
+			return
+		}
+		it := p.inventory[i]
+		p.inventory = removeItem(p.inventory, i)
+		l.items = append(l.items, it)
+		c.write(c.tr("locker.put_locker", "item", it.displayName(c)))
+	case "get":
+		if len(args) < 2 {
+			c.write(c.tr("locker.get_what_locker"))
+			return
+		}
+		i := findItem(l.items, args[1])
+		if i < 0 {
+			c.write(c.tr("locker.isnt_locker"))
+			return
+		}
+		it := l.items[i]
+		l.items = removeItem(l.items, i)
+		p.inventory = append(p.inventory, it)
+		c.write(c.tr("locker.take_locker", "item", it.displayName(c)))
+	default:
+		c.write(c.tr("locker.usage"))
+	}
+}
+
+// rentLocker rents a locker, or extends the rent on the player's locker.
+func (m *mud) rentLocker(c *connection) {
+	p := c.player
+	r := m.rooms[p.room]
+	if r == nil || !r.flags["lockers"] {
+		c.write(c.tr("locker.no_lockers_here"))
+		return
+	}
+	cost := m.config.LockerRent
+	if p.gold < cost {
+		c.write(c.tr("locker.locker_costs_gold", "cost", cost, "days", m.config.LockerDays))
+		return
+	}
+	p.gold -= cost
+	m.goldDestroyed("lockers", cost)
+	period := time.Duration(m.config.LockerDays) * 24 * time.Hour
+	if p.locker == nil {
+		p.locker = &locker{paidUntil: time.Now().Add(period)}
+		c.write(c.tr("locker.rent_locker_gold", "cost", cost))
+	} else {
+		p.locker.paidUntil = p.locker.paidUntil.Add(period)
+		c.write(c.tr("locker.extend_locker_rent", "cost", cost))
+	}
+	c.write(c.tr("locker.paid_until", "paid_until", p.locker.paidUntil.Format("2006-01-02 15:04")))
+	m.savePlayer(c)
+}
+
+// reclaimLockers empties every locker whose rent has lapsed, online or not,
+// and mails the contents back to the renter.
+func (m *mud) reclaimLockers() {
+	recs, err := m.store.list()
+	if err != nil {
+		log.Printf("error reclaiming lockers: %v", err)
+		return
+	}
+	now := time.Now()
+	for _, rec := range recs {
+		name := rec.Name
+		if conn := m.onlineAs(name); conn != nil {
+			if conn.player.locker == nil || now.Before(conn.player.locker.paidUntil) {
+				continue
+			}
+		} else if rec.Locker == nil || now.Before(rec.Locker.PaidUntil) {
+			continue
+		}
+
+		var items []itemRecord
+		err := m.withCharacter(name, func(p *player) {
+			for _, it := range p.locker.items {
+				items = append(items, it.record())
+			}
+			p.locker = nil
+		})
+		if err == nil {
+			err = m.sendMail(name, mailMessage{
+				From:    "Mall Management",
+				Subject: "Your locker rental has expired",
+				Body:    "Your locker rent lapsed, so we have returned its contents to you.",
+				Items:   items,
+			})
+		}
+		if err != nil {
+			log.Printf("error reclaiming locker for %s: %v", name, err)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package main
+
+import "math/rand"
+
+// lootEntry is a weighted item choice in a loot table. An empty item means
+// the roll drops nothing, and magic items roll a rarity tier and affixes.
+type lootEntry struct {
+	Item   string `json:"item"`
+	Weight int    `json:"weight"`
+	Magic  bool   `json:"magic"`
+}
+
+// rareDrop is an item with an independent chance of dropping on every kill.
+type rareDrop struct {
+	Item   string  `json:"item"`
+	Chance float64 `json:"chance"`
+}
+
+// lootTable describes what an NPC drops when it dies.
+type lootTable struct {
+	GoldMin int         `json:"goldMin"`
+	GoldMax int         `json:"goldMax"`
+	Rolls   int         `json:"rolls"`
+	Entries []lootEntry `json:"entries"`
+	Rare    []rareDrop  `json:"rare"`
+}
+
+// roll generates the items and gold dropped by one kill, and returns the
+// rare drops among the items again. Each point of luck raises the chance
+// of rare drops by one percent of their base chance.
+func (t *lootTable) roll(m *mud, luck int) (items []*item, rare []*item, gold int) {
+	// weighted rolls
+	total := 0
+	for _, e := range t.Entries {
+		total += e.Weight
+	}
+	for i := 0; i < t.Rolls && total > 0; i++ {
+		n := rand.Intn(total)
+		for _, e := range t.Entries {
+			if n < e.Weight {
+				var it *item
+				if e.Magic {
+					it = m.generateMagicItem(e.Item)
+				} else {
+					it = m.spawnItem(e.Item)
+				}
+				if it != nil {
+					items = append(items, it)
+				}
+				break
+			}
+			n -= e.Weight
+		}
+	}
+
+	// rare drops
+	for _, r := range t.Rare {
+		chance := r.Chance * (1 + float64(luck)/100)
+		if rand.Float64() < chance {
+			if it := m.spawnItem(r.Item); it != nil {
+				items = append(items, it)
+				rare = append(rare, it)
+			}
+		}
+	}
+
+	// gold
+	gold = t.GoldMin
+	if t.GoldMax > t.GoldMin {
+		gold += rand.Intn(t.GoldMax - t.GoldMin + 1)
+	}
+	return items, rare, gold
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// mailMessage is a message in a character's mailbox, optionally carrying items.
+type mailMessage struct {
+	From    string       `json:"from"`
+	Subject string       `json:"subject"`
+	Body    string       `json:"body"`
+	Sent    time.Time    `json:"sent"`
+	Read    bool         `json:"read"`
+	Items   []itemRecord `json:"items,omitempty"`
+}
+
+// withCharacter applies fn to the named character, using the live player
+// if they are online or loading and saving their record otherwise. If
+// they are playing in another world, that world applies it soon after.
+func (m *mud) withCharacter(name string, fn func(p *player)) error {
+	if conn := m.onlineAs(name); conn != nil {
+		fn(conn.player)
+		return nil
+	}
+	if w := m.host.playingIn(name); w != nil && w != m {
+		// saving them here would be undone when they next save there
+		go w.locked(func() {
+			if err := w.withCharacter(name, fn); err != nil {
+				log.Printf("error updating %s in %s: %v", name, w.name, err)
+			}
+		})
+		return nil
+	}
+	rec, err := m.store.load(name)
+	if err != nil {
+		return err
+	}
+	p := playerFromRecord(rec)
+	fn(p)
+	return m.store.save(p.record(rec.Name))
+}
+
+// sendMail delivers a message to the named character, notifying them if
+// they are online.
+func (m *mud) sendMail(to string, msg mailMessage) error {
+	msg.Sent = time.Now()
+	err := m.withCharacter(to, func(p *player) {
+		p.mail = append(p.mail, msg)
+	})
+	if err != nil {
+		return err
+	}
+	if conn := m.onlineAs(to); conn != nil {
+		m.send(only(conn), plain(conn.tr("mail.new_mail", "from", msg.From)).asAside(nil))
+	}
+	return nil
+}
+
+// unreadMail returns the number of unread messages in the player's mailbox.
+func (p *player) unreadMail() int {
+	n := 0
+	for _, msg := range p.mail {
+		if !msg.Read {
+			n++
+		}
+	}
+	return n
+}
+
+// mailCommand lists, reads, sends, and deletes mail.
+func (m *mud) mailCommand(c *connection, args []string) {
+	p := c.player
+	if len(args) == 0 || args[0] == "list" {
+		c.write(c.tr("mail.mailbox"))
+		if len(p.mail) == 0 {
+			c.write(c.tr("mail.empty"))
+		}
+		for i, msg := range p.mail {
+			flag := " "
+			if !msg.Read {
+				flag = "*"
+				if c.screenReader() {
+					flag = "unread"
+				}
+			}
+			attached := ""
+			if len(msg.Items) > 0 {
+				attached = fmt.Sprintf(" [%d items]", len(msg.Items))
+			}
+			c.write(c.tableRow("%s%2d. %-12s %s%s\n", []string{"", "", "from", "subject", "attached items"}, flag, i+1, msg.From, msg.Subject, attached))
+		}
+		return
+	}
+
+	switch args[0] {
+	case "read", "delete":
+		if len(args) < 2 {
+			c.write(c.tr("mail.usage_mail_number", "command", args[0]))
+			return
+		}
+		n, err := strconv.Atoi(args[1])
+		if err != nil || n < 1 || n > len(p.mail) {
+			c.write(c.tr("mail.no_such_message"))
+			return
+		}
+		if args[0] == "delete" {
+			p.mail = append(p.mail[:n-1], p.mail[n:]...)
+			c.write(c.tr("mail.message_deleted"))
+			return
+		}
+		msg := &p.mail[n-1]
+		msg.Read = true
+		c.write(c.tr("mail.message", "from", msg.From, "sent", msg.Sent.Format("2006-01-02 15:04"), "subject", msg.Subject, "body", msg.Body))
+		for _, rec := range msg.Items {
+			it := itemFromRecord(rec)
+			p.inventory = append(p.inventory, it)
+			c.write(c.tr("mail.take_package", "item", it.displayName(c)))
+		}
+		msg.Items = nil
+	case "send":
+		if len(args) < 3 {
+			c.write(c.tr("mail.usage_send"))
+			return
+		}
+		body := strings.Join(args[2:], " ")
+		subject := body
+		if len(subject) > 30 {
+			subject = subject[:30] + "..."
+		}
+		err := m.sendMail(args[1], mailMessage{From: c.name, Subject: subject, Body: body})
+		if os.IsNotExist(err) {
+			c.write(c.tr("common.no_such_player"))
+			return
+		}
+		if err != nil {
+			c.write(c.tr("mail.mail_could_not"))
+			return
+		}
+		c.write(c.tr("mail.mail_sent"))
+	default:
+		c.write(c.tr("mail.usage"))
+	}
+}
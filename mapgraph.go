@@ -0,0 +1,183 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// graphEdge is a link between two rooms in the map graph. Exits that lead
+// both ways are drawn as one edge, with back naming the return exit.
+type graphEdge struct {
+	from, to  string
+	dir, back string
+}
+
+// reachableRooms returns the IDs of the rooms that can be walked to from
+// any of the given rooms by following exits, doors and all.
+func (m *mud) reachableRooms(from ...string) map[string]bool {
+	seen := make(map[string]bool)
+	var queue []string
+	for _, id := range from {
+		if !seen[id] {
+			seen[id] = true
+			queue = append(queue, id)
+		}
+	}
+	for len(queue) > 0 {
+		r := m.rooms[queue[0]]
+		queue = queue[1:]
+		if r == nil {
+			continue
+		}
+		for _, to := range r.exits {
+			if !seen[to] {
+				seen[to] = true
+				queue = append(queue, to)
+			}
+		}
+	}
+	return seen
+}
+
+// graphEdges returns the edges of the map graph in a stable order.
+func (m *mud) graphEdges() []graphEdge {
+	var edges []graphEdge
+	for _, id := range sortedKeys(m.rooms) {
+		r := m.rooms[id]
+		for _, dir := range sortedKeys(r.exits) {
+			to := r.exits[dir]
+			back := ""
+			if r2, ok := m.rooms[to]; ok {
+				for _, d := range sortedKeys(r2.exits) {
+					if r2.exits[d] == id {
+						back = d
+						break
+					}
+				}
+			}
+			// a two-way link is drawn once, from the room with the lower ID
+			if back != "" && to < id {
+				continue
+			}
+			edges = append(edges, graphEdge{from: id, to: to, dir: dir, back: back})
+		}
+	}
+	return edges
+}
+
+// writeDOT writes the map as a Graphviz graph. Rooms on the map are pinned
+// to their positions for neato to keep, and rooms that can't be walked to
+// from the start room are drawn dashed in red.
+func (m *mud) writeDOT(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	reachable := m.reachableRooms(m.startRooms()...)
+	fmt.Fprintln(bw, "digraph world {")
+	fmt.Fprintln(bw, "\tnode [shape=box];")
+	for _, id := range sortedKeys(m.rooms) {
+		r := m.rooms[id]
+		attrs := []string{"label=" + dotQuote(r.name+"\n"+id)}
+		if r.mapped {
+			attrs = append(attrs, fmt.Sprintf("pos=\"%d,%d!\"", r.x*2, r.y*2))
+		}
+		if !reachable[id] {
+			attrs = append(attrs, "color=red", "style=dashed")
+		}
+		fmt.Fprintf(bw, "\t%s [%s];\n", dotQuote(id), strings.Join(attrs, ", "))
+	}
+	for _, e := range m.graphEdges() {
+		label := e.dir
+		attrs := ""
+		if e.back != "" {
+			label += "/" + e.back
+			attrs = ", dir=both"
+		}
+		fmt.Fprintf(bw, "\t%s -> %s [label=%s%s];\n", dotQuote(e.from), dotQuote(e.to), dotQuote(label), attrs)
+	}
+	fmt.Fprintln(bw, "}")
+	return bw.Flush()
+}
+
+// dotQuote quotes a string for a DOT file.
+func dotQuote(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, `"`, `\"`)
+	return `"` + strings.ReplaceAll(s, "\n", `\n`) + `"`
+}
+
+// writeGraphML writes the map as a GraphML graph, with each room's name,
+// position, and reachability as node data and each exit's direction as
+// edge data.
+func (m *mud) writeGraphML(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	reachable := m.reachableRooms(m.startRooms()...)
+	fmt.Fprintln(bw, xml.Header+`<graphml xmlns="http://graphml.graphdrawing.org/xmlns">`)
+	fmt.Fprintln(bw, `  <key id="name" for="node" attr.name="name" attr.type="string"/>`)
+	fmt.Fprintln(bw, `  <key id="x" for="node" attr.name="x" attr.type="int"/>`)
+	fmt.Fprintln(bw, `  <key id="y" for="node" attr.name="y" attr.type="int"/>`)
+	fmt.Fprintln(bw, `  <key id="reachable" for="node" attr.name="reachable" attr.type="boolean"/>`)
+	fmt.Fprintln(bw, `  <key id="dir" for="edge" attr.name="dir" attr.type="string"/>`)
+	fmt.Fprintln(bw, `  <key id="back" for="edge" attr.name="back" attr.type="string"/>`)
+	fmt.Fprintln(bw, `  <graph id="world" edgedefault="directed">`)
+	for _, id := range sortedKeys(m.rooms) {
+		r := m.rooms[id]
+		fmt.Fprintf(bw, "    <node id=\"%s\">\n", xmlEscape(id))
+		fmt.Fprintf(bw, "      <data key=\"name\">%s</data>\n", xmlEscape(r.name))
+		if r.mapped {
+			fmt.Fprintf(bw, "      <data key=\"x\">%d</data>\n", r.x)
+			fmt.Fprintf(bw, "      <data key=\"y\">%d</data>\n", r.y)
+		}
+		fmt.Fprintf(bw, "      <data key=\"reachable\">%t</data>\n", reachable[id])
+		fmt.Fprintln(bw, "    </node>")
+	}
+	for i, e := range m.graphEdges() {
+		fmt.Fprintf(bw, "    <edge id=\"e%d\" source=\"%s\" target=\"%s\">\n", i, xmlEscape(e.from), xmlEscape(e.to))
+		fmt.Fprintf(bw, "      <data key=\"dir\">%s</data>\n", xmlEscape(e.dir))
+		if e.back != "" {
+			fmt.Fprintf(bw, "      <data key=\"back\">%s</data>\n", xmlEscape(e.back))
+		}
+		fmt.Fprintln(bw, "    </edge>")
+	}
+	fmt.Fprintln(bw, "  </graph>")
+	fmt.Fprintln(bw, "</graphml>")
+	return bw.Flush()
+}
+
+// xmlEscape escapes a string for XML text or attribute values.
+func xmlEscape(s string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
+
+// handleMap serves the room graph to staff tools as DOT, or as GraphML
+// when the format query parameter asks for it.
+func (m *mud) handleMap(w http.ResponseWriter, r *http.Request) {
+	if !m.staffRequest(r) {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
+	}
+	write, contentType := m.writeDOT, "text/vnd.graphviz"
+	switch r.URL.Query().Get("format") {
+	case "", "dot":
+	case "graphml":
+		write, contentType = m.writeGraphML, "application/graphml+xml"
+	default:
+		http.Error(w, "format must be dot or graphml", http.StatusBadRequest)
+		return
+	}
+	// render under the lock but send without it, so a slow client can't
+	// hold up the game
+	var buf bytes.Buffer
+	func() {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		write(&buf)
+	}()
+	w.Header().Set("Content-Type", contentType)
+	w.Write(buf.Bytes())
+}
@@ -0,0 +1,360 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"math/rand"
+	"time"
+)
+
+// wagePeriod is how long a day's wage keeps a hireling in service.
+const wagePeriod = 24 * time.Hour
+
+// followerCapacity is how many items a hireling can carry.
+const followerCapacity = 10
+
+// followerRecord is the persisted form of a hireling.
+type followerRecord struct {
+	ID        string       `json:"id"`
+	Health    int          `json:"health"`
+	Following bool         `json:"following"`
+	PaidUntil time.Time    `json:"paidUntil"`
+	Items     []itemRecord `json:"items,omitempty"`
+}
+
+// leadership returns how many hirelings the player can command at once.
+func (p *player) leadership() int {
+	return 1 + p.level/5 + p.stat("charisma")
+}
+
+// roomLine returns how the NPC is shown to the given player in its room.
+func (n *npc) roomLine(c *connection) string {
+	if n.master != "" {
+		return c.tr("hire.in_service", "name", capitalize(n.name), "master", n.master)
+	}
+	return n.description
+}
+
+// npcRoom returns the room the NPC is in.
+func (m *mud) npcRoom(n *npc) *room {
+	for _, r := range m.rooms {
+		for _, other := range r.npcs {
+			if other == n {
+				return r
+			}
+		}
+	}
+	return nil
+}
+
+// findFollower returns the player's hireling in their room matching the
+// keyword.
+func (m *mud) findFollower(c *connection, keyword string) *npc {
+	r := m.rooms[c.player.room]
+	if r == nil {
+		return nil
+	}
+	for _, n := range r.npcs {
+		if n.master == c.name && matchKeywords(n.keywords, keyword) {
+			return n
+		}
+	}
+	return nil
+}
+
+// hire takes an NPC in the room into the player's service for a day's wage.
+func (m *mud) hire(c *connection, args []string) {
+	if len(args) == 0 {
+		c.write(c.tr("hire.hire_whom"))
+		return
+	}
+	p := c.player
+	r := m.rooms[p.room]
+	if r == nil {
+		c.write(c.tr("hire.nobody_here_hire"))
+		return
+	}
+	n := r.findNPC(args[0])
+	if n == nil {
+		c.write(c.tr("common.they_arent_here"))
+		return
+	}
+	if n.wage == 0 || n.master != "" {
+		c.write(c.tr("hire.isnt_hire", "name", capitalize(n.name)))
+		return
+	}
+	if len(p.followers) >= p.leadership() {
+		c.write(c.tr("hire.cant_lead_any"))
+		return
+	}
+	if p.gold < n.wage {
+		c.write(c.tr("hire.wants_gold_day", "name", capitalize(n.name), "wage", n.wage))
+		return
+	}
+	p.gold -= n.wage
+	m.goldDestroyed("wages", n.wage)
+	n.master = c.name
+	n.following = true
+	n.paidUntil = time.Now().Add(wagePeriod)
+	p.followers = append(p.followers, n)
+	c.write(c.tr("hire.hire_gold_day", "name", n.name, "wage", n.wage))
+}
+
+// showFollowers lists the player's hirelings.
+func (m *mud) showFollowers(c *connection) {
+	p := c.player
+	if len(p.followers) == 0 {
+		c.write(c.tr("followers.have_no_followers"))
+		return
+	}
+	c.write(c.tr("followers.followers", "count", len(p.followers), "max", p.leadership()))
+	for _, n := range p.followers {
+		status := "following"
+		if !n.following {
+			status = "staying"
+		}
+		c.write(c.tableRow("  %-22s %3d/%-3d hp  %s, paid for %s\n", []string{"", "health", "max health", "", "paid for"}, n.name, n.health, n.maxHealth, status,
+			formatDuration(time.Until(n.paidUntil))))
+	}
+}
+
+// order gives a command to one of the player's hirelings in the room.
+func (m *mud) order(c *connection, args []string) {
+	if len(args) < 2 {
+		c.write(c.tr("order.usage"))
+		return
+	}
+	n := m.findFollower(c, args[0])
+	if n == nil {
+		c.write(c.tr("order.have_no_follower"))
+		return
+	}
+	p := c.player
+	switch args[1] {
+	case "follow":
+		n.following = true
+		c.write(c.tr("order.falls_behind", "name", capitalize(n.name)))
+	case "stay":
+		n.following = false
+		c.write(c.tr("order.waits_here", "name", capitalize(n.name)))
+	case "carry":
+		if len(args) < 3 {
+			c.write(c.tr("order.carry_what"))
+			return
+		}
+		i := findItem(p.inventory, args[2])
+		if i < 0 {
+			c.write(c.tr("common.dont_have"))
+			return
+		}
+		if len(n.inventory) >= followerCapacity {
+			c.write(c.tr("order.cant_carry_any", "name", capitalize(n.name)))
+			return
+		}
+		it := p.inventory[i]
+		p.inventory = removeItem(p.inventory, i)
+		n.inventory = append(n.inventory, it)
+		c.write(c.tr("order.hand", "item", it.displayName(c), "name", n.name))
+	case "give":
+		if len(args) < 3 {
+			c.write(c.tr("order.give_what"))
+			return
+		}
+		if args[2] == "all" {
+			for _, it := range n.inventory {
+				p.pickUp(it)
+			}
+			n.inventory = nil
+			c.write(actText(c.tr("followers.hands_pockets"), npcActor(n, nil), actor{}, nil, "", c) + "\n")
+			return
+		}
+		i := findItem(n.inventory, args[2])
+		if i < 0 {
+			c.write(c.tr("order.isnt_carrying", "name", capitalize(n.name)))
+			return
+		}
+		it := n.inventory[i]
+		n.inventory = removeItem(n.inventory, i)
+		p.pickUp(it)
+		c.write(c.tr("order.hands", "name", capitalize(n.name), "item", it.displayName(c)))
+	case "inventory", "inv":
+		c.write(c.tr("order.carrying", "name", capitalize(n.name)))
+		if len(n.inventory) == 0 {
+			c.write(c.tr("common.nothing"))
+		}
+		for _, it := range n.inventory {
+			c.write(fmt.Sprintf("  %s\n", it.displayName(c)))
+		}
+	case "kill":
+		if len(args) < 3 {
+			c.write(c.tr("order.kill_whom"))
+			return
+		}
+		r := m.rooms[p.room]
+		target := r.findNPC(args[2])
+		if target == nil || target.master != "" {
+			c.write(c.tr("common.they_arent_here"))
+			return
+		}
+		m.followerFight(c, r, n, target)
+	case "dismiss":
+		m.dismiss(c, n)
+		c.write(c.tr("order.dismiss_service", "name", n.name))
+	default:
+		c.write(c.tr("order.follower_doesnt_understand"))
+	}
+}
+
+// followerFight has a hireling fight an NPC on its own until one side falls.
+func (m *mud) followerFight(c *connection, r *room, f, n *npc) {
+	for {
+		dmg := 1 + rand.Intn(f.damage)
+		n.health -= dmg
+		c.write(c.tr("followers.hits_damage", "attacker", capitalize(f.name), "target", n.name, "damage", dmg))
+		if n.health <= 0 {
+			m.npcDeath(c, r, n)
+			return
+		}
+
+		dmg = 1 + rand.Intn(n.damage)
+		f.health -= dmg
+		c.write(c.tr("followers.hits_damage", "attacker", capitalize(n.name), "target", f.name, "damage", dmg))
+		if f.health <= 0 {
+			m.followerDeath(c, r, f)
+			return
+		}
+	}
+}
+
+// followersAssist has the player's hirelings in the room strike the NPC the
+// player is fighting. It reports whether the NPC was slain.
+func (m *mud) followersAssist(c *connection, r *room, n *npc) bool {
+	for _, f := range r.npcs {
+		if f.master != c.name {
+			continue
+		}
+		dmg := 1 + rand.Intn(f.damage)
+		n.health -= dmg
+		n.addThreat(c, dmg)
+		c.write(c.tr("followers.hits_damage", "attacker", capitalize(f.name), "target", n.name, "damage", dmg))
+		if n.health <= 0 {
+			m.npcDeath(c, r, n)
+			return true
+		}
+	}
+	return false
+}
+
+// followerDeath removes a slain hireling, leaving its corpse with whatever it
+// was carrying.
+func (m *mud) followerDeath(c *connection, r *room, f *npc) {
+	c.write(c.tr("followers.has_been_slain", "name", capitalize(f.name)))
+	m.removeFollower(c.player, f)
+	r.removeNPC(f)
+	corpse := newCorpse(f.name)
+	corpse.contents = f.inventory
+	r.items = append(r.items, corpse)
+}
+
+// dismiss releases a hireling from service. It stays where it is and drops
+// anything it was carrying.
+func (m *mud) dismiss(c *connection, f *npc) {
+	m.removeFollower(c.player, f)
+	f.master = ""
+	if r := m.npcRoom(f); r != nil {
+		r.items = append(r.items, f.inventory...)
+	}
+	f.inventory = nil
+}
+
+// removeFollower takes the NPC off the player's list of followers.
+func (m *mud) removeFollower(p *player, f *npc) {
+	for i, other := range p.followers {
+		if other == f {
+			p.followers = append(p.followers[:i], p.followers[i+1:]...)
+			return
+		}
+	}
+}
+
+// bringFollowers moves the player's hirelings from the room they left into
+// the room they entered.
+func (m *mud) bringFollowers(c *connection, from, to *room) {
+	for _, f := range c.player.followers {
+		if !f.following {
+			continue
+		}
+		for _, n := range from.npcs {
+			if n == f {
+				from.removeNPC(f)
+				to.npcs = append(to.npcs, f)
+				c.write(c.tr("followers.follows", "name", capitalize(f.name)))
+				break
+			}
+		}
+	}
+}
+
+// payWages renews the service of each of the player's hirelings whose day
+// is up, dismissing those the player can't afford.
+func (m *mud) payWages(c *connection) {
+	p := c.player
+	for _, f := range append([]*npc(nil), p.followers...) {
+		for time.Now().After(f.paidUntil) {
+			if p.gold < f.wage {
+				m.dismiss(c, f)
+				c.write(c.tr("followers.cant_pay_who", "name", f.name))
+				break
+			}
+			p.gold -= f.wage
+			m.goldDestroyed("wages", f.wage)
+			f.paidUntil = f.paidUntil.Add(wagePeriod)
+			c.write(c.tr("followers.pay_gold_wages", "name", f.name, "wage", f.wage))
+		}
+	}
+}
+
+// payAllWages collects wages from every player online.
+func (m *mud) payAllWages() {
+	for _, conn := range m.conns {
+		if conn.state == statePlaying {
+			m.payWages(conn)
+		}
+	}
+}
+
+// stashFollowers takes the player's hirelings out of the world when they
+// leave the game. They are saved with the character.
+func (m *mud) stashFollowers(c *connection) {
+	for _, f := range c.player.followers {
+		if r := m.npcRoom(f); r != nil {
+			r.removeNPC(f)
+		}
+	}
+}
+
+// restoreFollowers puts the player's saved hirelings back into the world
+// beside them.
+func (m *mud) restoreFollowers(c *connection) {
+	p := c.player
+	r := m.rooms[p.room]
+	for _, rec := range p.followerRecords {
+		t, ok := m.npcTemplates[rec.ID]
+		if !ok || r == nil {
+			log.Printf("dropping follower %s of %s", rec.ID, c.name)
+			continue
+		}
+		f := newNPC(t)
+		f.health = rec.Health
+		f.master = c.name
+		f.following = rec.Following
+		f.paidUntil = rec.PaidUntil
+		for _, ir := range rec.Items {
+			f.inventory = append(f.inventory, itemFromRecord(ir))
+		}
+		p.followers = append(p.followers, f)
+		r.npcs = append(r.npcs, f)
+	}
+	p.followerRecords = nil
+	m.payWages(c)
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"runtime"
+)
+
+// serverMetrics is the server's memory use and how much of each world it
+// holds, as served to staff tools.
+type serverMetrics struct {
+	HeapAlloc   uint64         `json:"heapAlloc"`
+	HeapObjects uint64         `json:"heapObjects"`
+	Sys         uint64         `json:"sys"`
+	NumGC       uint32         `json:"numGC"`
+	Goroutines  int            `json:"goroutines"`
+	Worlds      []worldMetrics `json:"worlds,omitempty"`
+}
+
+// worldMetrics counts what one world has loaded.
+type worldMetrics struct {
+	Name         string `json:"name"`
+	Players      int    `json:"players"`
+	Waiting      int    `json:"waiting"`
+	Rooms        int    `json:"rooms"`
+	NPCs         int    `json:"npcs"`
+	Items        int    `json:"items"`
+	Zones        int    `json:"zones"`
+	ZonesLoaded  int    `json:"zonesLoaded"`
+	ZonePageIns  int    `json:"zonePageIns"`
+	ZonePageOuts int    `json:"zonePageOuts"`
+}
+
+// readServerMetrics reads the runtime's memory statistics.
+func readServerMetrics() serverMetrics {
+	var ms runtime.MemStats
+	runtime.ReadMemStats(&ms)
+	return serverMetrics{
+		HeapAlloc:   ms.HeapAlloc,
+		HeapObjects: ms.HeapObjects,
+		Sys:         ms.Sys,
+		NumGC:       ms.NumGC,
+		Goroutines:  runtime.NumGoroutine(),
+	}
+}
+
+// worldMetrics counts the world's players and what its rooms hold.
+func (m *mud) worldMetrics() worldMetrics {
+	wm := worldMetrics{
+		Name:         m.name,
+		Waiting:      len(m.waiting),
+		Rooms:        len(m.rooms),
+		Zones:        len(m.zones),
+		ZonePageIns:  m.zonePageIns,
+		ZonePageOuts: m.zonePageOuts,
+	}
+	for _, c := range m.conns {
+		if c.state == statePlaying && m.conns[nameKey(c.name)] == c {
+			wm.Players++
+		}
+	}
+	for _, r := range m.rooms {
+		wm.NPCs += len(r.npcs)
+		wm.Items += len(r.items)
+	}
+	for _, z := range m.zones {
+		if z.loaded {
+			wm.ZonesLoaded++
+		}
+	}
+	return wm
+}
+
+// handleMetrics serves staff tools the server's memory use and what each
+// of its worlds has loaded.
+func (m *mud) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	if !m.staffRequest(r) {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
+	}
+	sm := readServerMetrics()
+	for _, world := range m.host.worlds {
+		world.locked(func() {
+			sm.Worlds = append(sm.Worlds, world.worldMetrics())
+		})
+	}
+	writeJSON(w, sm)
+}
+
+// formatBytes writes a size in bytes in the largest unit that fits it.
+func formatBytes(n uint64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := uint64(unit), 0
+	for n/div >= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
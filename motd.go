@@ -0,0 +1,107 @@
+package main
+
+import (
+	"os"
+	"strings"
+	"time"
+)
+
+// textFile is a text file that is reloaded whenever it changes on disk.
+type textFile struct {
+	path    string
+	text    string
+	modTime time.Time
+}
+
+// newTextFile creates a text file for the given path. The contents are
+// loaded on first use.
+func newTextFile(path string) *textFile {
+	return &textFile{path: path}
+}
+
+// current returns the file's text, reloading it if the file has changed.
+// A missing file reads as empty.
+func (f *textFile) current() string {
+	info, err := os.Stat(f.path)
+	if err != nil {
+		f.text, f.modTime = "", time.Time{}
+		return f.text
+	}
+	if !info.ModTime().Equal(f.modTime) {
+		data, err := os.ReadFile(f.path)
+		if err != nil {
+			return f.text
+		}
+		f.text = string(data)
+		f.modTime = info.ModTime()
+	}
+	return f.text
+}
+
+// write replaces the file's contents.
+func (f *textFile) write(text string) error {
+	if !strings.HasSuffix(text, "\n") {
+		text += "\n"
+	}
+	if err := os.WriteFile(f.path, []byte(text), 0644); err != nil {
+		return err
+	}
+	f.current()
+	return nil
+}
+
+// greeting returns the text shown to new connections before login.
+func (m *mud) greeting(c *connection) string {
+	text := m.greetingFile.current()
+	if text == "" {
+		return c.tr("login.welcome")
+	}
+	return text + "\n"
+}
+
+// showMOTD displays the message of the day, highlighting it as new if it
+// changed since the given time. A zero time never highlights.
+func (m *mud) showMOTD(c *connection, since time.Time) {
+	text := m.motdFile.current()
+	if text == "" {
+		return
+	}
+	if !since.IsZero() && m.motdFile.modTime.After(since) {
+		if c.screenReader() {
+			c.write(c.tr("motd.new_since_last"))
+		} else {
+			c.write(colorize(c.tr("motd.new_banner"), "yellow") + "\n")
+		}
+	}
+	c.write(text)
+	if !strings.HasSuffix(text, "\n") {
+		c.write("\n")
+	}
+	c.write("\n")
+}
+
+// motd shows the message of the day. Staff can also edit or reload it.
+func (m *mud) motd(c *connection, args []string) {
+	if len(args) == 0 || !c.player.admin {
+		m.showMOTD(c, time.Time{})
+		return
+	}
+	switch args[0] {
+	case "edit":
+		m.startEditor(c, func(text string) {
+			if err := m.motdFile.write(text); err != nil {
+				c.write(c.tr("motd.motd_could_not", "error", err))
+				return
+			}
+			c.write(c.tr("motd.motd_updated"))
+		})
+	case "reload":
+		m.motdFile.modTime = time.Time{}
+		m.greetingFile.modTime = time.Time{}
+		m.motdFile.current()
+		m.greetingFile.current()
+		c.write(c.tr("motd.motd_greeting_reloaded"))
+	default:
+		c.write(c.tr("motd.usage"))
+	}
+}
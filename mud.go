@@ -2,9 +2,19 @@ package main
 
 import (
 	"bufio"
+	"flag"
 	"fmt"
+	"log"
+	"math/rand"
 	"net"
+	"os"
+	"os/signal"
+	"path/filepath"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
 )
 
 const (
@@ -13,23 +23,185 @@ const (
 	statePassword
 	statePlaying
 	stateDead
+	stateEditing
+	stateTravelling
+	stateWaiting
+	stateCreating
 )
 
+// playerDamage is the largest amount of damage a player deals with one hit.
+const playerDamage = 6
+
 // connection represents a connection to the MUD.
 type connection struct {
 	conn   net.Conn
 	name   string
 	state  int
 	output *bufio.Writer
+	outbox *outbox
 	player *player
-	mud    *mud
+	editor *editor
+
+	// world is the world the connection is in, which changes when the
+	// player steps through a portal
+	world atomic.Pointer[mud]
+
+	input     []queuedCommand
+	waitUntil time.Time
+	recorder  *recorder
+
+	// hashing is set while a password the connection gave is hashed, and
+	// passwordFailures counts the wrong ones it has given
+	hashing          bool
+	passwordFailures int
+
+	lastPrompt string
+
+	// when the connection was made, and where from
+	connectedAt time.Time
+	geo         geoInfo
+
+	// console is set for the operator's console on the server's terminal
+	console bool
+
+	// json is set for a client speaking the JSON-line protocol
+	json bool
+
+	// mxp is set once the client agrees to MXP, clientCharset to the
+	// character set it negotiates, and clientWidth to its window width
+	mxp           bool
+	clientCharset string
+	clientWidth   int
+
+	// the last of the connection's output, and how much of it was
+	// written after it last dropped
+	scrollback scrollback
+	missedFrom int
+
+	// resumeToken lets a player whose connection drops take up their
+	// character again, which waits link-dead until linkDeadTask has them
+	// quit. resumed is the connection a new one took up.
+	resumeToken  string
+	linkDead     bool
+	linkDeadTask *task
+	resumed      atomic.Pointer[connection]
+
+	// createdWaiting is set for a new character waiting in line to play,
+	// and rolled for one whose attributes were rolled rather than bought
+	createdWaiting bool
+	rolled         bool
+
+	// conversation is the player's place in a dialogue with an NPC, and
+	// scene the cutscene they are watching
+	conversation *conversation
+	scene        *scenePlay
+
+	// scriptWaits are the tasks that carry on scripts run for the player
+	// once a wait step is over
+	scriptWaits []*task
 }
 
 // mud represents the MUD server.
 type mud struct {
+	// mu guards all game state. Connection goroutines hold it while
+	// queueing input, and the game loop and tick scheduler while running
+	// commands or tasks.
+	mu   sync.Mutex
+	wake chan struct{}
+
+	// name is the world's name among those the host runs, and stateDir
+	// where its state is saved
+	name     string
+	stateDir string
+	host     *host
+
 	listener net.Listener
+	// jsonListener, if the world has a JSON address, takes bot clients
+	jsonListener net.Listener
 	conns    map[string]*connection
+	// waiting are the players in line for a place in a full world
+	waiting  []*connection
     rooms    map[string]*room
+	grid     map[string]*room
+
+	itemTemplates map[string]*itemTemplate
+	npcTemplates  map[string]*npcTemplate
+	lootTables    map[string]*lootTable
+	affixes       affixData
+	achievements  []*achievement
+	worldEvents   []*worldEvent
+	activeEvents  map[string]*activeWorldEvent
+
+	holidays       []*holiday
+	activeHolidays map[string]*activeHoliday
+	games         map[string]*gameConfig
+	gatherSkills  map[string]*gatherSkill
+	languages     map[string]*language
+	recipes       []*recipe
+	classes       []*class
+	quests        []*quest
+	cutscenes     []*cutscene
+	areas         []*area
+	danglingExits []graphEdge
+
+	// entrance is the room players start in, with the cutscene new ones
+	// watch there, and starts the rooms some start in instead;
+	// worldStarts are the rules the world file gave
+	entrance      string
+	entranceScene string
+	starts        []startRule
+	worldStarts   []startRule
+
+	// the areas, as zones that can be paged out while idle
+	zones        []*zone
+	roomZones    map[string]*zone
+	zonePageIns  int
+	zonePageOuts int
+
+	// where connections come from: the load balancers trusted to name the
+	// client behind them, the addresses refused, and when each address
+	// last connected
+	proxies  []*net.IPNet
+	banned   []*net.IPNet
+	connects map[string][]time.Time
+
+	// the config's country policies, by ISO code, and the addresses the
+	// multiplay rules pass over
+	countryPolicies map[string]string
+	exemptAddrs     []*net.IPNet
+
+	houseTemplates map[string]*houseTemplate
+	houses         []*house
+	bounties       []*bounty
+	deaths         []death
+	reports        []*report
+	snapshots      []*snapshot
+
+	shopConfigs []*shopConfig
+	shops       []*shop
+	ledger      *ledger
+
+	roomTriggers []*roomTrigger
+	trapConfigs  []*trapConfig
+	traps        []*trap
+
+	scriptCommands map[string]*scriptCommand
+	commandsPath   string
+	catalogs       map[string]catalog
+
+	vehicleConfigs   []*vehicleConfig
+	vehicles         []*vehicle
+	furnitureConfigs []*furnitureConfig
+	roomFlagConfigs  []*roomFlagConfig
+	season           *season
+
+	events       *eventBus
+	store        *store
+	leaderboards *leaderboards
+	config       *config
+	greetingFile *textFile
+	motdFile     *textFile
+	scheduler    *scheduler
 }
 
 // positionHash returns a hash of the given x and y position.
@@ -37,13 +209,26 @@ func positionHash(x, y int) string {
     return fmt.Sprintf("%04d%04d", x, y)
 }
 
-// room represents a room in the MUD.
+// room represents a room in the MUD. Exits lead to the IDs of other
+// rooms. Rooms on the map also have a position, which is used for mapping
+// and for linking neighbors.
 type room struct {
+	id          string
+	uid         string
     name        string
     description string
+	mapped      bool
     x           int
     y           int
     exits       map[string]string
+	flags       map[string]bool
+	doors       map[string]*door
+	cliffs      map[string]bool
+	items       []*item
+	npcs        []*npc
+
+	// capacity, if set, is how many players fit in the room at once
+	capacity int
 }
 
 // newRoom creates a new room.
@@ -52,15 +237,167 @@ func newRoom(name, description string) *room {
         name:        name,
         description: description,
         exits:       make(map[string]string),
+		flags:       make(map[string]bool),
+		doors:       make(map[string]*door),
+		cliffs:      make(map[string]bool),
     }
 }
 
 // player represents a player in the MUD.
 type player struct {
-	health int
-	mana   int
-	x      int
-	y      int
+	// uid stays with the character through renames and moves between
+	// servers
+	uid string
+
+	health    int
+	maxHealth int
+	mana      int
+	maxMana   int
+	room      string
+	gold      int
+	luck      int
+	inventory []*item
+	equipment map[string]*item
+
+	// appearance holds the cosmetic items the player wears, by slot
+	appearance map[string]*item
+
+	// attributes are the primary attributes, from str to con, before
+	// equipment bonuses
+	attributes map[string]int
+
+	// pronouns are what others' messages call the player by
+	pronouns pronouns
+
+	// class is the ID of the player's class, and practices the sessions
+	// they have to spend at trainers
+	class     string
+	practices int
+
+	// remorts counts the times the player has started over at level 1,
+	// and pastClasses are the classes they followed before
+	remorts     int
+	pastClasses []string
+
+	// talents are the ranks the player has learned in their class's
+	// talents, and talentStats the stats those give
+	talents     map[string]int
+	talentStats map[string]int
+
+	// quests are the quests the player is on, and questsDone those they
+	// have finished
+	quests     map[string]*questProgress
+	questsDone map[string]questDone
+
+	// holidayGifts is the year the player was last given each holiday's
+	// gifts
+	holidayGifts map[string]int
+
+	level        int
+	xp           int
+	kills        int
+	deathCount   int
+	deaths       []death
+	visited      map[string]bool
+	achievements map[string]time.Time
+
+	playtime  time.Duration
+	sessions  int
+	lastLogin time.Time
+	loginAt   time.Time
+
+	admin    bool
+	helper   bool
+	channels map[string]bool
+
+	// multiplay is set by staff to let the character play alongside
+	// others from the same address
+	multiplay bool
+
+	pose     string
+	wager    *wager
+
+	skills    map[string]int
+	gathering *task
+
+	locker *locker
+	mail   []mailMessage
+
+	consent     bool
+	bounty      int
+	arenaRating int
+	jailedUntil time.Time
+	jailTask    *task
+
+	followers       []*npc
+	followerRecords []followerRecord
+
+	effects map[string]time.Time
+
+	position  string
+	furniture *item
+
+	stance   string
+	fighting *npc
+	rival    *connection
+	party    *party
+	invite   *party
+	flags    playerFlags
+
+	intoxication int
+	disguise     string
+	language     string
+	locale       string
+	screenReader bool
+	brief        bool
+	noMXP        bool
+	charset      string
+	width        int
+
+	homeRoom string
+
+	// world is the world the player is in, and elsewhere their place in
+	// each of the others they have been to
+	world     string
+	elsewhere map[string]worldPlace
+
+	automation automation
+
+	passwordHash string
+	salt         string
+}
+
+// newPlayer creates a new player with full health and mana.
+func newPlayer() *player {
+	return &player{
+		uid:       newUUID(),
+		health:    100,
+		maxHealth: 100,
+		mana:      100,
+		maxMana:   100,
+		equipment: make(map[string]*item),
+
+		appearance: make(map[string]*item),
+		attributes: newAttributes(),
+		pronouns:   neutralPronouns,
+		talents:    make(map[string]int),
+		quests:     make(map[string]*questProgress),
+		questsDone: make(map[string]questDone),
+
+		holidayGifts: make(map[string]int),
+
+		level:        1,
+		visited:      make(map[string]bool),
+		achievements: make(map[string]time.Time),
+		channels:     make(map[string]bool),
+		skills:       make(map[string]int),
+		effects:      make(map[string]time.Time),
+		stance:       stanceBalanced,
+		flags:        defaultFlags,
+		arenaRating:  arenaStartRating,
+		elsewhere:    make(map[string]worldPlace),
+		automation:   automation{aliases: make(map[string]string)},
+	}
 }
 
 // newMud creates a new MUD server.
@@ -69,107 +406,573 @@ func newMud() *mud {
         listener: nil,
         conns:    make(map[string]*connection),
         rooms:    make(map[string]*room),
+		grid:     make(map[string]*room),
+
+		itemTemplates: make(map[string]*itemTemplate),
+		npcTemplates:  make(map[string]*npcTemplate),
+		lootTables:    make(map[string]*lootTable),
+		activeEvents:  make(map[string]*activeWorldEvent),
+
+		activeHolidays: make(map[string]*activeHoliday),
+		roomZones:     make(map[string]*zone),
+		connects:      make(map[string][]time.Time),
+
+		events:       newEventBus(),
+		name:         defaultWorld.Name,
+		stateDir:     defaultWorld.State,
+		host:         newHost(),
+		store:        newStore("players"),
+		leaderboards: &leaderboards{},
+		config:       defaultConfig(),
+		greetingFile: newTextFile("data/greeting.txt"),
+		motdFile:     newTextFile("data/motd.txt"),
+		scheduler:    newScheduler(),
+		ledger:       newLedger(),
+		wake:         make(chan struct{}, 1),
     }
 }
 
 
-// listen starts listening for connections on the given address.
-func (m *mud) listen(addr string) error {
-	listener, err := net.Listen("tcp", addr)
+// listen starts listening for connections on the world's address, and on
+// its JSON address if it has one.
+func (m *mud) listen(wc worldConfig) error {
+	listener, err := net.Listen("tcp", wc.Addr)
 	if err != nil {
 		return err
 	}
 	m.listener = listener
+	if wc.JSONAddr != "" {
+		if m.jsonListener, err = net.Listen("tcp", wc.JSONAddr); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
-// acceptConnection accepts a new connection and adds it to the list of connections.
-func (m *mud) acceptConnection() (*connection, error) {
-	conn, err := m.listener.Accept()
-	if err != nil {
-		return nil, err
+// acceptConnection looks up where a new connection comes from, then adds
+// it to the list of connections and greets it, or turns it away and
+// returns nil if its address is banned, its country denied, or it is
+// connecting too often.
+func (m *mud) acceptConnection(conn net.Conn, json bool) *connection {
+	c := newConnection(m, conn)
+	c.json = json
+	c.geo = m.host.geoip.lookup(addrIP(conn.RemoteAddr()))
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if reason := m.refusal(c); reason != "" {
+		log.Printf("refused a connection from %s: %s", c.whereFrom(), reason)
+		c.write(c.tr("connect." + reason))
+		c.close()
+		return nil
 	}
-	c := newConnection(conn)
 	m.conns[conn.RemoteAddr().String()] = c
-	c.write("Welcome to the MUD!\n\nEnter your name: ")
-	return c, nil
+	if !json {
+		c.offerOptions()
+	}
+	c.write(m.greeting(c) + c.tr("login.name"))
+	c.flush()
+	return c
 }
 
 // newConnection creates a new connection.
-func newConnection(conn net.Conn) *connection {
-	return &connection{
+func newConnection(m *mud, conn net.Conn) *connection {
+	c := &connection{
 		conn:   conn,
 		output: bufio.NewWriter(conn),
 		state:  stateLogin,
+
+		connectedAt: time.Now(),
 	}
+	c.scrollback.size = m.config.ScrollbackLines
+	c.world.Store(m)
+	c.startOutput()
+	return c
 }
 
-// handleConnection processes commands from the given connection.
-func (m *mud) handleConnection(c *connection) {
-	scanner := bufio.NewScanner(c.conn)
-	for scanner.Scan() {
-		line := scanner.Text()
-		if line == "" {
-			continue
+// handleConnection takes a newly accepted connection, learning who is
+// behind it if it came through a load balancer, then reads commands from
+// it and queues them for the game loop. json is set for connections to the
+// world's JSON address. A panic hangs up on the connection rather than
+// taking the server down.
+func (m *mud) handleConnection(conn net.Conn, json bool) {
+	r := protect(fmt.Sprintf("connection from %s", conn.RemoteAddr()), func() {
+		client, err := m.unwrapProxy(conn)
+		if err != nil {
+			log.Printf("dropping a connection from %s: %v", conn.RemoteAddr(), err)
+			conn.Close()
+			return
 		}
-		parts := strings.SplitN(line, " ", 2)
-		cmd := parts[0]
-		var args []string
-		if len(parts) == 2 {
-			args = strings.Split(parts[1], " ")
+		if c := m.acceptConnection(client, json); c != nil {
+			m.readInput(c)
 		}
-		switch c.state {
-		case stateLogin:
-			m.handleLogin(c, cmd)
-		case statePassword:
-			m.handlePassword(c, cmd)
-		case statePlaying:
-			m.handlePlaying(c, cmd, args)
-		case stateDead:
-			// do nothing
+	})
+	if r != nil {
+		conn.Close()
+	}
+}
+
+// readInput reads commands from the connection and queues them for the
+// game loop until it drops. If reading panics the player is still saved
+// as if the line had dropped.
+func (m *mud) readInput(c *connection) {
+	protect(fmt.Sprintf("reading from %s", c.conn.RemoteAddr()), func() {
+		scanner := bufio.NewScanner(newTelnetReader(c))
+		for scanner.Scan() {
+			line := scanner.Text()
+			if line == "" {
+				continue
+			}
+			for !c.current().world.Load().queueInput(c, line) {
+			}
 		}
+	})
+
+	// the connection dropped without quitting, so save and clean up
+	for !c.current().world.Load().hangUp(c) {
 	}
 }
 
+// queueInput records and queues a line of input from the connection. It
+// reports false if the connection left for another world first, and the
+// line should go there instead.
+func (m *mud) queueInput(c *connection, line string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	c = c.current()
+	if c.world.Load() != m {
+		return false
+	}
+	line = decodeInput(line, c.charset())
+	if c.recorder != nil {
+		c.recorder.input(line)
+	}
+	if c.json || c.state == stateLogin && strings.HasPrefix(line, "{") {
+		cmd, ok := c.jsonInput(line)
+		if !ok {
+			c.flush()
+			return true
+		}
+		line = cmd
+	}
+	if c.state == stateLogin && strings.HasPrefix(strings.ToLower(line), "resume ") {
+		m.resume(c, strings.TrimSpace(line[len("resume "):]))
+		c.flush()
+		return true
+	}
+	if c.state != stateDead {
+		m.enqueue(c, line, 0)
+	}
+	c.flush()
+	return true
+}
+
+// hangUp saves and cleans up after a connection that dropped without
+// quitting. It reports false if the connection left for another world
+// first, which must clean up instead.
+func (m *mud) hangUp(c *connection) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	c = c.current()
+	if c.world.Load() != m {
+		return false
+	}
+	c.clearQueue()
+	switch c.state {
+	case statePlaying, stateEditing:
+		if !m.linkDead(c) {
+			m.quit(c)
+		}
+	case stateTravelling:
+		// it never arrived, so it was last saved leaving the world before
+		c.state = stateDead
+		c.stopRecording()
+		m.host.release(c.name, m)
+	case stateLogin, statePassword, stateWaiting, stateCreating:
+		m.forget(c)
+		c.state = stateDead
+	}
+	c.close()
+	return true
+}
+
 // handleLogin processes login commands from the given connection.
 func (m *mud) handleLogin(c *connection, cmd string) {
 	c.name = cmd
-	if len(c.name) < 3 {
-		c.write("Name must be at least 3 characters.\nEnter your name: ")
+	if problem := nameProblem(c.name); problem != "" {
+		c.write(c.tr(problem) + "\n" + c.tr("login.name"))
+		return
+	}
+	if old, ok := m.conns[nameKey(c.name)]; ok && old.linkDead {
+		// the password takes up the character where it was left
+		c.write(c.tr("login.password"))
+		c.state = statePassword
 		return
 	}
-	if _, ok := m.conns[c.name]; ok {
-		c.write("Name is already in use.\nEnter your name: ")
+	if _, ok := m.conns[nameKey(c.name)]; ok || strings.EqualFold(c.name, consoleName) || !m.host.claim(c.name, m) {
+		c.write(c.tr("login.name_in_use") + c.tr("login.name"))
 		return
 	}
-	m.conns[c.name] = c
+	m.conns[nameKey(c.name)] = c
 	delete(m.conns, c.conn.RemoteAddr().String())
-	c.write("Enter your password: ")
+	c.write(c.tr("login.password"))
 	c.state = statePassword
 }
 
+// maxPasswordFailures is how many wrong passwords a connection may give
+// before it is hung up on.
+const maxPasswordFailures = 3
+
 // handlePassword processes password commands from the given connection.
 func (m *mud) handlePassword(c *connection, cmd string) {
 	if len(cmd) < 5 || !strings.ContainsAny(cmd, "0123456789") {
-		c.write("Password must be at least 5 characters and contain a number.\nEnter your password: ")
+		c.write(c.tr("login.password_weak") + c.tr("login.password"))
+		return
+	}
+	if old := m.conns[nameKey(c.name)]; old != c {
+		// the character was link-dead when the name was given
+		if old == nil || !old.linkDead {
+			c.write(c.tr("login.name"))
+			c.state = stateLogin
+			return
+		}
+		m.verifyPassword(c, cmd, old.player.salt, old.player.passwordHash, func(salt, hash string) {
+			if m.conns[nameKey(c.name)] != old || !old.linkDead {
+				// they quit while the password was checked
+				c.write(c.tr("login.name"))
+				c.state = stateLogin
+				return
+			}
+			if hash != "" {
+				old.player.salt, old.player.passwordHash = salt, hash
+			}
+			m.reattach(c, old)
+		})
 		return
 	}
-	c.player = &player{}
-	c.write(fmt.Sprintf("Welcome, %s!\n\n", c.name))
+
+	// load the existing character, or create a new one with this password
+	rec, err := m.store.load(c.name)
+	switch {
+	case err == nil:
+		m.verifyPassword(c, cmd, rec.Salt, rec.PasswordHash, func(salt, hash string) {
+			if m.multiplayRefused(c, rec.Multiplay) {
+				return
+			}
+			// the name as it was first given, whatever its case now
+			c.name = rec.Name
+			c.player = playerFromRecord(rec)
+			if hash != "" {
+				// replace the old kind of hash while the password is at hand
+				c.player.salt, c.player.passwordHash = salt, hash
+				m.savePlayer(c)
+			}
+			c.write(c.tr("login.welcome_back", "name", c.name))
+			m.admit(c, false)
+		})
+	case os.IsNotExist(err) && m.countryPolicy(c) == policyNoNew:
+		log.Printf("refused a new character %s from %s: no new characters from %s", c.name, c.conn.RemoteAddr(), c.geo.Country)
+		c.write(c.tr("login.no_new"))
+		c.close()
+	case os.IsNotExist(err) && m.multiplayRefused(c, false):
+	case os.IsNotExist(err):
+		var salt, hash string
+		m.passwordAside(c, func() {
+			salt, hash = newPasswordHash(cmd)
+		}, func() {
+			c.player = newPlayer()
+			c.player.salt, c.player.passwordHash = salt, hash
+			c.write(c.tr("login.welcome", "name", c.name))
+			m.savePlayer(c)
+			m.startCreation(c)
+		})
+	default:
+		log.Printf("error loading %s: %v", c.name, err)
+		c.write(c.tr("login.load_failed") + c.tr("login.password"))
+	}
+}
+
+// verifyPassword checks a password given at login against a salted hash,
+// calling ok if it is right with a new salt and hash to replace an old
+// kind of hash, or "" for both. A connection that gives too many wrong
+// passwords is hung up on.
+func (m *mud) verifyPassword(c *connection, password, salt, hash string, ok func(salt, hash string)) {
+	var right, stale bool
+	var freshSalt, freshHash string
+	m.passwordAside(c, func() {
+		right, stale = checkPassword(password, salt, hash)
+		if stale {
+			freshSalt, freshHash = newPasswordHash(password)
+		}
+	}, func() {
+		if right {
+			ok(freshSalt, freshHash)
+			return
+		}
+		c.passwordFailures++
+		if c.passwordFailures >= maxPasswordFailures {
+			log.Printf("hung up on %s after %d wrong passwords for %s", c.conn.RemoteAddr(), c.passwordFailures, c.name)
+			c.write(c.tr("login.password_too_many"))
+			c.close()
+			return
+		}
+		c.write(c.tr("login.password_wrong") + c.tr("login.password"))
+	})
+}
+
+// passwordAside runs work, which hashes a password and so is slow on
+// purpose, without holding the world's lock, and the connection's commands
+// wait meanwhile. done runs under the lock afterwards, if the connection is
+// still giving its password.
+func (m *mud) passwordAside(c *connection, work, done func()) {
+	c.hashing = true
+	go func() {
+		work()
+		m.locked(func() {
+			c.hashing = false
+			m.wakeLoop()
+			if c.state == statePassword {
+				done()
+			}
+		})
+	}()
+}
+
+// enterGame brings a player who has just logged in into the world.
+func (m *mud) enterGame(c *connection, created bool) {
 	c.state = statePlaying
+	m.startRecording(c)
+	c.player.enterWorld(m.host, m.name)
+	m.applyTalents(c.player)
+	m.locate(c.player)
+	c.player.visited[c.player.room] = true
+	m.wakeRoom(c.player.room)
+	m.showMOTD(c, c.player.lastLogin)
+	m.showRecentKills(c)
+	c.player.startSession()
+	c.player.admin = m.config.isAdmin(c.name) || c.console
+	if n := c.player.unreadMail(); n > 0 {
+		c.write(c.tr("login.unread_mail", "count", n))
+	}
+	m.autoJoinChannels(c)
+	m.resumeSentence(c)
+	m.restoreFollowers(c)
+	m.host.online(c.name, c.ip())
+	m.notifyLogin(c, created)
+	m.issueResumeToken(c)
+	if created {
+		m.playCutscene(c, m.startCutscene(c.player))
+	}
+	m.events.publish(event{kind: eventLogin, conn: c, room: m.rooms[c.player.room]})
 }
 
 // handlePlaying processes playing commands from the given connection.
 func (m *mud) handlePlaying(c *connection, cmd string, args []string) {
+	if m.expandAlias(c, cmd, args) {
+		return
+	}
+	if m.converse(c, cmd) || m.watchCutscene(c, cmd) {
+		c.writePrompt()
+		return
+	}
 	switch cmd {
-    case "look":
-        m.look(c)
+    case "look", "l":
+        if len(args) > 0 {
+            m.lookDirection(c, expandDirection(args[0]))
+        } else {
+            m.look(c)
+        }
 	case "who":
 		m.who(c)
 	case "say":
 		m.say(c, args)
+	case "shout", "yell":
+		m.shout(c, args)
 	case "quit":
 		m.quit(c)
+	case "delete":
+		m.deleteCharacter(c, args)
+	case "export":
+		m.exportCommand(c)
+	case "backup":
+		m.backupCommand(c, args)
+	case "worldcheck":
+		m.worldcheckCommand(c)
+	case "zones":
+		m.zonesCommand(c)
+	case "userlist", "users":
+		m.userlist(c)
+	case "multiplay":
+		m.multiplayCommand(c, args)
+	case "replay":
+		m.replayCommand(c, args)
+	case "bug", "typo", "idea":
+		m.fileReport(c, cmd, args)
+	case "reports":
+		m.reportsCommand(c, args)
+	case "reload":
+		m.reloadCommand(c, args)
+	case "locale":
+		m.localeCommand(c, args)
+	case "mxp":
+		m.mxpCommand(c, args)
+	case "charset":
+		m.charsetCommand(c, args)
+	case "width":
+		m.widthCommand(c, args)
+	case "screenreader":
+		m.screenReaderCommand(c, args)
+	case "map":
+		m.mapCommand(c)
+	case "brief":
+		m.setBrief(c, true)
+	case "verbose":
+		m.setBrief(c, false)
+	case "season":
+		m.seasonCommand(c, args)
+	case "alias":
+		m.aliasCommand(c, args)
+	case "unalias":
+		m.unalias(c, args)
+	case "trigger", "triggers":
+		m.triggerCommand(c, args)
+	case "rename":
+		m.renameCharacter(c, args)
+	case "get", "take":
+		m.get(c, args)
+	case "drop":
+		m.drop(c, args)
+	case "inventory", "inv", "i":
+		m.inventory(c)
+	case "shoot", "throw":
+		m.shoot(c, cmd, args)
+	case "kill", "k":
+		m.kill(c, args)
+	case "consider", "con":
+		m.consider(c, args)
+	case "portal":
+		m.portal(c, args)
+	case "deaths":
+		m.deathsCommand(c, args)
+	case "stance":
+		m.stanceCommand(c, args)
+	case "rescue":
+		m.rescue(c, args)
+	case "flee":
+		m.flee(c)
+	case "group":
+		m.groupCommand(c, args)
+	case "toggle", "toggles":
+		m.toggleCommand(c, args)
+	case "sacrifice", "sac":
+		m.sacrifice(c, args)
+	case "trip":
+		m.trip(c, args)
+	case "use":
+		m.use(c, args)
+	case "equip", "wear", "wield":
+		m.equip(c, args)
+	case "remove":
+		m.unequip(c, args)
+	case "equipment", "eq":
+		m.showEquipment(c)
+	case "examine", "exa":
+		m.examine(c, args)
+	case "achievements":
+		m.showAchievements(c)
+	case "top":
+		m.top(c, args)
+	case "score":
+		m.score(c)
+	case "finger", "whois":
+		m.finger(c, args)
+	case "newbie":
+		m.channelSay(c, newbieChannel, args)
+	case "channels":
+		m.showChannels(c)
+	case "join":
+		m.joinChannel(c, args)
+	case "leave":
+		m.leaveChannel(c, args)
+	case "ask":
+		m.ask(c, args)
+	case "helper":
+		m.grantHelper(c, args)
+	case "emote", "me":
+		m.emote(c, args)
+	case "pose":
+		m.pose(c, args)
+	case "motd":
+		m.motd(c, args)
+	case "announce":
+		m.announce(c, args)
+	case "worldevent":
+		m.worldEventCommand(c, args)
+	case "play":
+		m.play(c, args)
+	case "fish", "mine", "forage":
+		m.gather(c, cmd)
+	case "skills":
+		m.showSkills(c)
+	case "craft":
+		m.craft(c, args)
+	case "buy":
+		if len(args) > 0 && args[0] == "room" {
+			m.buyRoom(c, args[1:])
+		} else {
+			m.buy(c, args)
+		}
+	case "sell":
+		m.sell(c, args)
+	case "list":
+		m.listStock(c)
+	case "economy":
+		m.economy(c)
+	case "value":
+		m.appraise(c, args)
+	case "rent":
+		if len(args) > 0 && args[0] == "locker" {
+			m.rentLocker(c)
+		} else {
+			c.write(c.tr("locker.cant_rent"))
+		}
+	case "locker":
+		m.lockerCommand(c, args)
+	case "mail":
+		m.mailCommand(c, args)
+	case "open":
+		m.openDoor(c, args)
+	case "close":
+		m.closeDoor(c, args)
+	case "lock":
+		m.lockDoor(c, args)
+	case "unlock":
+		m.unlockDoor(c, args)
+	case "keys":
+		m.keysCommand(c, args)
+	case "peek":
+		m.peek(c, args)
+	case "search":
+		m.search(c)
+	case "disarm":
+		m.disarmCommand(c, args)
+	case "steal":
+		m.steal(c, args)
+	case "consent":
+		m.consent(c, args)
+	case "wanted":
+		m.wantedList(c)
+	case "bounty", "bounties":
+		m.bountyCommand(c, args)
+	case "hire":
+		m.hire(c, args)
+	case "order":
+		m.order(c, args)
+	case "followers":
+		m.showFollowers(c)
+	case "allow":
+		m.allow(c, args, true)
+	case "disallow":
+		m.allow(c, args, false)
     case "north":
         m.move(c, "north")
     case "east":
@@ -178,50 +981,156 @@ func (m *mud) handlePlaying(c *connection, cmd string, args []string) {
         m.move(c, "south")
     case "west":
         m.move(c, "west")
+	case "up", "down", "northeast", "northwest", "southeast", "southwest":
+		m.move(c, cmd)
+	case "n", "e", "s", "w", "ne", "nw", "se", "sw", "u", "d":
+		m.move(c, directionAbbreviations[cmd])
+	case "run":
+		m.run(c, args)
+	case "stop":
+		if !m.stopRun(c) {
+			c.write(c.tr("run.not_running"))
+		}
+	case "out", "exit":
+		m.move(c, outExit)
+	case "enter":
+		m.enter(c, args)
+	case "sit":
+		m.changePosition(c, positionSitting, args)
+	case "rest":
+		m.changePosition(c, positionResting, args)
+	case "sleep":
+		m.changePosition(c, positionSleeping, args)
+	case "stand", "wake":
+		m.stand(c)
+	case "drink":
+		m.drink(c, args)
+	case "cast":
+		m.cast(c, args)
+	case "train", "practice":
+		m.train(c, args)
+	case "remort":
+		m.remort(c, args)
+	case "talents", "talent":
+		m.talentsCommand(c, args)
+	case "quest", "quests":
+		m.questCommand(c, args)
+	case "talk":
+		m.talk(c, args)
+	case "dig":
+		m.dig(c)
+	case "holidays":
+		m.holidaysCommand(c)
+	case "appearance":
+		m.appearanceCommand(c, args)
+	case "pronouns":
+		m.pronounsCommand(c, args)
+	case "snapshot", "snapshots":
+		m.snapshotCommand(c, args)
+	case "dye":
+		m.dye(c, args)
+	case "speak":
+		m.speak(c, args)
+	case "bind":
+		m.bind(c)
+	case "recall":
+		m.recall(c)
+	case "travel", "waypoints":
+		m.travel(c, args)
+	case "undisguise", "unmask":
+		m.undisguise(c)
+	case "push", "press":
+		m.pushButton(c, args)
 	default:
-		c.write("Unknown command.\n")
+		if !m.runScriptCommand(c, cmd, args) {
+			c.write(c.tr("command.unknown"))
+		}
 	}
 	if c.state == statePlaying {
-		c.write(fmt.Sprintf("%s: %d/%d > ", c.name, c.player.health, c.player.mana))
+		c.writePrompt()
 	}
 }
 
 // move moves the player in the given direction if an exit exists in that direction.
 func (m *mud) move(c *connection, dir string) {
     p := c.player
+    dir = m.sway(c, dir)
 
     // check if an exit exists in the given direction
-    exitHash, ok := m.getRoomByPosition(p.x, p.y).exits[dir]
+    exitID, ok := m.rooms[p.room].exits[dir]
+    if d := m.rooms[p.room].doors[dir]; d != nil && d.concealed() {
+        ok = false
+    }
     if !ok {
         // no exit exists in the given direction, so do nothing
-        c.write("You cannot go that way.\n")
+        c.write(c.tr("move.no_exit"))
+        return
+    }
+
+    if d, ok := m.rooms[p.room].doors[dir]; ok && d.closed {
+        c.write(c.tr("move.closed", "door", capitalize(d.name)))
+        return
+    }
+    if !m.canEnter(c, exitID) {
+        return
+    }
+    if !m.hasRoomFor(c, m.rooms[exitID]) {
+        return
+    }
+    if !m.requireStanding(c) {
+        return
+    }
+    if m.inFight(c) {
+        c.write(c.tr("move.fighting"))
+        return
+    }
+    if !m.enterWater(c, m.rooms[exitID]) {
+        return
+    }
+    if p.affected(effectStuck) {
+        c.write(c.tr("move.stuck"))
+        return
+    }
+    if !m.climb(c, m.rooms[p.room], dir) {
+        return
+    }
+    if !m.exitTrap(c, m.rooms[p.room], dir) {
         return
     }
 
     // move the player to the room in the given direction
-    p.x, p.y = m.getRoomPositionFromHash(exitHash)
-    c.write(fmt.Sprintf("You move %s.\n", dir))
-    m.look(c)
-}
+    from := m.rooms[p.room]
+    p.room = exitID
+    c.write(moveMessage(c, dir))
+    m.glance(c)
+    m.bringFollowers(c, from, m.rooms[exitID])
 
-// getRoomByPosition returns the room at the given position.
-func (m *mud) getRoomByPosition(x, y int) *room {
-    return m.rooms[positionHash(x, y)]
+    // record the visit and let subscribers know
+    p.visited[exitID] = true
+    m.events.publish(event{kind: eventEnterRoom, conn: c, room: m.rooms[exitID]})
 }
 
-// getRoomPositionFromHash returns the position of the room with the given position hash.
-func (m *mud) getRoomPositionFromHash(hash string) (int, int) {
-    x, y := 0, 0
-    fmt.Sscanf(hash, "%04d%04d", &x, &y)
-    return x, y
+// getRoomByPosition returns the room at the given position on the map.
+func (m *mud) getRoomByPosition(x, y int) *room {
+    return m.grid[positionHash(x, y)]
 }
 
 // who displays the list of players in the playing state to the given connection.
 func (m *mud) who(c *connection) {
-	c.write("Connected players:\n")
+	c.write(c.tr("who.header"))
 	for _, conn := range m.conns {
 		if conn.state == statePlaying {
-			c.write(fmt.Sprintf("- %s\n", conn.name))
+			tags := ""
+			if conn.player.admin {
+				tags += c.tr("who.staff")
+			}
+			if conn.player.helper {
+				tags += c.tr("who.helper")
+			}
+			if conn.player.remorts > 0 {
+				tags += c.tr("who.remort", "count", conn.player.remorts)
+			}
+			c.write(c.tr("who.player", "name", c.playerLink(conn, conn.nameFor(c)), "tags", tags))
 		}
 	}
 }
@@ -231,102 +1140,145 @@ func (m *mud) say(c *connection, args []string) {
 	if len(args) == 0 {
 		return
 	}
-	msg := strings.Join(args, " ")
-	for _, conn := range m.conns {
-		if conn.state == statePlaying {
-			conn.write(fmt.Sprintf("%s says: %s\n", c.name, msg))
-		}
-	}
+	msg := c.player.slur(strings.Join(args, " "))
+	hearers := func(conn *connection) bool { return m.canHear(c, conn) }
+	m.send(hearers, rendered(m.speechView(c), func(conn *connection) string {
+		return conn.tr("say.says", "name", capitalize(c.nameFor(conn)), "message", m.hearLanguage(c, conn, msg))
+	}).asAside(c))
+	m.hearSpeech(c, msg)
 }
 
 // handleLook processes the look command for the given connection.
 func (m *mud) look(c *connection) {
-    // get the player's current position
-    x, y := c.player.x, c.player.y
+	m.showRoom(c, false)
+}
+
+// glance shows the player the room they have just moved into, leaving out
+// its description if they have asked for brief mode.
+func (m *mud) glance(c *connection) {
+	m.showRoom(c, c.player.brief)
+}
 
-    // look up the room at the player's position
-    key := positionHash(x, y)
-    r, ok := m.rooms[key]
+// showRoom describes the player's room to them, without its description
+// if brief is set.
+func (m *mud) showRoom(c *connection, brief bool) {
+	m.wakeRoom(c.player.room)
+    if m.asleep(c) {
+        return
+    }
+    if c.player.affected(effectBlinded) {
+        c.write(c.tr("look.blind"))
+        return
+    }
+
+    // look up the player's current room
+    r, ok := m.rooms[c.player.room]
     if !ok {
-        c.write("You are lost in the void.\n")
+        c.write(c.tr("look.void"))
         return
     }
 
     // write the room name and description
     c.write(fmt.Sprintf("%s\n", r.name))
-    c.write(fmt.Sprintf("%s\n", r.description))
+    if !brief {
+        c.write(fmt.Sprintf("%s\n", m.roomDescription(r)))
+    }
 
     // write the exits from the room
-    c.write("Exits:\n")
+    c.write(c.tr("look.exits"))
     for dir, key := range r.exits {
         r2, ok := m.rooms[key]
         if !ok {
             continue
         }
-        c.write(fmt.Sprintf("%s - %s\n", dir, r2.name))
+        if d, ok := r.doors[dir]; ok && d.concealed() {
+            continue
+        }
+        if d, ok := r.doors[dir]; ok && d.closed {
+            c.write(c.tr("look.exit_closed", "dir", dir, "door", d.name))
+            continue
+        }
+        c.write(c.tr("look.exit", "dir", c.mxpSend(dir, dir), "room", r2.name))
     }
+
+	// write the other players, NPCs, and items in the room
+	for _, conn := range m.playersInRoom(r.id) {
+		if conn != c {
+			c.write(fmt.Sprintf("%s\n", conn.player.roomLine(c.playerLink(conn, capitalize(conn.nameFor(c))))))
+		}
+	}
+	for _, n := range r.npcs {
+		c.write(fmt.Sprintf("%s\n", c.npcLine(n)))
+	}
+	for _, it := range r.items {
+		c.write(c.tr("look.item", "item", c.itemLink(it, colorize(capitalize(it.name), it.color), "get")+c.rarityNote(it)))
+	}
+	m.showVehicles(c, r)
+	m.showTraps(c)
 }
 
 
+// setBrief turns brief mode on or off. In brief mode moving into a room
+// doesn't repeat its description; look still shows it.
+func (m *mud) setBrief(c *connection, brief bool) {
+	c.player.brief = brief
+	m.savePlayer(c)
+	if brief {
+		c.write(c.tr("brief.on"))
+	} else {
+		c.write(c.tr("brief.off"))
+	}
+}
+
 // quit disconnects the given connection.
 func (m *mud) quit(c *connection) {
-	c.write("Bye!\n")
-	delete(m.conns, c.name)
-	delete(m.conns, c.conn.RemoteAddr().String())
-	c.conn.Close()
-	c.state = stateDead
-	for _, conn := range m.conns {
-		if conn.state == statePlaying {
-			conn.write(fmt.Sprintf("%s has quit.\n", c.name))
-		}
+	c.write(c.tr("quit.bye"))
+	if w := c.player.wager; w != nil {
+		// refund a game left unfinished
+		c.player.gold += w.bet
+		c.player.wager = nil
+	}
+	if t := c.player.jailTask; t != nil {
+		m.scheduler.cancel(t.id)
 	}
+	m.savePlayer(c)
+	m.stashFollowers(c)
+	m.leaveGroup(c)
+	m.forget(c)
+	c.stopRecording()
+	c.close()
+	c.state = stateDead
+	m.send(everyone(), rendered((*connection).locale, func(conn *connection) string {
+		return conn.tr("quit.others", "name", c.name)
+	}).asAside(nil))
 }
 
-// addRoom adds a room at the given position.
-func (m *mud) addRoom(x, y int, r *room) {
-    r.x, r.y = x, y
-    m.rooms[positionHash(x, y)] = r
+// addRoom adds a room to the world under the given ID, at the given
+// position on the map.
+func (m *mud) addRoom(id string, x, y int, r *room) {
+    m.placeRoom(id, r)
+    r.mapped, r.x, r.y = true, x, y
+    m.grid[positionHash(x, y)] = r
 }
 
 // addExit adds an exit in the given direction from the given room to another room.
 func (m *mud) addExit(r *room, dir string) {
     // check if a room already exists in the given direction
-    x, y := r.x, r.y
-    switch dir {
-    case "north":
-        y++
-    case "east":
-        x++
-    case "south":
-        y--
-    case "west":
-        x--
-    default:
+    off, ok := directionOffsets[dir]
+    if !ok || !r.mapped {
         return
     }
-    key := positionHash(x, y)
-    r2, ok := m.rooms[key]
-    if !ok {
+    r2 := m.getRoomByPosition(r.x+off[0], r.y+off[1])
+    if r2 == nil {
         // no room exists in the given direction, so do nothing
         return
     }
 
     // add the exit from the given room to the room in the given direction
-    r.exits[dir] = key
+    r.exits[dir] = r2.id
 
     // add the return exit from the room in the given direction to the given room
-    var returnDir string
-    switch dir {
-    case "north":
-        returnDir = "south"
-    case "east":
-        returnDir = "west"
-    case "south":
-        returnDir = "north"
-    case "west":
-        returnDir = "east"
-    }
-    r2.exits[returnDir] = positionHash(r.x, r.y)
+    r2.exits[reverseDirections[dir]] = r.id
 }
 
 // getExit looks up the room in the given direction.
@@ -338,10 +1290,37 @@ func (r *room) getExit(direction string, rooms map[string]*room) *room {
     return rooms[key]
 }
 
-// write sends the given message to the connection.
+// write adds the given message to the output gathered for the connection.
 func (c *connection) write(msg string) {
-	c.output.WriteString(msg)
-	c.output.Flush()
+	c.outbox.pending.WriteString(msg)
+	c.scrollback.write(msg)
+	if c.recorder != nil {
+		c.recorder.output(msg)
+	}
+	if c.player != nil {
+		c.player.automation.watchOutput(msg)
+	}
+}
+
+// writePrompt sends the player's status prompt to the connection after the
+// rest of the output gathered for it, however many times it is asked for.
+func (c *connection) writePrompt() {
+	c.outbox.promptDue = true
+}
+
+// prompt returns the player's status prompt.
+func (c *connection) prompt() string {
+	if !c.screenReader() {
+		return c.tr("prompt", "name", c.name, "health", c.player.health, "mana", c.player.mana)
+	}
+	// a screen reader reads the prompt after every command, so it only
+	// gives health and mana when they have changed
+	prompt := c.tr("prompt.status", "health", c.player.health, "mana", c.player.mana)
+	if prompt == c.lastPrompt {
+		return c.tr("prompt.short")
+	}
+	c.lastPrompt = prompt
+	return prompt
 }
 
 // center returns the given string padded with spaces so that it is centered
@@ -356,11 +1335,19 @@ func center(s string, width int) string {
     return strings.Repeat(" ", left) + s + strings.Repeat(" ", right)
 }
 
+// capitalize returns the given string with its first letter in upper case.
+func capitalize(s string) string {
+	if s == "" {
+		return s
+	}
+	return strings.ToUpper(s[:1]) + s[1:]
+}
+
 // createMap creates the map of rooms and adds them to the given mud struct.
 func (m *mud)createMap() {
     // create rooms
 	r1 := newRoom("Mall Entrance", "The mall entrance is bustling with people coming and going.")
-	r2 := newRoom("Directory", "The directory is a large board listing all the stores in the mall.")
+	r2 := newRoom("Directory", "The directory is a large board listing all the stores in the mall. A bounty board is pinned up beside it.")
 	r3 := newRoom("Food Court", "The food court is full of the smells and sounds of various restaurants.")
 	r4 := newRoom("Arcade", "The arcade is filled with flashing lights and the sounds of games.")
 	r5 := newRoom("Restroom", "The restroom is clean and well-maintained.")
@@ -369,18 +1356,68 @@ func (m *mud)createMap() {
 	r8 := newRoom("Clothing Store", "The clothing store is full of racks of clothes in the latest styles.")
 	r9 := newRoom("Shoe Store", "The shoe store is filled with rows of shoes of all shapes, sizes, and colors.")
 	r10 := newRoom("Sporting Goods Store", "The sporting goods store is full of a wide variety of sports equipment and apparel.")
+	r11 := newRoom("Janitor's Closet", "The cramped closet smells of bleach. Mops and buckets line the walls.")
+	r12 := newRoom("Security Office", "A bare holding cell in the mall security office. The door is locked from the outside.")
+	r13 := newRoom("Maintenance Tunnel", "A narrow service tunnel runs behind the arcade cabinets, humming with old wiring.")
+	r14 := newRoom("Fountain Pool", "You are out in the wide pool of the mall fountain. Coins glint on the tiles far below.")
+	r15 := newRoom("Fountain Depths", "Deep beneath the fountain spray, drifting coins settle among the pipes.")
+	r16 := newRoom("Broken Escalator", "The stalled escalator climbs steeply toward the upper level. Several steps are missing.")
+	r17 := newRoom("Mezzanine", "A quiet balcony overlooks the whole mall. The railing to the east has been torn away.")
+	r18 := newRoom("Atrium Air", "There is nothing beneath you but the open atrium.")
+	r19 := newRoom("Above the Arcade", "The flashing arcade signs rush up at you.")
+	r20 := newRoom("Photo Booth", "A curtained booth with a padded stool and a camera behind smudged glass.")
 
     // add rooms to the map
-    m.rooms[positionHash(0, 0)] = r1
-    m.rooms[positionHash(1, 0)] = r2
-    m.rooms[positionHash(2, 0)] = r3
-    m.rooms[positionHash(3, 0)] = r4
-    m.rooms[positionHash(3, 1)] = r5
-    m.rooms[positionHash(3, 2)] = r6
-    m.rooms[positionHash(2, 2)] = r7
-    m.rooms[positionHash(1, 2)] = r8
-    m.rooms[positionHash(0, 2)] = r9
-    m.rooms[positionHash(0, 1)] = r10
+    m.addRoom(defaultStartRoom, 0, 0, r1)
+    m.addRoom("directory", 1, 0, r2)
+    m.addRoom("food_court", 2, 0, r3)
+    m.addRoom("arcade", 3, 0, r4)
+    m.addRoom("restroom", 3, 1, r5)
+    m.addRoom("toy_store", 3, 2, r6)
+    m.addRoom("electronics_store", 2, 2, r7)
+    m.addRoom("clothing_store", 1, 2, r8)
+    m.addRoom("shoe_store", 0, 2, r9)
+    m.addRoom("sporting_goods_store", 0, 1, r10)
+    m.addRoom("janitors_closet", 1, 1, r11)
+	m.addRoom("maintenance_tunnel", 4, 0, r13)
+	m.addRoom("fountain_pool", 0, -1, r14)
+	m.addRoom("fountain_depths", 1, -1, r15)
+
+	// the jail has no exits, and the upper level and photo booth are
+	// reached by up, down, and named exits, so none of them are on the map
+	m.placeRoom(jailRoom, r12)
+	m.placeRoom("broken_escalator", r16)
+	m.placeRoom("mezzanine", r17)
+	m.placeRoom("atrium_air", r18)
+	m.placeRoom("above_arcade", r19)
+	m.placeRoom("photo_booth", r20)
+
+	// flag the rooms where gathering skills can be used
+	r1.flags["fishing"] = true
+	r15.flags["fishing"] = true
+	r3.flags["foraging"] = true
+	r7.flags["mining"] = true
+
+	// flag the rooms that need swimming or a boat, and those that also need
+	// water breathing
+	r14.flags["water"] = true
+	r15.flags["underwater"] = true
+
+	// flag the rooms that need climbing, and the open air where anyone
+	// not levitating falls
+	r16.flags["cliff"] = true
+	r18.flags["air"] = true
+	r19.flags["air"] = true
+
+	// flag the rooms where player housing can be bought
+	r2.flags["housing"] = true
+	r8.flags["housing"] = true
+
+	// flag the rooms with rentable lockers
+	r5.flags["lockers"] = true
+
+	// flag the room with the bounty board
+	r2.flags["bounties"] = true
 
 	// add exits
 	m.addExit(r1, "north")
@@ -394,6 +1431,7 @@ func (m *mud)createMap() {
 	m.addExit(r4, "east")
 	m.addExit(r5, "south")
 	m.addExit(r5, "east")
+	m.addExit(r5, "north")
 	m.addExit(r6, "west")
 	m.addExit(r6, "north")
 	m.addExit(r7, "west")
@@ -404,22 +1442,185 @@ func (m *mud)createMap() {
 	m.addExit(r9, "north")
 	m.addExit(r10, "west")
 	m.addExit(r10, "north")
+	m.addExit(r11, "south")
+	m.addExit(r14, "east")
+	m.addExit(r3, "northeast")
+	m.addExit(r10, "northeast")
+	m.linkNamed(r4, "booth", r20)
+	m.linkRooms(r3, "up", r16)
+	m.linkRooms(r16, "up", r17)
+	m.linkRooms(r17, "east", r18)
+	m.linkRooms(r18, "down", r19)
+	m.linkRooms(r19, "down", r4)
+	r3.cliffs["up"] = true
+	r16.cliffs["up"] = true
+
+	// add doors
+	m.addDoor(r11, "south", "a steel door", "janitor_key")
+	m.addSecretDoor(r4, "east", "a hidden panel")
 }
 
 
 func main() {
-	m := newMud()
+	httpAddr := flag.String("http", "localhost:8081", "address for the HTTP API, or empty to disable it")
+	grpcAddr := flag.String("grpc", "", "address for the gRPC control API, or empty to disable it")
+	configPath := flag.String("config", "config.json", "path to the server config file")
+	restore := flag.String("restore", "", "restore a character from a backup, as <backup>/<name>, and exit")
+	importPath := flag.String("import", "", "convert a ROM 2.4 or Merc area file into data/areas and exit")
+	exportPath := flag.String("export-world", "", "write the world's rooms, NPCs, and items to a world file and exit")
+	exportChar := flag.String("export-character", "", "write a character to a signed transfer file, <name>.transfer.json, and exit")
+	importChar := flag.String("import-character", "", "add the character in a signed transfer file to this server and exit")
+	worldcheck := flag.Bool("worldcheck", false, "check the world data for problems and exit")
+	bots := flag.Int("bot", 0, "load test a running server with this many scripted clients, then exit")
+	botAddr := flag.String("bot-addr", "localhost:8080", "address of the server the bots connect to")
+	botTime := flag.Duration("bot-time", time.Minute, "how long the bots run for")
+	dev := flag.Bool("dev", false, "run a throwaway server for development: the mall alone, test accounts, nothing kept, and a REPL on stdin")
+	seed := flag.Int64("seed", 1, "the random seed in dev mode, so runs play out the same")
+	console := flag.Bool("console", true, "when stdin is a terminal, play the staff character \"console\" from it")
+	flag.Parse()
 
-	m.createMap()
+	if *dev {
+		rand.Seed(*seed)
+	} else {
+		rand.Seed(time.Now().UnixNano())
+	}
+	if *bots > 0 {
+		runBots(*bots, *botAddr, *botTime)
+		return
+	}
+
+	m := newMud()
 
-	if err := m.listen("localhost:8080"); err != nil {
+	cfg, err := loadConfig(*configPath)
+	scratch := ""
+	if *dev {
+		cfg, scratch, err = devSetup()
+	}
+	if err != nil {
 		panic(err)
 	}
-	for {
-		c, err := m.acceptConnection()
+	m.config = cfg
+	if *restore != "" {
+		backup, name, _ := strings.Cut(*restore, "/")
+		if err := m.store.restoreCharacter(backup, name); err != nil {
+			log.Fatal(err)
+		}
+		log.Printf("restored %s from backup %s", name, backup)
+		return
+	}
+	if *exportChar != "" {
+		path := strings.ToLower(*exportChar) + ".transfer.json"
+		if err := m.exportCharacter(*exportChar, path); err != nil {
+			log.Fatal(err)
+		}
+		log.Printf("exported %s to %s", *exportChar, path)
+		return
+	}
+	if *importPath != "" {
+		out, err := importAreaFile(*importPath, filepath.Join("data", "areas"))
 		if err != nil {
+			log.Fatal(err)
+		}
+		log.Printf("imported %s into %s", *importPath, out)
+		return
+	}
+	// the first world answers for the whole server on the command line
+	h := newHost()
+	worlds, err := cfg.worlds()
+	if err != nil {
+		panic(err)
+	}
+	for _, wc := range worlds {
+		if _, err := h.bootWorld(cfg, wc); err != nil {
+			panic(fmt.Errorf("world %s: %v", wc.Name, err))
+		}
+	}
+	m = h.worlds[0]
+	if *exportPath != "" {
+		if err := m.exportWorldFile(*exportPath); err != nil {
+			log.Fatal(err)
+		}
+		log.Printf("exported the world to %s", *exportPath)
+		return
+	}
+	if *importChar != "" {
+		rec, dropped, err := m.importCharacter(*importChar)
+		if err != nil {
+			log.Fatal(err)
+		}
+		for _, name := range dropped {
+			log.Printf("left behind %s, which can't be transferred or is already here", name)
+		}
+		log.Printf("imported %s from %s", rec.Name, *importChar)
+		return
+	}
+	if *worldcheck {
+		total := 0
+		for _, w := range h.worlds {
+			problems := w.checkWorld()
+			for _, p := range problems {
+				log.Printf("%s: %s", w.name, p)
+			}
+			if len(problems) == 0 {
+				log.Printf("the world %s checks out: %d rooms, no problems", w.name, len(w.rooms))
+			}
+			total += len(problems)
+		}
+		if total > 0 {
+			log.Fatalf("the world check found %d problems", total)
+		}
+		return
+	}
+	if *dev {
+		if err := m.createDevAccounts(); err != nil {
 			panic(err)
 		}
-		go m.handleConnection(c)
 	}
+	if err := h.startWebhooks(cfg.Webhooks); err != nil {
+		panic(err)
+	}
+	for i, w := range h.worlds {
+		if err := w.start(worlds[i]); err != nil {
+			panic(fmt.Errorf("world %s: %v", w.name, err))
+		}
+	}
+	if *httpAddr != "" {
+		go func() {
+			if err := m.serveHTTP(*httpAddr); err != nil {
+				log.Printf("http server: %v", err)
+			}
+		}()
+	}
+	if *grpcAddr != "" {
+		go func() {
+			if err := h.serveGRPC(*grpcAddr); err != nil {
+				log.Printf("grpc server: %v", err)
+			}
+		}()
+	}
+	for _, w := range h.worlds {
+		go w.serve(w.listener, false)
+		if w.jsonListener != nil {
+			go w.serve(w.jsonListener, true)
+		}
+	}
+	if *dev {
+		// the REPL ending, or an interrupt, shuts the server down and
+		// throws away the scratch directory
+		quit := make(chan os.Signal, 1)
+		signal.Notify(quit, os.Interrupt, syscall.SIGTERM)
+		go func() {
+			m.devREPL(os.Stdin, os.Stdout)
+			quit <- os.Interrupt
+		}()
+		<-quit
+		if err := os.RemoveAll(scratch); err != nil {
+			log.Printf("error removing %s: %v", scratch, err)
+		}
+		return
+	}
+	if *console && interactive() {
+		go m.runConsole(os.Stdin, os.Stdout)
+	}
+	select {}
 }
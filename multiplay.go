@@ -0,0 +1,141 @@
+package main
+
+import (
+	"log"
+	"net"
+	"sort"
+	"strings"
+)
+
+// ip returns the address the connection comes from.
+func (c *connection) ip() net.IP {
+	return addrIP(c.conn.RemoteAddr())
+}
+
+// online records the address a character is playing from, for the
+// multiplay rules.
+func (h *host) online(name string, ip net.IP) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.addrs[strings.ToLower(name)] = ip.String()
+}
+
+// fromAddr returns the characters playing from the address in any of the
+// host's worlds, other than the named one.
+func (h *host) fromAddr(ip net.IP, except string) []string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	var names []string
+	for name, addr := range h.addrs {
+		if addr == ip.String() && name != strings.ToLower(except) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// multiplayExempt reports whether the multiplay rules pass over the
+// connection: staff have allowed its character to multiplay, or it comes
+// from one of the config's exempt addresses, such as a school's.
+func (m *mud) multiplayExempt(c *connection) bool {
+	return c.player != nil && c.player.multiplay || inNets(m.exemptAddrs, c.ip())
+}
+
+// multiplayRefused turns a login away if its address already has as many
+// characters playing as the config allows, reporting whether it did.
+// exempt is whether staff have allowed the character to multiplay, as
+// it isn't playing yet.
+func (m *mud) multiplayRefused(c *connection, exempt bool) bool {
+	limit := m.config.MaxPerAddress
+	if limit <= 0 || exempt || m.multiplayExempt(c) {
+		return false
+	}
+	others := m.host.fromAddr(c.ip(), c.name)
+	if len(others) < limit {
+		return false
+	}
+	log.Printf("refused %s from %s: already playing %s", c.name, c.ip(), strings.Join(others, ", "))
+	c.write(c.tr("login.multiplay", "count", limit))
+	c.close()
+	return true
+}
+
+// sameAddressMember returns a member of the group playing from the same
+// address as the connection, if the config forbids them grouping.
+func (m *mud) sameAddressMember(c *connection, members []*connection) *connection {
+	if !m.config.NoSameAddressGroups || m.multiplayExempt(c) {
+		return nil
+	}
+	for _, member := range members {
+		if member != c && member.ip().Equal(c.ip()) && !m.multiplayExempt(member) {
+			return member
+		}
+	}
+	return nil
+}
+
+// multiplayCommand shows staff the addresses with more than one character
+// playing, or toggles whether a character may multiplay.
+func (m *mud) multiplayCommand(c *connection, args []string) {
+	if !c.player.admin {
+		c.write(c.tr("command.unknown"))
+		return
+	}
+	if len(args) == 0 {
+		m.showMultiplay(c)
+		return
+	}
+	conn, ok := m.conns[nameKey(args[0])]
+	if !ok || conn.state != statePlaying {
+		c.write(c.tr("multiplay.they_not_online"))
+		return
+	}
+	p := conn.player
+	p.multiplay = !p.multiplay
+	if p.multiplay {
+		c.write(c.tr("multiplay.may_play_alongside", "name", conn.name))
+	} else {
+		c.write(c.tr("multiplay.subject_multiplay_rules", "name", conn.name))
+	}
+	m.savePlayer(conn)
+}
+
+// showMultiplay lists the addresses in this world with more than one
+// character playing, and the rules in force.
+func (m *mud) showMultiplay(c *connection) {
+	var rules []string
+	if m.config.MaxPerAddress > 0 {
+		rules = append(rules, c.tr("multiplay.rule_max", "max", m.config.MaxPerAddress))
+	}
+	if m.config.NoSameAddressGroups {
+		rules = append(rules, c.tr("multiplay.rule_no_groups"))
+	}
+	if len(rules) == 0 {
+		rules = append(rules, c.tr("multiplay.no_rules"))
+	}
+	c.write(c.tr("multiplay.multiplay_rules", "rules", strings.Join(rules, "; ")))
+
+	byAddr := make(map[string][]string)
+	for _, conn := range m.conns {
+		if conn.state == statePlaying {
+			name := conn.name
+			if m.multiplayExempt(conn) {
+				name += c.tr("multiplay.exempt")
+			}
+			addr := conn.ip().String()
+			byAddr[addr] = append(byAddr[addr], name)
+		}
+	}
+	shared := false
+	for _, addr := range sortedKeys(byAddr) {
+		if names := byAddr[addr]; len(names) > 1 {
+			sort.Strings(names)
+			c.write(c.tableRow("  %-24s %s\n", []string{"", "playing"}, addr, strings.Join(names, ", ")))
+			shared = true
+		}
+	}
+	if !shared {
+		c.write(c.tr("multiplay.no_one_here"))
+	}
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// MXP mode changes. The server locks clients into locked mode, where
+// nothing looks like a tag, and switches to secure mode only around the
+// tags it sends.
+const (
+	mxpSecureLine = "\x1b[1z"
+	mxpLockedLine = "\x1b[2z"
+	mxpLockLocked = "\x1b[7z"
+)
+
+// mxpEscaper escapes text for MXP secure mode.
+var mxpEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", "\"", "&quot;")
+
+// startMXP begins MXP with a client that has agreed to it.
+func (c *connection) startMXP() {
+	c.telnet(telnetIAC, telnetSB, optMXP, telnetIAC, telnetSE)
+	c.queueOutput(mxpLockLocked)
+}
+
+// mxpOn reports whether the connection's output should carry MXP links:
+// the client agreed to MXP and the player hasn't turned it off.
+func (c *connection) mxpOn() bool {
+	return c.mxp && !c.json && c.player != nil && !c.player.noMXP
+}
+
+// mxpSend returns text that, for a client using MXP, sends the command
+// when clicked.
+func (c *connection) mxpSend(text, command string) string {
+	if !c.mxpOn() {
+		return text
+	}
+	return fmt.Sprintf("%s<send href=\"%s\">%s</send>%s", mxpSecureLine, mxpEscaper.Replace(command), mxpEscaper.Replace(text), mxpLockedLine)
+}
+
+// playerLink returns a player's name, as given, linked to fingering them
+// unless they are disguised.
+func (c *connection) playerLink(conn *connection, name string) string {
+	if !strings.EqualFold(conn.nameFor(c), conn.name) {
+		return name
+	}
+	return c.mxpSend(name, "finger "+conn.name)
+}
+
+// itemLink returns an item's name linked to a command on it, by its first
+// keyword.
+func (c *connection) itemLink(it *item, name, verb string) string {
+	if len(it.keywords) == 0 {
+		return name
+	}
+	return c.mxpSend(name, verb+" "+it.keywords[0])
+}
+
+// mxpCommand shows whether the player's client is using MXP, or turns
+// its links on or off.
+func (m *mud) mxpCommand(c *connection, args []string) {
+	p := c.player
+	if len(args) == 0 {
+		switch {
+		case !c.mxp:
+			c.write(c.tr("mxp.client_hasnt_agreed"))
+		case p.noMXP:
+			c.write(c.tr("mxp.off"))
+		default:
+			c.write(c.tr("mxp.on"))
+		}
+		return
+	}
+	switch strings.ToLower(args[0]) {
+	case "on":
+		p.noMXP = false
+	case "off":
+		p.noMXP = true
+	default:
+		c.write(c.tr("mxp.usage_mxp_off"))
+		return
+	}
+	m.savePlayer(c)
+	if p.noMXP {
+		c.write(c.tr("mxp.now_off"))
+	} else {
+		c.write(c.tr("mxp.now_on"))
+	}
+}
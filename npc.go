@@ -0,0 +1,239 @@
+package main
+
+import "time"
+
+// npcTemplate describes a non-player character as defined in the NPC data file.
+type npcTemplate struct {
+	ID          string    `json:"id"`
+	Name        string    `json:"name"`
+	Keywords    []string  `json:"keywords"`
+	Description string    `json:"description"`
+	Level       int       `json:"level"`
+	Health      int       `json:"health"`
+	Damage      int       `json:"damage"`
+	Loot        string    `json:"loot"`
+	Guard       bool      `json:"guard"`
+	Wage        int       `json:"wage"`
+	Aggressive  bool      `json:"aggressive"`
+	Spawns      []roomRef `json:"spawns"`
+
+	// Pronouns names the NPC's pronouns, as a player would choose them;
+	// they/them/their if not given
+	Pronouns string `json:"pronouns,omitempty"`
+
+	Triggers []*speechTrigger `json:"triggers"`
+	Boss     *bossScript      `json:"boss"`
+	Trainer  *trainerConfig   `json:"trainer,omitempty"`
+	Dialogue *dialogue        `json:"dialogue,omitempty"`
+}
+
+// npc represents a non-player character in the MUD.
+type npc struct {
+	id          string
+	name        string
+	keywords    []string
+	description string
+	level       int
+	health      int
+	maxHealth   int
+	damage      int
+	loot        string
+	guard       bool
+	wage        int
+	aggressive  bool
+	pronouns    pronouns
+
+	// set while the NPC is hired by a player
+	master    string
+	following bool
+	paidUntil time.Time
+	inventory []*item
+
+	// set while the NPC is in a fight
+	fighting      *connection
+	threat        map[*connection]int
+	disarmedUntil time.Time
+	trippedUntil  time.Time
+
+	// set for a boss, and for the adds it summons
+	rounds   int
+	phase    int
+	summoner *npc
+}
+
+// newNPC creates a new NPC from the given template.
+func newNPC(t *npcTemplate) *npc {
+	return &npc{
+		id:          t.ID,
+		name:        t.Name,
+		keywords:    t.Keywords,
+		description: t.Description,
+		level:       t.Level,
+		health:      t.Health,
+		maxHealth:   t.Health,
+		damage:      t.Damage,
+		loot:        t.Loot,
+		guard:       t.Guard,
+		wage:        t.Wage,
+		aggressive:  t.Aggressive,
+		pronouns:    npcPronouns(t.Pronouns),
+	}
+}
+
+// npcPronouns reads an NPC's pronouns, defaulting to they/them/their.
+func npcPronouns(set string) pronouns {
+	if p, ok := parsePronouns(set); ok {
+		return p
+	}
+	return neutralPronouns
+}
+
+// spawnNPCIn creates an NPC from the template with the given id in the
+// room, returning nil if either doesn't exist.
+func (m *mud) spawnNPCIn(id, roomID string) *npc {
+	t, r := m.npcTemplates[id], m.rooms[roomID]
+	if t == nil || r == nil {
+		return nil
+	}
+	m.wakeRoom(r.id)
+	n := newNPC(t)
+	r.npcs = append(r.npcs, n)
+	return n
+}
+
+// spawnNPCs places every NPC template in each of its spawn rooms.
+func (m *mud) spawnNPCs() {
+	for _, t := range m.npcTemplates {
+		for _, ref := range t.Spawns {
+			r := m.roomFor(ref)
+			if r == nil {
+				continue
+			}
+			r.npcs = append(r.npcs, newNPC(t))
+		}
+	}
+}
+
+// findNPC returns the first NPC in the room matching the keyword.
+func (r *room) findNPC(keyword string) *npc {
+	for _, n := range r.npcs {
+		if matchKeywords(n.keywords, keyword) {
+			return n
+		}
+	}
+	return nil
+}
+
+// hasNPC reports whether the NPC is in the room.
+func (r *room) hasNPC(n *npc) bool {
+	for _, other := range r.npcs {
+		if other == n {
+			return true
+		}
+	}
+	return false
+}
+
+// removeNPC removes the given NPC from the room.
+func (r *room) removeNPC(n *npc) {
+	for i, other := range r.npcs {
+		if other == n {
+			r.npcs = append(r.npcs[:i], r.npcs[i+1:]...)
+			return
+		}
+	}
+}
+
+// kill attacks an NPC in the player's room. The player lands the first blow
+// and the fight goes on each combat round until one side falls.
+func (m *mud) kill(c *connection, args []string) {
+	if len(args) == 0 {
+		c.write(c.tr("kill.kill_whom"))
+		return
+	}
+	p := c.player
+	r := m.rooms[p.room]
+	if r == nil {
+		c.write(c.tr("kill.nothing_here_fight"))
+		return
+	}
+	n := r.findNPC(args[0])
+	if n == nil {
+		if target := m.findPlayerNear(c, args[0]); target != nil {
+			m.fightPlayer(c, target)
+			return
+		}
+		c.write(c.tr("common.they_arent_here"))
+		return
+	}
+	if n.master != "" {
+		c.write(c.tr("kill.service", "name", capitalize(n.name), "master", n.master))
+		return
+	}
+	if f := m.opponent(c); f == n {
+		c.write(c.tr("kill.already_fighting", "name", n.name))
+		return
+	} else if f != nil {
+		// switching targets takes a moment to turn
+		c.lag(combatRound)
+		c.write(c.tr("kill.turn_attack", "name", n.name))
+		m.engage(c, n)
+		return
+	}
+	if !m.requireStanding(c) {
+		return
+	}
+
+	m.fightNoise(r)
+	c.write(c.tr("kill.attack", "name", n.name))
+	m.engage(c, n)
+	m.strike(c, r, n)
+}
+
+// npcDeath removes a slain NPC from the room and leaves its corpse with any loot.
+func (m *mud) npcDeath(c *connection, r *room, n *npc) {
+	c.write(c.tr("death.have_slain", "name", n.name))
+	r.removeNPC(n)
+	m.dismissAdds(r, n)
+	for _, conn := range m.conns {
+		if conn.player != nil && conn.player.fighting == n {
+			conn.player.fighting = nil
+		}
+	}
+
+	corpse := newCorpse(n.name)
+	var rare []*item
+	if t, ok := m.lootTables[n.loot]; ok {
+		var items []*item
+		var gold int
+		items, rare, gold = t.roll(m, c.player.totalLuck())
+		corpse.contents = append(corpse.contents, items...)
+		if gold > 0 {
+			corpse.contents = append(corpse.contents, newGold(gold))
+			m.goldCreated("loot", gold)
+		}
+	}
+	r.items = append(r.items, corpse)
+	m.collectSpoils(c, r, corpse)
+
+	c.player.kills++
+	m.recordNPCDeath(c, r, n)
+	m.events.publish(event{kind: eventKill, conn: c, npc: n, room: r})
+	for _, it := range rare {
+		m.events.publish(event{kind: eventRareDrop, conn: c, npc: n, room: r, item: it})
+	}
+	m.gainXP(c, 10*n.level)
+}
+
+// playerDeath restores a slain player and returns them to their bound
+// shrine or the nearest graveyard.
+func (m *mud) playerDeath(c *connection, killer string) {
+	p := c.player
+	c.write(c.tr("death.have_been_slain", "killer", killer))
+	m.recordPlayerDeath(c, killer, m.rooms[p.room])
+	m.events.publish(event{kind: eventDeath, conn: c, room: m.rooms[p.room], killer: killer})
+	m.disengage(c)
+	p.health = p.totalMaxHealth()
+	p.room = m.respawnRoom(p, p.room)
+	m.look(c)
+}
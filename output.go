@@ -0,0 +1,202 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"log"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+// outboxSize is how many messages can wait to go out to a client before it
+// is judged to have stopped reading and is disconnected.
+const outboxSize = 1024
+
+// repeatWindow is how soon the same output has to come again to be
+// collapsed with what came before.
+const repeatWindow = 3 * time.Second
+
+// outbox is the queue of a connection's output. A goroutine of its own
+// sends it to the client, so that writing never waits on the network and a
+// slow client can't hold up the game.
+//
+// Output written during a command or tick gathers in pending, and is queued
+// in one piece when it ends, with the prompt last if one is due. The game
+// lock guards these.
+type outbox struct {
+	mu     sync.Mutex
+	queue  chan string
+	closed bool
+
+	pending   strings.Builder
+	promptDue bool
+
+	// answered is set when the pending output answers a command the
+	// player typed, which is always shown in full
+	answered bool
+
+	// last is the last output that arrived unasked, repeats how many
+	// times since it has come again and been held back, and lastAt when
+	// it last came
+	last    string
+	repeats int
+	lastAt  time.Time
+}
+
+// startOutput gives the connection its outbox and starts sending from it.
+func (c *connection) startOutput() {
+	c.outbox = &outbox{queue: make(chan string, outboxSize)}
+	go c.outbox.send(c.output, c.conn)
+}
+
+// send sends queued output to the client as it comes, flushing whenever
+// the queue runs dry, and closes the connection once the queue is closed
+// and empty.
+func (o *outbox) send(output *bufio.Writer, conn net.Conn) {
+	for msg := range o.queue {
+		output.WriteString(msg)
+		if len(o.queue) == 0 {
+			output.Flush()
+		}
+	}
+	output.Flush()
+	conn.Close()
+}
+
+// queueOutput adds a message to the connection's outbox. A client so far
+// behind that the outbox is full is disconnected rather than waited on.
+func (c *connection) queueOutput(msg string) {
+	o := c.outbox
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.closed {
+		return
+	}
+	select {
+	case o.queue <- msg:
+	default:
+		log.Printf("%s stopped reading its output and was disconnected", c.conn.RemoteAddr())
+		o.closed = true
+		close(o.queue)
+		// the sender is likely stuck writing to the client, so hang up
+		// here; the reader then fails and the connection is cleaned up
+		c.conn.Close()
+	}
+}
+
+// flush queues the output gathered for the connection, followed by the
+// prompt if one is due.
+func (c *connection) flush() {
+	o := c.outbox
+	if c.collapseRepeats() {
+		return
+	}
+	if o.promptDue && c.player != nil {
+		if c.json {
+			c.writeJSON(c.status())
+		} else {
+			c.write(c.prompt())
+			c.scrollback.prompt()
+		}
+	}
+	o.promptDue = false
+	c.queuePending()
+}
+
+// queuePending queues the output gathered for the connection, wrapped to
+// its width and in its character set, or as text events for a client in
+// JSON mode.
+func (c *connection) queuePending() {
+	o := c.outbox
+	if o.pending.Len() == 0 {
+		return
+	}
+	msg := o.pending.String()
+	o.pending.Reset()
+	if c.json {
+		if msg = jsonTextLine(msg); msg == "" {
+			return
+		}
+	} else {
+		msg = encodeOutput(wrapText(msg, c.wrapWidth()), c.charset())
+	}
+	c.queueOutput(msg)
+}
+
+// collapseRepeats holds back output that only repeats what the player
+// was just shown, for players with the collapse toggle on, and reports
+// whether it did. Once something else arrives, or the repeats stop
+// coming, the player is told how many times it came, as "... (x5)".
+func (c *connection) collapseRepeats() bool {
+	o := c.outbox
+	answered := o.answered
+	o.answered = false
+	on := c.player != nil && c.player.has(flagCollapse)
+	now := time.Now()
+	block := strings.TrimLeft(o.pending.String(), "\n")
+	if block == "" {
+		if o.repeats > 0 && (!on || now.Sub(o.lastAt) > repeatWindow) {
+			o.pending.WriteString("\n" + o.summary())
+			o.promptDue = true
+			o.last, o.repeats = "", 0
+		}
+		return false
+	}
+	if on && !answered && block == o.last && now.Sub(o.lastAt) <= repeatWindow {
+		o.repeats++
+		o.lastAt = now
+		o.pending.Reset()
+		o.promptDue = false
+		return true
+	}
+	if o.repeats > 0 {
+		msg := o.pending.String()
+		lead := msg[:len(msg)-len(block)]
+		o.pending.Reset()
+		o.pending.WriteString(lead + o.summary() + block)
+	}
+	o.last, o.repeats, o.lastAt = block, 0, now
+	if answered || !on {
+		o.last = ""
+	}
+	return false
+}
+
+// summary tells how many times the held back output came in all, on the
+// end of its last line.
+func (o *outbox) summary() string {
+	return strings.TrimRight(o.last, "\n") + fmt.Sprintf(" (x%d)\n", o.repeats+1)
+}
+
+// flushOutput queues the output gathered for every connection in the world.
+func (m *mud) flushOutput() {
+	for _, c := range m.conns {
+		c.flush()
+	}
+}
+
+// aside writes a message that arrives between the player's own commands,
+// on a line of its own, with their prompt redrawn after it.
+func (c *connection) aside(msg string) {
+	if c.outbox.pending.Len() == 0 {
+		// the client's cursor is still after the last prompt
+		c.write("\n")
+	}
+	c.write(msg)
+	c.writePrompt()
+}
+
+// close hangs up the connection once the output gathered and queued for it
+// has gone out.
+func (c *connection) close() {
+	o := c.outbox
+	c.queuePending()
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if !o.closed {
+		o.closed = true
+		close(o.queue)
+	}
+}
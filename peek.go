@@ -0,0 +1,95 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+)
+
+// lookDirection describes the room through the exit in the given direction,
+// including who can be seen there.
+func (m *mud) lookDirection(c *connection, dir string) {
+	p := c.player
+	if m.asleep(c) {
+		return
+	}
+	if p.affected(effectBlinded) {
+		c.write(c.tr("look.blind"))
+		return
+	}
+	r := m.rooms[p.room]
+	if r == nil {
+		c.write(c.tr("look.see_nothing_void"))
+		return
+	}
+	key, ok := r.exits[dir]
+	if d := r.doors[dir]; d != nil && d.concealed() {
+		ok = false
+	}
+	if !ok {
+		c.write(c.tr("look.see_nothing_special"))
+		return
+	}
+	if d, ok := r.doors[dir]; ok && d.closed {
+		c.write(c.tr("move.closed", "door", capitalize(d.name)))
+		return
+	}
+	r2, ok := m.rooms[key]
+	if !ok {
+		c.write(c.tr("look.see_nothing_special"))
+		return
+	}
+	m.wakeRoom(key)
+
+	c.write(c.tr("look.looking_see", "dir", dir, "room", r2.name))
+	for _, conn := range m.playersInRoom(key) {
+		c.write(fmt.Sprintf("%s\n", conn.player.roomLine(conn.nameFor(c))))
+	}
+	for _, n := range r2.npcs {
+		c.write(fmt.Sprintf("%s\n", c.npcLine(n)))
+	}
+}
+
+// improveSkill gives the player a chance to raise a skill, which shrinks as
+// the skill grows.
+func (m *mud) improveSkill(c *connection, skill string) {
+	p := c.player
+	level := p.skills[skill]
+	if level < maxSkill && rand.Intn(maxSkill) >= level {
+		p.skills[skill] = level + 1
+		c.write(c.tr("skills.skill_improves", "skill", skill, "level", level+1))
+	}
+}
+
+// peek tries to view another player's inventory. Failing the skill check
+// means the target notices.
+func (m *mud) peek(c *connection, args []string) {
+	if len(args) == 0 {
+		c.write(c.tr("peek.peek_whom"))
+		return
+	}
+	p := c.player
+	target := m.findPlayerNear(c, args[0])
+	if target == nil {
+		c.write(c.tr("common.they_arent_here"))
+		return
+	}
+
+	chance := 30 + p.skills["peek"]/2
+	c.lag(skillLag)
+	if rand.Intn(100) >= chance {
+		me, them := playerActor(c), playerActor(target)
+		m.act(toActor, "peek.caught", me, them, "")
+		m.act(toVictim, "peek.caught_victim", me, them, "")
+		m.improveSkill(c, "peek")
+		return
+	}
+
+	c.write(c.tr("peek.carrying", "name", capitalize(target.nameFor(c))))
+	if len(target.player.inventory) == 0 {
+		c.write(c.tr("common.nothing"))
+	}
+	for _, it := range target.player.inventory {
+		c.write(fmt.Sprintf("  %s\n", it.displayName(c)))
+	}
+	m.improveSkill(c, "peek")
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// character positions; the zero value is standing
+const (
+	positionStanding = ""
+	positionSitting  = "sitting"
+	positionResting  = "resting"
+	positionSleeping = "sleeping"
+)
+
+// regenInterval is how often players regain health and mana.
+const regenInterval = 10 * time.Second
+
+// regenRates is how much health and mana a player regains each interval in
+// each position.
+var regenRates = map[string]int{
+	positionStanding: 1,
+	positionSitting:  2,
+	positionResting:  4,
+	positionSleeping: 6,
+}
+
+// furnitureConfig places a piece of furniture in a room.
+type furnitureConfig struct {
+	Item string  `json:"item"`
+	Room roomRef `json:"room"`
+}
+
+// placeFurniture puts the furniture from the furniture data in its rooms.
+func (m *mud) placeFurniture() {
+	for _, cfg := range m.furnitureConfigs {
+		t, ok := m.itemTemplates[cfg.Item]
+		r := m.roomFor(cfg.Room)
+		if !ok || r == nil {
+			continue
+		}
+		r.items = append(r.items, newItem(t))
+	}
+}
+
+// requireStanding reports whether the player is on their feet, telling them
+// to get up if not.
+func (m *mud) requireStanding(c *connection) bool {
+	switch c.player.position {
+	case positionStanding:
+		return true
+	case positionSleeping:
+		c.write(c.tr("position.cant_do_sleep"))
+	default:
+		c.write(c.tr("position.need_stand_up"))
+	}
+	return false
+}
+
+// asleep reports whether the player is asleep, telling them so.
+func (m *mud) asleep(c *connection) bool {
+	if c.player.position == positionSleeping {
+		c.write(c.tr("position.cant_see_anything"))
+		return true
+	}
+	return false
+}
+
+// positionLine returns how the player's position is shown in their room.
+func (p *player) positionLine() string {
+	switch {
+	case p.position == positionStanding:
+		return "is here."
+	case p.furniture != nil:
+		return fmt.Sprintf("is %s on %s.", p.position, p.furniture.name)
+	}
+	return fmt.Sprintf("is %s here.", p.position)
+}
+
+// changePosition sits, rests, or sleeps, optionally on furniture in the room.
+func (m *mud) changePosition(c *connection, position string, args []string) {
+	p := c.player
+	if p.position == position && len(args) == 0 {
+		c.write(c.tr("position.already", "position", position))
+		return
+	}
+	var furniture *item
+	if len(args) > 0 {
+		if args[0] == "on" && len(args) > 1 {
+			args = args[1:]
+		}
+		r := m.rooms[p.room]
+		if r == nil {
+			c.write(c.tr("common.dont_see_here"))
+			return
+		}
+		i := findItem(r.items, args[0])
+		if i < 0 {
+			c.write(c.tr("common.dont_see_here"))
+			return
+		}
+		furniture = r.items[i]
+		if furniture.seats == 0 {
+			c.write(c.tr("position.cant_get_comfortable", "furniture", furniture.displayName(c)))
+			return
+		}
+		if furniture != p.furniture && m.occupants(furniture) >= furniture.seats {
+			c.write(c.tr("position.theres_no_room", "furniture", furniture.displayName(c)))
+			return
+		}
+	}
+	p.position = position
+	p.furniture = furniture
+	verbs := map[string]string{
+		positionSitting:  "sit down",
+		positionResting:  "lie back and rest",
+		positionSleeping: "go to sleep",
+	}
+	if furniture != nil {
+		c.write(c.tr("position.on_furniture", "verb", verbs[position], "furniture", furniture.displayName(c)))
+	} else {
+		c.write(c.tr("position.changed", "verb", verbs[position]))
+	}
+}
+
+// stand gets the player back on their feet.
+func (m *mud) stand(c *connection) {
+	p := c.player
+	switch p.position {
+	case positionStanding:
+		c.write(c.tr("position.already_standing"))
+		return
+	case positionSleeping:
+		c.write(c.tr("position.wake_up_stand"))
+	default:
+		c.write(c.tr("position.stand_up"))
+	}
+	p.position = positionStanding
+	p.furniture = nil
+}
+
+// occupants returns how many players are using a piece of furniture.
+func (m *mud) occupants(furniture *item) int {
+	n := 0
+	for _, conn := range m.conns {
+		if conn.state == statePlaying && conn.player.furniture == furniture {
+			n++
+		}
+	}
+	return n
+}
+
+// regenerate restores some health and mana to every player, more so the
+// more comfortable they are, and mana more so the wiser.
+func (m *mud) regenerate() {
+	for _, conn := range m.conns {
+		if conn.state != statePlaying {
+			continue
+		}
+		p := conn.player
+		rate := regenRates[p.position]
+		if p.furniture != nil {
+			rate++
+		}
+		p.health += rate
+		if max := p.totalMaxHealth(); p.health > max {
+			p.health = max
+		}
+		mana := rate + p.attributeBonus("wis")
+		if mana < 1 {
+			mana = 1
+		}
+		p.mana += mana
+		if p.mana > p.maxMana {
+			p.mana = p.maxMana
+		}
+	}
+}
@@ -0,0 +1,234 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// proxyHeaderTimeout is how long a load balancer has to say who it is
+// passing a connection on for.
+const proxyHeaderTimeout = 5 * time.Second
+
+// proxyV1MaxLength is the longest a text PROXY header can be.
+const proxyV1MaxLength = 107
+
+// proxyV2Signature begins a binary PROXY header.
+var proxyV2Signature = []byte("\r\n\r\n\x00\r\nQUIT\n")
+
+// proxiedConn is a connection passed on by a load balancer, which reports
+// the address of the client behind it rather than the balancer's own.
+type proxiedConn struct {
+	net.Conn
+	r      *bufio.Reader
+	remote net.Addr
+}
+
+// Read reads what followed the PROXY header.
+func (p *proxiedConn) Read(b []byte) (int, error) {
+	return p.r.Read(b)
+}
+
+// RemoteAddr returns the client's address.
+func (p *proxiedConn) RemoteAddr() net.Addr {
+	return p.remote
+}
+
+// parseNets parses a list of addresses and CIDR ranges from the config.
+// A bare address stands for itself alone.
+func parseNets(list []string) ([]*net.IPNet, error) {
+	var nets []*net.IPNet
+	for _, s := range list {
+		if !strings.Contains(s, "/") {
+			ip := net.ParseIP(s)
+			if ip == nil {
+				return nil, fmt.Errorf("bad address %q", s)
+			}
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 8*net.IPv4len
+			}
+			nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, n, err := net.ParseCIDR(s)
+		if err != nil {
+			return nil, fmt.Errorf("bad address range %q", s)
+		}
+		nets = append(nets, n)
+	}
+	return nets, nil
+}
+
+// inNets reports whether the address falls in any of the ranges.
+func inNets(nets []*net.IPNet, ip net.IP) bool {
+	for _, n := range nets {
+		if ip != nil && n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// addrIP returns the IP address of a connection's address, or nil if it
+// hasn't one.
+func addrIP(addr net.Addr) net.IP {
+	if tcp, ok := addr.(*net.TCPAddr); ok {
+		return tcp.IP
+	}
+	host, _, err := net.SplitHostPort(addr.String())
+	if err != nil {
+		return nil
+	}
+	return net.ParseIP(host)
+}
+
+// unwrapProxy reads the PROXY header a trusted load balancer sends ahead
+// of a connection, returning the connection as coming from the client it
+// names. Connections from anywhere else are returned as they are, so
+// players may connect directly as well as through the balancer.
+func (m *mud) unwrapProxy(conn net.Conn) (net.Conn, error) {
+	if !inNets(m.proxies, addrIP(conn.RemoteAddr())) {
+		return conn, nil
+	}
+	conn.SetReadDeadline(time.Now().Add(proxyHeaderTimeout))
+	defer conn.SetReadDeadline(time.Time{})
+	r := bufio.NewReader(conn)
+	remote, err := readProxyHeader(r)
+	if err != nil {
+		return nil, err
+	}
+	if remote == nil {
+		// the balancer's own health checks, or a client it can't name
+		remote = conn.RemoteAddr()
+	}
+	return &proxiedConn{Conn: conn, r: r, remote: remote}, nil
+}
+
+// readProxyHeader reads a PROXY header of either version, returning the
+// client's address, or nil if the header doesn't give one.
+func readProxyHeader(r *bufio.Reader) (net.Addr, error) {
+	start, err := r.Peek(len(proxyV2Signature))
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case bytes.Equal(start, proxyV2Signature):
+		return readProxyV2(r)
+	case bytes.HasPrefix(start, []byte("PROXY ")):
+		return readProxyV1(r)
+	}
+	return nil, errors.New("no PROXY header")
+}
+
+// readProxyV1 reads a text PROXY header, such as
+// "PROXY TCP4 203.0.113.7 192.0.2.1 51234 8080\r\n".
+func readProxyV1(r *bufio.Reader) (net.Addr, error) {
+	line, err := r.ReadSlice('\n')
+	if err != nil || len(line) > proxyV1MaxLength || !bytes.HasSuffix(line, []byte("\r\n")) {
+		return nil, errors.New("bad PROXY header")
+	}
+	fields := strings.Fields(string(line))
+	if len(fields) >= 2 && fields[1] == "UNKNOWN" {
+		return nil, nil
+	}
+	if len(fields) != 6 || (fields[1] != "TCP4" && fields[1] != "TCP6") {
+		return nil, fmt.Errorf("bad PROXY header %q", strings.TrimSpace(string(line)))
+	}
+	ip := net.ParseIP(fields[2])
+	port, err := strconv.Atoi(fields[4])
+	if ip == nil || err != nil || port < 0 || port > 65535 {
+		return nil, fmt.Errorf("bad PROXY header %q", strings.TrimSpace(string(line)))
+	}
+	return &net.TCPAddr{IP: ip, Port: port}, nil
+}
+
+// readProxyV2 reads a binary PROXY header: the signature, the version and
+// command, the address family, and the length of the addresses that
+// follow, along with any extensions, which are skipped.
+func readProxyV2(r *bufio.Reader) (net.Addr, error) {
+	var head [16]byte
+	if _, err := io.ReadFull(r, head[:]); err != nil {
+		return nil, err
+	}
+	if head[12]>>4 != 2 {
+		return nil, fmt.Errorf("unsupported PROXY version %d", head[12]>>4)
+	}
+	body := make([]byte, binary.BigEndian.Uint16(head[14:16]))
+	if _, err := io.ReadFull(r, body); err != nil {
+		return nil, err
+	}
+	switch head[12] & 0x0f {
+	case 0x0:
+		// LOCAL: the balancer speaking for itself
+		return nil, nil
+	case 0x1:
+	default:
+		return nil, fmt.Errorf("unsupported PROXY command %d", head[12]&0x0f)
+	}
+	switch family := head[13] >> 4; {
+	case family == 0x1 && len(body) >= 12:
+		return &net.TCPAddr{IP: net.IP(body[0:4]), Port: int(binary.BigEndian.Uint16(body[8:10]))}, nil
+	case family == 0x2 && len(body) >= 36:
+		return &net.TCPAddr{IP: net.IP(body[0:16]), Port: int(binary.BigEndian.Uint16(body[32:34]))}, nil
+	case family == 0x1 || family == 0x2:
+		return nil, errors.New("short PROXY header")
+	}
+	// unspecified or Unix socket addresses, which say nothing useful
+	return nil, nil
+}
+
+// refusal returns why a new connection is turned away, or "" to let it
+// in: its address is "banned", its country's policy is to "deny" it, or
+// it is "throttled" for connecting more often in the last minute than the
+// config allows. Connections let in are counted.
+func (m *mud) refusal(c *connection) string {
+	ip := c.ip()
+	if inNets(m.banned, ip) {
+		return "banned"
+	}
+	if m.countryPolicy(c) == policyDeny {
+		return policyDeny
+	}
+	limit := m.config.ConnectionsPerMinute
+	if limit <= 0 || ip == nil {
+		return ""
+	}
+	key := ip.String()
+	recent := pruneConnects(m.connects[key], time.Now())
+	if len(recent) >= limit {
+		m.connects[key] = recent
+		return "throttled"
+	}
+	m.connects[key] = append(recent, time.Now())
+	return ""
+}
+
+// pruneConnects drops the connection times more than a minute old.
+func pruneConnects(times []time.Time, now time.Time) []time.Time {
+	i := 0
+	for i < len(times) && now.Sub(times[i]) >= time.Minute {
+		i++
+	}
+	return times[i:]
+}
+
+// forgetConnects forgets the addresses that haven't connected in the last
+// minute, so the throttle doesn't grow without end.
+func (m *mud) forgetConnects() {
+	now := time.Now()
+	for key, times := range m.connects {
+		if times = pruneConnects(times, now); len(times) == 0 {
+			delete(m.connects, key)
+		} else {
+			m.connects[key] = times
+		}
+	}
+}
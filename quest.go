@@ -0,0 +1,510 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// quest is a task an NPC gives players, as defined in the quest data file.
+// A quest is offered once the player is of its level and has finished the
+// quests it requires, which may name the choice they made at the end of
+// one as quest/choice. Finishing it pays its reward and leads on to its
+// next quest, or to the reward and next quest of the choice the player
+// makes if it has choices. A daily quest can be done again once the world
+// clock has passed midnight since it was last finished, and a holiday
+// quest is only offered while its holiday is on, once each year. Intro
+// and Outro are cutscenes played when the quest starts and ends.
+type quest struct {
+	ID          string            `json:"id"`
+	Name        string            `json:"name"`
+	Description string            `json:"description"`
+	Giver       string            `json:"giver"`
+	Level       int               `json:"level,omitempty"`
+	Requires    []string          `json:"requires,omitempty"`
+	Objectives  []*questObjective `json:"objectives"`
+	Reward      questReward       `json:"reward"`
+	Choices     []*questChoice    `json:"choices,omitempty"`
+	Next        string            `json:"next,omitempty"`
+	Daily       bool              `json:"daily,omitempty"`
+	Holiday     string            `json:"holiday,omitempty"`
+	Intro       string            `json:"intro,omitempty"`
+	Outro       string            `json:"outro,omitempty"`
+}
+
+// questObjective is one thing a quest asks for: killing some number of an
+// NPC, reaching a room, or bringing some number of an item to the giver.
+type questObjective struct {
+	Kill  string   `json:"kill,omitempty"`
+	Room  *roomRef `json:"room,omitempty"`
+	Item  string   `json:"item,omitempty"`
+	Count int      `json:"count,omitempty"`
+}
+
+// need returns how many the objective asks for, one if it doesn't say.
+func (o *questObjective) need() int {
+	if o.Count < 1 {
+		return 1
+	}
+	return o.Count
+}
+
+// questReward is what finishing a quest pays.
+type questReward struct {
+	XP    int      `json:"xp,omitempty"`
+	Gold  int      `json:"gold,omitempty"`
+	Items []string `json:"items,omitempty"`
+}
+
+// questChoice is one way a player can settle a quest.
+type questChoice struct {
+	ID     string      `json:"id"`
+	Text   string      `json:"text"`
+	Reward questReward `json:"reward"`
+	Next   string      `json:"next,omitempty"`
+}
+
+// questProgress is how far a player has got with a quest they are on, a
+// count for each of its objectives. Items are counted when handed in.
+type questProgress struct {
+	Counts []int `json:"counts"`
+}
+
+// questDone records when a player last finished a quest, and the choice
+// they made.
+type questDone struct {
+	At     time.Time `json:"at"`
+	Choice string    `json:"choice,omitempty"`
+}
+
+// findQuest returns the quest with the given ID or name.
+func (m *mud) findQuest(name string) *quest {
+	for _, q := range m.quests {
+		if strings.EqualFold(q.ID, name) || strings.EqualFold(q.Name, name) {
+			return q
+		}
+	}
+	return nil
+}
+
+// questDay returns when the current quest day began: the last midnight by
+// the world clock, which keeps UTC.
+func questDay() time.Time {
+	return time.Now().UTC().Truncate(24 * time.Hour)
+}
+
+// questOpen reports whether the player may take the quest, or else the
+// catalog key and params of the reason why not.
+func (m *mud) questOpen(p *player, q *quest) (bool, string, []interface{}) {
+	if _, ok := p.quests[q.ID]; ok {
+		return false, "quest.already_on", nil
+	}
+	if q.Holiday != "" && !m.holidayActive(q.Holiday) {
+		name := q.Holiday
+		if h := m.findHoliday(q.Holiday); h != nil {
+			name = h.Name
+		}
+		return false, "quest.only_during", []interface{}{"holiday", name}
+	}
+	if done, ok := p.questsDone[q.ID]; ok {
+		switch {
+		case q.Daily:
+			if !done.At.Before(questDay()) {
+				return false, "quest.done_today", nil
+			}
+		case q.Holiday != "":
+			if !done.At.Before(m.activeHolidays[q.Holiday].started) {
+				return false, "quest.done_this_year", nil
+			}
+		default:
+			return false, "quest.already_done", nil
+		}
+	}
+	if p.level < q.Level {
+		return false, "quest.level_too_low", []interface{}{"level", q.Level}
+	}
+	for _, req := range q.Requires {
+		id, choice, _ := strings.Cut(req, "/")
+		done, ok := p.questsDone[id]
+		if !ok || (choice != "" && done.Choice != choice) {
+			return false, "quest.not_ready", nil
+		}
+	}
+	return true, "", nil
+}
+
+// giverHere returns the NPC in the player's room who gives the quest.
+func (m *mud) giverHere(c *connection, q *quest) *npc {
+	if r := m.rooms[c.player.room]; r != nil {
+		for _, n := range r.npcs {
+			if n.id == q.Giver && n.master == "" {
+				return n
+			}
+		}
+	}
+	return nil
+}
+
+// questCommand shows the player's quests and those on offer in the room,
+// or accepts, abandons, or hands in a quest.
+func (m *mud) questCommand(c *connection, args []string) {
+	if len(args) == 0 {
+		m.listQuests(c)
+		return
+	}
+	if len(args) < 2 {
+		c.write(c.tr("quest.usage"))
+		return
+	}
+	q := m.findQuest(args[1])
+	if q == nil {
+		c.write(c.tr("quest.no_such_quest"))
+		return
+	}
+	switch strings.ToLower(args[0]) {
+	case "accept":
+		m.acceptQuest(c, q)
+	case "abandon":
+		if _, ok := c.player.quests[q.ID]; !ok {
+			c.write(c.tr("quest.arent_quest"))
+			return
+		}
+		delete(c.player.quests, q.ID)
+		m.savePlayer(c)
+		c.write(c.tr("quest.abandon", "quest", q.Name))
+	case "complete":
+		m.completeQuest(c, q, args[2:])
+	case "info":
+		m.questInfo(c, q)
+	default:
+		c.write(c.tr("quest.usage"))
+	}
+}
+
+// listQuests shows the player the quests they are on and those the NPCs in
+// their room have for them.
+func (m *mud) listQuests(c *connection) {
+	p := c.player
+	c.write(c.tr("quest.quests"))
+	if len(p.quests) == 0 {
+		c.write(c.tr("common.none"))
+	}
+	for _, id := range sortedKeys(p.quests) {
+		if q := m.findQuest(id); q != nil {
+			c.write(fmt.Sprintf("  %s (%s)\n", q.Name, q.ID))
+			for i, o := range q.Objectives {
+				c.write(fmt.Sprintf("    %s\n", m.objectiveLine(p, o, p.quests[id].count(i))))
+			}
+		}
+	}
+	var offered []string
+	for _, q := range m.quests {
+		if ok, _, _ := m.questOpen(p, q); ok && m.giverHere(c, q) != nil {
+			offered = append(offered, c.tr("quest.offered", "quest", q.Name, "id", q.ID, "giver", m.npcTemplates[q.Giver].Name))
+		}
+	}
+	if len(offered) > 0 {
+		c.write(c.tr("quest.quests_offer_here", "quests", strings.Join(offered, "")))
+		c.write(c.tr("quest.use_quest_info"))
+	}
+}
+
+// count returns the progress towards the objective at index i.
+func (qp *questProgress) count(i int) int {
+	if i < len(qp.Counts) {
+		return qp.Counts[i]
+	}
+	return 0
+}
+
+// objectiveLine describes an objective and the player's progress on it.
+func (m *mud) objectiveLine(p *player, o *questObjective, count int) string {
+	need := o.need()
+	switch {
+	case o.Kill != "":
+		name := o.Kill
+		if t := m.npcTemplates[o.Kill]; t != nil {
+			name = t.Name
+		}
+		return fmt.Sprintf("Defeat %s [%d/%d]", name, count, need)
+	case o.Room != nil:
+		name := o.Room.String()
+		if r := m.roomFor(*o.Room); r != nil {
+			name = r.name
+		}
+		return fmt.Sprintf("Reach %s [%d/%d]", name, count, need)
+	case o.Item != "":
+		name := o.Item
+		if t := m.itemTemplates[o.Item]; t != nil {
+			name = t.Name
+		}
+		return fmt.Sprintf("Bring %s [%d/%d]", name, carrying(p, o.Item), need)
+	}
+	return "?"
+}
+
+// carrying returns how many of the item the player has in their inventory.
+func carrying(p *player, id string) int {
+	n := 0
+	for _, it := range p.inventory {
+		if it.id == id {
+			n++
+		}
+	}
+	return n
+}
+
+// questInfo tells the player about a quest.
+func (m *mud) questInfo(c *connection, q *quest) {
+	p := c.player
+	c.write(fmt.Sprintf("%s\n%s\n", q.Name, q.Description))
+	qp := p.quests[q.ID]
+	if qp == nil {
+		qp = &questProgress{}
+	}
+	for i, o := range q.Objectives {
+		c.write(fmt.Sprintf("  %s\n", m.objectiveLine(p, o, qp.count(i))))
+	}
+	if q.Daily {
+		c.write(c.tr("quest.quest_can_done"))
+	}
+	if h := m.findHoliday(q.Holiday); h != nil {
+		c.write(c.tr("quest.quest_offered_during", "holiday", h.Name))
+	}
+	if _, ok := p.quests[q.ID]; !ok {
+		if ok, why, params := m.questOpen(p, q); !ok {
+			c.write(c.tr(why, params...))
+		}
+	}
+}
+
+// acceptQuest starts the player on a quest from the giver in their room.
+func (m *mud) acceptQuest(c *connection, q *quest) {
+	n := m.giverHere(c, q)
+	if n == nil {
+		c.write(c.tr("quest.nobody_here_offering"))
+		return
+	}
+	if ok, why, params := m.questOpen(c.player, q); !ok {
+		c.write(c.tr(why, params...))
+		return
+	}
+	c.write(c.tr("quest.gives_quest", "name", capitalize(n.name), "quest", q.Name))
+	c.write(q.Description + "\n")
+	m.startQuest(c, q)
+}
+
+// startQuest puts the player on the quest, playing its intro.
+func (m *mud) startQuest(c *connection, q *quest) {
+	c.player.quests[q.ID] = &questProgress{Counts: make([]int, len(q.Objectives))}
+	m.savePlayer(c)
+	if q.Intro != "" {
+		m.playCutscene(c, q.Intro)
+	}
+}
+
+// questReady reports whether the player has met every objective of a quest
+// they are on.
+func (m *mud) questReady(p *player, q *quest) bool {
+	qp := p.quests[q.ID]
+	for i, o := range q.Objectives {
+		need := o.need()
+		have := qp.count(i)
+		if o.Item != "" {
+			have = carrying(p, o.Item)
+		}
+		if have < need {
+			return false
+		}
+	}
+	return true
+}
+
+// completeQuest hands in a finished quest to its giver, settling it with
+// the player's choice if it has choices, and leads them on to the next
+// quest in its chain.
+func (m *mud) completeQuest(c *connection, q *quest, args []string) {
+	p := c.player
+	if _, ok := p.quests[q.ID]; !ok {
+		c.write(c.tr("quest.arent_quest"))
+		return
+	}
+	n := m.giverHere(c, q)
+	if n == nil {
+		c.write(c.tr("quest.must_hand_quest", "giver", m.npcTemplates[q.Giver].Name))
+		return
+	}
+	if !m.questReady(p, q) {
+		c.write(c.tr("quest.havent_finished_quest"))
+		return
+	}
+	reward, next, choice := q.Reward, q.Next, ""
+	if len(q.Choices) > 0 {
+		var ch *questChoice
+		if len(args) > 0 {
+			for _, qc := range q.Choices {
+				if strings.EqualFold(qc.ID, args[0]) {
+					ch = qc
+				}
+			}
+		}
+		if ch == nil {
+			c.write(c.tr("quest.waits_decision", "name", capitalize(n.name)))
+			for _, qc := range q.Choices {
+				c.write(fmt.Sprintf("  %-10s %s\n", qc.ID, qc.Text))
+			}
+			c.write(c.tr("quest.use_quest_complete", "quest", q.ID))
+			return
+		}
+		reward.XP += ch.Reward.XP
+		reward.Gold += ch.Reward.Gold
+		reward.Items = append(append([]string(nil), reward.Items...), ch.Reward.Items...)
+		choice = ch.ID
+		if ch.Next != "" {
+			next = ch.Next
+		}
+	}
+
+	for _, o := range q.Objectives {
+		if o.Item == "" {
+			continue
+		}
+		for i := 0; i < o.need(); i++ {
+			if j := indexOfItem(p.inventory, o.Item); j >= 0 {
+				p.inventory = removeItem(p.inventory, j)
+			}
+		}
+	}
+	delete(p.quests, q.ID)
+	p.questsDone[q.ID] = questDone{At: time.Now(), Choice: choice}
+	c.write(c.tr("quest.thanks_have_completed", "name", capitalize(n.name), "quest", q.Name))
+	m.payQuestReward(c, reward)
+	if q.Outro != "" {
+		m.playCutscene(c, q.Outro)
+	}
+
+	if nq := m.findQuest(next); nq != nil {
+		switch ok, _, _ := m.questOpen(p, nq); {
+		case !ok:
+		case nq.Giver == q.Giver:
+			c.write(c.tr("quest.has_more_do", "name", capitalize(n.name), "quest", nq.Name, "description", nq.Description))
+			m.startQuest(c, nq)
+		default:
+			c.write(c.tr("quest.should_see_about", "giver", m.npcTemplates[nq.Giver].Name, "quest", nq.Name))
+		}
+	}
+	m.savePlayer(c)
+}
+
+// indexOfItem returns the index of the first item with the given ID, or -1.
+func indexOfItem(items []*item, id string) int {
+	for i, it := range items {
+		if it.id == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// payQuestReward gives the player a quest's reward.
+func (m *mud) payQuestReward(c *connection, r questReward) {
+	p := c.player
+	if r.Gold > 0 {
+		p.gold += r.Gold
+		m.goldCreated("quests", r.Gold)
+		c.write(c.tr("quest.receive_gold", "gold", r.Gold))
+	}
+	for _, id := range r.Items {
+		if t, ok := m.itemTemplates[id]; ok {
+			it := newItem(t)
+			p.pickUp(it)
+			c.write(c.tr("quest.receive", "item", it.displayName(c)))
+		}
+	}
+	if r.XP > 0 {
+		m.gainXP(c, r.XP)
+	}
+}
+
+// questKill counts a kill towards the killer's quests.
+func (m *mud) questKill(e event) {
+	if e.npc == nil {
+		return
+	}
+	m.advanceQuests(e.conn, func(o *questObjective) bool { return o.Kill == e.npc.id })
+}
+
+// questVisit counts reaching a room towards the player's quests.
+func (m *mud) questVisit(e event) {
+	m.advanceQuests(e.conn, func(o *questObjective) bool { return o.Room != nil && o.Room.is(e.room) })
+}
+
+// advanceQuests adds one to each of the player's quest objectives that
+// match, up to what the objective needs, telling them of their progress.
+func (m *mud) advanceQuests(c *connection, match func(*questObjective) bool) {
+	if c == nil || c.player == nil {
+		return
+	}
+	p := c.player
+	for _, id := range sortedKeys(p.quests) {
+		q := m.findQuest(id)
+		if q == nil {
+			continue
+		}
+		qp := p.quests[id]
+		for i, o := range q.Objectives {
+			need := o.need()
+			if !match(o) || qp.count(i) >= need {
+				continue
+			}
+			for len(qp.Counts) <= i {
+				qp.Counts = append(qp.Counts, 0)
+			}
+			qp.Counts[i]++
+			c.write(c.tr("quest.quest", "quest", q.Name, "objective", m.objectiveLine(p, o, qp.Counts[i])))
+			if m.questReady(p, q) {
+				c.write(c.tr("quest.have_done_all", "quest", q.Name, "giver", m.npcTemplates[q.Giver].Name))
+			}
+		}
+	}
+}
+
+// checkQuests reports quests that refer to NPCs, rooms, items, or other
+// quests that don't exist.
+func (m *mud) checkQuests() []string {
+	var problems []string
+	known := func(id string) bool {
+		id, _, _ = strings.Cut(id, "/")
+		return id == "" || m.findQuest(id) != nil
+	}
+	for _, q := range m.quests {
+		if _, ok := m.npcTemplates[q.Giver]; !ok {
+			problems = append(problems, fmt.Sprintf("quest %s is given by missing NPC %s", q.ID, q.Giver))
+		}
+		for _, id := range append(append([]string{q.Next}, q.Requires...), choiceNexts(q)...) {
+			if !known(id) {
+				problems = append(problems, fmt.Sprintf("quest %s refers to missing quest %s", q.ID, id))
+			}
+		}
+		for _, o := range q.Objectives {
+			switch {
+			case o.Kill != "" && m.npcTemplates[o.Kill] == nil:
+				problems = append(problems, fmt.Sprintf("quest %s asks for missing NPC %s", q.ID, o.Kill))
+			case o.Room != nil && m.roomFor(*o.Room) == nil:
+				problems = append(problems, fmt.Sprintf("quest %s asks for missing room %v", q.ID, o.Room))
+			case o.Item != "" && m.itemTemplates[o.Item] == nil:
+				problems = append(problems, fmt.Sprintf("quest %s asks for missing item %s", q.ID, o.Item))
+			}
+		}
+	}
+	return problems
+}
+
+// choiceNexts returns the quests the choices of a quest lead on to.
+func choiceNexts(q *quest) []string {
+	var ids []string
+	for _, ch := range q.Choices {
+		ids = append(ids, ch.Next)
+	}
+	return ids
+}
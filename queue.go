@@ -0,0 +1,140 @@
+package main
+
+import (
+	"strings"
+	"time"
+)
+
+// pulseInterval is how often the game loop checks for commands whose wait
+// has run out, when no new input wakes it sooner.
+const pulseInterval = 100 * time.Millisecond
+
+// maxQueued is how many commands a connection may have waiting.
+const maxQueued = 20
+
+// skillLag is how long using a skill like stealing or searching keeps the
+// player from their next command.
+const skillLag = 2 * time.Second
+
+// queuedCommand is a line of input waiting to be run. Depth counts how many
+// aliases it was expanded through, and run marks a step of a speedwalk.
+type queuedCommand struct {
+	line  string
+	depth int
+	run   bool
+}
+
+// enqueue adds a line to the end of the connection's command queue.
+func (m *mud) enqueue(c *connection, line string, depth int) {
+	if len(c.input) >= maxQueued {
+		c.write(c.tr("queue.have_too_many"))
+		return
+	}
+	c.input = append(c.input, queuedCommand{line: line, depth: depth})
+	m.wakeLoop()
+}
+
+// enqueueFront puts lines at the front of the connection's command queue,
+// in order, so they run before anything already waiting.
+func (m *mud) enqueueFront(c *connection, lines []string, depth int) {
+	front := make([]queuedCommand, 0, len(lines)+len(c.input))
+	for _, line := range lines {
+		front = append(front, queuedCommand{line: line, depth: depth})
+	}
+	c.input = append(front, c.input...)
+	if len(c.input) > maxQueued {
+		c.input = c.input[:maxQueued]
+	}
+	m.wakeLoop()
+}
+
+// clearQueue drops every command the connection has waiting, returning how
+// many there were.
+func (c *connection) clearQueue() int {
+	n := len(c.input)
+	c.input = nil
+	return n
+}
+
+// lag makes the connection wait before its next command runs.
+func (c *connection) lag(d time.Duration) {
+	until := time.Now().Add(d)
+	if until.After(c.waitUntil) {
+		c.waitUntil = until
+	}
+}
+
+// wakeLoop tells the game loop there may be commands to run.
+func (m *mud) wakeLoop() {
+	select {
+	case m.wake <- struct{}{}:
+	default:
+	}
+}
+
+// runCommands is the game loop: it runs queued commands, one per
+// connection at a time, for as long as the server is up.
+func (m *mud) runCommands() {
+	ticker := time.NewTicker(pulseInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-m.wake:
+		case <-ticker.C:
+		}
+		m.mu.Lock()
+		m.pulse()
+		m.mu.Unlock()
+	}
+}
+
+// pulse runs the next command of every connection that isn't waiting, then
+// wakes the loop again if any have more ready to go.
+func (m *mud) pulse() {
+	now := time.Now()
+	more := false
+	for _, c := range m.conns {
+		if len(c.input) == 0 || c.hashing || now.Before(c.waitUntil) || c.state == stateDead {
+			continue
+		}
+		cmd := c.input[0]
+		c.input = c.input[1:]
+		m.runProtected(c, cmd)
+		c.outbox.answered = true
+		more = more || len(c.input) > 0
+	}
+	m.runTriggers()
+	m.flushOutput()
+	if more {
+		m.wakeLoop()
+	}
+}
+
+// dispatch runs a queued command according to the connection's state.
+func (m *mud) dispatch(c *connection, qc queuedCommand) {
+	parts := strings.SplitN(qc.line, " ", 2)
+	cmd := parts[0]
+	var args []string
+	if len(parts) == 2 {
+		args = strings.Split(parts[1], " ")
+	}
+	switch c.state {
+	case stateLogin:
+		m.handleLogin(c, cmd)
+	case statePassword:
+		m.handlePassword(c, cmd)
+	case statePlaying:
+		if qc.run {
+			m.runStep(c, qc.line)
+			return
+		}
+		c.player.automation.depth = qc.depth
+		m.handlePlaying(c, cmd, args)
+	case stateEditing:
+		m.handleEditing(c, qc.line)
+	case stateWaiting:
+		m.handleWaiting(c, cmd)
+	case stateCreating:
+		m.handleCreation(c, cmd, args)
+	}
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"math/rand"
+	"strings"
+	"time"
+)
+
+// rangedLag is how long a shot keeps the player from their next command.
+const rangedLag = 3 * time.Second
+
+// rangedHitChance is the percent chance of a shot hitting, before luck.
+const rangedHitChance = 70
+
+// rangedWeapon returns the launcher the player has equipped, such as a
+// bow, or nil if they have none.
+func (m *mud) rangedWeapon(p *player) *itemTemplate {
+	it := p.equipment["weapon"]
+	if it == nil {
+		return nil
+	}
+	if t := m.itemTemplates[it.id]; t != nil && t.Ranged != "" {
+		return t
+	}
+	return nil
+}
+
+// findAmmo returns the index in the player's inventory of something to
+// shoot: ammunition for their launcher if they have one, or else
+// something made for throwing.
+func (m *mud) findAmmo(p *player, launcher *itemTemplate) int {
+	for i, it := range p.inventory {
+		t := m.itemTemplates[it.id]
+		if t == nil {
+			continue
+		}
+		if launcher != nil && t.Ammo == launcher.Ranged {
+			return i
+		}
+		if launcher == nil && t.Thrown {
+			return i
+		}
+	}
+	return -1
+}
+
+// lineOfFire returns the room the player can shoot into in the given
+// direction, telling them if there is none. The way must be a real exit
+// with nothing closed across it.
+func (m *mud) lineOfFire(c *connection, dir string) *room {
+	r := m.rooms[c.player.room]
+	to, ok := r.exits[dir]
+	if d := r.doors[dir]; ok && d != nil && d.concealed() {
+		ok = false
+	}
+	if !ok {
+		c.write(c.tr("shoot.no_way_through"))
+		return nil
+	}
+	if d := r.doors[dir]; d != nil && d.closed {
+		c.write(c.tr("move.closed", "door", capitalize(d.name)))
+		return nil
+	}
+	return m.rooms[to]
+}
+
+// shoot fires the player's launcher, or throws something, at a target in
+// the next room over. Throwing never uses the launcher.
+func (m *mud) shoot(c *connection, cmd string, args []string) {
+	if len(args) < 2 {
+		c.write(c.tr("shoot.usage_direction_target", "command", cmd))
+		return
+	}
+	p := c.player
+	dir := expandDirection(args[0])
+	var launcher *itemTemplate
+	if cmd == "shoot" {
+		launcher = m.rangedWeapon(p)
+	}
+	i := m.findAmmo(p, launcher)
+	if i < 0 {
+		if launcher != nil {
+			c.write(c.tr("shoot.no_ammo_for", "weapon", p.equipment["weapon"].displayName(c)))
+		} else {
+			c.write(c.tr("shoot.no_ammo"))
+		}
+		return
+	}
+	if !m.requireStanding(c) {
+		return
+	}
+	target := m.lineOfFire(c, dir)
+	if target == nil {
+		return
+	}
+	n := target.findNPC(args[1])
+	var victim *connection
+	if n == nil {
+		for _, conn := range m.playersInRoom(target.id) {
+			if strings.EqualFold(conn.name, args[1]) {
+				victim = conn
+			}
+		}
+		if victim == nil {
+			c.write(c.tr("shoot.dont_see_them"))
+			return
+		}
+		if !victim.player.wanted() {
+			c.write(c.tr("shoot.isnt_wanted_mall", "name", capitalize(victim.nameFor(c))))
+			return
+		}
+	} else if n.master != "" {
+		c.write(c.tr("shoot.service", "name", capitalize(n.name), "master", n.master))
+		return
+	}
+
+	// the shot leaves the player either way, landing where it was aimed
+	ammo := p.inventory[i]
+	p.inventory = removeItem(p.inventory, i)
+	target.items = append(target.items, ammo)
+	c.lag(rangedLag)
+	missKey, hitKey := "shoot.throw_miss", "shoot.throw_hit"
+	if launcher != nil {
+		missKey, hitKey = "shoot.shoot_miss", "shoot.shoot_hit"
+	}
+	way := m.wayTo(target, m.rooms[p.room], dir)
+	me := playerActor(c)
+	var vict actor
+	if victim != nil {
+		vict = playerActor(victim)
+	} else {
+		vict = npcActor(n, target)
+	}
+
+	if rand.Intn(100) >= rangedHitChance+p.totalLuck() {
+		m.actObj(toActor, missKey, me, vict, ammo, dir)
+		m.actFormat(inRoom(target.id), func(conn *connection) string {
+			return conn.tr("shoot.flies_in_misses", "from", fromDirection(conn, way))
+		}, me, vict, ammo, "")
+		return
+	}
+
+	dmg := 1 + rand.Intn(p.damage()+ammo.stats["damage"])
+	m.actObj(toActor, hitKey, me, vict, ammo, dir, "damage", dmg)
+	m.actFormat(actAudience(toVictim, me, vict), func(conn *connection) string {
+		return conn.tr("shoot.flies_in_hits_you", "from", fromDirection(conn, way), "damage", dmg)
+	}, me, vict, ammo, "")
+	m.actFormat(inRoom(target.id).except(victim), func(conn *connection) string {
+		return conn.tr("shoot.flies_in_hits", "from", fromDirection(conn, way))
+	}, me, vict, ammo, "")
+	if victim != nil {
+		victim.player.health -= dmg
+		if victim.player.health <= 0 {
+			m.defeatPlayer(c, victim)
+			victim.writePrompt()
+		}
+		return
+	}
+	n.health -= dmg
+	if n.health <= 0 {
+		m.npcDeath(c, target, n)
+		return
+	}
+	m.actFormat(actAudience(toRoom, vict, actor{}), func(conn *connection) string {
+		return conn.tr("shoot.glares", "toward", towardDirection(conn, way))
+	}, vict, actor{}, nil, "")
+}
+
+// towardDirection describes looking or heading the way of the given exit,
+// in the listener's locale.
+func towardDirection(c *connection, dir string) string {
+	switch dir {
+	case "up":
+		return c.tr("shoot.upward")
+	case "down":
+		return c.tr("shoot.downward")
+	case outExit:
+		return c.tr("shoot.outside")
+	}
+	return c.tr("shoot.to_the", "dir", dir)
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// room flags for binding a recall and respawn point, and for fast travel
+const (
+	flagShrine   = "shrine"
+	flagWaypoint = "waypoint"
+)
+
+// the mana cost of recalling, and the gold or mana cost of travelling
+// between waypoints
+const (
+	recallCost     = 10
+	travelGoldCost = 10
+	travelManaCost = 25
+)
+
+// home returns the ID of the room the player recalls to: their bound
+// shrine, or their start room if they haven't bound one.
+func (m *mud) home(p *player) string {
+	if p.homeRoom != "" {
+		return p.homeRoom
+	}
+	return m.startRoom(p)
+}
+
+// bind sets the player's recall point, and where they come back when they
+// die, to the shrine they are standing in. Binding again at another shrine
+// moves it there.
+func (m *mud) bind(c *connection) {
+	p := c.player
+	r := m.rooms[p.room]
+	if !r.flags[flagShrine] {
+		c.write(c.tr("recall.can_only_bind"))
+		return
+	}
+	if p.homeRoom == p.room {
+		c.write(c.tr("recall.already_bound", "room", r.name))
+		return
+	}
+	p.homeRoom = p.room
+	c.write(c.tr("recall.bind_yourself_will", "room", r.name))
+}
+
+// recall returns the player to their recall point.
+func (m *mud) recall(c *connection) {
+	p := c.player
+	home := m.rooms[m.home(p)]
+	if home == nil || home.id == p.room {
+		c.write(c.tr("common.already_there"))
+		return
+	}
+	if !m.requireStanding(c) || !m.canCast(c) {
+		return
+	}
+	if p.mana < recallCost {
+		c.write(c.tr("common.dont_have_enough"))
+		return
+	}
+	c.write(c.tr("recall.close_eyes_picture"))
+	if m.teleport(c, home) {
+		p.mana -= recallCost
+	}
+}
+
+// waypoints returns the waypoint rooms the player has visited, sorted by
+// name.
+func (m *mud) waypoints(p *player) []*room {
+	var rooms []*room
+	for id, r := range m.rooms {
+		if r.flags[flagWaypoint] && p.visited[id] {
+			rooms = append(rooms, r)
+		}
+	}
+	sort.Slice(rooms, func(i, j int) bool { return rooms[i].name < rooms[j].name })
+	return rooms
+}
+
+// travel lists the player's discovered waypoints, or travels from one to
+// another for gold, or for mana if they can't afford it.
+func (m *mud) travel(c *connection, args []string) {
+	p := c.player
+	here := m.rooms[p.room]
+	known := m.waypoints(p)
+	if len(args) == 0 {
+		if len(known) == 0 {
+			c.write(c.tr("travel.havent_discovered_any"))
+			return
+		}
+		c.write(c.tr("travel.waypoints_gold_mana", "gold", travelGoldCost, "mana", travelManaCost))
+		for _, r := range known {
+			c.write(fmt.Sprintf("  %s\n", r.name))
+		}
+		return
+	}
+	if !here.flags[flagWaypoint] {
+		c.write(c.tr("travel.need_waypoint_travel"))
+		return
+	}
+	name := strings.ToLower(strings.Join(args, " "))
+	var dest *room
+	for _, r := range known {
+		if strings.HasPrefix(strings.ToLower(r.name), name) {
+			dest = r
+			break
+		}
+	}
+	switch {
+	case dest == nil:
+		c.write(c.tr("travel.dont_know_waypoint"))
+		return
+	case dest == here:
+		c.write(c.tr("common.already_there"))
+		return
+	case !m.requireStanding(c):
+		return
+	}
+	gold := p.gold >= travelGoldCost
+	if !gold && p.mana < travelManaCost {
+		c.write(c.tr("travel.cant_afford_travel"))
+		return
+	}
+	c.write(c.tr("travel.travel", "world", dest.name))
+	if !m.teleport(c, dest) {
+		return
+	}
+	if gold {
+		p.gold -= travelGoldCost
+		m.goldDestroyed("travel", travelGoldCost)
+	} else {
+		p.mana -= travelManaCost
+	}
+}
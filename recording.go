@@ -0,0 +1,185 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// recordingDir is where session recordings are kept.
+const recordingDir = "recordings"
+
+// replayPage is how many lines of a recording the replay command shows at
+// a time.
+const replayPage = 40
+
+// recordingTimeFormat is how each line of a recording is timestamped.
+const recordingTimeFormat = "2006-01-02 15:04:05.000"
+
+// recorder writes a connection's input and output to a session recording,
+// one timestamped line at a time. Input lines are marked with < and output
+// lines with >.
+type recorder struct {
+	file *os.File
+}
+
+// startRecording begins recording the connection's session, if sessions
+// are being recorded. It is called once the player has logged in, so
+// passwords typed at login are never recorded.
+func (m *mud) startRecording(c *connection) {
+	if !m.config.RecordSessions {
+		return
+	}
+	if err := os.MkdirAll(recordingDir, 0755); err != nil {
+		log.Printf("error recording %s: %v", c.name, err)
+		return
+	}
+	name := fmt.Sprintf("%s-%s.log", strings.ToLower(c.name), time.Now().Format("20060102-150405"))
+	f, err := os.OpenFile(filepath.Join(recordingDir, name), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		log.Printf("error recording %s: %v", c.name, err)
+		return
+	}
+	c.recorder = &recorder{file: f}
+}
+
+// input records a line the player typed. The password given to delete a
+// character is left out.
+func (r *recorder) input(line string) {
+	if fields := strings.Fields(line); len(fields) > 2 && strings.EqualFold(fields[0], "delete") {
+		line = strings.Join(fields[:2], " ") + " ***"
+	}
+	r.record("<", line)
+}
+
+// output records text sent to the player, a line at a time.
+func (r *recorder) output(msg string) {
+	for _, line := range strings.Split(strings.TrimSuffix(msg, "\n"), "\n") {
+		r.record(">", line)
+	}
+}
+
+// record writes one timestamped line to the recording.
+func (r *recorder) record(mark, line string) {
+	fmt.Fprintf(r.file, "%s %s %s\n", time.Now().Format(recordingTimeFormat), mark, strings.TrimRight(line, "\r"))
+}
+
+// stopRecording closes the connection's recording, if it has one.
+func (c *connection) stopRecording() {
+	if c.recorder != nil {
+		c.recorder.file.Close()
+		c.recorder = nil
+	}
+}
+
+// recordings returns the names of the saved recordings, newest first,
+// keeping only those of the named character if a name is given.
+func recordings(name string) ([]string, error) {
+	paths, err := filepath.Glob(filepath.Join(recordingDir, "*.log"))
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, path := range paths {
+		base := filepath.Base(path)
+		if name == "" || strings.HasPrefix(base, strings.ToLower(name)+"-") {
+			names = append(names, strings.TrimSuffix(base, ".log"))
+		}
+	}
+	// names end in their start time, which sorts in time order
+	sort.Slice(names, func(i, j int) bool {
+		return names[i][strings.IndexByte(names[i], '-'):] > names[j][strings.IndexByte(names[j], '-'):]
+	})
+	return names, nil
+}
+
+// replayCommand shows the player the last of their own output again, or
+// lets staff list session recordings and page through one.
+func (m *mud) replayCommand(c *connection, args []string) {
+	if len(args) == 0 || !c.player.admin {
+		m.replayOutput(c, args)
+		return
+	}
+	if _, err := strconv.Atoi(args[0]); err == nil {
+		m.replayOutput(c, args)
+		return
+	}
+	// recording names are the character's name and the time, joined by -
+	if !strings.Contains(args[0], "-") {
+		name := args[0]
+		if strings.EqualFold(name, "recordings") {
+			name = ""
+		}
+		m.listRecordings(c, name)
+		return
+	}
+	start := 1
+	if len(args) > 1 {
+		n, err := strconv.Atoi(args[1])
+		if err != nil || n < 1 {
+			c.write(c.tr("replay.usage_replay_recording"))
+			return
+		}
+		start = n
+	}
+	m.showRecording(c, filepath.Base(args[0]), start)
+}
+
+// listRecordings shows the most recent recordings, of everyone or of the
+// named character.
+func (m *mud) listRecordings(c *connection, name string) {
+	names, err := recordings(name)
+	if err != nil {
+		c.write(c.tr("replay.recordings_could_not", "error", err))
+		return
+	}
+	if len(names) == 0 {
+		c.write(c.tr("replay.no_recordings"))
+		return
+	}
+	c.write(c.tr("replay.recordings_newest_first"))
+	for i, n := range names {
+		if i == 20 {
+			c.write(c.tr("replay.more", "count", len(names)-i))
+			break
+		}
+		c.write(fmt.Sprintf("  %s\n", n))
+	}
+	c.write(c.tr("replay.type_replay_recording"))
+}
+
+// showRecording shows a page of a recording, starting at the given line.
+func (m *mud) showRecording(c *connection, name string, start int) {
+	f, err := os.Open(filepath.Join(recordingDir, name+".log"))
+	if err != nil {
+		c.write(c.tr("replay.no_such_recording"))
+		return
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	n := 0
+	shown := 0
+	for scanner.Scan() {
+		n++
+		if n < start {
+			continue
+		}
+		if shown == replayPage {
+			c.write(c.tr("replay.type_replay_more", "name", name, "number", n))
+			return
+		}
+		c.write(fmt.Sprintf("%5d %s\n", n, scanner.Text()))
+		shown++
+	}
+	if shown == 0 {
+		c.write(c.tr("replay.recording_has_only", "count", n))
+		return
+	}
+	c.write(c.tr("replay.end_recording"))
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"runtime/debug"
+)
+
+// protect runs fn, recovering from any panic in it so that one bad command
+// or task can't bring the whole server down. The panic is logged with its
+// stack under the given description and returned; nil means fn finished.
+func protect(what string, fn func()) (panicked interface{}) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("panic in %s: %v\n%s", what, r, debug.Stack())
+			panicked = r
+		}
+	}()
+	fn()
+	return nil
+}
+
+// runProtected runs a queued command, disconnecting the player if it
+// panics. Everyone else plays on.
+func (m *mud) runProtected(c *connection, qc queuedCommand) {
+	r := protect(fmt.Sprintf("%q from %s", qc.line, c.name), func() {
+		m.dispatch(c, qc)
+	})
+	if r == nil {
+		return
+	}
+	m.dropConnection(c)
+	m.send(staff(), rendered((*connection).locale, func(conn *connection) string {
+		return conn.tr("error.staff_crashed", "command", fmt.Sprintf("%q", qc.line), "name", c.name, "error", r)
+	}).asAside(nil))
+}
+
+// dropConnection disconnects a player whose command panicked, saving them
+// through the usual quit if that works and simply hanging up if not.
+func (m *mud) dropConnection(c *connection) {
+	r := protect("disconnecting "+c.name, func() {
+		c.write(c.tr("error.something_went_wrong"))
+		if c.state == statePlaying || c.state == stateEditing {
+			m.quit(c)
+		}
+	})
+	if r == nil && c.state == stateDead {
+		return
+	}
+	// the connection may have crashed before claiming its name
+	m.forget(c)
+	c.stopRecording()
+	c.close()
+	c.state = stateDead
+}
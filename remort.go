@@ -0,0 +1,95 @@
+package main
+
+import (
+	"log"
+	"strings"
+)
+
+// what a player keeps when they remort: this percentage of each skill, and
+// for every remort so far, extra health and mana and an extra practice
+// session each level
+const (
+	remortKeep      = 50
+	remortHealth    = 10
+	remortMana      = 10
+	remortPractices = 1
+)
+
+// hasClass reports whether the player follows the class now or has in an
+// earlier life.
+func (p *player) hasClass(id string) bool {
+	if p.class == id {
+		return true
+	}
+	for _, past := range p.pastClasses {
+		if past == id {
+			return true
+		}
+	}
+	return false
+}
+
+// remort starts a player at the highest level over again at level 1 in a
+// class they have never followed, keeping part of their skills. They must
+// name the class twice over, as 'remort <class> confirm', since there is
+// no going back.
+func (m *mud) remort(c *connection, args []string) {
+	p := c.player
+	if p.level < maxLevel {
+		c.write(c.tr("remort.can_remort_once", "level", maxLevel))
+		return
+	}
+	if len(args) == 0 {
+		c.write(c.tr("remort.explain", "keep", remortKeep, "health", remortHealth, "mana", remortMana, "practices", remortPractices))
+		c.write(c.tr("remort.choose", "classes", strings.Join(m.newClasses(p), ", ")))
+		return
+	}
+	cl := m.findClass(args[0])
+	switch {
+	case cl == nil:
+		c.write(c.tr("common.no_such_class", "classes", strings.Join(m.newClasses(p), ", ")))
+		return
+	case p.hasClass(cl.ID):
+		c.write(c.tr("remort.have_already_lived", "class", strings.ToLower(cl.Name)))
+		return
+	case len(args) < 2 || !strings.EqualFold(args[1], "confirm"):
+		c.write(c.tr("remort.cant_undone_type", "class", cl.ID))
+		return
+	}
+
+	m.clearTalents(p)
+	if p.class != "" {
+		p.pastClasses = append(p.pastClasses, p.class)
+	}
+	p.class = cl.ID
+	p.remorts++
+	p.level, p.xp = 1, 0
+	p.maxHealth = 100 + remortHealth*p.remorts
+	p.maxMana = 100 + remortMana*p.remorts
+	p.health, p.mana = p.totalMaxHealth(), p.maxMana
+	for id, level := range p.skills {
+		if _, ok := m.languages[id]; ok {
+			continue
+		}
+		p.skills[id] = level * remortKeep / 100
+	}
+	p.practices = 0
+	p.gainPractices()
+	m.savePlayer(c)
+	log.Printf("%s remorted as a %s, remort %d", c.name, cl.ID, p.remorts)
+	c.write(c.tr("remort.world_blurs_wake", "class", strings.ToLower(cl.Name)))
+	m.send(everyone().except(c), rendered((*connection).locale, func(conn *connection) string {
+		return conn.tr("remort.others", "name", capitalize(c.name), "class", strings.ToLower(cl.Name))
+	}).asAside(nil))
+}
+
+// newClasses returns the IDs of the classes the player has never followed.
+func (m *mud) newClasses(p *player) []string {
+	var ids []string
+	for _, cl := range m.classes {
+		if !p.hasClass(cl.ID) {
+			ids = append(ids, cl.ID)
+		}
+	}
+	return ids
+}
@@ -0,0 +1,220 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// reportsFile is where player reports are saved, in the state directory.
+const reportsFile = "reports.json"
+
+// report is a bug, typo, or idea a player filed, with where and when they
+// filed it and what staff have done about it.
+type report struct {
+	ID         int        `json:"id"`
+	Kind       string     `json:"kind"`
+	Text       string     `json:"text"`
+	Player     string     `json:"player"`
+	Room       string     `json:"room"`
+	Filed      time.Time  `json:"filed"`
+	Assignee   string     `json:"assignee,omitempty"`
+	Resolved   *time.Time `json:"resolved,omitempty"`
+	Resolution string     `json:"resolution,omitempty"`
+}
+
+// open reports whether the report still needs looking at.
+func (r *report) open() bool {
+	return r.Resolved == nil
+}
+
+// loadReports reads the saved reports, if there are any.
+func (m *mud) loadReports() error {
+	err := loadJSON(m.statePath(reportsFile), &m.reports)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+// saveReports writes the reports to disk.
+func (m *mud) saveReports() {
+	data, err := json.MarshalIndent(m.reports, "", "  ")
+	if err == nil {
+		err = os.MkdirAll(m.stateDir, 0755)
+	}
+	if err == nil {
+		err = os.WriteFile(m.statePath(reportsFile), data, 0644)
+	}
+	if err != nil {
+		log.Printf("error saving reports: %v", err)
+	}
+}
+
+// fileReport handles the bug, typo, and idea commands, recording what the
+// player describes along with where they are.
+func (m *mud) fileReport(c *connection, kind string, args []string) {
+	text := strings.TrimSpace(strings.Join(args, " "))
+	if text == "" {
+		c.write(c.tr("report.usage_description", "kind", kind))
+		return
+	}
+	id := 1
+	if n := len(m.reports); n > 0 {
+		id = m.reports[n-1].ID + 1
+	}
+	m.reports = append(m.reports, &report{
+		ID:     id,
+		Kind:   kind,
+		Text:   text,
+		Player: c.name,
+		Room:   c.player.room,
+		Filed:  time.Now(),
+	})
+	m.saveReports()
+	c.write(c.tr("report.thanks_report", "kind", kind, "id", id))
+	m.send(staff().except(c), rendered((*connection).locale, func(conn *connection) string {
+		return conn.tr("report.staff_filed", "name", c.name, "kind", kind, "id", id, "text", text)
+	}).asAside(nil))
+}
+
+// findReport returns the report with the given number.
+func (m *mud) findReport(arg string) *report {
+	id, err := strconv.Atoi(strings.TrimPrefix(arg, "#"))
+	if err != nil {
+		return nil
+	}
+	for _, r := range m.reports {
+		if r.ID == id {
+			return r
+		}
+	}
+	return nil
+}
+
+// reportsCommand lets staff list the reports, read one, assign it, and
+// resolve it.
+func (m *mud) reportsCommand(c *connection, args []string) {
+	if !c.player.admin {
+		c.write(c.tr("command.unknown"))
+		return
+	}
+	sub := ""
+	if len(args) > 0 {
+		sub = strings.ToLower(args[0])
+	}
+	switch {
+	case sub == "" || sub == "all" || sub == "mine" || sub == "bug" || sub == "typo" || sub == "idea":
+		m.listReports(c, sub)
+	case sub == "assign" && len(args) == 3:
+		r := m.findReport(args[1])
+		if r == nil {
+			c.write(c.tr("reports.no_such_report"))
+			return
+		}
+		if !m.config.isAdmin(args[2]) {
+			c.write(c.tr("reports.isnt_staff", "name", capitalize(args[2])))
+			return
+		}
+		r.Assignee = args[2]
+		conn := m.onlineAs(args[2])
+		if conn != nil {
+			r.Assignee = conn.name
+		}
+		m.saveReports()
+		c.write(c.tr("reports.report_assigned", "id", r.ID, "assignee", r.Assignee))
+		if conn != nil && conn != c {
+			conn.aside(conn.tr("reports.assigned_you", "name", c.name, "kind", r.Kind, "id", r.ID, "text", r.Text))
+		}
+	case sub == "resolve" && len(args) >= 2:
+		r := m.findReport(args[1])
+		if r == nil {
+			c.write(c.tr("reports.no_such_report"))
+			return
+		}
+		if !r.open() {
+			c.write(c.tr("reports.report_already_resolved", "id", r.ID))
+			return
+		}
+		now := time.Now()
+		r.Resolved = &now
+		r.Resolution = strings.Join(args[2:], " ")
+		if r.Assignee == "" {
+			r.Assignee = c.name
+		}
+		m.saveReports()
+		c.write(c.tr("reports.report_resolved", "id", r.ID))
+		if conn := m.onlineAs(r.Player); conn != nil && conn != c {
+			if r.Resolution != "" {
+				conn.aside(conn.tr("reports.resolved_with", "kind", r.Kind, "id", r.ID, "resolution", r.Resolution))
+			} else {
+				conn.aside(conn.tr("reports.resolved_yours", "kind", r.Kind, "id", r.ID))
+			}
+		}
+	case len(args) == 1 && m.findReport(sub) != nil:
+		m.showReport(c, m.findReport(sub))
+	default:
+		c.write(c.tr("reports.usage"))
+	}
+}
+
+// listReports shows the open reports, narrowed by kind or to those
+// assigned to the viewer, or every report with all.
+func (m *mud) listReports(c *connection, filter string) {
+	var shown []*report
+	for _, r := range m.reports {
+		switch {
+		case filter == "all":
+		case !r.open():
+			continue
+		case filter == "mine" && !strings.EqualFold(r.Assignee, c.name):
+			continue
+		case filter != "" && filter != "mine" && r.Kind != filter:
+			continue
+		}
+		shown = append(shown, r)
+	}
+	if len(shown) == 0 {
+		c.write(c.tr("reports.no_reports_show"))
+		return
+	}
+	for _, r := range shown {
+		status := "open"
+		if !r.open() {
+			status = "resolved"
+		}
+		if r.Assignee != "" {
+			status += ", " + r.Assignee
+		}
+		text := r.Text
+		if len(text) > 50 {
+			text = text[:47] + "..."
+		}
+		c.write(c.tableRow("  #%-4d %-5s %-12s %-20s %s\n", []string{"report", "", "from", "", ""}, r.ID, r.Kind, r.Player, "("+status+")", text))
+	}
+}
+
+// showReport shows everything about one report.
+func (m *mud) showReport(c *connection, r *report) {
+	room := r.Room
+	if rm, ok := m.rooms[r.Room]; ok {
+		room = fmt.Sprintf("%s (%s)", rm.name, r.Room)
+	}
+	c.write(c.tr("reports.header", "kind", capitalize(r.Kind), "id", r.ID, "player", r.Player))
+	c.write(c.tr("reports.filed", "filed", r.Filed.Format("2006-01-02 15:04"), "room", room))
+	if r.Assignee != "" {
+		c.write(c.tr("reports.assigned", "assignee", r.Assignee))
+	}
+	c.write(r.Text + "\n")
+	if !r.open() {
+		c.write(c.tr("reports.resolved", "resolved", r.Resolved.Format("2006-01-02 15:04")))
+		if r.Resolution != "" {
+			c.write(": " + r.Resolution)
+		}
+		c.write("\n")
+	}
+}
@@ -0,0 +1,74 @@
+package main
+
+import "fmt"
+
+// flagGraveyard marks a room where players who die nearby come back to
+// life. Each part of the world should have one.
+const flagGraveyard = "graveyard"
+
+// respawnRoom returns the ID of the room a player who died in the given
+// room comes back in: the shrine they have bound themselves to, or else
+// the graveyard nearest where they fell.
+func (m *mud) respawnRoom(p *player, from string) string {
+	if _, ok := m.rooms[p.homeRoom]; ok {
+		return p.homeRoom
+	}
+	if id := m.nearestGraveyard(from); id != "" {
+		return id
+	}
+	return m.startRoom(p)
+}
+
+// nearestGraveyard returns the ID of the graveyard the fewest steps from
+// the given room, or "" if none can be reached. Exits are followed in
+// order so that ties always go the same way.
+func (m *mud) nearestGraveyard(from string) string {
+	seen := map[string]bool{from: true}
+	queue := []string{from}
+	for len(queue) > 0 {
+		r := m.rooms[queue[0]]
+		queue = queue[1:]
+		if r == nil {
+			continue
+		}
+		if r.flags[flagGraveyard] {
+			return r.id
+		}
+		for _, dir := range sortedKeys(r.exits) {
+			if to := r.exits[dir]; !seen[to] {
+				seen[to] = true
+				queue = append(queue, to)
+			}
+		}
+	}
+	return ""
+}
+
+// checkGraveyards describes the parts of the world where a player who dies
+// has no graveyard to return to.
+func (m *mud) checkGraveyards() []string {
+	var problems []string
+	found := false
+	for _, r := range m.rooms {
+		if r.flags[flagGraveyard] {
+			found = true
+			break
+		}
+	}
+	if !found {
+		problems = append(problems, fmt.Sprintf("no room is a graveyard, so players who die return to %s", m.entrance))
+	}
+	for _, a := range m.areas {
+		has := false
+		for _, ar := range a.Rooms {
+			if r := m.rooms[ar.ID]; r != nil && r.flags[flagGraveyard] {
+				has = true
+				break
+			}
+		}
+		if !has {
+			problems = append(problems, fmt.Sprintf("area %s has no graveyard", a.Name))
+		}
+	}
+	return problems
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"log"
+	"time"
+)
+
+// jsonResume gives a JSON-mode client its resume token.
+type jsonResume struct {
+	Type    string `json:"type"`
+	Token   string `json:"token"`
+	Minutes int    `json:"minutes"`
+}
+
+// issueResumeToken gives the player a fresh token that lets them pick up
+// where they left off if their connection drops.
+func (m *mud) issueResumeToken(c *connection) {
+	minutes := m.config.ResumeMinutes
+	if minutes <= 0 || c.console {
+		return
+	}
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		log.Printf("error making a resume token: %v", err)
+		return
+	}
+	c.resumeToken = hex.EncodeToString(b)
+	if c.json {
+		c.writeJSON(jsonResume{Type: "resume", Token: c.resumeToken, Minutes: minutes})
+		return
+	}
+	c.write(c.tr("resume.token", "token", c.resumeToken, "minutes", minutes))
+}
+
+// linkDead keeps the character of a connection that dropped in the world
+// for a while, in case the player comes back, and has them quit if they
+// don't. It reports false if the character should quit now.
+func (m *mud) linkDead(c *connection) bool {
+	minutes := m.config.ResumeMinutes
+	if minutes <= 0 || c.resumeToken == "" || c.console {
+		return false
+	}
+	c.linkDead = true
+	c.missedFrom = c.scrollback.total
+	c.linkDeadTask = m.scheduler.after("link-dead "+c.name, time.Duration(minutes)*time.Minute, func() {
+		if c.linkDead && c.state != stateDead {
+			c.linkDead = false
+			m.quit(c)
+		}
+	})
+	log.Printf("%s lost their link", c.name)
+	m.act(toRoom, "resume.link_lost", playerActor(c), actor{}, "")
+	return true
+}
+
+// resume hands a new connection, at the name prompt, the link-dead
+// character its token is for.
+func (m *mud) resume(c *connection, token string) {
+	for _, old := range m.conns {
+		if old.linkDead && subtle.ConstantTimeCompare([]byte(token), []byte(old.resumeToken)) == 1 {
+			m.reattach(c, old)
+			return
+		}
+	}
+	c.write(c.tr("resume.bad_token", "prompt", c.tr("login.name")))
+}
+
+// reattach moves a new connection's link to the link-dead connection of
+// the character it logged back in to, and replays what the player missed.
+// The new connection's reader carries on for the old one.
+func (m *mud) reattach(c, old *connection) {
+	m.scheduler.cancel(old.linkDeadTask.id)
+	delete(m.conns, c.conn.RemoteAddr().String())
+	old.conn, old.output, old.outbox = c.conn, c.output, c.outbox
+	old.json, old.mxp, old.clientCharset, old.clientWidth = c.json, c.mxp, c.clientCharset, c.clientWidth
+	old.connectedAt, old.geo = c.connectedAt, c.geo
+	old.linkDead = false
+	c.resumed.Store(old)
+	c.state = stateDead
+	m.host.online(old.name, old.ip())
+	log.Printf("%s reconnected from %s", old.name, old.whereFrom())
+
+	missed := old.scrollback.since(old.missedFrom)
+	if len(missed) == 0 {
+		old.write(old.tr("resume.reconnected"))
+	} else {
+		old.write(old.tr("resume.reconnected_while_were"))
+		old.replay(missed)
+	}
+	m.issueResumeToken(old)
+	old.writePrompt()
+	old.flush()
+	m.send(inRoom(old.player.room).except(old), rendered((*connection).locale, func(conn *connection) string {
+		return conn.tr("resume.others", "name", capitalize(old.name))
+	}).asAside(nil))
+}
+
+// current returns the connection that took this one's link, if one did,
+// or else this one.
+func (c *connection) current() *connection {
+	for next := c.resumed.Load(); next != nil; next = c.resumed.Load() {
+		c = next
+	}
+	return c
+}
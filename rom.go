@@ -0,0 +1,839 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// romDirections are the directions of exits in area files, by number.
+var romDirections = []string{"north", "east", "south", "west", "up", "down"}
+
+// romItemTypes names the numbered item types of Merc area files the way
+// ROM area files spell them.
+var romItemTypes = map[int]string{
+	1:  "light",
+	2:  "scroll",
+	3:  "wand",
+	4:  "staff",
+	5:  "weapon",
+	8:  "treasure",
+	9:  "armor",
+	10: "potion",
+	12: "furniture",
+	13: "trash",
+	15: "container",
+	17: "drink_con",
+	18: "key",
+	19: "food",
+	20: "money",
+	22: "boat",
+	23: "npc_corpse",
+	25: "fountain",
+	26: "pill",
+}
+
+// romWearSlots maps wear flags to equipment slots, in order of preference.
+var romWearSlots = []struct {
+	flag byte
+	slot string
+}{
+	{'N', "weapon"},
+	{'D', "body"},
+	{'E', "head"},
+	{'F', "legs"},
+	{'G', "feet"},
+	{'H', "hands"},
+	{'I', "arms"},
+	{'J', "shield"},
+	{'K', "about"},
+	{'L', "waist"},
+	{'M', "wrist"},
+	{'C', "neck"},
+	{'B', "finger"},
+	{'O', "hold"},
+}
+
+// flags and apply locations read from area files
+const (
+	romActAggressive = 'F'
+	romWearTake      = 'A'
+	romRoomPrivate   = 'J'
+	romRoomSolitary  = 'L'
+	romRoomNoRecall  = 'N'
+	romApplyCha      = 6
+	romApplyHit      = 13
+	romApplyDamroll  = 19
+	romSectorSwim    = 6
+	romSectorNoSwim  = 7
+)
+
+// IDs given to imported rooms, NPCs, and items. Vnums are unique across a
+// whole ROM world, so exits between imported areas still line up.
+func romRoomID(vnum int) string { return fmt.Sprintf("rom_room_%d", vnum) }
+func romMobID(vnum int) string  { return fmt.Sprintf("rom_mob_%d", vnum) }
+func romObjID(vnum int) string  { return fmt.Sprintf("rom_obj_%d", vnum) }
+
+// areaReader reads the tokens of a ROM or Merc area file, in the manner of
+// the fread functions of those servers. The first error stops the reading
+// and is kept in err.
+type areaReader struct {
+	data []byte
+	pos  int
+	err  error
+}
+
+// fail records an error at the current line and stops the reader.
+func (r *areaReader) fail(format string, args ...interface{}) {
+	if r.err == nil {
+		line := bytes.Count(r.data[:r.pos], []byte{'\n'}) + 1
+		r.err = fmt.Errorf("line %d: %s", line, fmt.Sprintf(format, args...))
+	}
+	r.pos = len(r.data)
+}
+
+// skipSpace moves past any whitespace.
+func (r *areaReader) skipSpace() {
+	for r.pos < len(r.data) && isAreaSpace(r.data[r.pos]) {
+		r.pos++
+	}
+}
+
+// isAreaSpace reports whether c separates tokens.
+func isAreaSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r'
+}
+
+// letter reads the next character that isn't whitespace.
+func (r *areaReader) letter() byte {
+	r.skipSpace()
+	if r.pos >= len(r.data) {
+		r.fail("unexpected end of file")
+		return 0
+	}
+	r.pos++
+	return r.data[r.pos-1]
+}
+
+// expect reads a letter and fails unless it is c.
+func (r *areaReader) expect(c byte) {
+	if got := r.letter(); got != c && r.err == nil {
+		r.fail("expected %q, found %q", c, got)
+	}
+}
+
+// unread puts back the letter just read.
+func (r *areaReader) unread() {
+	if r.pos > 0 && r.err == nil {
+		r.pos--
+	}
+}
+
+// number reads a whole number. Numbers joined by | are added together.
+func (r *areaReader) number() int {
+	r.skipSpace()
+	start := r.pos
+	if r.pos < len(r.data) && (r.data[r.pos] == '+' || r.data[r.pos] == '-') {
+		r.pos++
+	}
+	for r.pos < len(r.data) && r.data[r.pos] >= '0' && r.data[r.pos] <= '9' {
+		r.pos++
+	}
+	n, err := strconv.Atoi(string(r.data[start:r.pos]))
+	if err != nil {
+		r.pos = start
+		r.fail("expected a number")
+		return 0
+	}
+	if r.pos < len(r.data) && r.data[r.pos] == '|' {
+		r.pos++
+		n += r.number()
+	}
+	return n
+}
+
+// flags reads a set of flags, given as letters, as a number, or as several
+// of either joined by |. Bits 0 to 25 are the letters A to Z and bits 26 to
+// 51 are a to z.
+func (r *areaReader) flags() uint64 {
+	r.skipSpace()
+	var set uint64
+	start := r.pos
+	for ; r.pos < len(r.data); r.pos++ {
+		c := r.data[r.pos]
+		if c >= 'A' && c <= 'Z' {
+			set |= 1 << (c - 'A')
+		} else if c >= 'a' && c <= 'z' {
+			set |= 1 << (c - 'a' + 26)
+		} else {
+			break
+		}
+	}
+	n := uint64(0)
+	for r.pos < len(r.data) && r.data[r.pos] >= '0' && r.data[r.pos] <= '9' {
+		n = n*10 + uint64(r.data[r.pos]-'0')
+		r.pos++
+	}
+	if r.pos == start {
+		r.fail("expected flags")
+		return 0
+	}
+	set |= n
+	if r.pos < len(r.data) && r.data[r.pos] == '|' {
+		r.pos++
+		set |= r.flags()
+	}
+	return set
+}
+
+// hasFlag reports whether the flag with the given letter is in the set.
+func hasFlag(set uint64, letter byte) bool {
+	if letter >= 'a' {
+		return set&(1<<(letter-'a'+26)) != 0
+	}
+	return set&(1<<(letter-'A')) != 0
+}
+
+// word reads a word, which may be quoted with ' or ".
+func (r *areaReader) word() string {
+	r.skipSpace()
+	if r.pos >= len(r.data) {
+		r.fail("unexpected end of file")
+		return ""
+	}
+	var quote byte
+	if c := r.data[r.pos]; c == '\'' || c == '"' {
+		quote = c
+		r.pos++
+	}
+	start := r.pos
+	for r.pos < len(r.data) {
+		c := r.data[r.pos]
+		if (quote != 0 && c == quote) || (quote == 0 && isAreaSpace(c)) {
+			break
+		}
+		r.pos++
+	}
+	w := string(r.data[start:r.pos])
+	if quote != 0 && r.pos < len(r.data) {
+		r.pos++
+	}
+	return w
+}
+
+// str reads text up to the next ~.
+func (r *areaReader) str() string {
+	r.skipSpace()
+	end := bytes.IndexByte(r.data[r.pos:], '~')
+	if end < 0 {
+		r.fail("unterminated string")
+		return ""
+	}
+	s := string(r.data[r.pos : r.pos+end])
+	r.pos += end + 1
+	return strings.TrimSpace(strings.ReplaceAll(s, "\r", ""))
+}
+
+// dice reads dice such as 2d8+15 and returns their average roll.
+func (r *areaReader) dice() int {
+	n := r.number()
+	r.expect('d')
+	size := r.number()
+	r.expect('+')
+	bonus := r.number()
+	return n*(size+1)/2 + bonus
+}
+
+// toEOL skips the rest of the line.
+func (r *areaReader) toEOL() {
+	if end := bytes.IndexByte(r.data[r.pos:], '\n'); end >= 0 {
+		r.pos += end + 1
+	} else {
+		r.pos = len(r.data)
+	}
+}
+
+// isString reports whether the next token ends in ~, which tells the
+// strings ROM added to records apart from the numbers of Merc's.
+func (r *areaReader) isString() bool {
+	r.skipSpace()
+	for i := r.pos; i < len(r.data) && !isAreaSpace(r.data[i]); i++ {
+		if r.data[i] == '~' {
+			return true
+		}
+	}
+	return false
+}
+
+// lineNumber reads a number if there is one before the end of the line,
+// for the fields ROM added to lines Merc ends early.
+func (r *areaReader) lineNumber() (int, bool) {
+	for r.pos < len(r.data) && (r.data[r.pos] == ' ' || r.data[r.pos] == '\t') {
+		r.pos++
+	}
+	if r.pos >= len(r.data) {
+		return 0, false
+	}
+	if c := r.data[r.pos]; (c < '0' || c > '9') && c != '-' {
+		return 0, false
+	}
+	return r.number(), true
+}
+
+// romMob is a mobile read from an area file.
+type romMob struct {
+	vnum   int
+	tmpl   *npcTemplate
+	wealth int // in silver
+}
+
+// romImport holds what has been read of an area file so far.
+type romImport struct {
+	area    *area
+	mobs    map[int]*romMob
+	order   []*romMob
+	objs    map[int]*itemTemplate
+	rooms   map[int]*areaRoom
+	keepers map[int]bool
+
+	// items given to or worn by each mobile by the resets
+	given    map[int][]string
+	equipped map[int][]string
+}
+
+// importArea reads a ROM 2.4 or Merc area file and converts its rooms,
+// mobiles, objects, resets, and shops into an area.
+func importArea(path string) (*area, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	imp := &romImport{
+		area:     &area{},
+		mobs:     make(map[int]*romMob),
+		objs:     make(map[int]*itemTemplate),
+		rooms:    make(map[int]*areaRoom),
+		keepers:  make(map[int]bool),
+		given:    make(map[int][]string),
+		equipped: make(map[int][]string),
+	}
+	r := &areaReader{data: data}
+	for r.err == nil {
+		r.expect('#')
+		section := r.word()
+		switch section {
+		case "$":
+			imp.finish()
+			return imp.area, nil
+		case "AREA":
+			imp.readHeader(r)
+		case "AREADATA":
+			imp.readAreaData(r)
+		case "MOBILES":
+			imp.readMobiles(r)
+		case "OBJECTS":
+			imp.readObjects(r)
+		case "ROOMS":
+			imp.readRooms(r)
+		case "RESETS":
+			imp.readResets(r)
+		case "SHOPS":
+			imp.readShops(r)
+		case "SPECIALS":
+			skipSpecials(r)
+		case "HELPS":
+			skipHelps(r)
+		case "MOBPROGS":
+			skipMobprogs(r)
+		default:
+			r.fail("unsupported section #%s", section)
+		}
+	}
+	return nil, fmt.Errorf("%s: %v", path, r.err)
+}
+
+// readHeader reads the #AREA section. ROM gives the file name, area name,
+// and credits followed by the vnum range; Merc gives only the credits line.
+func (imp *romImport) readHeader(r *areaReader) {
+	first := r.str()
+	r.skipSpace()
+	if r.pos < len(r.data) && r.data[r.pos] == '#' {
+		// the credits start with the level range, such as { 5 35}
+		name := first
+		if i := strings.IndexByte(name, '}'); strings.HasPrefix(name, "{") && i > 0 {
+			name = name[i+1:]
+		}
+		imp.area.Name = strings.Join(strings.Fields(name), " ")
+		imp.area.Credits = first
+		return
+	}
+	imp.area.Name = r.str()
+	imp.area.Credits = r.str()
+	r.number()
+	r.number()
+}
+
+// readAreaData reads the #AREADATA section written by OLC builds.
+func (imp *romImport) readAreaData(r *areaReader) {
+	for r.err == nil {
+		switch r.word() {
+		case "End":
+			return
+		case "Name":
+			imp.area.Name = r.str()
+		case "Credits":
+			imp.area.Credits = r.str()
+		default:
+			r.toEOL()
+		}
+	}
+}
+
+// readMobiles reads the #MOBILES section in either the ROM format or
+// Merc's simple format.
+func (imp *romImport) readMobiles(r *areaReader) {
+	for r.err == nil {
+		r.expect('#')
+		vnum := r.number()
+		if vnum == 0 {
+			return
+		}
+		t := &npcTemplate{ID: romMobID(vnum)}
+		t.Keywords = strings.Fields(strings.ToLower(r.str()))
+		t.Name = r.str()
+		t.Description = r.str()
+		r.str() // description shown when looked at
+		mob := &romMob{vnum: vnum, tmpl: t}
+		merc := !r.isString()
+		if !merc {
+			r.str() // race
+		}
+		act := r.flags()
+		r.flags()  // affects
+		r.number() // alignment
+		if merc {
+			r.expect('S')
+			t.Level = r.number()
+			r.number() // hitroll
+			r.number() // armor class
+			t.Health = r.dice()
+			t.Damage = r.dice()
+			mob.wealth = r.number() * 100
+			r.number() // experience
+			r.number() // positions and sex
+			r.number()
+			r.number()
+		} else {
+			imp.readROMMobile(r, t, mob)
+		}
+		t.Aggressive = hasFlag(act, romActAggressive)
+		if t.Health < 1 {
+			t.Health = 1
+		}
+		imp.mobs[vnum] = mob
+		imp.order = append(imp.order, mob)
+		imp.area.NPCs = append(imp.area.NPCs, t)
+	}
+}
+
+// readROMMobile reads the rest of a mobile in the ROM format.
+func (imp *romImport) readROMMobile(r *areaReader, t *npcTemplate, mob *romMob) {
+	r.number() // group
+	t.Level = r.number()
+	r.number() // hitroll
+	t.Health = r.dice()
+	r.dice() // mana
+	t.Damage = r.dice()
+	r.word() // damage type
+	for i := 0; i < 4; i++ {
+		r.number() // armor class
+	}
+	for i := 0; i < 4; i++ {
+		r.flags() // offense, immunities, resistances, vulnerabilities
+	}
+	r.word() // positions and sex
+	r.word()
+	r.word()
+	mob.wealth = r.number()
+	r.flags() // form and parts
+	r.flags()
+	r.word() // size and material
+	r.word()
+	for r.err == nil {
+		switch r.letter() {
+		case 'F':
+			r.word()
+			r.flags()
+		case 'M':
+			r.word()
+			r.number()
+			r.str()
+		default:
+			r.unread()
+			return
+		}
+	}
+}
+
+// readObjects reads the #OBJECTS section in either format. ROM names the
+// item type; Merc numbers it.
+func (imp *romImport) readObjects(r *areaReader) {
+	for r.err == nil {
+		r.expect('#')
+		vnum := r.number()
+		if vnum == 0 {
+			return
+		}
+		t := &itemTemplate{ID: romObjID(vnum)}
+		t.Keywords = strings.Fields(strings.ToLower(r.str()))
+		t.Name = r.str()
+		r.str() // description shown in the room
+		r.str() // material, or Merc's action description
+		kind := r.word()
+		merc := false
+		if n, err := strconv.Atoi(kind); err == nil {
+			kind, merc = romItemTypes[n], true
+		}
+		r.flags() // extra flags
+		wear := r.flags()
+		count := 5
+		if merc {
+			count = 4
+		}
+		values := make([]string, count)
+		for i := range values {
+			values[i] = r.word()
+		}
+		if merc {
+			r.number() // weight
+			t.Value = r.number()
+			r.number() // rent
+		} else {
+			r.number() // level
+			r.number() // weight
+			t.Value = r.number()
+			r.letter() // condition
+		}
+		imp.readObjectExtras(r, t)
+		convertObject(t, kind, wear, values)
+		imp.objs[vnum] = t
+		imp.area.Items = append(imp.area.Items, t)
+	}
+}
+
+// readObjectExtras reads an object's affects, flags, and extra
+// descriptions, keeping the affects this engine has stats for.
+func (imp *romImport) readObjectExtras(r *areaReader, t *itemTemplate) {
+	for r.err == nil {
+		switch r.letter() {
+		case 'A':
+			location, modifier := r.number(), r.number()
+			stat := ""
+			switch location {
+			case romApplyHit:
+				stat = "health"
+			case romApplyCha:
+				stat = "charisma"
+			case romApplyDamroll:
+				stat = "damage"
+			}
+			if stat != "" {
+				if t.Stats == nil {
+					t.Stats = make(map[string]int)
+				}
+				t.Stats[stat] += modifier
+			}
+		case 'F':
+			r.letter()
+			r.number()
+			r.number()
+			r.flags()
+		case 'E':
+			r.str()
+			r.str()
+		default:
+			r.unread()
+			return
+		}
+	}
+}
+
+// convertObject sets the template fields that follow from an object's type,
+// wear flags, and values.
+func convertObject(t *itemTemplate, kind string, wear uint64, values []string) {
+	value := func(i int) int {
+		n, _ := strconv.Atoi(values[i])
+		return n
+	}
+	switch kind {
+	case "weapon":
+		n, size := value(1), value(2)
+		if t.Stats == nil {
+			t.Stats = make(map[string]int)
+		}
+		t.Stats["damage"] += n * (size + 1) / 2
+	case "container":
+		t.Container = true
+	case "drink_con":
+		t.Drink = true
+	case "key":
+		t.Key = true
+	case "boat":
+		t.Boat = true
+	case "furniture":
+		t.Seats = value(0)
+		if t.Seats < 1 {
+			t.Seats = 1
+		}
+	}
+	if hasFlag(wear, romWearTake) {
+		for _, w := range romWearSlots {
+			if hasFlag(wear, w.flag) {
+				t.Slot = w.slot
+				break
+			}
+		}
+	}
+}
+
+// readRooms reads the #ROOMS section, which is the same in both formats
+// apart from ROM's extra room fields.
+func (imp *romImport) readRooms(r *areaReader) {
+	for r.err == nil {
+		r.expect('#')
+		vnum := r.number()
+		if vnum == 0 {
+			return
+		}
+		ar := &areaRoom{ID: romRoomID(vnum), Exits: make(map[string]string)}
+		ar.Name = r.str()
+		ar.Description = r.str()
+		r.number() // area number
+		flags := r.flags()
+		sector := r.number()
+		if hasFlag(flags, romRoomPrivate) || hasFlag(flags, romRoomSolitary) {
+			ar.Flags = append(ar.Flags, flagPrivate)
+		}
+		if hasFlag(flags, romRoomNoRecall) {
+			ar.Flags = append(ar.Flags, flagNoRecall)
+		}
+		if sector == romSectorSwim || sector == romSectorNoSwim {
+			ar.Flags = append(ar.Flags, "water")
+		}
+		imp.readRoomExtras(r, ar)
+		imp.rooms[vnum] = ar
+		imp.area.Rooms = append(imp.area.Rooms, ar)
+	}
+}
+
+// readRoomExtras reads a room's exits and extra fields up to its S.
+func (imp *romImport) readRoomExtras(r *areaReader, ar *areaRoom) {
+	for r.err == nil {
+		switch c := r.letter(); c {
+		case 'S':
+			return
+		case 'D':
+			n := r.number()
+			r.str() // description
+			keyword := r.str()
+			locks, key, to := r.number(), r.number(), r.number()
+			if n < 0 || n >= len(romDirections) {
+				r.fail("bad exit direction %d", n)
+				return
+			}
+			if to <= 0 {
+				continue
+			}
+			dir := romDirections[n]
+			ar.Exits[dir] = romRoomID(to)
+			if locks == 0 {
+				continue
+			}
+			d := areaDoor{Dir: dir, Name: "a door"}
+			if words := strings.Fields(keyword); len(words) > 0 {
+				d.Name = "the " + strings.ToLower(words[0])
+			}
+			if key > 0 {
+				d.Key = romObjID(key)
+			}
+			ar.Doors = append(ar.Doors, d)
+		case 'E':
+			r.str()
+			r.str()
+		case 'H', 'M':
+			r.number()
+		case 'C', 'O':
+			r.str()
+		default:
+			r.fail("unknown room field %q", c)
+		}
+	}
+}
+
+// readResets reads the #RESETS section, which places mobiles and objects
+// and sets the state of doors.
+func (imp *romImport) readResets(r *areaReader) {
+	lastMob := 0
+	for r.err == nil {
+		c := r.letter()
+		switch c {
+		case 'S':
+			return
+		case '*':
+			r.toEOL()
+			continue
+		}
+		r.number() // if flag
+		arg1 := r.number()
+		arg2 := r.number()
+		arg3 := 0
+		if c != 'G' && c != 'R' {
+			arg3 = r.number()
+		}
+		if c == 'M' || c == 'P' {
+			r.lineNumber()
+		}
+		r.toEOL()
+
+		switch c {
+		case 'M':
+			lastMob = arg1
+			if mob, ok := imp.mobs[arg1]; ok {
+				mob.tmpl.Spawns = append(mob.tmpl.Spawns, roomRef{id: romRoomID(arg3)})
+			}
+		case 'O':
+			if ar, ok := imp.rooms[arg3]; ok {
+				ar.Items = append(ar.Items, romObjID(arg1))
+			}
+		case 'P':
+			if t, ok := imp.objs[arg3]; ok {
+				t.Contents = append(t.Contents, romObjID(arg1))
+			}
+		case 'G':
+			imp.given[lastMob] = append(imp.given[lastMob], romObjID(arg1))
+		case 'E':
+			imp.equipped[lastMob] = append(imp.equipped[lastMob], romObjID(arg1))
+		case 'D':
+			imp.setDoor(arg1, arg2, arg3)
+		case 'R':
+		default:
+			r.fail("unknown reset %q", c)
+		}
+	}
+}
+
+// setDoor applies a door reset: 0 leaves the door open, 1 closes it, and 2
+// also locks it.
+func (imp *romImport) setDoor(vnum, n, state int) {
+	ar, ok := imp.rooms[vnum]
+	if !ok || n < 0 || n >= len(romDirections) {
+		return
+	}
+	for i := range ar.Doors {
+		if ar.Doors[i].Dir == romDirections[n] {
+			ar.Doors[i].Closed = state >= 1
+			ar.Doors[i].Locked = state >= 2
+		}
+	}
+}
+
+// readShops reads the #SHOPS section. Only the keepers are kept; what they
+// sell comes from the items the resets give them.
+func (imp *romImport) readShops(r *areaReader) {
+	for r.err == nil {
+		keeper := r.number()
+		if keeper == 0 {
+			return
+		}
+		imp.keepers[keeper] = true
+		r.toEOL()
+	}
+}
+
+// skipSpecials skips the #SPECIALS section, whose special functions are
+// built into the servers that use them.
+func skipSpecials(r *areaReader) {
+	for r.err == nil {
+		switch r.letter() {
+		case 'S':
+			return
+		default:
+			r.toEOL()
+		}
+	}
+}
+
+// skipHelps skips the #HELPS section.
+func skipHelps(r *areaReader) {
+	for r.err == nil {
+		r.number()
+		if strings.HasPrefix(r.str(), "$") {
+			return
+		}
+		r.str()
+	}
+}
+
+// skipMobprogs skips the #MOBPROGS section.
+func skipMobprogs(r *areaReader) {
+	for r.err == nil {
+		r.expect('#')
+		if r.number() == 0 {
+			return
+		}
+		r.str()
+	}
+}
+
+// finish turns what the resets gave each mobile into shops for shopkeepers
+// and loot for everyone else.
+func (imp *romImport) finish() {
+	for _, mob := range imp.order {
+		vnum, t := mob.vnum, mob.tmpl
+		if imp.keepers[vnum] && len(t.Spawns) > 0 {
+			s := &shopConfig{ID: fmt.Sprintf("rom_shop_%d", vnum), Name: t.Name, Keeper: t.ID, Room: t.Spawns[0]}
+			for _, id := range imp.given[vnum] {
+				s.Stock = append(s.Stock, shopStockConfig{Item: id, Target: 5})
+			}
+			imp.area.Shops = append(imp.area.Shops, s)
+			imp.given[vnum] = nil
+		}
+		drops := append(imp.given[vnum], imp.equipped[vnum]...)
+		gold := mob.wealth / 100
+		if len(drops) == 0 && gold == 0 {
+			continue
+		}
+		loot := &lootTable{GoldMin: gold / 2, GoldMax: gold * 3 / 2}
+		for _, id := range drops {
+			loot.Rare = append(loot.Rare, rareDrop{Item: id, Chance: 1})
+		}
+		if imp.area.Loot == nil {
+			imp.area.Loot = make(map[string]*lootTable)
+		}
+		imp.area.Loot[t.ID] = loot
+		t.Loot = t.ID
+	}
+}
+
+// importAreaFile converts an area file into an area data file in the given
+// directory, named after it, and returns the path written.
+func importAreaFile(path, dir string) (string, error) {
+	a, err := importArea(path)
+	if err != nil {
+		return "", err
+	}
+	data, err := json.MarshalIndent(a, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+	name := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	out := filepath.Join(dir, name+".json")
+	return out, os.WriteFile(out, data, 0644)
+}
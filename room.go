@@ -0,0 +1,99 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// roomRef refers to a room from the data files, either by its ID or by its
+// [x, y] position on the map.
+type roomRef struct {
+	id  string
+	pos *[2]int
+}
+
+// UnmarshalJSON reads a room reference given as an ID or a position.
+func (ref *roomRef) UnmarshalJSON(data []byte) error {
+	if err := json.Unmarshal(data, &ref.id); err == nil {
+		return nil
+	}
+	var pos [2]int
+	if err := json.Unmarshal(data, &pos); err != nil {
+		return fmt.Errorf("room must be an ID or an [x, y] position")
+	}
+	ref.pos = &pos
+	return nil
+}
+
+// MarshalJSON writes the reference back in the form it was given.
+func (ref roomRef) MarshalJSON() ([]byte, error) {
+	if ref.pos != nil {
+		return json.Marshal(ref.pos)
+	}
+	return json.Marshal(ref.id)
+}
+
+// String describes the reference for log messages.
+func (ref roomRef) String() string {
+	if ref.pos != nil {
+		return fmt.Sprintf("%v", *ref.pos)
+	}
+	return ref.id
+}
+
+// roomFor returns the room a reference points to, or nil if there is none.
+func (m *mud) roomFor(ref roomRef) *room {
+	if ref.pos != nil {
+		return m.getRoomByPosition(ref.pos[0], ref.pos[1])
+	}
+	return m.rooms[ref.id]
+}
+
+// is reports whether the reference points to the given room.
+func (ref roomRef) is(r *room) bool {
+	if r == nil {
+		return false
+	}
+	if ref.pos != nil {
+		return r.mapped && r.x == ref.pos[0] && r.y == ref.pos[1]
+	}
+	return r.id == ref.id
+}
+
+// placeRoom adds a room to the world under the given ID, without a
+// position on the map. It can only be reached through exits linked to it.
+func (m *mud) placeRoom(id string, r *room) {
+	r.id = id
+	if r.uid == "" {
+		r.uid = nameUUID(m.name + "/" + id)
+	}
+	m.rooms[id] = r
+}
+
+// roomKey returns the ID of the room a saved key names. Older saves named
+// rooms by their position hash, which is looked up on the map.
+func (m *mud) roomKey(key string) string {
+	if _, ok := m.rooms[key]; ok {
+		return key
+	}
+	if r, ok := m.grid[key]; ok {
+		return r.id
+	}
+	return ""
+}
+
+// locate resolves the rooms a loaded player refers to, sending them to
+// their start room if they have none or theirs is gone.
+func (m *mud) locate(p *player) {
+	if p.room = m.roomKey(p.room); p.room == "" {
+		p.room = m.startRoom(p)
+	}
+	p.homeRoom = m.roomKey(p.homeRoom)
+	visited := make(map[string]bool)
+	for key := range p.visited {
+		if id := m.roomKey(key); id != "" {
+			visited[id] = true
+		}
+	}
+	p.visited = visited
+}
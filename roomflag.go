@@ -0,0 +1,114 @@
+package main
+
+// room flags enforced by the subsystems they affect
+const (
+	flagSoundproof = "soundproof"
+	flagNoMagic    = "nomagic"
+	flagPrivate    = "private"
+	flagNoRecall   = "norecall"
+)
+
+// privateOccupancy is how many players fit in a private room.
+const privateOccupancy = 2
+
+// roomFlagConfig sets flags on a room from the room flag data file, and
+// caps how many players fit in it if it has a capacity.
+type roomFlagConfig struct {
+	Room     roomRef  `json:"room"`
+	Flags    []string `json:"flags"`
+	Capacity int      `json:"capacity,omitempty"`
+}
+
+// applyRoomFlags sets the flags and capacities from the room flag data on
+// their rooms.
+func (m *mud) applyRoomFlags() {
+	for _, cfg := range m.roomFlagConfigs {
+		r := m.roomFor(cfg.Room)
+		if r == nil {
+			continue
+		}
+		for _, flag := range cfg.Flags {
+			r.flags[flag] = true
+		}
+		if cfg.Capacity > 0 {
+			r.capacity = cfg.Capacity
+		}
+	}
+}
+
+// canHear reports whether a say carries from the speaker to the listener:
+// sound neither leaves nor enters a soundproof room.
+func (m *mud) canHear(speaker, listener *connection) bool {
+	s, l := speaker.player, listener.player
+	if s.room == l.room {
+		return true
+	}
+	from, to := m.rooms[s.room], m.rooms[l.room]
+	return !from.flags[flagSoundproof] && !to.flags[flagSoundproof]
+}
+
+// canCast reports whether magic works where the player is, telling them if
+// not.
+func (m *mud) canCast(c *connection) bool {
+	if m.rooms[c.player.room].flags[flagNoMagic] {
+		c.write(c.tr("cast.magic_fizzles_out"))
+		return false
+	}
+	return true
+}
+
+// roomOccupants returns the other players whose characters are in the room,
+// including those busy in an editor or whose link has dropped.
+func (m *mud) roomOccupants(c *connection, id string) []*connection {
+	var conns []*connection
+	for _, conn := range m.conns {
+		if conn != c && (conn.state == statePlaying || conn.state == stateEditing) && conn.player.room == id {
+			conns = append(conns, conn)
+		}
+	}
+	return conns
+}
+
+// hasRoomFor reports whether the player fits in the room, telling them if
+// not. Private rooms hold only a few players, except for a house's owner
+// and guests, and crowded rooms only as many as their capacity, though
+// staff always fit.
+func (m *mud) hasRoomFor(c *connection, r *room) bool {
+	if c.player.admin {
+		return true
+	}
+	invited := false
+	if h := m.houseIn(r.id); h != nil {
+		invited = h.allows(c.name)
+	}
+	switch n := len(m.roomOccupants(c, r.id)); {
+	case r.flags[flagPrivate] && !invited && n >= privateOccupancy:
+		c.write(c.tr("room.already_occupied", "room", r.name))
+	case r.capacity > 0 && n >= r.capacity:
+		c.write(c.tr("room.packed_full_theres", "room", r.name))
+	default:
+		return true
+	}
+	return false
+}
+
+// teleport moves the player straight to another room, unless they are in a
+// room that blocks it or the destination is full. It reports whether the
+// player moved.
+func (m *mud) teleport(c *connection, r *room) bool {
+	p := c.player
+	if r == nil {
+		return false
+	}
+	if m.rooms[p.room].flags[flagNoRecall] {
+		c.write(c.tr("teleport.strange_force_holds"))
+		return false
+	}
+	if !m.hasRoomFor(c, r) {
+		return false
+	}
+	p.room = r.id
+	p.visited[r.id] = true
+	m.glance(c)
+	return true
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"sort"
+	"time"
+)
+
+// tickInterval is how often the scheduler checks for due tasks.
+const tickInterval = time.Second
+
+// task is a function the scheduler runs once or repeatedly.
+type task struct {
+	id       int
+	name     string
+	next     time.Time
+	interval time.Duration
+	run      func()
+}
+
+// scheduler runs tasks on the game tick.
+type scheduler struct {
+	nextID int
+	tasks  map[int]*task
+}
+
+// newScheduler creates a scheduler with no tasks.
+func newScheduler() *scheduler {
+	return &scheduler{
+		tasks: make(map[int]*task),
+	}
+}
+
+// add registers a task to first run after the delay. A non-zero interval
+// makes it repeat.
+func (s *scheduler) add(name string, delay, interval time.Duration, run func()) *task {
+	s.nextID++
+	t := &task{
+		id:       s.nextID,
+		name:     name,
+		next:     time.Now().Add(delay),
+		interval: interval,
+		run:      run,
+	}
+	s.tasks[t.id] = t
+	return t
+}
+
+// after registers a task to run once after the delay.
+func (s *scheduler) after(name string, delay time.Duration, run func()) *task {
+	return s.add(name, delay, 0, run)
+}
+
+// every registers a task to run repeatedly at the interval.
+func (s *scheduler) every(name string, interval time.Duration, run func()) *task {
+	return s.add(name, interval, interval, run)
+}
+
+// cancel removes the task with the given id, reporting whether it existed.
+func (s *scheduler) cancel(id int) bool {
+	if _, ok := s.tasks[id]; !ok {
+		return false
+	}
+	delete(s.tasks, id)
+	return true
+}
+
+// list returns the scheduled tasks in order of id.
+func (s *scheduler) list() []*task {
+	tasks := make([]*task, 0, len(s.tasks))
+	for _, t := range s.tasks {
+		tasks = append(tasks, t)
+	}
+	sort.Slice(tasks, func(i, j int) bool { return tasks[i].id < tasks[j].id })
+	return tasks
+}
+
+// tick runs every task that is due at the given time. A task cancelled by
+// one that ran earlier in the same tick is skipped.
+func (s *scheduler) tick(now time.Time) {
+	for _, t := range s.list() {
+		if _, ok := s.tasks[t.id]; !ok {
+			continue
+		}
+		if now.Before(t.next) {
+			continue
+		}
+		if t.interval > 0 {
+			t.next = now.Add(t.interval)
+		} else {
+			delete(s.tasks, t.id)
+		}
+		protect("task "+t.name, t.run)
+	}
+}
+
+// runTicks drives the scheduler forever, holding the game lock while tasks run.
+func (m *mud) runTicks() {
+	ticker := time.NewTicker(tickInterval)
+	defer ticker.Stop()
+	for now := range ticker.C {
+		m.mu.Lock()
+		m.scheduler.tick(now)
+		m.runTriggers()
+		m.flushOutput()
+		m.mu.Unlock()
+	}
+}
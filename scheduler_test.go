@@ -0,0 +1,21 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTickSkipsTasksCancelledDuringTheTick(t *testing.T) {
+	s := newScheduler()
+	ran := false
+	var second *task
+	s.after("first", 0, func() { s.cancel(second.id) })
+	second = s.after("second", 0, func() { ran = true })
+	s.tick(time.Now().Add(time.Second))
+	if ran {
+		t.Error("a task cancelled earlier in the tick still ran")
+	}
+	if len(s.tasks) != 0 {
+		t.Errorf("%d tasks left after the tick, want 0", len(s.tasks))
+	}
+}
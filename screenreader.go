@@ -0,0 +1,67 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// screenReader reports whether the player has asked for output suited to
+// a screen reader: no decoration or ASCII art, lists instead of tables,
+// nothing shown by color alone, and a terse prompt.
+func (c *connection) screenReader() bool {
+	return c.player != nil && c.player.screenReader
+}
+
+// screenReaderCommand turns screen reader mode on or off.
+func (m *mud) screenReaderCommand(c *connection, args []string) {
+	on := !c.player.screenReader
+	if len(args) > 0 {
+		switch strings.ToLower(args[0]) {
+		case "on":
+			on = true
+		case "off":
+			on = false
+		default:
+			c.write(c.tr("screenreader.usage"))
+			return
+		}
+	}
+	c.player.screenReader = on
+	c.lastPrompt = ""
+	m.savePlayer(c)
+	if on {
+		c.write(c.tr("screenreader.on"))
+	} else {
+		c.write(c.tr("screenreader.off"))
+	}
+}
+
+// rarityNote names the rarity of an item whose color shows it, for screen
+// reader users who can't see the color.
+func (c *connection) rarityNote(it *item) string {
+	if !c.screenReader() || it.color == "" || it.rarity == "" {
+		return ""
+	}
+	return " (" + it.rarity + ")"
+}
+
+// tableRow formats one row of a table with the given format. Padded
+// columns read poorly aloud, so screen reader users get each value after
+// its label instead, with an empty label for a value that needs none.
+func (c *connection) tableRow(format string, labels []string, values ...interface{}) string {
+	if !c.screenReader() {
+		return fmt.Sprintf(format, values...)
+	}
+	parts := make([]string, 0, len(values))
+	for i, v := range values {
+		s := strings.TrimSpace(fmt.Sprint(v))
+		if s == "" {
+			continue
+		}
+		if i < len(labels) && labels[i] != "" {
+			s = labels[i] + ": " + s
+		}
+		parts = append(parts, s)
+	}
+	return strings.Join(parts, ", ") + "\n"
+}
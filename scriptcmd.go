@@ -0,0 +1,112 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// scriptCommand is a command written as a script in the command data file
+// rather than in Go, so it can be changed and reloaded while the server
+// runs. Commands built into the server take precedence over them.
+type scriptCommand struct {
+	Name    string   `json:"name"`
+	Aliases []string `json:"aliases"`
+	Usage   string   `json:"usage"`
+	Args    int      `json:"args"`
+	Staff   bool     `json:"staff"`
+	Script  []string `json:"script"`
+}
+
+// loadScriptCommands reads the script commands from the given file, keyed
+// by name and alias, checking that each script only uses known steps.
+func loadScriptCommands(path string) (map[string]*scriptCommand, error) {
+	var cmds []*scriptCommand
+	if err := loadJSON(path, &cmds); err != nil {
+		return nil, err
+	}
+	byName := make(map[string]*scriptCommand)
+	for _, sc := range cmds {
+		if err := checkScript(sc.Script); err != nil {
+			return nil, fmt.Errorf("command %s: %v", sc.Name, err)
+		}
+		for _, name := range append([]string{sc.Name}, sc.Aliases...) {
+			name = strings.ToLower(name)
+			if name == "" || strings.ContainsAny(name, " \t") {
+				return nil, fmt.Errorf("command %s: bad name %q", sc.Name, name)
+			}
+			if _, ok := byName[name]; ok {
+				return nil, fmt.Errorf("command %s is defined twice", name)
+			}
+			byName[name] = sc
+		}
+	}
+	return byName, nil
+}
+
+// expandArgs fills in the arguments the player gave a script command: $*
+// is all of them and $1 to $9 each one. Dollar signs the player typed are
+// doubled, so they show as themselves rather than being read as codes.
+func expandArgs(script []string, args []string) []string {
+	escaped := make([]string, len(args))
+	for i, arg := range args {
+		escaped[i] = strings.ReplaceAll(arg, "$", "$$")
+	}
+	pairs := []string{"$*", strings.Join(escaped, " ")}
+	for i := 9; i >= 1; i-- {
+		arg := ""
+		if i <= len(escaped) {
+			arg = escaped[i-1]
+		}
+		pairs = append(pairs, "$"+strconv.Itoa(i), arg)
+	}
+	r := strings.NewReplacer(pairs...)
+	expanded := make([]string, len(script))
+	for i, line := range script {
+		expanded[i] = r.Replace(line)
+	}
+	return expanded
+}
+
+// runScriptCommand runs the script command with the given name, reporting
+// whether there was one the player may use.
+func (m *mud) runScriptCommand(c *connection, name string, args []string) bool {
+	sc, ok := m.scriptCommands[strings.ToLower(name)]
+	if !ok || (sc.Staff && !c.player.admin) {
+		return false
+	}
+	if len(args) < sc.Args {
+		usage := sc.Usage
+		if usage == "" {
+			usage = sc.Name
+		}
+		c.write(c.tr("script.usage", "usage", usage))
+		return true
+	}
+	m.runScript(c, m.rooms[c.player.room], nil, expandArgs(sc.Script, args))
+	return true
+}
+
+// reloadCommand lets staff reload the script commands from disk. If the
+// file has a mistake the commands already loaded are kept.
+func (m *mud) reloadCommand(c *connection, args []string) {
+	if !c.player.admin {
+		c.write(c.tr("command.unknown"))
+		return
+	}
+	if len(args) != 1 || strings.ToLower(args[0]) != "commands" {
+		c.write(c.tr("reload.usage_reload_commands"))
+		return
+	}
+	cmds, err := loadScriptCommands(m.commandsPath)
+	if err != nil {
+		c.write(c.tr("reload.commands_could_not", "error", err))
+		return
+	}
+	m.scriptCommands = cmds
+	names := make(map[*scriptCommand]bool)
+	for _, sc := range cmds {
+		names[sc] = true
+	}
+	c.write(c.tr("reload.reloaded_script_commands", "count", len(names)))
+}
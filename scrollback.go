@@ -0,0 +1,102 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+)
+
+// replayLines is how many lines replay shows unless asked for more.
+const replayLines = 20
+
+// scrollback keeps the last lines of a connection's output, without its
+// prompts, so that they can be shown again.
+type scrollback struct {
+	size    int
+	lines   []string
+	total   int
+	partial strings.Builder
+
+	// prompted is set when a prompt was the last thing written; the
+	// newline an aside starts with after one isn't kept
+	prompted bool
+}
+
+// write adds output to the scrollback, line by line.
+func (s *scrollback) write(msg string) {
+	if s.prompted && s.partial.Len() == 0 {
+		msg = strings.TrimPrefix(msg, "\n")
+	}
+	s.prompted = false
+	for {
+		i := strings.IndexByte(msg, '\n')
+		if i < 0 {
+			s.partial.WriteString(msg)
+			return
+		}
+		s.partial.WriteString(msg[:i])
+		s.add(s.partial.String())
+		s.partial.Reset()
+		msg = msg[i+1:]
+	}
+}
+
+// add keeps a line, dropping the oldest if the scrollback is full.
+func (s *scrollback) add(line string) {
+	switch {
+	case s.size <= 0:
+		return
+	case len(s.lines) < s.size:
+		s.lines = append(s.lines, line)
+	default:
+		s.lines[s.total%s.size] = line
+	}
+	s.total++
+}
+
+// prompt notes that a prompt went out, throwing away any unfinished line,
+// which was a prompt too.
+func (s *scrollback) prompt() {
+	s.partial.Reset()
+	s.prompted = true
+}
+
+// since returns the lines kept after the first n ever written, oldest
+// first.
+func (s *scrollback) since(n int) []string {
+	if oldest := s.total - len(s.lines); n < oldest {
+		n = oldest
+	}
+	var lines []string
+	for i := n; i < s.total; i++ {
+		lines = append(lines, s.lines[i%s.size])
+	}
+	return lines
+}
+
+// replay shows the player lines of their own output again. They aren't
+// kept a second time.
+func (c *connection) replay(lines []string) {
+	for _, line := range lines {
+		c.outbox.pending.WriteString(line + "\n")
+	}
+}
+
+// replayOutput shows the player the last lines of their output again, as
+// many as they ask for.
+func (m *mud) replayOutput(c *connection, args []string) {
+	n := replayLines
+	if len(args) > 0 {
+		var err error
+		if n, err = strconv.Atoi(args[0]); err != nil || n < 1 {
+			c.write(c.tr("replay.usage_replay_lines"))
+			return
+		}
+	}
+	lines := c.scrollback.since(c.scrollback.total - n)
+	if len(lines) == 0 {
+		c.write(c.tr("replay.nothing_replay"))
+		return
+	}
+	c.write(c.tr("replay.last_lines", "count", len(lines)))
+	c.replay(lines)
+}
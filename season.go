@@ -0,0 +1,272 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// seasonFile is where the season metadata is saved, in the state directory.
+const seasonFile = "season.json"
+
+// seasonsDir holds an archive of the characters and world of each past
+// season.
+const seasonsDir = "seasons"
+
+// seasonChampions is how many of the top characters on each leaderboard are
+// remembered for a finished season.
+const seasonChampions = 3
+
+// the parts of a character that can carry over into a new season
+const (
+	carryAchievements = "achievements"
+	carryCosmetics    = "cosmetics"
+)
+
+// season is the current season and the record of those before it.
+type season struct {
+	Number  int             `json:"number"`
+	Name    string          `json:"name"`
+	Started time.Time       `json:"started"`
+	Past    []seasonSummary `json:"past,omitempty"`
+}
+
+// seasonSummary remembers a finished season.
+type seasonSummary struct {
+	Number     int                           `json:"number"`
+	Name       string                        `json:"name"`
+	Started    time.Time                     `json:"started"`
+	Ended      time.Time                     `json:"ended"`
+	Characters int                           `json:"characters"`
+	Champions  map[string][]leaderboardEntry `json:"champions"`
+}
+
+// loadSeason reads the season metadata, starting the first season if there
+// is none yet.
+func (m *mud) loadSeason() error {
+	m.season = &season{Number: 1, Name: "Grand Opening", Started: time.Now()}
+	err := loadJSON(m.statePath(seasonFile), m.season)
+	if os.IsNotExist(err) {
+		m.saveSeason()
+		return nil
+	}
+	return err
+}
+
+// saveSeason writes the season metadata to disk.
+func (m *mud) saveSeason() {
+	data, err := json.MarshalIndent(m.season, "", "  ")
+	if err == nil {
+		err = os.MkdirAll(m.stateDir, 0755)
+	}
+	if err == nil {
+		err = os.WriteFile(m.statePath(seasonFile), data, 0644)
+	}
+	if err != nil {
+		log.Printf("error saving season: %v", err)
+	}
+}
+
+// carries reports whether the config carries the named part of a character
+// over into a new season.
+func (m *mud) carries(part string) bool {
+	for _, p := range m.config.SeasonCarryover {
+		if strings.EqualFold(p, part) {
+			return true
+		}
+	}
+	return false
+}
+
+// seasonReset returns a fresh character for the new season, keeping the
+// account itself, the attributes and pronouns chosen at creation, their
+// class, their settings and automation, their mail without attachments,
+// and whatever the config carries over.
+func (m *mud) seasonReset(rec *characterRecord) *player {
+	p := newPlayer()
+	if rec.UID != "" {
+		p.uid = rec.UID
+	}
+	p.passwordHash, p.salt = rec.PasswordHash, rec.Salt
+	p.helper = rec.Helper
+	p.language = rec.Language
+	for name, score := range rec.Attributes {
+		p.attributes[name] = score
+	}
+	if rec.Pronouns != nil {
+		p.pronouns = *rec.Pronouns
+	}
+	p.class = rec.Class
+	p.locale = rec.Locale
+	p.screenReader = rec.ScreenReader
+	p.brief = rec.Brief
+	p.noMXP = rec.NoMXP
+	if _, ok := charsetNames[rec.Charset]; ok {
+		p.charset = rec.Charset
+	}
+	if rec.Width >= minWidth && rec.Width <= maxWidth {
+		p.width = rec.Width
+	}
+	if rec.Flags != nil {
+		p.flags = *rec.Flags
+	}
+	p.gainPractices()
+	for _, ch := range rec.Channels {
+		p.channels[ch] = true
+	}
+	for name, body := range rec.Aliases {
+		p.automation.aliases[name] = body
+	}
+	for _, t := range rec.Triggers {
+		if t, err := compileTrigger(t.Pattern, t.Command); err == nil {
+			p.automation.triggers = append(p.automation.triggers, t)
+		}
+	}
+	for _, msg := range rec.Mail {
+		msg.Items = nil
+		p.mail = append(p.mail, msg)
+	}
+	if m.carries(carryAchievements) {
+		for id, t := range rec.Achievements {
+			p.achievements[id] = t
+		}
+	}
+	if m.carries(carryCosmetics) {
+		p.pose = rec.Pose
+		for slot, r := range rec.Appearance {
+			p.appearance[slot] = itemFromRecord(r)
+		}
+	}
+	return p
+}
+
+// seasonCommand shows the current season, or lets staff end it and start a
+// new one.
+func (m *mud) seasonCommand(c *connection, args []string) {
+	if len(args) == 0 || !c.player.admin {
+		s := m.season
+		c.write(c.tr("season.season_running_since", "number", s.Number, "name", s.Name, "started", s.Started.Format("2006-01-02")))
+		for i := len(s.Past) - 1; i >= 0; i-- {
+			past := s.Past[i]
+			c.write(c.tr("season.season", "number", past.Number, "name", past.Name, "started", past.Started.Format("2006-01-02"), "ended", past.Ended.Format("2006-01-02")))
+		}
+		return
+	}
+	if strings.ToLower(args[0]) != "start" || len(args) < 2 {
+		c.write(c.tr("season.usage_season_start"))
+		return
+	}
+	if err := m.startSeason(strings.Join(args[1:], " "), c); err != nil {
+		log.Printf("error starting season: %v", err)
+		c.write(c.tr("season.new_season_could", "error", err))
+	}
+}
+
+// startSeason ends the current season and starts a new one. Everything is
+// backed up and archived first; then every character is reset, keeping
+// only the configured carry-over, and the world's economy and leaderboards
+// start from scratch.
+func (m *mud) startSeason(name string, by *connection) error {
+	if len(m.host.worlds) > 1 {
+		return errors.New("seasons can only be started on a server hosting a single world")
+	}
+	if _, err := m.backup(); err != nil {
+		return err
+	}
+	archive := filepath.Join(seasonsDir, strconv.Itoa(m.season.Number))
+	for _, dir := range []string{m.store.dir, m.stateDir} {
+		if err := copyDir(dir, filepath.Join(archive, filepath.Base(dir))); err != nil {
+			return err
+		}
+	}
+	recs, err := m.store.list()
+	if err != nil {
+		return err
+	}
+
+	// remember the season's champions before the boards are wiped
+	now := time.Now()
+	summary := seasonSummary{
+		Number:     m.season.Number,
+		Name:       m.season.Name,
+		Started:    m.season.Started,
+		Ended:      now,
+		Characters: len(recs),
+		Champions:  make(map[string][]leaderboardEntry),
+	}
+	for board, entries := range computeLeaderboards(recs) {
+		if len(entries) > seasonChampions {
+			entries = entries[:seasonChampions]
+		}
+		summary.Champions[board] = entries
+	}
+
+	// reset every character, live or saved
+	var reset []*characterRecord
+	for _, rec := range recs {
+		if m.onlineAs(rec.Name) == nil {
+			reset = append(reset, m.seasonReset(rec).record(rec.Name))
+		}
+	}
+	if err := m.store.replace(reset, ""); err != nil {
+		return err
+	}
+	for _, conn := range m.conns {
+		if conn.state != statePlaying {
+			continue
+		}
+		for _, f := range append([]*npc(nil), conn.player.followers...) {
+			m.dismiss(conn, f)
+		}
+		if t := conn.player.jailTask; t != nil {
+			m.scheduler.cancel(t.id)
+		}
+		p := m.seasonReset(conn.player.record(conn.name))
+		p.admin = conn.player.admin
+		p.startSession()
+		m.locate(p)
+		p.visited[p.room] = true
+		conn.player = p
+		m.savePlayer(conn)
+	}
+
+	// and the world's economy
+	for _, h := range append([]*house(nil), m.houses...) {
+		m.detachHouse(h)
+	}
+	m.saveHouses()
+	m.bounties = nil
+	m.saveBounties()
+	for _, s := range m.shops {
+		for _, e := range s.stock {
+			e.demand = 0
+		}
+	}
+	m.ledger = newLedger()
+	m.saveLedger()
+	m.leaderboards.mu.Lock()
+	m.leaderboards.boards = nil
+	m.leaderboards.mu.Unlock()
+
+	m.season = &season{
+		Number:  m.season.Number + 1,
+		Name:    name,
+		Started: now,
+		Past:    append(m.season.Past, summary),
+	}
+	m.saveSeason()
+	m.send(everyone(), rendered((*connection).locale, func(conn *connection) string {
+		return colorize(conn.tr("season.begun", "number", m.season.Number, "name", name), "yellow")
+	}).asAside(by))
+	for _, conn := range m.conns {
+		if conn.state == statePlaying {
+			m.look(conn)
+		}
+	}
+	return nil
+}
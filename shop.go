@@ -0,0 +1,287 @@
+package main
+
+import (
+	"math"
+	"time"
+)
+
+// restockInterval is how often shops replenish their stock.
+const restockInterval = 5 * time.Minute
+
+// shopConfig describes a shop as defined in the shop data file.
+type shopConfig struct {
+	ID     string            `json:"id"`
+	Name   string            `json:"name"`
+	Keeper string            `json:"keeper"`
+	Room   roomRef           `json:"room"`
+	Stock  []shopStockConfig `json:"stock"`
+}
+
+// shopStockConfig is an item a shop keeps in stock, and how many of it the
+// shop tries to hold.
+type shopStockConfig struct {
+	Item   string `json:"item"`
+	Target int    `json:"target"`
+}
+
+// shop is a store run by a shopkeeper NPC whose stock and prices change as
+// players buy and sell.
+type shop struct {
+	*shopConfig
+	stock []*stockEntry
+}
+
+// stockEntry is a line of identical items in a shop. Entries for items the
+// shop doesn't normally carry come from players selling to it and are not
+// restocked.
+type stockEntry struct {
+	template string
+	name     string
+	keywords []string
+	value    int
+	target   int
+	demand   int
+	items    []*item
+}
+
+// newShop creates a shop fully stocked from its configuration.
+func (m *mud) newShop(cfg *shopConfig) *shop {
+	s := &shop{shopConfig: cfg}
+	for _, sc := range cfg.Stock {
+		t, ok := m.itemTemplates[sc.Item]
+		if !ok {
+			continue
+		}
+		e := &stockEntry{template: t.ID, name: t.Name, keywords: t.Keywords, value: t.Value, target: sc.Target}
+		for i := 0; i < sc.Target; i++ {
+			e.items = append(e.items, newItem(t))
+		}
+		s.stock = append(s.stock, e)
+	}
+	return s
+}
+
+// openShops creates the shops from the loaded shop data.
+func (m *mud) openShops() {
+	for _, cfg := range m.shopConfigs {
+		m.shops = append(m.shops, m.newShop(cfg))
+	}
+}
+
+// price returns what the shop charges for one item from the entry. Prices
+// rise as stock runs short of the target and as players keep buying, and
+// fall when the shop is overstocked.
+func (e *stockEntry) price() int {
+	target := e.target
+	if target < 1 {
+		target = 1
+	}
+	factor := 1 + 0.5*float64(target-len(e.items))/float64(target) + 0.1*float64(e.demand)
+	factor = math.Max(0.5, math.Min(3, factor))
+	price := int(math.Round(float64(e.value) * factor))
+	if price < 1 {
+		price = 1
+	}
+	return price
+}
+
+// offer returns what the shop pays for an item.
+func (s *shop) offer(it *item) int {
+	if e := s.entryFor(it); e != nil {
+		return e.price() / 2
+	}
+	return it.value / 2
+}
+
+// entryFor returns the stock entry the item belongs in.
+func (s *shop) entryFor(it *item) *stockEntry {
+	for _, e := range s.stock {
+		if e.name == it.name {
+			return e
+		}
+	}
+	return nil
+}
+
+// findEntry returns the stock entry matching the keyword.
+func (s *shop) findEntry(keyword string) *stockEntry {
+	for _, e := range s.stock {
+		if matchKeywords(e.keywords, keyword) {
+			return e
+		}
+	}
+	return nil
+}
+
+// restock moves each stock entry one step back toward its target. Shortages
+// are refilled from the supplier and surplus is sold off to other shoppers.
+// Demand cools off over time.
+func (m *mud) restock() {
+	for _, s := range m.shops {
+		kept := s.stock[:0]
+		for _, e := range s.stock {
+			switch {
+			case len(e.items) < e.target:
+				if t, ok := m.itemTemplates[e.template]; ok {
+					e.items = append(e.items, newItem(t))
+				}
+			case len(e.items) > e.target:
+				e.items = e.items[1:]
+			}
+			e.demand /= 2
+			if e.target > 0 || len(e.items) > 0 {
+				kept = append(kept, e)
+			}
+		}
+		s.stock = kept
+	}
+}
+
+// isShopkeeper reports whether NPCs from the template run a shop.
+func (m *mud) isShopkeeper(id string) bool {
+	for _, s := range m.shops {
+		if s.Keeper == id {
+			return true
+		}
+	}
+	return false
+}
+
+// shopHere returns the shop in the player's room if its keeper is there to
+// serve them, telling the player why not otherwise.
+func (m *mud) shopHere(c *connection) *shop {
+	r := m.rooms[c.player.room]
+	for _, s := range m.shops {
+		if !s.Room.is(r) {
+			continue
+		}
+		for _, n := range r.npcs {
+			if n.id == s.Keeper && n.master == "" {
+				return s
+			}
+		}
+		c.write(c.tr("shop.nobody_here_serve"))
+		return nil
+	}
+	c.write(c.tr("shop.no_shop_here"))
+	return nil
+}
+
+// listStock shows what the shop in the player's room has for sale.
+func (m *mud) listStock(c *connection) {
+	s := m.shopHere(c)
+	if s == nil {
+		return
+	}
+	c.write(c.tr("shop.sells", "shop", s.Name))
+	if m.config.SalesTax > 0 {
+		c.write(c.tr("shop.prices_before_sales", "percent", m.config.SalesTax))
+	}
+	for _, e := range s.stock {
+		if len(e.items) == 0 {
+			c.write(c.tableRow("  %-24s %s\n", nil, e.name, "sold out"))
+			continue
+		}
+		c.write(c.tableRow("  %-24s %3d left  %5d gold\n", []string{"", "left", "gold"}, e.items[0].displayName(c), len(e.items), e.price()))
+	}
+}
+
+// buy purchases an item from the shop in the player's room.
+func (m *mud) buy(c *connection, args []string) {
+	if len(args) == 0 {
+		c.write(c.tr("shop.buy_what"))
+		return
+	}
+	s := m.shopHere(c)
+	if s == nil {
+		return
+	}
+	e := s.findEntry(args[0])
+	if e == nil {
+		c.write(c.tr("shop.shop_doesnt_sell"))
+		return
+	}
+	if len(e.items) == 0 {
+		c.write(c.tr("shop.sold_out", "item", capitalize(e.name)))
+		return
+	}
+	p := c.player
+	price := e.price()
+	tax := m.tax(price)
+	if p.gold < price+tax {
+		c.write(c.tr("shop.costs_gold_tax", "item", capitalize(e.name), "price", price+tax))
+		return
+	}
+	it := e.items[0]
+	if !p.canCarry(c, it) {
+		return
+	}
+	e.items = e.items[1:]
+	e.demand++
+	p.gold -= price + tax
+	m.goldDestroyed("shops", price)
+	m.goldDestroyed("taxes", tax)
+	if tax > 0 {
+		c.write(c.tr("shop.buy_gold_plus", "item", it.displayName(c), "price", price, "tax", tax))
+	} else {
+		c.write(c.tr("shop.buy_gold", "item", it.displayName(c), "price", price))
+	}
+	if p.pickUp(it) {
+		c.write(c.tr("common.add_key_keyring"))
+	}
+}
+
+// sell sells an item from the player's inventory to the shop in their room,
+// which adds it to its stock.
+func (m *mud) sell(c *connection, args []string) {
+	if len(args) == 0 {
+		c.write(c.tr("shop.sell_what"))
+		return
+	}
+	s := m.shopHere(c)
+	if s == nil {
+		return
+	}
+	p := c.player
+	i := findItem(p.inventory, args[0])
+	if i < 0 {
+		c.write(c.tr("common.dont_have"))
+		return
+	}
+	it := p.inventory[i]
+	offer := s.offer(it)
+	if offer < 1 {
+		c.write(c.tr("shop.shop_isnt_interested", "item", it.displayName(c)))
+		return
+	}
+	p.inventory = removeItem(p.inventory, i)
+	e := s.entryFor(it)
+	if e == nil {
+		e = &stockEntry{name: it.name, keywords: it.keywords, value: it.value}
+		s.stock = append(s.stock, e)
+	}
+	e.items = append(e.items, it)
+	p.gold += offer
+	m.goldCreated("shops", offer)
+	c.write(c.tr("shop.sell_gold", "item", it.displayName(c), "offer", offer))
+	m.events.publish(event{kind: eventGold, conn: c, amount: offer})
+}
+
+// appraise tells the player what the shop in their room would pay for an item.
+func (m *mud) appraise(c *connection, args []string) {
+	if len(args) == 0 {
+		c.write(c.tr("shop.value_what"))
+		return
+	}
+	s := m.shopHere(c)
+	if s == nil {
+		return
+	}
+	i := findItem(c.player.inventory, args[0])
+	if i < 0 {
+		c.write(c.tr("common.dont_have"))
+		return
+	}
+	it := c.player.inventory[i]
+	c.write(c.tr("shop.shop_would_pay", "offer", s.offer(it), "item", it.displayName(c)))
+}
@@ -0,0 +1,206 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// snapshotsFile is where snapshots are saved, in the state directory.
+const snapshotsFile = "snapshots.json"
+
+// maxSnapshots is how many snapshots are kept; the oldest make way for
+// new ones.
+const maxSnapshots = 500
+
+// snapshotWidth is the width snapshots are wrapped to, so they read the
+// same wherever they are shared.
+const snapshotWidth = 78
+
+// snapshot is a picture in words of a room as a player saw it, kept so
+// that it can be shared by its ID.
+type snapshot struct {
+	ID     string    `json:"id"`
+	Player string    `json:"player"`
+	Room   string    `json:"room"`
+	Taken  time.Time `json:"taken"`
+	Text   string    `json:"text"`
+}
+
+// loadSnapshots reads the saved snapshots, if there are any.
+func (m *mud) loadSnapshots() error {
+	err := loadJSON(m.statePath(snapshotsFile), &m.snapshots)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+// saveSnapshots writes the snapshots to disk.
+func (m *mud) saveSnapshots() {
+	data, err := json.MarshalIndent(m.snapshots, "", "  ")
+	if err == nil {
+		err = os.MkdirAll(m.stateDir, 0755)
+	}
+	if err == nil {
+		err = os.WriteFile(m.statePath(snapshotsFile), data, 0644)
+	}
+	if err != nil {
+		log.Printf("error saving snapshots: %v", err)
+	}
+}
+
+// findSnapshot returns the snapshot with the given ID.
+func (m *mud) findSnapshot(id string) *snapshot {
+	for _, s := range m.snapshots {
+		if strings.EqualFold(s.ID, id) {
+			return s
+		}
+	}
+	return nil
+}
+
+// snapshotText renders the player's room as they see it: its name and
+// description, the way out, who and what is there, and the map around
+// it. Colors and links are left out so it reads anywhere.
+func (m *mud) snapshotText(c *connection, r *room) string {
+	var b strings.Builder
+	b.WriteString(r.name + "\n")
+	b.WriteString(m.roomDescription(r) + "\n")
+	var exits []string
+	for _, dir := range sortedKeys(r.exits) {
+		if d, ok := r.doors[dir]; ok && d.concealed() {
+			continue
+		}
+		if _, ok := m.rooms[r.exits[dir]]; ok {
+			exits = append(exits, dir)
+		}
+	}
+	if len(exits) == 0 {
+		exits = []string{"none"}
+	}
+	b.WriteString("Exits: " + strings.Join(exits, ", ") + "\n")
+	for _, conn := range m.playersInRoom(r.id) {
+		b.WriteString(conn.player.roomLine(conn.nameFor(c)) + "\n")
+	}
+	for _, n := range r.npcs {
+		b.WriteString(n.roomLine(c) + "\n")
+	}
+	for _, it := range r.items {
+		b.WriteString(fmt.Sprintf("%s is here.\n", capitalize(it.name)))
+	}
+	if r.mapped {
+		b.WriteString("\n" + strings.Join(m.mapLines(c.player, r), "\n") + "\n")
+	}
+	return ansiCodes.ReplaceAllString(wrapText(b.String(), snapshotWidth), "")
+}
+
+// snapshotCommand takes a snapshot of the player's room, shows a saved
+// one by its ID, or lists the player's own.
+func (m *mud) snapshotCommand(c *connection, args []string) {
+	if len(args) > 0 {
+		if strings.EqualFold(args[0], "list") {
+			m.listSnapshots(c)
+			return
+		}
+		s := m.findSnapshot(args[0])
+		if s == nil {
+			c.write(c.tr("snapshot.no_snapshot_id"))
+			return
+		}
+		m.showSnapshot(c, s)
+		return
+	}
+	if m.asleep(c) {
+		return
+	}
+	if c.player.affected(effectBlinded) {
+		c.write(c.tr("snapshot.cant_frame_picture"))
+		return
+	}
+	r := m.rooms[c.player.room]
+	if r == nil {
+		return
+	}
+	s := &snapshot{
+		ID:     newUUID()[:8],
+		Player: c.name,
+		Room:   r.id,
+		Taken:  time.Now(),
+		Text:   m.snapshotText(c, r),
+	}
+	m.snapshots = append(m.snapshots, s)
+	if n := len(m.snapshots) - maxSnapshots; n > 0 {
+		m.snapshots = m.snapshots[n:]
+	}
+	m.saveSnapshots()
+	c.write(c.tr("snapshot.click_take_snapshot"))
+	m.tellOthers(c, "snapshot.others")
+	m.showSnapshot(c, s)
+	c.write(c.tr("snapshot.share", "id", s.ID))
+}
+
+// showSnapshot shows a saved snapshot, framed.
+func (m *mud) showSnapshot(c *connection, s *snapshot) {
+	c.write(c.tr("snapshot.header", "id", s.ID, "player", s.Player, "taken", s.Taken.Format("2006-01-02 15:04")))
+	c.write(s.Text)
+	c.write("---\n")
+}
+
+// listSnapshots lists the snapshots the player has taken.
+func (m *mud) listSnapshots(c *connection) {
+	c.write(c.tr("snapshot.snapshots"))
+	found := false
+	for i := len(m.snapshots) - 1; i >= 0; i-- {
+		s := m.snapshots[i]
+		if !strings.EqualFold(s.Player, c.name) {
+			continue
+		}
+		found = true
+		name := s.Room
+		if r := m.rooms[s.Room]; r != nil {
+			name = r.name
+		}
+		c.write(c.tableRow("  %-8s  %s  %s\n", nil, s.ID, s.Taken.Format("2006-01-02 15:04"), name))
+	}
+	if !found {
+		c.write(c.tr("common.none"))
+	}
+}
+
+// handleSnapshot serves the snapshot with the ID given in the id query
+// parameter, from whichever world it was taken in, as JSON or, with
+// format=text, as plain text.
+func (m *mud) handleSnapshot(w http.ResponseWriter, r *http.Request) {
+	id := r.URL.Query().Get("id")
+	if id == "" {
+		http.Error(w, "id is required", http.StatusBadRequest)
+		return
+	}
+	var found snapshot
+	ok := false
+	for _, world := range m.host.worlds {
+		world.locked(func() {
+			if s := world.findSnapshot(id); s != nil {
+				found, ok = *s, true
+			}
+		})
+		if ok {
+			break
+		}
+	}
+	if !ok {
+		http.Error(w, "no such snapshot", http.StatusNotFound)
+		return
+	}
+	if r.URL.Query().Get("format") == "text" {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		fmt.Fprintf(w, "Snapshot %s, taken by %s on %s\n\n%s", found.ID, found.Player, found.Taken.Format("2006-01-02 15:04"), found.Text)
+		return
+	}
+	writeJSON(w, found)
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"math/rand"
+	"strings"
+)
+
+// how many rooms away a shout and a fight can be heard
+const (
+	shoutRange = 3
+	fightRange = 2
+)
+
+// earshot is a room within range of a sound: how far the sound travels to
+// reach it, and which way the listener would go to reach the source.
+type earshot struct {
+	distance int
+	dir      string
+}
+
+// inEarshot reaches the players in the room a sound is made in and the
+// rooms it carries to.
+func inEarshot(from *room, reach map[string]earshot) audience {
+	return func(conn *connection) bool {
+		_, ok := reach[conn.player.room]
+		return ok || conn.player.room == from.id
+	}
+}
+
+// soundReach finds the rooms a sound made in the given room carries to
+// within the given number of rooms, searching outward along the exits.
+// Soundproof rooms neither let sound in nor out, and a closed door muffles
+// it as much as an extra room would.
+func (m *mud) soundReach(from *room, maxDistance int) map[string]earshot {
+	reach := map[string]earshot{from.id: {}}
+	if from.flags[flagSoundproof] {
+		return reach
+	}
+	// a closed door makes some paths longer than their number of rooms,
+	// so a room is searched again whenever a shorter way to it turns up
+	queue := []*room{from}
+	for len(queue) > 0 {
+		r := queue[0]
+		queue = queue[1:]
+		for _, dir := range sortedKeys(r.exits) {
+			to := m.rooms[r.exits[dir]]
+			if to == nil || to == from || to.flags[flagSoundproof] {
+				continue
+			}
+			distance := reach[r.id].distance + 1
+			if door := r.doors[dir]; door != nil && door.closed {
+				distance++
+			}
+			if e, ok := reach[to.id]; distance > maxDistance || (ok && e.distance <= distance) {
+				continue
+			}
+			reach[to.id] = earshot{distance, m.wayTo(to, r, dir)}
+			queue = append(queue, to)
+		}
+	}
+	delete(reach, from.id)
+	return reach
+}
+
+// wayTo returns the exit of a room that leads back to the room next to it,
+// which it was reached from through the given exit.
+func (m *mud) wayTo(r, back *room, dir string) string {
+	for _, d := range sortedKeys(r.exits) {
+		if r.exits[d] == back.id {
+			return d
+		}
+	}
+	if rev, ok := reverseDirections[dir]; ok {
+		return rev
+	}
+	return outExit
+}
+
+// fromDirection describes where a sound comes from for someone who would
+// take the given exit towards it, in the listener's locale.
+func fromDirection(c *connection, dir string) string {
+	switch dir {
+	case "up":
+		return c.tr("sound.from_above")
+	case "down":
+		return c.tr("sound.from_below")
+	case outExit:
+		return c.tr("sound.from_outside")
+	}
+	return c.tr("sound.from_the", "dir", dir)
+}
+
+// muffle drops some of the words of a message, as heard through walls.
+func muffle(msg string) string {
+	words := strings.Fields(msg)
+	for i := range words {
+		if rand.Intn(2) == 0 {
+			words[i] = "..."
+		}
+	}
+	return strings.Join(words, " ")
+}
+
+// shout calls out a message that carries to the rooms nearby, fading
+// with distance.
+func (m *mud) shout(c *connection, args []string) {
+	if len(args) == 0 {
+		c.write(c.tr("shout.shout_what"))
+		return
+	}
+	r := m.rooms[c.player.room]
+	if r == nil {
+		return
+	}
+	msg := c.player.slur(strings.Join(args, " "))
+	c.write(c.tr("shout.shout", "message", msg))
+	reach := m.soundReach(r, shoutRange)
+	// those in a room hear the same shout, muffled alike
+	view := m.speechView(c)
+	if view != nil {
+		seen := view
+		view = func(conn *connection) string { return conn.player.room + "\x00" + seen(conn) }
+	}
+	m.send(inEarshot(r, reach).except(c), rendered(view, func(conn *connection) string {
+		heard := m.hearLanguage(c, conn, msg)
+		if conn.player.room == r.id {
+			return conn.tr("sound.shouts", "name", capitalize(c.nameFor(conn)), "message", heard)
+		}
+		e := reach[conn.player.room]
+		switch e.distance {
+		case 1:
+			return conn.tr("sound.someone_shouts", "from", fromDirection(conn, e.dir), "message", heard)
+		case 2:
+			return conn.tr("sound.muffled_shout", "from", fromDirection(conn, e.dir), "message", muffle(heard))
+		}
+		return conn.tr("sound.faint_shout", "from", fromDirection(conn, e.dir))
+	}).asAside(nil))
+	m.hearSpeech(c, msg)
+}
+
+// fightNoise lets players in the rooms nearby hear a fight breaking out in
+// the given room.
+func (m *mud) fightNoise(r *room) {
+	reach := m.soundReach(r, fightRange)
+	inRange := func(conn *connection) bool {
+		_, ok := reach[conn.player.room]
+		return ok
+	}
+	roomOf := func(conn *connection) string { return conn.player.room }
+	m.send(inRange, rendered(roomOf, func(conn *connection) string {
+		e := reach[conn.player.room]
+		if e.distance == 1 {
+			return conn.tr("sound.fighting", "from", fromDirection(conn, e.dir))
+		}
+		return conn.tr("sound.distant_fight", "from", fromDirection(conn, e.dir))
+	}).asAside(nil))
+}
@@ -0,0 +1,280 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// speechTrigger runs a script when a player says something matching its
+// pattern. Patterns are case-insensitive regular expressions.
+type speechTrigger struct {
+	Pattern string   `json:"pattern"`
+	Script  []string `json:"script"`
+
+	re *regexp.Regexp
+}
+
+// roomTrigger is a speech trigger that listens in one room.
+type roomTrigger struct {
+	Room roomRef `json:"room"`
+	speechTrigger
+}
+
+// compile prepares the trigger's pattern for matching.
+func (t *speechTrigger) compile() error {
+	re, err := regexp.Compile("(?i)" + t.Pattern)
+	if err != nil {
+		return fmt.Errorf("speech trigger %q: %v", t.Pattern, err)
+	}
+	t.re = re
+	return nil
+}
+
+// compileTriggers prepares every room and NPC speech trigger.
+func (m *mud) compileTriggers() error {
+	for _, t := range m.roomTriggers {
+		if err := t.compile(); err != nil {
+			return err
+		}
+	}
+	for _, nt := range m.npcTemplates {
+		for _, t := range nt.Triggers {
+			if err := t.compile(); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// hearSpeech fires the triggers of the speaker's room and of the NPCs in it
+// that match what was said.
+func (m *mud) hearSpeech(c *connection, msg string) {
+	p := c.player
+	r := m.rooms[p.room]
+	if r == nil {
+		return
+	}
+	for _, t := range m.roomTriggers {
+		if t.Room.is(r) && t.re.MatchString(msg) {
+			m.runScript(c, r, nil, t.Script)
+		}
+	}
+	for _, n := range append([]*npc(nil), r.npcs...) {
+		nt, ok := m.npcTemplates[n.id]
+		if !ok {
+			continue
+		}
+		for _, t := range nt.Triggers {
+			if t.re.MatchString(msg) {
+				m.runScript(c, r, n, t.Script)
+			}
+		}
+	}
+}
+
+// scriptSteps are the steps a script can use, as runScript describes them.
+var scriptSteps = map[string]bool{
+	"echo": true, "tell": true, "others": true, "say": true, "rumble": true,
+	"open": true, "close": true, "lock": true, "unlock": true,
+	"give": true, "teleport": true, "wait": true,
+	"blast": true, "summon": true, "enrage": true, "affect": true,
+	"cutscene": true,
+}
+
+// checkScript reports the first step of a script that isn't known.
+func checkScript(script []string) error {
+	for _, line := range script {
+		if fields := strings.Fields(line); len(fields) > 0 && !scriptSteps[fields[0]] {
+			return fmt.Errorf("unknown step %q", line)
+		}
+	}
+	return nil
+}
+
+// runScript runs the steps of a trigger script in the given room for the
+// player who set it off. speaker is the NPC whose trigger fired, if any.
+// The steps are:
+//
+//	echo <text>          show text to everyone in the room
+//	tell <text>          show text to the player alone
+//	others <text>        show text to everyone in the room but the player
+//	say <text>           have the NPC say something
+//	rumble <text>        show text to everyone in the room's zone
+//	open|close <dir>     open or close the door in a direction
+//	lock|unlock <dir>    lock or unlock the door in a direction
+//	give <item>          give the player an item
+//	teleport <room>      move the player to the room with an ID
+//	teleport <x> <y>     or at a position on the map
+//	wait <seconds>       pause before the remaining steps
+//	blast <damage>       hit everyone in the room for up to the damage
+//	summon <npc> [count] bring NPCs with an ID in to fight the player
+//	enrage <percent>     make the NPC's blows stronger by the percentage
+//	affect <effect> <s>  place a status effect on the player for a time
+//	cutscene <id> [room] play a cutscene to the player, or to the room
+//
+// The text of tell and others is an act message about the player, so $n
+// is their name as each listener knows them and $e, $m, and $s their
+// pronouns. Elsewhere $n is replaced with the player's name. Everywhere
+// $$ stands for a dollar sign.
+func (m *mud) runScript(c *connection, r *room, speaker *npc, script []string) {
+	me := actor{conn: c, room: r.id}
+	for i, line := range script {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		text := strings.TrimSpace(strings.TrimPrefix(line, fields[0]))
+		arg := strings.NewReplacer("$$", "$", "$n", c.name).Replace(text)
+		switch fields[0] {
+		case "echo":
+			m.roomEcho(r, arg+"\n")
+		case "tell":
+			m.scriptAct(toActor, text, me)
+		case "others":
+			m.scriptAct(toRoom, text, me)
+		case "rumble":
+			m.send(m.inZone(r.id), plain(arg+"\n").asAside(c))
+		case "say":
+			if speaker != nil {
+				m.roomEcho(r, fmt.Sprintf("%s says: %s\n", capitalize(speaker.name), arg))
+			}
+		case "open", "close", "lock", "unlock":
+			d, ok := r.doors[arg]
+			if !ok {
+				log.Printf("script in %s: no door %s", r.name, arg)
+				continue
+			}
+			switch fields[0] {
+			case "open":
+				d.closed, d.locked = false, false
+			case "close":
+				d.closed = true
+			case "lock":
+				d.closed, d.locked = true, true
+			case "unlock":
+				d.locked = false
+			}
+		case "give":
+			t, ok := m.itemTemplates[arg]
+			if !ok || c.state != statePlaying {
+				continue
+			}
+			it := newItem(t)
+			c.player.pickUp(it)
+			c.write(c.tr("script.receive", "item", it.displayName(c)))
+		case "teleport":
+			if c.state != statePlaying {
+				continue
+			}
+			var dest *room
+			switch len(fields) {
+			case 2:
+				dest = m.rooms[fields[1]]
+			case 3:
+				x, errX := strconv.Atoi(fields[1])
+				y, errY := strconv.Atoi(fields[2])
+				if errX == nil && errY == nil {
+					dest = m.getRoomByPosition(x, y)
+				}
+			}
+			if dest == nil {
+				log.Printf("script in %s: bad teleport %q", r.name, line)
+				continue
+			}
+			m.teleport(c, dest)
+		case "wait":
+			secs, err := strconv.Atoi(arg)
+			if err != nil {
+				log.Printf("script in %s: bad wait %q", r.name, line)
+				continue
+			}
+			rest := script[i+1:]
+			var wait *task
+			wait = m.scheduler.after("script: "+r.name, time.Duration(secs)*time.Second, func() {
+				if c.world.Load() != m {
+					return
+				}
+				for i, t := range c.scriptWaits {
+					if t == wait {
+						c.scriptWaits = append(c.scriptWaits[:i], c.scriptWaits[i+1:]...)
+						break
+					}
+				}
+				// the rest of the script is for whoever is still in the room
+				if c.state != statePlaying || c.player.room != r.id {
+					return
+				}
+				m.runScript(c, r, speaker, rest)
+			})
+			c.scriptWaits = append(c.scriptWaits, wait)
+			return
+		case "blast":
+			dmg, err := strconv.Atoi(arg)
+			if err != nil || dmg < 1 {
+				log.Printf("script in %s: bad blast %q", r.name, line)
+				continue
+			}
+			m.blast(r, speaker, dmg)
+		case "summon":
+			var t *npcTemplate
+			count := 1
+			if len(fields) > 1 {
+				t = m.npcTemplates[fields[1]]
+			}
+			if len(fields) > 2 {
+				count, _ = strconv.Atoi(fields[2])
+			}
+			if t == nil || count < 1 {
+				log.Printf("script in %s: bad summon %q", r.name, line)
+				continue
+			}
+			m.summon(r, speaker, c, t, count)
+		case "enrage":
+			pct, err := strconv.Atoi(arg)
+			if err != nil || speaker == nil {
+				log.Printf("script in %s: bad enrage %q", r.name, line)
+				continue
+			}
+			speaker.damage += speaker.damage * pct / 100
+		case "affect":
+			if len(fields) != 3 || c.state != statePlaying {
+				continue
+			}
+			secs, err := strconv.Atoi(fields[2])
+			if err != nil {
+				log.Printf("script in %s: bad affect %q", r.name, line)
+				continue
+			}
+			c.player.addEffect(fields[1], time.Duration(secs)*time.Second)
+		case "cutscene":
+			switch {
+			case len(fields) == 3 && fields[2] == "room":
+				m.playRoomCutscene(r, fields[1])
+			case len(fields) == 2 && c.state == statePlaying:
+				m.playCutscene(c, fields[1])
+			default:
+				log.Printf("script in %s: bad cutscene %q", r.name, line)
+			}
+		default:
+			log.Printf("script in %s: unknown step %q", r.name, line)
+		}
+	}
+}
+
+// scriptAct shows an act message a script gives, which is written in the
+// world's data rather than the catalog.
+func (m *mud) scriptAct(to int, text string, me actor) {
+	if a := actAudience(to, me, actor{}); a != nil {
+		m.actFormat(a, func(*connection) string { return text }, me, actor{}, nil, "")
+	}
+}
+
+// roomEcho shows a message to every player in the room.
+func (m *mud) roomEcho(r *room, msg string) {
+	m.send(inRoom(r.id), plain(msg).asAside(nil))
+}
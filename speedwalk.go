@@ -0,0 +1,124 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// speedwalkDirections maps each speedwalk letter to its direction.
+var speedwalkDirections = map[rune]string{
+	'n': "north",
+	'e': "east",
+	's': "south",
+	'w': "west",
+	'u': "up",
+	'd': "down",
+}
+
+// parseSpeedwalk expands speedwalk text like "3n2e s" into the directions
+// it walks. A count applies to the letter after it.
+func parseSpeedwalk(text string) ([]string, error) {
+	var dirs []string
+	count := 0
+	for _, r := range strings.ToLower(text) {
+		switch {
+		case unicode.IsSpace(r):
+			continue
+		case unicode.IsDigit(r):
+			count = count*10 + int(r-'0')
+			if count > maxQueued {
+				return nil, fmt.Errorf("that's too far to run in one go")
+			}
+		default:
+			dir, ok := speedwalkDirections[r]
+			if !ok {
+				return nil, fmt.Errorf("%q is not a direction", r)
+			}
+			if count == 0 {
+				count = 1
+			}
+			for ; count > 0; count-- {
+				dirs = append(dirs, dir)
+			}
+		}
+		if len(dirs) > maxQueued {
+			return nil, fmt.Errorf("that's too far to run in one go")
+		}
+	}
+	if count > 0 {
+		return nil, fmt.Errorf("a count needs a direction after it")
+	}
+	return dirs, nil
+}
+
+// run queues a speedwalk, one move at a time.
+func (m *mud) run(c *connection, args []string) {
+	if len(args) == 0 {
+		c.write(c.tr("run.run_where_example"))
+		return
+	}
+	dirs, err := parseSpeedwalk(strings.Join(args, " "))
+	if err != nil {
+		c.write(c.tr("run.cant_run_way", "error", err))
+		return
+	}
+	if len(dirs) == 0 {
+		c.write(c.tr("run.run_where"))
+		return
+	}
+	if len(c.input)+len(dirs) > maxQueued {
+		c.write(c.tr("run.have_too_many"))
+		return
+	}
+	if !m.requireStanding(c) {
+		return
+	}
+	for _, dir := range dirs {
+		c.input = append(c.input, queuedCommand{line: dir, run: true})
+	}
+	m.wakeLoop()
+}
+
+// runStep takes one step of a speedwalk. The run stops whenever the player
+// fails to move, such as at a closed door, or comes upon an aggressive
+// creature.
+func (m *mud) runStep(c *connection, dir string) {
+	p := c.player
+	defer c.writePrompt()
+	from := p.room
+	m.move(c, dir)
+	if p.room == from {
+		m.stopRun(c)
+		return
+	}
+	if c.state != statePlaying {
+		return
+	}
+	for _, n := range m.rooms[p.room].npcs {
+		if n.aggressive && n.master == "" {
+			c.write(c.tr("run.stop_short_blocks", "name", n.name))
+			m.stopRun(c)
+			return
+		}
+	}
+}
+
+// stopRun cancels the rest of the connection's speedwalk, reporting whether
+// it had one.
+func (m *mud) stopRun(c *connection) bool {
+	kept := c.input[:0]
+	stopped := false
+	for _, qc := range c.input {
+		if qc.run {
+			stopped = true
+			continue
+		}
+		kept = append(kept, qc)
+	}
+	c.input = kept
+	if stopped {
+		c.write(c.tr("run.stop_running"))
+	}
+	return stopped
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"strconv"
+	"time"
+)
+
+// effectWarded shields the player, making them harder to hit.
+const effectWarded = "warded"
+
+// the mana cost of the class spells, how much heal restores before spell
+// power, and how long and how well a ward protects
+const (
+	healCost     = 20
+	healAmount   = 15
+	wardCost     = 25
+	wardDuration = 3 * time.Minute
+	wardArmor    = 10
+)
+
+// heal restores the health of the player, or of another player in the
+// room.
+func (m *mud) heal(c *connection, args []string) {
+	p := c.player
+	target := c
+	if len(args) > 0 {
+		if target = m.findPlayerNear(c, args[0]); target == nil {
+			c.write(c.tr("common.they_arent_here"))
+			return
+		}
+	}
+	if p.mana < healCost {
+		c.write(c.tr("common.dont_have_enough"))
+		return
+	}
+	p.mana -= healCost
+	t := target.player
+	amount := (healAmount + p.level) * p.spellPower() / 100
+	if max := t.totalMaxHealth(); t.health+amount > max {
+		amount = max - t.health
+	}
+	t.health += amount
+	me, them := playerActor(c), playerActor(target)
+	if target == c {
+		m.act(toActor, "heal.self", me, them, strconv.Itoa(amount))
+		m.act(toRoom, "heal.self_others", me, them, "")
+		return
+	}
+	m.act(toActor, "heal.other", me, them, strconv.Itoa(amount))
+	m.act(toVictim, "heal.other_you", me, them, strconv.Itoa(amount))
+	m.act(toNotVictim, "heal.other_others", me, them, "")
+}
+
+// ward surrounds the player with a shield that turns aside blows.
+func (m *mud) ward(c *connection) {
+	p := c.player
+	if p.mana < wardCost {
+		c.write(c.tr("common.dont_have_enough"))
+		return
+	}
+	p.mana -= wardCost
+	p.addEffect(effectWarded, wardDuration*time.Duration(p.spellPower())/100)
+	m.act(toActor, "ward.ward", playerActor(c), actor{}, "")
+	m.act(toRoom, "ward.others", playerActor(c), actor{}, "")
+}
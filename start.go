@@ -0,0 +1,86 @@
+package main
+
+import "fmt"
+
+// defaultStartRoom is the ID of the room players start in when neither
+// the world's config nor its world file says otherwise.
+const defaultStartRoom = "mall_entrance"
+
+// startRule names a room players start in, and where they are returned to
+// when they have nowhere better to go. A rule with a tutorial is only for
+// players who haven't yet reached that room, the one that ends the
+// tutorial; a rule without one is for everyone else. A new character who
+// starts by a rule with a cutscene is shown it.
+type startRule struct {
+	Room     roomRef `json:"room"`
+	Tutorial string  `json:"tutorial,omitempty"`
+	Cutscene string  `json:"cutscene,omitempty"`
+}
+
+// resolveStarts settles the world's start rules: those in its config,
+// then those in its world file and areas, with the default start room
+// for anyone no rule covers. It fails if a rule names a missing room or
+// cutscene.
+func (m *mud) resolveStarts(config []startRule) error {
+	rules := append(append([]startRule(nil), config...), m.worldStarts...)
+	for _, a := range m.areas {
+		rules = append(rules, a.Starts...)
+	}
+	m.starts, m.entrance, m.entranceScene = nil, "", ""
+	for _, rule := range rules {
+		r := m.roomFor(rule.Room)
+		if r == nil {
+			return fmt.Errorf("start room %s doesn't exist", rule.Room)
+		}
+		if rule.Cutscene != "" && m.findCutscene(rule.Cutscene) == nil {
+			return fmt.Errorf("start room %s: cutscene %s doesn't exist", rule.Room, rule.Cutscene)
+		}
+		if rule.Tutorial == "" {
+			if m.entrance == "" {
+				m.entrance, m.entranceScene = r.id, rule.Cutscene
+			}
+			continue
+		}
+		if _, ok := m.rooms[rule.Tutorial]; !ok {
+			return fmt.Errorf("start room %s: tutorial room %s doesn't exist", rule.Room, rule.Tutorial)
+		}
+		m.starts = append(m.starts, startRule{Room: roomRef{id: r.id}, Tutorial: rule.Tutorial, Cutscene: rule.Cutscene})
+	}
+	if m.entrance == "" {
+		m.entrance = defaultStartRoom
+	}
+	if _, ok := m.rooms[m.entrance]; !ok {
+		return fmt.Errorf("the world has no %s room, and no start room is set", m.entrance)
+	}
+	return nil
+}
+
+// startRoom returns the ID of the room the player starts in.
+func (m *mud) startRoom(p *player) string {
+	for _, rule := range m.starts {
+		if !p.visited[rule.Tutorial] {
+			return rule.Room.id
+		}
+	}
+	return m.entrance
+}
+
+// startCutscene returns the ID of the cutscene for a new character to
+// watch where they start, if there is one.
+func (m *mud) startCutscene(p *player) string {
+	for _, rule := range m.starts {
+		if !p.visited[rule.Tutorial] {
+			return rule.Cutscene
+		}
+	}
+	return m.entranceScene
+}
+
+// startRooms returns the IDs of every room players may start in.
+func (m *mud) startRooms() []string {
+	ids := []string{m.entrance}
+	for _, rule := range m.starts {
+		ids = append(ids, rule.Room.id)
+	}
+	return ids
+}
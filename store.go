@@ -0,0 +1,579 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+// itemRecord is the persisted form of an item.
+type itemRecord struct {
+	UID       string         `json:"uid,omitempty"`
+	ID        string         `json:"id"`
+	Name      string         `json:"name"`
+	BaseName  string         `json:"baseName,omitempty"`
+	Keywords  []string       `json:"keywords"`
+	Value     int            `json:"value"`
+	Slot      string         `json:"slot,omitempty"`
+	Stats     map[string]int `json:"stats,omitempty"`
+	Rarity    string         `json:"rarity,omitempty"`
+	Color     string         `json:"color,omitempty"`
+	Container bool           `json:"container,omitempty"`
+	Contents  []itemRecord   `json:"contents,omitempty"`
+	Key       bool           `json:"key,omitempty"`
+	Keyring   bool           `json:"keyring,omitempty"`
+
+	Treasure     string `json:"treasure,omitempty"`
+	TreasureRoom string `json:"treasureRoom,omitempty"`
+	Cosmetic     string `json:"cosmetic,omitempty"`
+	Dyed         string `json:"dyed,omitempty"`
+}
+
+// characterRecord is the persisted form of a player's character.
+type characterRecord struct {
+	UID          string                    `json:"uid,omitempty"`
+	Name         string                    `json:"name"`
+	PasswordHash string                    `json:"passwordHash"`
+	Salt         string                    `json:"salt"`
+	Level        int                       `json:"level"`
+	XP           int                       `json:"xp"`
+	Health       int                       `json:"health"`
+	MaxHealth    int                       `json:"maxHealth"`
+	Mana         int                       `json:"mana"`
+	MaxMana      int                       `json:"maxMana"`
+	Room         string                    `json:"room"`
+	X            int                       `json:"x,omitempty"`
+	Y            int                       `json:"y,omitempty"`
+	Gold         int                       `json:"gold"`
+	Luck         int                       `json:"luck"`
+	Attributes   map[string]int            `json:"attributes,omitempty"`
+	Pronouns     *pronouns                 `json:"pronouns,omitempty"`
+	Class        string                    `json:"class,omitempty"`
+	Practices    int                       `json:"practices,omitempty"`
+	Remorts      int                       `json:"remorts,omitempty"`
+	PastClasses  []string                  `json:"pastClasses,omitempty"`
+	Talents      map[string]int            `json:"talents,omitempty"`
+	Quests       map[string]*questProgress `json:"quests,omitempty"`
+	QuestsDone   map[string]questDone      `json:"questsDone,omitempty"`
+	HolidayGifts map[string]int            `json:"holidayGifts,omitempty"`
+	Kills        int                       `json:"kills"`
+	Deaths       int                       `json:"deaths,omitempty"`
+	DeathLog     []death                   `json:"death_log,omitempty"`
+	Inventory    []itemRecord              `json:"inventory"`
+	Equipment    map[string]itemRecord     `json:"equipment"`
+	Appearance   map[string]itemRecord     `json:"appearance,omitempty"`
+	Visited      []string                  `json:"visited"`
+	Achievements map[string]time.Time      `json:"achievements"`
+	Playtime     int64                     `json:"playtime"`
+	Sessions     int                       `json:"sessions"`
+	LastLogin    time.Time                 `json:"lastLogin"`
+	Helper       bool                      `json:"helper"`
+	Multiplay    bool                      `json:"multiplay,omitempty"`
+	Channels     []string                  `json:"channels"`
+	Pose         string                    `json:"pose,omitempty"`
+	Skills       map[string]int            `json:"skills,omitempty"`
+	Locker       *lockerRecord             `json:"locker,omitempty"`
+	Mail         []mailMessage             `json:"mail,omitempty"`
+	Consent      bool                      `json:"consent,omitempty"`
+	Bounty       int                       `json:"bounty,omitempty"`
+	ArenaRating  int                       `json:"arenaRating,omitempty"`
+	JailedUntil  time.Time                 `json:"jailedUntil,omitempty"`
+	Followers    []followerRecord          `json:"followers,omitempty"`
+	Language     string                    `json:"language,omitempty"`
+	Locale       string                    `json:"locale,omitempty"`
+	ScreenReader bool                      `json:"screenReader,omitempty"`
+	Brief        bool                      `json:"brief,omitempty"`
+	NoMXP        bool                      `json:"noMXP,omitempty"`
+	Charset      string                    `json:"charset,omitempty"`
+	Width        int                       `json:"width,omitempty"`
+	Stance       string                    `json:"stance,omitempty"`
+	Flags        *playerFlags              `json:"flags,omitempty"`
+	Home         *[2]int                   `json:"home,omitempty"`
+	HomeRoom     string                    `json:"homeRoom,omitempty"`
+	World        string                    `json:"world,omitempty"`
+	Elsewhere    map[string]worldPlace     `json:"elsewhere,omitempty"`
+	Aliases      map[string]string         `json:"aliases,omitempty"`
+	Triggers     []*playerTrigger          `json:"triggers,omitempty"`
+}
+
+// store persists character records as JSON files in a directory.
+type store struct {
+	dir string
+}
+
+// newStore creates a store that keeps its files in the given directory.
+func newStore(dir string) *store {
+	return &store{dir: dir}
+}
+
+// path returns the file path of the named character's record.
+func (s *store) path(name string) string {
+	return filepath.Join(s.dir, strings.ToLower(name)+".json")
+}
+
+// load reads the named character's record. It returns an error satisfying
+// os.IsNotExist if the character has never been saved.
+func (s *store) load(name string) (*characterRecord, error) {
+	rec := &characterRecord{}
+	if err := loadJSON(s.path(name), rec); err != nil {
+		return nil, err
+	}
+	return rec, nil
+}
+
+// save writes the character's record, replacing any previous version.
+func (s *store) save(rec *characterRecord) error {
+	if err := os.MkdirAll(s.dir, 0755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(rec, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := s.path(rec.Name) + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.path(rec.Name))
+}
+
+// remove deletes the named character's record.
+func (s *store) remove(name string) error {
+	return os.Remove(s.path(name))
+}
+
+// replace writes the changed records and removes the named record, staging
+// every write before touching any file so that a failure leaves the store
+// as it was.
+func (s *store) replace(recs []*characterRecord, remove string) error {
+	var st staging
+	for _, rec := range recs {
+		if err := st.write(s.path(rec.Name), rec, 0600); err != nil {
+			st.discard()
+			return err
+		}
+	}
+	if err := st.commit(); err != nil {
+		return err
+	}
+	if remove == "" {
+		return nil
+	}
+	return os.Remove(s.path(remove))
+}
+
+// staging collects files written beside where they belong, so that a
+// change spanning several files only moves them into place once every one
+// has been written.
+type staging struct {
+	paths []string
+}
+
+// write stages v, as JSON, to be written to the path.
+func (st *staging) write(path string, v interface{}, perm os.FileMode) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err == nil {
+		err = os.MkdirAll(filepath.Dir(path), 0755)
+	}
+	if err == nil {
+		err = os.WriteFile(path+".tmp", data, perm)
+	}
+	if err != nil {
+		return err
+	}
+	st.paths = append(st.paths, path)
+	return nil
+}
+
+// discard throws away everything staged.
+func (st *staging) discard() {
+	for _, path := range st.paths {
+		os.Remove(path + ".tmp")
+	}
+	st.paths = nil
+}
+
+// commit moves the staged files into place in the order they were staged.
+func (st *staging) commit() error {
+	for _, path := range st.paths {
+		if err := os.Rename(path+".tmp", path); err != nil {
+			return err
+		}
+	}
+	st.paths = nil
+	return nil
+}
+
+// newSalt returns a random salt for password hashing.
+func newSalt() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// scryptPrefix marks a password hash made with scrypt. Hashes without it
+// are plain salted SHA-256, from before, and are replaced at the next
+// login.
+const scryptPrefix = "scrypt:"
+
+// the scrypt cost parameters, as recommended for interactive logins
+const (
+	scryptN      = 1 << 15
+	scryptR      = 8
+	scryptP      = 1
+	scryptKeyLen = 32
+)
+
+// hashPassword returns the salted hash of the given password.
+func hashPassword(password, salt string) string {
+	key, err := scrypt.Key([]byte(password), []byte(salt), scryptN, scryptR, scryptP, scryptKeyLen)
+	if err != nil {
+		// only bad parameters fail, and these are fixed
+		panic(err)
+	}
+	return scryptPrefix + hex.EncodeToString(key)
+}
+
+// checkPassword reports whether the password matches the salted hash, and
+// whether the hash is of an old kind that should be made again.
+func checkPassword(password, salt, hash string) (ok, stale bool) {
+	if strings.HasPrefix(hash, scryptPrefix) {
+		return subtle.ConstantTimeCompare([]byte(hashPassword(password, salt)), []byte(hash)) == 1, false
+	}
+	sum := sha256.Sum256([]byte(salt + password))
+	ok = subtle.ConstantTimeCompare([]byte(hex.EncodeToString(sum[:])), []byte(hash)) == 1
+	return ok, ok
+}
+
+// checkPassword reports whether the password is the player's, hashing it
+// afresh if their hash is of an old kind.
+func (p *player) checkPassword(password string) bool {
+	ok, stale := checkPassword(password, p.salt, p.passwordHash)
+	if stale {
+		p.setPassword(password)
+	}
+	return ok
+}
+
+// newPasswordHash returns a new salt and the password's hash with it.
+func newPasswordHash(password string) (salt, hash string) {
+	salt = newSalt()
+	return salt, hashPassword(password, salt)
+}
+
+// setPassword gives the player a new password, with a new salt.
+func (p *player) setPassword(password string) {
+	p.salt, p.passwordHash = newPasswordHash(password)
+}
+
+// record converts the item into its persisted form.
+func (i *item) record() itemRecord {
+	rec := itemRecord{
+		UID:       i.uid,
+		ID:        i.id,
+		Name:      i.name,
+		BaseName:  i.baseName,
+		Keywords:  i.keywords,
+		Value:     i.value,
+		Slot:      i.slot,
+		Stats:     i.stats,
+		Rarity:    i.rarity,
+		Color:     i.color,
+		Container: i.container,
+		Key:       i.isKey,
+		Keyring:   i.isKeyring,
+
+		Treasure:     i.treasure,
+		TreasureRoom: i.treasureRoom,
+		Cosmetic:     i.cosmetic,
+		Dyed:         i.dyed,
+	}
+	for _, it := range i.contents {
+		rec.Contents = append(rec.Contents, it.record())
+	}
+	return rec
+}
+
+// itemFromRecord restores an item from its persisted form.
+func itemFromRecord(rec itemRecord) *item {
+	it := &item{
+		uid:       rec.UID,
+		id:        rec.ID,
+		name:      rec.Name,
+		baseName:  rec.BaseName,
+		keywords:  rec.Keywords,
+		value:     rec.Value,
+		slot:      rec.Slot,
+		stats:     rec.Stats,
+		rarity:    rec.Rarity,
+		color:     rec.Color,
+		container: rec.Container,
+		isKey:     rec.Key,
+		isKeyring: rec.Keyring,
+
+		treasure:     rec.Treasure,
+		treasureRoom: rec.TreasureRoom,
+		cosmetic:     rec.Cosmetic,
+		dyed:         rec.Dyed,
+	}
+	if it.uid == "" {
+		// saved before items had their own IDs
+		it.uid = newUUID()
+	}
+	for _, r := range rec.Contents {
+		it.contents = append(it.contents, itemFromRecord(r))
+	}
+	return it
+}
+
+// record converts the player into the persisted form of the named character.
+func (p *player) record(name string) *characterRecord {
+	rec := &characterRecord{
+		UID:          p.uid,
+		Name:         name,
+		PasswordHash: p.passwordHash,
+		Salt:         p.salt,
+		Level:        p.level,
+		XP:           p.xp,
+		Health:       p.health,
+		MaxHealth:    p.maxHealth,
+		Mana:         p.mana,
+		MaxMana:      p.maxMana,
+		Room:         p.room,
+		Gold:         p.gold,
+		Luck:         p.luck,
+		Attributes:   p.attributes,
+		Pronouns:     &p.pronouns,
+		Class:        p.class,
+		Practices:    p.practices,
+		Remorts:      p.remorts,
+		PastClasses:  p.pastClasses,
+		Talents:      p.talents,
+		Quests:       p.quests,
+		QuestsDone:   p.questsDone,
+		HolidayGifts: p.holidayGifts,
+		Kills:        p.kills,
+		Deaths:       p.deathCount,
+		DeathLog:     p.deaths,
+		Equipment:    make(map[string]itemRecord),
+		Achievements: p.achievements,
+		Playtime:     int64(p.totalPlaytime().Seconds()),
+		Sessions:     p.sessions,
+		LastLogin:    p.lastLogin,
+		Helper:       p.helper,
+		Multiplay:    p.multiplay,
+		Channels:     p.channelList(),
+		Pose:         p.pose,
+		Skills:       p.skills,
+		Mail:         p.mail,
+		Consent:      p.consent,
+		Bounty:       p.bounty,
+		ArenaRating:  p.arenaRating,
+		JailedUntil:  p.jailedUntil,
+		Language:     p.language,
+		Locale:       p.locale,
+		ScreenReader: p.screenReader,
+		Brief:        p.brief,
+		NoMXP:        p.noMXP,
+		Charset:      p.charset,
+		Width:        p.width,
+		Stance:       p.stance,
+		Flags:        &p.flags,
+		HomeRoom:     p.homeRoom,
+		World:        p.world,
+		Elsewhere:    p.elsewhere,
+		Aliases:      p.automation.aliases,
+		Triggers:     p.automation.triggers,
+	}
+	if p.locker != nil {
+		rec.Locker = &lockerRecord{PaidUntil: p.locker.paidUntil}
+		for _, it := range p.locker.items {
+			rec.Locker.Items = append(rec.Locker.Items, it.record())
+		}
+	}
+	for _, it := range p.inventory {
+		rec.Inventory = append(rec.Inventory, it.record())
+	}
+	for slot, it := range p.equipment {
+		rec.Equipment[slot] = it.record()
+	}
+	if len(p.appearance) > 0 {
+		rec.Appearance = make(map[string]itemRecord)
+		for slot, it := range p.appearance {
+			rec.Appearance[slot] = it.record()
+		}
+	}
+	for key := range p.visited {
+		rec.Visited = append(rec.Visited, key)
+	}
+	for _, f := range p.followers {
+		fr := followerRecord{ID: f.id, Health: f.health, Following: f.following, PaidUntil: f.paidUntil}
+		for _, it := range f.inventory {
+			fr.Items = append(fr.Items, it.record())
+		}
+		rec.Followers = append(rec.Followers, fr)
+	}
+	rec.Followers = append(rec.Followers, p.followerRecords...)
+	return rec
+}
+
+// playerFromRecord restores a player from a persisted character.
+func playerFromRecord(rec *characterRecord) *player {
+	p := newPlayer()
+	if rec.UID != "" {
+		p.uid = rec.UID
+	}
+	p.passwordHash = rec.PasswordHash
+	p.salt = rec.Salt
+	p.level = rec.Level
+	p.xp = rec.XP
+	p.health = rec.Health
+	p.maxHealth = rec.MaxHealth
+	p.mana = rec.Mana
+	p.maxMana = rec.MaxMana
+	p.room = rec.Room
+	if p.room == "" && (rec.X != 0 || rec.Y != 0) {
+		// older saves kept a position on the map, which locate resolves;
+		// one at the origin was the start room, as is no room at all
+		p.room = positionHash(rec.X, rec.Y)
+	}
+	p.gold = rec.Gold
+	p.luck = rec.Luck
+	for name, score := range rec.Attributes {
+		// characters made before attributes keep the starting scores
+		p.attributes[name] = score
+	}
+	if rec.Pronouns != nil {
+		// characters made before pronouns go by they/them/their
+		p.pronouns = *rec.Pronouns
+	}
+	p.class, p.practices = rec.Class, rec.Practices
+	p.remorts, p.pastClasses = rec.Remorts, rec.PastClasses
+	for id, rank := range rec.Talents {
+		p.talents[id] = rank
+	}
+	for id, qp := range rec.Quests {
+		p.quests[id] = qp
+	}
+	for id, done := range rec.QuestsDone {
+		p.questsDone[id] = done
+	}
+	for id, year := range rec.HolidayGifts {
+		p.holidayGifts[id] = year
+	}
+	p.kills = rec.Kills
+	p.deathCount = rec.Deaths
+	p.deaths = rec.DeathLog
+	for _, r := range rec.Inventory {
+		p.inventory = append(p.inventory, itemFromRecord(r))
+	}
+	for slot, r := range rec.Equipment {
+		p.equipment[slot] = itemFromRecord(r)
+	}
+	for slot, r := range rec.Appearance {
+		p.appearance[slot] = itemFromRecord(r)
+	}
+	for _, key := range rec.Visited {
+		p.visited[key] = true
+	}
+	for id, t := range rec.Achievements {
+		p.achievements[id] = t
+	}
+	p.playtime = time.Duration(rec.Playtime) * time.Second
+	p.sessions = rec.Sessions
+	p.lastLogin = rec.LastLogin
+	p.helper = rec.Helper
+	p.multiplay = rec.Multiplay
+	p.pose = rec.Pose
+	p.language = rec.Language
+	p.locale = rec.Locale
+	p.screenReader = rec.ScreenReader
+	p.brief = rec.Brief
+	p.noMXP = rec.NoMXP
+	if _, ok := charsetNames[rec.Charset]; ok {
+		p.charset = rec.Charset
+	}
+	if rec.Width >= minWidth && rec.Width <= maxWidth {
+		p.width = rec.Width
+	}
+	if _, ok := stances[rec.Stance]; ok {
+		p.stance = rec.Stance
+	}
+	if rec.Flags != nil {
+		p.flags = *rec.Flags
+	}
+	p.homeRoom = rec.HomeRoom
+	p.world = rec.World
+	if rec.Elsewhere != nil {
+		p.elsewhere = rec.Elsewhere
+	}
+	if p.homeRoom == "" && rec.Home != nil {
+		p.homeRoom = positionHash(rec.Home[0], rec.Home[1])
+	}
+	for name, body := range rec.Aliases {
+		p.automation.aliases[name] = body
+	}
+	for _, t := range rec.Triggers {
+		if t, err := compileTrigger(t.Pattern, t.Command); err == nil {
+			p.automation.triggers = append(p.automation.triggers, t)
+		}
+	}
+	for name, level := range rec.Skills {
+		p.skills[name] = level
+	}
+	if rec.Locker != nil {
+		p.locker = &locker{paidUntil: rec.Locker.PaidUntil}
+		for _, r := range rec.Locker.Items {
+			p.locker.items = append(p.locker.items, itemFromRecord(r))
+		}
+	}
+	p.mail = rec.Mail
+	p.consent = rec.Consent
+	p.bounty = rec.Bounty
+	if rec.ArenaRating != 0 {
+		// characters who have never fought in the arena start at the default
+		p.arenaRating = rec.ArenaRating
+	}
+	p.jailedUntil = rec.JailedUntil
+	p.followerRecords = rec.Followers
+	for _, ch := range rec.Channels {
+		p.channels[ch] = true
+	}
+	return p
+}
+
+// savePlayer persists the connection's character, logging any failure.
+func (m *mud) savePlayer(c *connection) {
+	if c.player == nil {
+		return
+	}
+	if err := m.store.save(c.player.record(c.name)); err != nil {
+		log.Printf("error saving %s: %v", c.name, err)
+	}
+}
+
+// list reads every saved character record.
+func (s *store) list() ([]*characterRecord, error) {
+	paths, err := filepath.Glob(filepath.Join(s.dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	recs := make([]*characterRecord, 0, len(paths))
+	for _, path := range paths {
+		rec := &characterRecord{}
+		if err := loadJSON(path, rec); err != nil {
+			return nil, err
+		}
+		recs = append(recs, rec)
+	}
+	return recs, nil
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// respecGold is what a trainer charges for each level of the player to
+// clear their talents.
+const respecGold = 50
+
+// talent is one node of a class's talent tree. Each rank adds its stats,
+// which count as equipment bonuses do, and the first rank teaches the
+// skill if there is one.
+type talent struct {
+	ID          string         `json:"id"`
+	Name        string         `json:"name"`
+	Description string         `json:"description"`
+	Ranks       int            `json:"ranks"`
+	Level       int            `json:"level"`
+	Requires    string         `json:"requires,omitempty"`
+	Stats       map[string]int `json:"stats,omitempty"`
+	Skill       string         `json:"skill,omitempty"`
+}
+
+// talentPoints returns how many talent points the player has to spend: one
+// for each level past the first, less those already spent.
+func (p *player) talentPoints() int {
+	left := p.level - 1
+	for _, rank := range p.talents {
+		left -= rank
+	}
+	return left
+}
+
+// findTalent returns the talent in the player's class tree with the given
+// ID or name.
+func (m *mud) findTalent(p *player, name string) *talent {
+	cl := m.findClass(p.class)
+	if cl == nil {
+		return nil
+	}
+	for _, t := range cl.Talents {
+		if strings.EqualFold(t.ID, name) || strings.EqualFold(t.Name, name) {
+			return t
+		}
+	}
+	return nil
+}
+
+// applyTalents works out the stats the player's talents give them.
+func (m *mud) applyTalents(p *player) {
+	p.talentStats = make(map[string]int)
+	cl := m.findClass(p.class)
+	if cl == nil {
+		return
+	}
+	for _, t := range cl.Talents {
+		for name, n := range t.Stats {
+			p.talentStats[name] += n * p.talents[t.ID]
+		}
+	}
+}
+
+// talentsCommand shows the player's talent tree, or spends a point on a
+// talent.
+func (m *mud) talentsCommand(c *connection, args []string) {
+	p := c.player
+	cl := m.findClass(p.class)
+	if cl == nil || len(cl.Talents) == 0 {
+		c.write(c.tr("talents.none_for_class"))
+		return
+	}
+	if len(args) == 0 {
+		c.write(c.tr("talents.talents", "class", cl.Name))
+		for _, t := range cl.Talents {
+			status := ""
+			switch {
+			case p.level < t.Level:
+				status = fmt.Sprintf("  (level %d)", t.Level)
+			case t.Requires != "" && p.talents[t.Requires] == 0:
+				status = fmt.Sprintf("  (needs %s)", t.Requires)
+			}
+			c.write(c.tableRow("  %-14s %d/%d  %s%s\n", nil, t.ID, p.talents[t.ID], t.Ranks, t.Description, status))
+		}
+		c.write(c.tr("talents.have_talent_points", "count", p.talentPoints()))
+		return
+	}
+	t := m.findTalent(p, strings.Join(args, " "))
+	switch {
+	case t == nil:
+		c.write(c.tr("talents.no_such_talent"))
+	case p.talents[t.ID] >= t.Ranks:
+		c.write(c.tr("talents.have_mastered", "name", t.Name))
+	case p.level < t.Level:
+		c.write(c.tr("talents.must_level_learn", "level", t.Level, "name", t.Name))
+	case t.Requires != "" && p.talents[t.Requires] == 0:
+		c.write(c.tr("talents.must_learn_first", "requires", t.Requires))
+	case p.talentPoints() < 1:
+		c.write(c.tr("talents.have_no_talent"))
+	default:
+		p.talents[t.ID]++
+		if t.Skill != "" && p.skills[t.Skill] < trainedSkill {
+			p.skills[t.Skill] = trainedSkill
+		}
+		m.applyTalents(p)
+		m.savePlayer(c)
+		c.write(c.tr("talents.learn_rank", "name", t.Name, "rank", p.talents[t.ID], "ranks", t.Ranks))
+	}
+}
+
+// clearTalents takes back the player's talents, and any skills only a
+// talent taught them.
+func (m *mud) clearTalents(p *player) {
+	if cl := m.findClass(p.class); cl != nil {
+		for _, t := range cl.Talents {
+			if t.Skill != "" && p.talents[t.ID] > 0 && !m.classTeaches(p, t.Skill) {
+				delete(p.skills, t.Skill)
+			}
+		}
+	}
+	p.talents = make(map[string]int)
+	m.applyTalents(p)
+}
+
+// classTeaches reports whether one of the player's classes teaches the
+// skill.
+func (m *mud) classTeaches(p *player, skill string) bool {
+	for _, cl := range m.classes {
+		if !p.hasClass(cl.ID) {
+			continue
+		}
+		for _, s := range cl.Skills {
+			if s.Skill == skill {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// respec clears the player's talents at a trainer, for gold, so they can
+// spend their points again.
+func (m *mud) respec(c *connection, n *npc) {
+	p := c.player
+	if len(p.talents) == 0 {
+		c.write(c.tr("talents.havent_learned_any"))
+		return
+	}
+	cost := respecGold * p.level
+	if p.gold < cost {
+		c.write(c.tr("talents.wants_gold_help", "name", capitalize(n.name), "cost", cost))
+		return
+	}
+	p.gold -= cost
+	m.goldDestroyed("respec", cost)
+	m.clearTalents(p)
+	m.savePlayer(c)
+	c.write(c.tr("talents.pay_gold_helps", "cost", cost, "name", n.name, "points", p.talentPoints()))
+}
@@ -0,0 +1,181 @@
+package main
+
+import "bufio"
+
+// Telnet commands and the options the server negotiates.
+const (
+	telnetSE   = 240
+	telnetSB   = 250
+	telnetWILL = 251
+	telnetWONT = 252
+	telnetDO   = 253
+	telnetDONT = 254
+	telnetIAC  = 255
+
+	optNAWS    = 31
+	optCharset = 42
+	optMXP     = 91
+)
+
+// maxSubnegotiation is the most data a subnegotiation can carry. Those
+// the server understands need far less, so anything longer is thrown
+// away rather than held in memory.
+const maxSubnegotiation = 4096
+
+// telnetReader reads a client's input with its telnet commands taken out
+// and handed to the world the connection is in.
+type telnetReader struct {
+	c *connection
+	r *bufio.Reader
+}
+
+// newTelnetReader returns a reader of the connection's input.
+func newTelnetReader(c *connection) *telnetReader {
+	return &telnetReader{c: c, r: bufio.NewReader(c.conn)}
+}
+
+// Read reads input up to the next telnet command, if any, carrying it out
+// first.
+func (t *telnetReader) Read(p []byte) (int, error) {
+	n := 0
+	for n < len(p) {
+		if n > 0 && t.r.Buffered() == 0 {
+			break
+		}
+		b, err := t.r.ReadByte()
+		if err != nil {
+			if n > 0 {
+				return n, nil
+			}
+			return 0, err
+		}
+		if b != telnetIAC {
+			p[n] = b
+			n++
+			continue
+		}
+		if literal, err := t.command(); err != nil {
+			return n, err
+		} else if literal {
+			p[n] = telnetIAC
+			n++
+		}
+	}
+	return n, nil
+}
+
+// command reads the rest of a telnet command and carries it out. It
+// reports true for an escaped IAC, which stands for itself.
+func (t *telnetReader) command() (bool, error) {
+	verb, err := t.r.ReadByte()
+	if err != nil {
+		return false, err
+	}
+	switch verb {
+	case telnetIAC:
+		return true, nil
+	case telnetWILL, telnetWONT, telnetDO, telnetDONT:
+		opt, err := t.r.ReadByte()
+		if err != nil {
+			return false, err
+		}
+		for !t.c.current().world.Load().negotiate(t.c, verb, opt, nil) {
+		}
+	case telnetSB:
+		opt, err := t.r.ReadByte()
+		if err != nil {
+			return false, err
+		}
+		data, ok, err := t.subnegotiation()
+		if err != nil {
+			return false, err
+		}
+		if !ok {
+			break
+		}
+		for !t.c.current().world.Load().negotiate(t.c, telnetSB, opt, data) {
+		}
+	}
+	// anything else, such as a keepalive NOP, needs nothing done
+	return false, nil
+}
+
+// subnegotiation reads the data of a subnegotiation up to the IAC SE that
+// ends it. It reports false, with the data thrown away, if there was more
+// than maxSubnegotiation bytes of it.
+func (t *telnetReader) subnegotiation() ([]byte, bool, error) {
+	var data []byte
+	long := false
+	for {
+		b, err := t.r.ReadByte()
+		if err != nil {
+			return nil, false, err
+		}
+		if b == telnetIAC {
+			if b, err = t.r.ReadByte(); err != nil {
+				return nil, false, err
+			}
+			if b == telnetSE {
+				return data, !long, nil
+			}
+		}
+		if len(data) == maxSubnegotiation {
+			// keep reading to the end, but hold on to none of it
+			long, data = true, data[:0]
+		}
+		data = append(data, b)
+	}
+}
+
+// telnet sends a telnet command to the client, after the output gathered
+// for it so far.
+func (c *connection) telnet(cmd ...byte) {
+	c.queuePending()
+	c.queueOutput(string(cmd))
+}
+
+// offerOptions asks a new client to turn on the telnet options the server
+// supports.
+func (c *connection) offerOptions() {
+	c.telnet(telnetIAC, telnetDO, optNAWS, telnetIAC, telnetWILL, optCharset, telnetIAC, telnetWILL, optMXP)
+}
+
+// negotiate handles a telnet option command from the connection. It
+// reports false if the connection left for another world first, which
+// must handle it instead.
+func (m *mud) negotiate(c *connection, verb, opt byte, data []byte) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	c = c.current()
+	if c.world.Load() != m {
+		return false
+	}
+	switch {
+	case opt == optMXP && verb == telnetDO:
+		if !c.mxp {
+			c.mxp = true
+			c.startMXP()
+		}
+	case opt == optMXP && verb == telnetDONT:
+		c.mxp = false
+	case opt == optNAWS && verb == telnetWILL:
+		// the window size follows
+	case opt == optNAWS && verb == telnetWONT:
+		c.clientWidth = 0
+	case opt == optNAWS && verb == telnetSB:
+		c.nawsSubnegotiation(data)
+	case opt == optCharset && verb == telnetDO:
+		c.requestCharset()
+	case opt == optCharset && verb == telnetWILL:
+		// the client has character sets to offer
+		c.telnet(telnetIAC, telnetDO, optCharset)
+	case opt == optCharset && verb == telnetSB:
+		c.charsetSubnegotiation(data)
+	case verb == telnetDO:
+		c.telnet(telnetIAC, telnetWONT, opt)
+	case verb == telnetWILL:
+		c.telnet(telnetIAC, telnetDONT, opt)
+	}
+	c.flush()
+	return true
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"testing"
+)
+
+// subnegotiationInput is an IAC SB for NAWS carrying n bytes of data,
+// ended by IAC SE and followed by the given input.
+func subnegotiationInput(n int, after string) []byte {
+	in := []byte{telnetIAC, telnetSB, optNAWS}
+	in = append(in, bytes.Repeat([]byte{'x'}, n)...)
+	in = append(in, telnetIAC, telnetSE)
+	return append(in, after...)
+}
+
+func TestSubnegotiation(t *testing.T) {
+	in := subnegotiationInput(4, "")
+	tr := &telnetReader{r: bufio.NewReader(bytes.NewReader(in[3:]))}
+	data, ok, err := tr.subnegotiation()
+	if err != nil || !ok || string(data) != "xxxx" {
+		t.Fatalf("subnegotiation() = %q, %v, %v; want \"xxxx\", true, nil", data, ok, err)
+	}
+}
+
+func TestOversizedSubnegotiation(t *testing.T) {
+	in := subnegotiationInput(10*maxSubnegotiation, "look\n")
+	tr := &telnetReader{r: bufio.NewReader(bytes.NewReader(in[3:]))}
+	data, ok, err := tr.subnegotiation()
+	if err != nil {
+		t.Fatalf("subnegotiation() error = %v", err)
+	}
+	if ok {
+		t.Errorf("subnegotiation() of %d bytes was accepted", 10*maxSubnegotiation)
+	}
+	if cap(data) > 2*maxSubnegotiation {
+		t.Errorf("subnegotiation() held %d bytes, want at most %d", cap(data), 2*maxSubnegotiation)
+	}
+
+	// the input after the subnegotiation is still read as usual
+	rest, err := io.ReadAll(tr.r)
+	if err != nil || string(rest) != "look\n" {
+		t.Errorf("input after subnegotiation = %q, %v; want \"look\\n\"", rest, err)
+	}
+}
+
+func TestOversizedSubnegotiationIsIgnored(t *testing.T) {
+	// an oversized subnegotiation is dropped without reaching the world,
+	// which a reader with no connection would panic on
+	in := subnegotiationInput(2*maxSubnegotiation, "look\n")
+	tr := &telnetReader{r: bufio.NewReader(bytes.NewReader(in))}
+	got, err := io.ReadAll(tr)
+	if err != nil || string(got) != "look\n" {
+		t.Errorf("Read() = %q, %v; want \"look\\n\"", got, err)
+	}
+}
@@ -0,0 +1,135 @@
+package main
+
+import "strings"
+
+// playerFlags holds a player's on or off preferences, one bit each.
+type playerFlags uint32
+
+const (
+	flagAutoLoot playerFlags = 1 << iota
+	flagAutoGold
+	flagAutoSac
+	flagAutoAssist
+	flagCollapse
+)
+
+// defaultFlags are the preferences a new character starts with.
+const defaultFlags = flagAutoGold | flagAutoAssist | flagCollapse
+
+// sacrificeReward is the gold mall management pays for disposing of a
+// corpse.
+const sacrificeReward = 1
+
+// toggles are the flags a player can turn on and off, as the toggle
+// command lists them.
+var toggles = []struct {
+	name  string
+	flag  playerFlags
+	about string
+}{
+	{"autoloot", flagAutoLoot, "take everything from the corpses of your kills"},
+	{"autogold", flagAutoGold, "take the gold from the corpses of your kills"},
+	{"autosac", flagAutoSac, "dispose of the emptied corpses of your kills"},
+	{"autoassist", flagAutoAssist, "join in the fights of your group"},
+	{"collapse", flagCollapse, "show the same message coming again and again once, with a count"},
+}
+
+// has reports whether the player has the flag turned on.
+func (p *player) has(f playerFlags) bool {
+	return p.flags&f != 0
+}
+
+// toggleCommand lists the player's toggles and whether each is on, or
+// turns one on or off.
+func (m *mud) toggleCommand(c *connection, args []string) {
+	p := c.player
+	if len(args) == 0 {
+		c.write(c.tr("toggle.toggles"))
+		for _, t := range toggles {
+			state := "off"
+			if p.has(t.flag) {
+				state = "on"
+			}
+			c.write(c.tableRow("  %-12s %-3s  %s\n", nil, t.name, state, t.about))
+		}
+		c.write(c.tr("toggle.use_toggle_name"))
+		return
+	}
+	for _, t := range toggles {
+		if !strings.EqualFold(args[0], t.name) {
+			continue
+		}
+		p.flags ^= t.flag
+		m.savePlayer(c)
+		if p.has(t.flag) {
+			c.write(c.tr("toggle.on", "name", capitalize(t.name)))
+		} else {
+			c.write(c.tr("toggle.off", "name", capitalize(t.name)))
+		}
+		return
+	}
+	c.write(c.tr("toggle.no_toggle_called", "name", args[0]))
+}
+
+// collectSpoils loots the corpse of an NPC the player has slain, and then
+// disposes of it, as far as their toggles ask.
+func (m *mud) collectSpoils(c *connection, r *room, corpse *item) {
+	p := c.player
+	if p.room != r.id {
+		return
+	}
+	gold := p.gold
+	var kept []*item
+	for _, it := range corpse.contents {
+		if !p.has(flagAutoLoot) && !(it.id == goldItemID && p.has(flagAutoGold)) {
+			kept = append(kept, it)
+			continue
+		}
+		onRing := p.pickUp(it)
+		c.write(c.tr("spoils.take", "item", it.displayName(c), "corpse", corpse.name))
+		if onRing {
+			c.write(c.tr("common.add_key_keyring"))
+		}
+	}
+	corpse.contents = kept
+	if p.gold != gold {
+		m.events.publish(event{kind: eventGold, conn: c, amount: p.gold - gold})
+	}
+	if p.has(flagAutoSac) && len(corpse.contents) == 0 {
+		m.disposeOf(c, r, corpse)
+	}
+}
+
+// sacrifice disposes of a corpse in the player's room.
+func (m *mud) sacrifice(c *connection, args []string) {
+	if len(args) == 0 {
+		c.write(c.tr("sacrifice.sacrifice_what"))
+		return
+	}
+	r := m.rooms[c.player.room]
+	i := findItem(r.items, args[0])
+	if i < 0 {
+		c.write(c.tr("common.dont_see_here"))
+		return
+	}
+	if r.items[i].id != corpseItemID {
+		c.write(c.tr("sacrifice.mall_management_only"))
+		return
+	}
+	m.disposeOf(c, r, r.items[i])
+}
+
+// disposeOf removes a corpse from the room, and anything left in it, for
+// which mall management pays the player.
+func (m *mud) disposeOf(c *connection, r *room, corpse *item) {
+	for i, it := range r.items {
+		if it == corpse {
+			r.items = removeItem(r.items, i)
+			break
+		}
+	}
+	c.player.gold += sacrificeReward
+	m.goldCreated("sacrifice", sacrificeReward)
+	m.events.publish(event{kind: eventGold, conn: c, amount: sacrificeReward})
+	c.write(c.tr("spoils.dispose_mall_management", "corpse", corpse.name, "gold", sacrificeReward))
+}
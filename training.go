@@ -0,0 +1,286 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// practicesPerLevel is how many practice sessions a player earns each
+// level, before their wisdom.
+const practicesPerLevel = 2
+
+// trainedSkill is the level a trainer brings a skill to.
+const trainedSkill = 10
+
+// what training costs: a skill takes a practice session or gold for each
+// level it needs, and raising an attribute takes more sessions, or gold
+// for each point of the new score
+const (
+	skillSessions     = 1
+	skillGold         = 50
+	attributeSessions = 2
+	attributeGold     = 100
+)
+
+// class is a calling a character follows, as defined in the class data
+// file, with the skills and spells it can learn from trainers.
+type class struct {
+	ID          string       `json:"id"`
+	Name        string       `json:"name"`
+	Description string       `json:"description"`
+	Skills      []classSkill `json:"skills"`
+	Talents     []*talent    `json:"talents,omitempty"`
+}
+
+// classSkill is a skill or spell a class can learn, once the player
+// reaches its level.
+type classSkill struct {
+	Skill string `json:"skill"`
+	Level int    `json:"level"`
+	Spell bool   `json:"spell,omitempty"`
+}
+
+// trainerConfig makes an NPC a trainer, teaching the skills of the listed
+// classes and raising the listed attributes.
+type trainerConfig struct {
+	Classes    []string `json:"classes"`
+	Attributes []string `json:"attributes"`
+}
+
+// attributeCap returns the highest a trainer will raise an attribute for
+// a player of the given level.
+func attributeCap(level int) int {
+	return baseAttribute + level
+}
+
+// findClass returns the class with the given ID or name.
+func (m *mud) findClass(name string) *class {
+	for _, cl := range m.classes {
+		if strings.EqualFold(cl.ID, name) || strings.EqualFold(cl.Name, name) {
+			return cl
+		}
+	}
+	return nil
+}
+
+// className returns the name of the player's class, or "" if they have
+// none.
+func (m *mud) className(p *player) string {
+	if cl := m.findClass(p.class); cl != nil {
+		return cl.Name
+	}
+	return ""
+}
+
+// classIDs lists the IDs of the classes.
+func (m *mud) classIDs() string {
+	ids := make([]string, 0, len(m.classes))
+	for _, cl := range m.classes {
+		ids = append(ids, cl.ID)
+	}
+	return strings.Join(ids, ", ")
+}
+
+// sessions returns a count of practice sessions in words.
+func sessions(n int) string {
+	if n == 1 {
+		return "1 practice session"
+	}
+	return fmt.Sprintf("%d practice sessions", n)
+}
+
+// gainPractices awards the practice sessions for reaching a level, more
+// for the wise and for those who have remorted.
+func (p *player) gainPractices() int {
+	n := practicesPerLevel + p.attributeBonus("wis") + remortPractices*p.remorts
+	if n < 1 {
+		n = 1
+	}
+	p.practices += n
+	return n
+}
+
+// trainerHere returns the trainer in the player's room, if there is one
+// free to teach them.
+func (m *mud) trainerHere(c *connection) (*npc, *trainerConfig) {
+	r := m.rooms[c.player.room]
+	if r == nil {
+		return nil, nil
+	}
+	for _, n := range r.npcs {
+		if t := m.npcTemplates[n.id]; t != nil && t.Trainer != nil && n.master == "" && n.fighting == nil {
+			return n, t.Trainer
+		}
+	}
+	return nil, nil
+}
+
+// lessons returns the skills the trainer can teach the player, from their
+// class and any they followed before remorting.
+func (m *mud) lessons(p *player, t *trainerConfig) []classSkill {
+	var skills []classSkill
+	for _, id := range t.Classes {
+		if cl := m.findClass(id); cl != nil && p.hasClass(cl.ID) {
+			skills = append(skills, cl.Skills...)
+		}
+	}
+	return skills
+}
+
+// train lists what the trainer in the player's room can teach them, or
+// teaches them a skill or raises an attribute, paying with practice
+// sessions if they have enough and with gold if not.
+func (m *mud) train(c *connection, args []string) {
+	p := c.player
+	n, t := m.trainerHere(c)
+	if n == nil {
+		c.write(c.tr("train.no_trainer_here"))
+		return
+	}
+	if len(args) == 0 {
+		m.listTraining(c, n, t)
+		return
+	}
+	if strings.EqualFold(args[0], "class") {
+		m.takeClass(c, n, t, args[1:])
+		return
+	}
+	if strings.EqualFold(args[0], "respec") {
+		m.respec(c, n)
+		return
+	}
+
+	name := strings.ToLower(args[0])
+	for _, attr := range t.Attributes {
+		if attr != name && !strings.EqualFold(attributeTitles[attr], name) {
+			continue
+		}
+		score := p.attributes[attr]
+		switch {
+		case score >= maxAttribute:
+			c.write(c.tr("train.cant_trained_any", "attribute", strings.ToLower(attributeTitles[attr])))
+			return
+		case score >= attributeCap(p.level):
+			c.write(c.tr("train.must_level_train", "level", score+1-baseAttribute, "attribute", strings.ToLower(attributeTitles[attr])))
+			return
+		}
+		if !m.payForTraining(c, attributeSessions, attributeGold*(score+1)) {
+			return
+		}
+		p.attributes[attr] = score + 1
+		m.savePlayer(c)
+		c.write(c.tr("train.works_hard_rises", "name", capitalize(n.name), "attribute", strings.ToLower(attributeTitles[attr]), "score", score+1))
+		return
+	}
+	for _, s := range m.lessons(p, t) {
+		if s.Skill != name {
+			continue
+		}
+		switch {
+		case p.level < s.Level:
+			c.write(c.tr("train.must_level_learn", "level", s.Level, "skill", s.Skill))
+		case p.skills[s.Skill] >= trainedSkill:
+			c.write(c.tr("train.already_know_well", "skill", s.Skill, "name", n.name))
+		case m.payForTraining(c, skillSessions, skillGold*s.Level):
+			p.skills[s.Skill] = trainedSkill
+			m.savePlayer(c)
+			c.write(c.tr("train.teaches", "name", capitalize(n.name), "skill", s.Skill))
+		}
+		return
+	}
+	c.write(c.tr("train.cant_teach_type", "name", capitalize(n.name)))
+}
+
+// payForTraining takes the price of a lesson from the player, in practice
+// sessions if they have enough and gold if not, reporting whether they
+// could pay.
+func (m *mud) payForTraining(c *connection, cost, gold int) bool {
+	p := c.player
+	switch {
+	case p.practices >= cost:
+		p.practices -= cost
+	case p.gold >= gold:
+		p.gold -= gold
+		m.goldDestroyed("training", gold)
+		c.write(c.tr("train.pay_gold_lesson", "gold", gold))
+	default:
+		c.write(c.tr("train.takes_gold_have", "sessions", sessions(cost), "gold", gold))
+		return false
+	}
+	return true
+}
+
+// listTraining shows the player what the trainer can teach them, and what
+// each lesson costs.
+func (m *mud) listTraining(c *connection, n *npc, t *trainerConfig) {
+	p := c.player
+	c.write(c.tr("train.can_train", "name", capitalize(n.name)))
+	for _, attr := range t.Attributes {
+		score := p.attributes[attr]
+		switch {
+		case score >= maxAttribute:
+			c.write(c.tableRow("  %-14s %2d  maxed\n", nil, attributeTitles[attr], score))
+			continue
+		case score >= attributeCap(p.level):
+			c.write(c.tableRow("  %-14s %2d  at level %d\n", nil, attributeTitles[attr], score, score+1-baseAttribute))
+			continue
+		}
+		c.write(c.tableRow("  %-14s %2d  %s or %d gold\n", nil, attributeTitles[attr], score, sessions(attributeSessions), attributeGold*(score+1)))
+	}
+	if p.class == "" {
+		c.write(c.tr("train.have_no_class", "classes", m.classIDs()))
+	} else {
+		for _, s := range m.lessons(p, t) {
+			kind := "skill"
+			if s.Spell {
+				kind = "spell"
+			}
+			status := fmt.Sprintf("%s or %d gold", sessions(skillSessions), skillGold*s.Level)
+			switch {
+			case p.skills[s.Skill] >= trainedSkill:
+				status = "learned"
+			case p.level < s.Level:
+				status = fmt.Sprintf("at level %d", s.Level)
+			}
+			c.write(c.tableRow("  %-14s %-5s  %s\n", nil, s.Skill, kind, status))
+		}
+	}
+	if len(p.talents) > 0 {
+		c.write(c.tr("train.respec", "cost", respecGold*p.level))
+	}
+	c.write(c.tr("train.have_gold", "sessions", sessions(p.practices), "gold", p.gold))
+}
+
+// takeClass has a player without a class take one up at a trainer.
+func (m *mud) takeClass(c *connection, n *npc, t *trainerConfig, args []string) {
+	p := c.player
+	if p.class != "" {
+		c.write(c.tr("train.already", "class", strings.ToLower(m.className(p))))
+		return
+	}
+	if len(args) == 0 {
+		c.write(c.tr("train.usage_train_class", "classes", strings.Join(strings.Split(m.classIDs(), ", "), "|")))
+		return
+	}
+	cl := m.findClass(args[0])
+	if cl == nil {
+		c.write(c.tr("common.no_such_class", "classes", m.classIDs()))
+		return
+	}
+	p.class = cl.ID
+	m.savePlayer(c)
+	c.write(c.tr("train.welcomes", "name", capitalize(n.name), "class", strings.ToLower(cl.Name)))
+}
+
+// taughtSpell reports whether a class teaches the spell, so that it must
+// be learned before it can be cast.
+func (m *mud) taughtSpell(name string) bool {
+	for _, cl := range m.classes {
+		for _, s := range cl.Skills {
+			if s.Spell && s.Skill == name {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,224 @@
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+// characterTransfer is a character exported from one server for import
+// into another, such as from a test server into production. The payload
+// is signed, in its compact form, with the transfer key the servers share,
+// so a file that has been edited since is refused.
+type characterTransfer struct {
+	Payload   json.RawMessage `json:"payload"`
+	Signature string          `json:"signature"`
+}
+
+// transferPayload is the signed part of a character transfer.
+type transferPayload struct {
+	Exported  time.Time        `json:"exported"`
+	Character *characterRecord `json:"character"`
+}
+
+// transferSignature signs a transfer payload with the key.
+func transferSignature(key string, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(key))
+	mac.Write(payload)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// exportCharacter writes the named character to a signed transfer file.
+func (m *mud) exportCharacter(name, path string) error {
+	if m.config.TransferKey == "" {
+		return errors.New("no transferKey is configured")
+	}
+	rec, err := m.store.load(name)
+	if err != nil {
+		return fmt.Errorf("no character named %s", name)
+	}
+	payload, err := json.Marshal(transferPayload{Exported: time.Now(), Character: rec})
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(characterTransfer{
+		Payload:   payload,
+		Signature: transferSignature(m.config.TransferKey, payload),
+	}, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0600)
+}
+
+// importCharacter checks the signature on a transfer file and adds its
+// character to this server. Items not on the config's transfer list, or
+// not in this world, are left behind; their names are returned. The gold
+// the character brings is entered in the ledger.
+func (m *mud) importCharacter(path string) (*characterRecord, []string, error) {
+	if m.config.TransferKey == "" {
+		return nil, nil, errors.New("no transferKey is configured")
+	}
+	var t characterTransfer
+	if err := loadJSON(path, &t); err != nil {
+		return nil, nil, err
+	}
+	var compact bytes.Buffer
+	if err := json.Compact(&compact, t.Payload); err != nil {
+		return nil, nil, err
+	}
+	want := transferSignature(m.config.TransferKey, compact.Bytes())
+	if !hmac.Equal([]byte(want), []byte(t.Signature)) {
+		return nil, nil, errors.New("the signature doesn't match; the file was changed or signed with another key")
+	}
+	var payload transferPayload
+	if err := json.Unmarshal(compact.Bytes(), &payload); err != nil {
+		return nil, nil, err
+	}
+	rec := payload.Character
+	if err := m.validateTransfer(rec); err != nil {
+		return nil, nil, err
+	}
+	if _, err := m.store.load(rec.Name); !os.IsNotExist(err) {
+		return nil, nil, fmt.Errorf("a character named %s already exists", rec.Name)
+	}
+	known, err := m.knownUIDs()
+	if err != nil {
+		return nil, nil, err
+	}
+	if rec.UID == "" {
+		rec.UID = newUUID()
+	} else if known[rec.UID] {
+		return nil, nil, fmt.Errorf("%s is already here under another name", rec.Name)
+	}
+	dropped := m.filterTransferItems(rec, known)
+	if err := m.loadLedger(); err != nil {
+		return nil, nil, err
+	}
+	if err := m.store.save(rec); err != nil {
+		return nil, nil, err
+	}
+	m.goldCreated("import", rec.Gold)
+	m.saveLedger()
+	return rec, dropped, nil
+}
+
+// validateTransfer refuses a transferred character that couldn't have been
+// saved by a working server, and moves it out of rooms this world lacks.
+func (m *mud) validateTransfer(rec *characterRecord) error {
+	switch {
+	case rec == nil:
+		return errors.New("the file has no character")
+	case nameProblem(rec.Name) != "":
+		return fmt.Errorf("bad character name %q", rec.Name)
+	case rec.PasswordHash == "" || rec.Salt == "":
+		return fmt.Errorf("%s has no password", rec.Name)
+	case rec.Level < 1 || rec.XP < 0 || rec.Gold < 0 || rec.MaxHealth < 1 || rec.MaxMana < 0:
+		return fmt.Errorf("%s has impossible stats", rec.Name)
+	}
+	if _, ok := m.rooms[rec.Room]; !ok {
+		rec.Room, rec.X, rec.Y = m.entrance, 0, 0
+	}
+	if _, ok := m.rooms[rec.HomeRoom]; !ok {
+		rec.HomeRoom, rec.Home = "", nil
+	}
+	return nil
+}
+
+// transferable reports whether an item may be brought in from another
+// server: it must be on the config's transfer list and exist in this
+// world.
+func (m *mud) transferable(id string) bool {
+	if _, ok := m.itemTemplates[id]; !ok {
+		return false
+	}
+	for _, allowed := range m.config.TransferItems {
+		if allowed == id {
+			return true
+		}
+	}
+	return false
+}
+
+// knownUIDs returns the UIDs of every saved character and everything they
+// own, so that nothing is brought in twice.
+func (m *mud) knownUIDs() (map[string]bool, error) {
+	recs, err := m.store.list()
+	if err != nil {
+		return nil, err
+	}
+	known := make(map[string]bool)
+	var walk func(items []itemRecord) []itemRecord
+	walk = func(items []itemRecord) []itemRecord {
+		for _, it := range items {
+			known[it.UID] = true
+			walk(it.Contents)
+		}
+		return items
+	}
+	for _, rec := range recs {
+		known[rec.UID] = true
+		rec.eachItemList(walk)
+	}
+	delete(known, "")
+	return known, nil
+}
+
+// eachItemList replaces each list of items the character keeps, in their
+// inventory, equipment, locker, mail, and hirelings' packs, with what fn
+// returns for it.
+func (rec *characterRecord) eachItemList(fn func(items []itemRecord) []itemRecord) {
+	rec.Inventory = fn(rec.Inventory)
+	for _, slot := range sortedKeys(rec.Equipment) {
+		if kept := fn([]itemRecord{rec.Equipment[slot]}); len(kept) == 0 {
+			delete(rec.Equipment, slot)
+		} else {
+			rec.Equipment[slot] = kept[0]
+		}
+	}
+	if rec.Locker != nil {
+		rec.Locker.Items = fn(rec.Locker.Items)
+	}
+	for i := range rec.Mail {
+		rec.Mail[i].Items = fn(rec.Mail[i].Items)
+	}
+	for i := range rec.Followers {
+		rec.Followers[i].Items = fn(rec.Followers[i].Items)
+	}
+}
+
+// filterTransferItems removes the items that can't be transferred, or are
+// already here, from everywhere a character keeps them, returning their
+// names. Hirelings of NPCs this world lacks are dismissed.
+func (m *mud) filterTransferItems(rec *characterRecord, known map[string]bool) []string {
+	var followers []followerRecord
+	for _, f := range rec.Followers {
+		if _, ok := m.npcTemplates[f.ID]; ok {
+			followers = append(followers, f)
+		}
+	}
+	rec.Followers = followers
+
+	var dropped []string
+	var filter func(items []itemRecord) []itemRecord
+	filter = func(items []itemRecord) []itemRecord {
+		var kept []itemRecord
+		for _, it := range items {
+			if !m.transferable(it.ID) || known[it.UID] {
+				dropped = append(dropped, it.Name)
+				continue
+			}
+			it.Contents = filter(it.Contents)
+			kept = append(kept, it)
+		}
+		return kept
+	}
+	rec.eachItemList(filter)
+	return dropped
+}
@@ -0,0 +1,243 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"time"
+)
+
+// trap kinds
+const (
+	trapDamage   = "damage"
+	trapTeleport = "teleport"
+	trapEffect   = "effect"
+)
+
+// trapConfig describes a trap as defined in the trap data file. A trap is
+// set on a room, on one of its exits, or on a container it places there.
+type trapConfig struct {
+	ID         string   `json:"id"`
+	Name       string   `json:"name"`
+	Keywords   []string `json:"keywords"`
+	Room       roomRef  `json:"room"`
+	Exit       string   `json:"exit"`
+	Container  string   `json:"container"`
+	Kind       string   `json:"kind"`
+	Damage     int      `json:"damage"`
+	To         roomRef  `json:"to"`
+	Effect     string   `json:"effect"`
+	Duration   int      `json:"duration"`
+	Difficulty int      `json:"difficulty"`
+	Reset      int      `json:"reset"`
+	Message    string   `json:"message"`
+}
+
+// trap is a trap placed in the world. Players who spot an armed trap avoid
+// it until it resets.
+type trap struct {
+	*trapConfig
+	armed     bool
+	container *item
+	spotted   map[string]bool
+}
+
+// setTraps arms every trap from the trap data, placing trapped containers
+// in their rooms.
+func (m *mud) setTraps() {
+	for _, cfg := range m.trapConfigs {
+		t := &trap{trapConfig: cfg, armed: true, spotted: make(map[string]bool)}
+		if cfg.Container != "" {
+			tmpl, ok := m.itemTemplates[cfg.Container]
+			r := m.roomFor(cfg.Room)
+			if !ok || r == nil {
+				continue
+			}
+			t.container = newItem(tmpl)
+			for _, id := range tmpl.Contents {
+				if ct, ok := m.itemTemplates[id]; ok {
+					t.container.contents = append(t.container.contents, newItem(ct))
+				}
+			}
+			r.items = append(r.items, t.container)
+		}
+		m.traps = append(m.traps, t)
+	}
+}
+
+// trapsIn returns the traps set in the given room.
+func (m *mud) trapsIn(r *room) []*trap {
+	var traps []*trap
+	for _, t := range m.traps {
+		if t.Room.is(r) {
+			traps = append(traps, t)
+		}
+	}
+	return traps
+}
+
+// trapRoll reports whether a check with the player's trap skill against the
+// trap's difficulty succeeds. bonus makes the check easier.
+func (p *player) trapRoll(t *trap, bonus int) bool {
+	return rand.Intn(100) < bonus+p.skills["traps"]-t.Difficulty
+}
+
+// spring sets off the trap on the player. It reports whether the player can
+// carry on with what they were doing.
+func (m *mud) spring(c *connection, t *trap) bool {
+	p := c.player
+	if !t.armed {
+		return true
+	}
+	if t.spotted[c.name] {
+		c.write(c.tr("trap.carefully_avoid", "name", t.Name))
+		return true
+	}
+	m.disarmTrap(t)
+
+	c.write(colorize(t.Message, "red") + "\n")
+	switch t.Kind {
+	case trapDamage:
+		p.health -= t.Damage
+		c.write(c.tr("trap.take_damage", "damage", t.Damage))
+		if p.health <= 0 {
+			m.playerDeath(c, t.Name)
+			return false
+		}
+	case trapTeleport:
+		if to := m.roomFor(t.To); to != nil {
+			c.write("\n")
+			if m.teleport(c, to) {
+				return false
+			}
+		}
+	case trapEffect:
+		p.addEffect(t.Effect, time.Duration(t.Duration)*time.Second)
+	}
+	return true
+}
+
+// enterTraps springs the room traps where the player arrives, giving them a
+// chance to notice each one first.
+func (m *mud) enterTraps(e event) {
+	c := e.conn
+	for _, t := range m.trapsIn(e.room) {
+		if !t.armed || t.Exit != "" || t.container != nil {
+			continue
+		}
+		if !t.spotted[c.name] && c.player.trapRoll(t, 0) {
+			t.spotted[c.name] = true
+			c.write(c.tr("trap.notice_just_time", "name", t.Name))
+		}
+		if !m.spring(c, t) {
+			return
+		}
+	}
+}
+
+// exitTrap springs any trap on the exit the player is taking. It reports
+// whether they can still go that way.
+func (m *mud) exitTrap(c *connection, r *room, dir string) bool {
+	for _, t := range m.trapsIn(r) {
+		if t.Exit == dir && !m.spring(c, t) {
+			return false
+		}
+	}
+	return true
+}
+
+// containerTrap springs any trap on the container the player is opening.
+// It reports whether they can still take from it.
+func (m *mud) containerTrap(c *connection, container *item) bool {
+	for _, t := range m.traps {
+		if t.container == container && !m.spring(c, t) {
+			return false
+		}
+	}
+	return true
+}
+
+// describe returns where the trap is set.
+func (t *trap) describe() string {
+	switch {
+	case t.Exit != "":
+		return fmt.Sprintf("%s on the %s exit", t.Name, t.Exit)
+	case t.container != nil:
+		return fmt.Sprintf("%s on %s", t.Name, t.container.name)
+	}
+	return t.Name
+}
+
+// showTraps lists the armed traps the player has spotted in their room.
+func (m *mud) showTraps(c *connection) {
+	p := c.player
+	for _, t := range m.trapsIn(m.rooms[p.room]) {
+		if t.armed && t.spotted[c.name] {
+			c.write(colorize(c.tr("trap.have_spotted", "trap", t.describe()), "red"))
+		}
+	}
+}
+
+// search looks for traps in the player's room.
+func (m *mud) search(c *connection) {
+	p := c.player
+	c.lag(skillLag)
+	found := false
+	for _, t := range m.trapsIn(m.rooms[p.room]) {
+		if !t.armed || t.spotted[c.name] || !p.trapRoll(t, 50) {
+			continue
+		}
+		t.spotted[c.name] = true
+		found = true
+		c.write(c.tr("trap.find", "trap", t.describe()))
+	}
+	if !found {
+		c.write(c.tr("trap.dont_find_anything"))
+	}
+	m.improveSkill(c, "traps")
+}
+
+// disarm tries to disarm a trap the player has spotted, given by keyword or
+// by the exit it is set on. Failing badly sets it off.
+func (m *mud) disarm(c *connection, args []string) {
+	if len(args) == 0 {
+		c.write(c.tr("trap.disarm_what"))
+		return
+	}
+	p := c.player
+	var target *trap
+	for _, t := range m.trapsIn(m.rooms[p.room]) {
+		if t.armed && t.spotted[c.name] && (t.Exit == args[0] || matchKeywords(t.Keywords, args[0])) {
+			target = t
+		}
+	}
+	if target == nil {
+		c.write(c.tr("trap.dont_know_trap"))
+		return
+	}
+	c.lag(skillLag)
+	defer m.improveSkill(c, "traps")
+	if p.trapRoll(target, 40) {
+		m.disarmTrap(target)
+		c.write(c.tr("trap.disarm", "trap", target.Name))
+		return
+	}
+	if rand.Intn(2) == 0 {
+		c.write(c.tr("trap.fumble_set_off", "trap", target.Name))
+		delete(target.spotted, c.name)
+		m.spring(c, target)
+		return
+	}
+	c.write(c.tr("trap.fail_disarm", "trap", target.Name))
+}
+
+// disarmTrap disarms the trap until its reset timer rearms it. Rearmed traps
+// have to be spotted again.
+func (m *mud) disarmTrap(t *trap) {
+	t.armed = false
+	if t.Reset > 0 {
+		m.scheduler.after("reset trap: "+t.ID, time.Duration(t.Reset)*time.Second, func() {
+			t.armed = true
+			t.spotted = make(map[string]bool)
+		})
+	}
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"math/rand"
+)
+
+// treasureSite reports whether treasure can be buried in the room: it must
+// be on the map, with ground to dig in.
+func treasureSite(r *room) bool {
+	return r.mapped && r.id != jailRoom && !r.flags["private"] &&
+		!r.flags["air"] && !r.flags["water"] && !r.flags["underwater"]
+}
+
+// markMap picks the spot a treasure map points to, the first time anyone
+// looks, so maps from every source are marked the same way. It reports
+// false if the world has nowhere to bury treasure.
+func (m *mud) markMap(it *item) bool {
+	if r := m.rooms[it.treasureRoom]; r != nil && treasureSite(r) {
+		return true
+	}
+	var sites []string
+	for _, id := range sortedKeys(m.rooms) {
+		if treasureSite(m.rooms[id]) {
+			sites = append(sites, id)
+		}
+	}
+	if len(sites) == 0 {
+		return false
+	}
+	it.treasureRoom = sites[rand.Intn(len(sites))]
+	return true
+}
+
+// describeMap tells the player where a treasure map points, from where
+// they stand.
+func (m *mud) describeMap(c *connection, it *item) {
+	if !m.markMap(it) {
+		c.write(c.tr("treasure.map_too_faded"))
+		return
+	}
+	site, here := m.rooms[it.treasureRoom], m.rooms[c.player.room]
+	switch {
+	case site == here:
+		c.write(c.tr("treasure.map_here"))
+	case here != nil && here.mapped:
+		c.write(c.tr("treasure.map_direction", "x", site.x, "y", site.y, "direction", relativePosition(site.x-here.x, site.y-here.y)))
+	default:
+		c.write(c.tr("treasure.map_position", "x", site.x, "y", site.y))
+	}
+}
+
+// dig digs for treasure where the player stands. A treasure map marking
+// the spot turns up what was buried there, and is used up.
+func (m *mud) dig(c *connection) {
+	p := c.player
+	r := m.rooms[p.room]
+	if r == nil || !treasureSite(r) {
+		c.write(c.tr("treasure.theres_nowhere_dig"))
+		return
+	}
+	c.lag(skillLag)
+	at := -1
+	for i, it := range p.inventory {
+		if it.treasure != "" && m.markMap(it) && it.treasureRoom == r.id {
+			at = i
+			break
+		}
+	}
+	if at < 0 {
+		c.write(c.tr("treasure.dig_while_find"))
+		m.tellOthers(c, "treasure.dig_nothing_others")
+		return
+	}
+	tmap := p.inventory[at]
+	t, ok := m.lootTables[tmap.treasure]
+	if !ok {
+		log.Printf("treasure map %s buries missing loot table %s", tmap.id, tmap.treasure)
+		c.write(c.tr("treasure.dig_where_map"))
+		return
+	}
+	p.inventory = removeItem(p.inventory, at)
+	items, _, gold := t.roll(m, p.totalLuck())
+	c.write(c.tr("treasure.dig_where_x"))
+	m.tellOthers(c, "treasure.dig_treasure_others")
+	if gold > 0 {
+		p.gold += gold
+		m.goldCreated("treasure", gold)
+		c.write(c.tr("treasure.find_gold", "gold", gold))
+	}
+	for _, it := range items {
+		if len(p.inventory) < p.carryLimit() {
+			p.pickUp(it)
+			c.write(c.tr("treasure.find", "item", it.displayName(c)))
+			continue
+		}
+		r.items = append(r.items, it)
+		c.write(c.tr("treasure.find_cant_carry", "item", it.displayName(c)))
+	}
+	c.write(c.tr("treasure.crumbles_nothing", "map", capitalize(tmap.name)))
+	m.savePlayer(c)
+}
+
+// checkTreasure reports the treasure maps that bury loot tables that don't
+// exist.
+func (m *mud) checkTreasure() []string {
+	var problems []string
+	for _, id := range sortedKeys(m.itemTemplates) {
+		if t := m.itemTemplates[id]; t.Treasure != "" && m.lootTables[t.Treasure] == nil {
+			problems = append(problems, fmt.Sprintf("treasure map %s buries missing loot table %s", id, t.Treasure))
+		}
+	}
+	return problems
+}
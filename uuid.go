@@ -0,0 +1,42 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/sha1"
+	"fmt"
+)
+
+// uuidNamespace is the namespace of the name-based UUIDs the game derives,
+// such as for rooms that aren't given one.
+var uuidNamespace = [16]byte{0x6d, 0x61, 0x6c, 0x6c, 0x2d, 0x6d, 0x75, 0x64, 0x8e, 0x1f, 0x4b, 0x0a, 0x9c, 0x3d, 0x52, 0x27}
+
+// newUUID returns a random (version 4) UUID, for telling apart rooms,
+// items, and characters across worlds and servers where names and
+// positions can collide.
+func newUUID() string {
+	var u [16]byte
+	if _, err := rand.Read(u[:]); err != nil {
+		panic(err)
+	}
+	u[6] = u[6]&0x0f | 0x40
+	u[8] = u[8]&0x3f | 0x80
+	return formatUUID(u)
+}
+
+// nameUUID returns the name-based (version 5) UUID of the name, which is
+// always the same for the same name.
+func nameUUID(name string) string {
+	h := sha1.New()
+	h.Write(uuidNamespace[:])
+	h.Write([]byte(name))
+	var u [16]byte
+	copy(u[:], h.Sum(nil))
+	u[6] = u[6]&0x0f | 0x50
+	u[8] = u[8]&0x3f | 0x80
+	return formatUUID(u)
+}
+
+// formatUUID writes a UUID in its usual text form.
+func formatUUID(u [16]byte) string {
+	return fmt.Sprintf("%x-%x-%x-%x-%x", u[0:4], u[4:6], u[6:8], u[8:10], u[10:16])
+}
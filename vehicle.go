@@ -0,0 +1,259 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// vehicleConfig describes a vehicle as defined in the vehicle data file.
+// Vehicles with a schedule move on their own; the rest go where their
+// buttons send them.
+type vehicleConfig struct {
+	ID          string        `json:"id"`
+	Name        string        `json:"name"`
+	Keywords    []string      `json:"keywords"`
+	Description string        `json:"description"`
+	Stops       []vehicleStop `json:"stops"`
+	Travel      int           `json:"travel"`
+	Schedule    int           `json:"schedule"`
+	Capacity    int           `json:"capacity,omitempty"`
+}
+
+// vehicleStop is a room a vehicle stops at.
+type vehicleStop struct {
+	Name string  `json:"name"`
+	Room roomRef `json:"room"`
+}
+
+// vehicle is an object players can enter, with its own room inside that
+// is carried between its stops.
+type vehicle struct {
+	*vehicleConfig
+	interior *room
+	stops    []string
+	stop     int
+	moving   bool
+	forward  bool
+}
+
+// placeVehicles creates the vehicles from the vehicle data, giving each an
+// interior room off the map, and schedules those that run on their own.
+func (m *mud) placeVehicles() {
+	for _, cfg := range m.vehicleConfigs {
+		if len(cfg.Stops) == 0 {
+			continue
+		}
+		v := &vehicle{vehicleConfig: cfg, forward: true}
+		for _, stop := range cfg.Stops {
+			id := ""
+			if r := m.roomFor(stop.Room); r != nil {
+				id = r.id
+			}
+			v.stops = append(v.stops, id)
+		}
+		v.interior = newRoom(capitalize(cfg.Name), cfg.Description)
+		v.interior.capacity = cfg.Capacity
+		m.placeRoom("vehicle_"+cfg.ID, v.interior)
+		v.arrive(0)
+		m.vehicles = append(m.vehicles, v)
+		if cfg.Schedule > 0 {
+			m.scheduler.every(cfg.Name, time.Duration(cfg.Schedule)*time.Second, func() {
+				m.depart(v, v.nextStop(), nil)
+			})
+		}
+	}
+}
+
+// stopRoom returns the room ID of the given stop.
+func (v *vehicle) stopRoom(stop int) string {
+	return v.stops[stop]
+}
+
+// arrive stops the vehicle at the given stop and opens its doors.
+func (v *vehicle) arrive(stop int) {
+	v.stop = stop
+	v.moving = false
+	v.interior.exits["out"] = v.stopRoom(stop)
+}
+
+// nextStop returns the stop after the current one, running back and forth
+// along the line of stops.
+func (v *vehicle) nextStop() int {
+	if len(v.Stops) == 1 {
+		return 0
+	}
+	if v.forward && v.stop == len(v.Stops)-1 {
+		v.forward = false
+	} else if !v.forward && v.stop == 0 {
+		v.forward = true
+	}
+	if v.forward {
+		return v.stop + 1
+	}
+	return v.stop - 1
+}
+
+// vehiclesAt returns the vehicles standing with their doors open in the
+// given room.
+func (m *mud) vehiclesAt(r *room) []*vehicle {
+	var vs []*vehicle
+	for _, v := range m.vehicles {
+		if !v.moving && v.stopRoom(v.stop) == r.id {
+			vs = append(vs, v)
+		}
+	}
+	return vs
+}
+
+// vehicleInside returns the vehicle whose interior the player is in.
+func (m *mud) vehicleInside(p *player) *vehicle {
+	for _, v := range m.vehicles {
+		if v.interior.id == p.room {
+			return v
+		}
+	}
+	return nil
+}
+
+// showVehicles lists the vehicles waiting in the room.
+func (m *mud) showVehicles(c *connection, r *room) {
+	for _, v := range m.vehiclesAt(r) {
+		c.write(c.tr("vehicle.here_doors_open", "vehicle", capitalize(v.Name)))
+	}
+}
+
+// enterVehicle moves the player into a vehicle waiting in their room.
+func (m *mud) enterVehicle(c *connection, args []string) {
+	if len(args) == 0 {
+		c.write(c.tr("vehicle.enter_what"))
+		return
+	}
+	p := c.player
+	from := m.rooms[p.room]
+	if from == nil {
+		c.write(c.tr("vehicle.nothing_here_enter"))
+		return
+	}
+	for _, v := range m.vehiclesAt(from) {
+		if !matchKeywords(v.Keywords, args[0]) {
+			continue
+		}
+		if !m.hasRoomFor(c, v.interior) {
+			return
+		}
+		p.room = v.interior.id
+		p.visited[p.room] = true
+		c.write(c.tr("vehicle.step_into", "vehicle", v.Name))
+		m.glance(c)
+		m.bringFollowers(c, from, v.interior)
+		m.events.publish(event{kind: eventEnterRoom, conn: c, room: v.interior})
+		return
+	}
+	c.write(c.tr("common.dont_see_here"))
+}
+
+// pushButton works a vehicle's buttons. Inside, it sends the vehicle to a
+// stop by number or name, or to the next stop; at a stop, it calls the
+// vehicle there.
+func (m *mud) pushButton(c *connection, args []string) {
+	if len(args) == 0 || args[0] != "button" {
+		c.write(c.tr("vehicle.push_what"))
+		return
+	}
+	p := c.player
+	if v := m.vehicleInside(p); v != nil {
+		if v.Schedule > 0 {
+			c.write(c.tr("vehicle.runs_own_schedule", "vehicle", capitalize(v.Name)))
+			return
+		}
+		if v.moving {
+			c.write(c.tr("vehicle.already_way"))
+			return
+		}
+		stop := v.nextStop()
+		if len(args) > 1 {
+			stop = v.findStop(strings.Join(args[1:], " "))
+			if stop < 0 {
+				c.write(c.tr("vehicle.no_button_stop"))
+				for i, s := range v.Stops {
+					c.write(fmt.Sprintf("  %d. %s\n", i+1, s.Name))
+				}
+				return
+			}
+		}
+		if stop == v.stop {
+			c.write(c.tr("common.already_there"))
+			return
+		}
+		m.depart(v, stop, c)
+		return
+	}
+
+	key := p.room
+	for _, v := range m.vehicles {
+		if v.Schedule > 0 {
+			continue
+		}
+		for i := range v.Stops {
+			if v.stopRoom(i) != key {
+				continue
+			}
+			switch {
+			case v.moving:
+				c.write(c.tr("vehicle.push_button_way", "vehicle", capitalize(v.Name)))
+			case v.stop == i:
+				c.write(c.tr("vehicle.already_here", "vehicle", capitalize(v.Name)))
+			default:
+				c.write(c.tr("vehicle.push_button_call", "vehicle", v.Name))
+				m.depart(v, i, c)
+			}
+			return
+		}
+	}
+	c.write(c.tr("vehicle.no_button_here"))
+}
+
+// findStop returns the index of the stop with the given number or name.
+func (v *vehicle) findStop(s string) int {
+	if n, err := strconv.Atoi(s); err == nil && n >= 1 && n <= len(v.Stops) {
+		return n - 1
+	}
+	for i, stop := range v.Stops {
+		if strings.EqualFold(stop.Name, s) {
+			return i
+		}
+	}
+	return -1
+}
+
+// depart closes the vehicle's doors and sends it to the given stop,
+// carrying everyone inside with it. by is the player who sent it, if any.
+func (m *mud) depart(v *vehicle, stop int, by *connection) {
+	if v.moving || stop == v.stop {
+		return
+	}
+	from := m.rooms[v.stopRoom(v.stop)]
+	v.moving = true
+	delete(v.interior.exits, "out")
+	m.vehicleEcho(v.interior, by, "vehicle.sets_off", "vehicle", v.Name, "stop", v.Stops[stop].Name)
+	if from != nil {
+		m.vehicleEcho(from, by, "vehicle.departs", "vehicle", v.Name)
+	}
+	m.scheduler.after(v.Name, time.Duration(v.Travel)*time.Second, func() {
+		v.arrive(stop)
+		m.vehicleEcho(v.interior, nil, "vehicle.arrives_at", "vehicle", capitalize(v.Name), "stop", v.Stops[stop].Name)
+		if to := m.rooms[v.stopRoom(stop)]; to != nil {
+			m.vehicleEcho(to, nil, "vehicle.arrives", "vehicle", capitalize(v.Name))
+		}
+	})
+}
+
+// vehicleEcho shows a catalog message to the players in a room. Everyone
+// but the player whose command caused it gets their prompt redrawn.
+func (m *mud) vehicleEcho(r *room, by *connection, key string, params ...interface{}) {
+	m.send(inRoom(r.id), rendered((*connection).locale, func(conn *connection) string {
+		return conn.tr(key, params...)
+	}).asAside(by))
+}
@@ -0,0 +1,95 @@
+package main
+
+import "log"
+
+// playerCount returns how many characters are playing in the world,
+// counting those in an editor or whose link has dropped but not the
+// console.
+func (m *mud) playerCount() int {
+	n := 0
+	for key, c := range m.conns {
+		if key == nameKey(c.name) && !c.console && (c.state == statePlaying || c.state == stateEditing) {
+			n++
+		}
+	}
+	return n
+}
+
+// full reports whether the world has as many players as the config allows.
+func (m *mud) full() bool {
+	limit := m.config.MaxPlayers
+	return limit > 0 && m.playerCount() >= limit
+}
+
+// admit brings a player who has just logged in into the world, or puts
+// them at the back of the line to wait for a place if it is full. Staff
+// never wait.
+func (m *mud) admit(c *connection, created bool) {
+	if !c.console && !m.config.isAdmin(c.name) && (m.full() || len(m.waiting) > 0) {
+		c.state = stateWaiting
+		c.createdWaiting = created
+		m.waiting = append(m.waiting, c)
+		log.Printf("%s is waiting for a place, %d in line", c.name, len(m.waiting))
+		c.write(c.tr("waiting.full"))
+		c.write(c.tr("waiting.position", "position", len(m.waiting)))
+		return
+	}
+	m.enterGame(c, created)
+}
+
+// admitWaiting lets in as many of the players waiting in line as there
+// are places for, and tells the rest where they stand if that changed.
+func (m *mud) admitWaiting() {
+	admitted := 0
+	for len(m.waiting) > 0 && !m.full() {
+		c := m.waiting[0]
+		m.waiting = m.waiting[1:]
+		admitted++
+		c.write(c.tr("waiting.admitted"))
+		m.enterGame(c, c.createdWaiting)
+		c.writePrompt()
+		c.flush()
+	}
+	if admitted == 0 {
+		return
+	}
+	for i, c := range m.waiting {
+		c.write(c.tr("waiting.position", "position", i+1))
+		c.flush()
+	}
+}
+
+// leaveLine takes a player out of the line, letting those behind them
+// know they have moved up.
+func (m *mud) leaveLine(c *connection) {
+	for i, w := range m.waiting {
+		if w != c {
+			continue
+		}
+		m.waiting = append(m.waiting[:i], m.waiting[i+1:]...)
+		for j := i; j < len(m.waiting); j++ {
+			w := m.waiting[j]
+			w.write(w.tr("waiting.position", "position", j+1))
+			w.flush()
+		}
+		return
+	}
+}
+
+// handleWaiting answers a player waiting in line: they may quit, and are
+// otherwise reminded where they stand.
+func (m *mud) handleWaiting(c *connection, cmd string) {
+	if cmd == "quit" {
+		c.write(c.tr("quit.bye"))
+		m.forget(c)
+		c.close()
+		c.state = stateDead
+		return
+	}
+	for i, w := range m.waiting {
+		if w == c {
+			c.write(c.tr("waiting.position", "position", i+1))
+			return
+		}
+	}
+}
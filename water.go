@@ -0,0 +1,115 @@
+package main
+
+import (
+	"math/rand"
+	"time"
+)
+
+// drownInterval is how often players in water are checked for drowning.
+const drownInterval = 5 * time.Second
+
+// effectWaterBreathing lets a player breathe in underwater rooms.
+const effectWaterBreathing = "waterbreathing"
+
+// hasBoat reports whether the player is carrying a boat.
+func (m *mud) hasBoat(p *player) bool {
+	for _, it := range p.inventory {
+		if t, ok := m.itemTemplates[it.id]; ok && t.Boat {
+			return true
+		}
+	}
+	return false
+}
+
+// swimRoll makes a swimming check.
+func (p *player) swimRoll() bool {
+	return rand.Intn(100) < 40+p.skills["swimming"]
+}
+
+// swim makes a swimming check for moving through water, which improves the
+// skill either way.
+func (m *mud) swim(c *connection) bool {
+	ok := c.player.swimRoll()
+	m.improveSkill(c, "swimming")
+	return ok
+}
+
+// enterWater reports whether the player can make it into the given room.
+// Water rooms need a boat or a successful swim, and boats are no use
+// underwater.
+func (m *mud) enterWater(c *connection, r *room) bool {
+	switch {
+	case r.flags["water"] && m.hasBoat(c.player):
+		return true
+	case !r.flags["water"] && !r.flags["underwater"]:
+		return true
+	case m.swim(c):
+		return true
+	}
+	c.write(c.tr("water.flounder_water_cant"))
+	return false
+}
+
+// drown hurts the players in water who can't keep their heads above it:
+// swimmers without a boat who fail a swim check, and anyone underwater
+// without water breathing.
+func (m *mud) drown() {
+	for _, conn := range m.conns {
+		if conn.state != statePlaying {
+			continue
+		}
+		p := conn.player
+		r := m.rooms[p.room]
+		if r == nil {
+			continue
+		}
+		damage := 0
+		switch {
+		case r.flags["underwater"] && !p.affected(effectWaterBreathing):
+			damage = 10
+			m.send(only(conn), plain(colorize(conn.tr("water.drowning"), "red")).asAside(nil))
+		case r.flags["water"] && !m.hasBoat(p) && !p.swimRoll():
+			damage = 5
+			m.send(only(conn), plain(colorize(conn.tr("water.slip_under"), "red")).asAside(nil))
+		}
+		if damage == 0 {
+			continue
+		}
+		p.health -= damage
+		if p.health <= 0 {
+			m.playerDeath(conn, "the water")
+		}
+	}
+}
+
+// use consumes an item from the player's inventory for its effect.
+func (m *mud) use(c *connection, args []string) {
+	if len(args) == 0 {
+		c.write(c.tr("water.use_what"))
+		return
+	}
+	p := c.player
+	i := findItem(p.inventory, args[0])
+	if i < 0 {
+		c.write(c.tr("common.dont_have"))
+		return
+	}
+	it := p.inventory[i]
+	t, ok := m.itemTemplates[it.id]
+	if ok && t.Teaches != "" {
+		p.inventory = removeItem(p.inventory, i)
+		m.study(c, it, t.Teaches)
+		return
+	}
+	if !ok || t.Effect == "" {
+		c.write(c.tr("water.cant_use", "item", it.displayName(c)))
+		return
+	}
+	p.inventory = removeItem(p.inventory, i)
+	p.addEffect(t.Effect, time.Duration(t.Duration)*time.Second)
+	c.write(c.tr("water.use", "item", it.displayName(c)))
+	if t.Disguise != "" {
+		p.disguiseAs(t.Disguise, time.Duration(t.Duration)*time.Second)
+		c.write(c.tr("water.others_see", "disguise", t.Disguise))
+	}
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// webhookConfig is a URL the server posts game events to, as JSON.
+type webhookConfig struct {
+	URL string `json:"url"`
+
+	// Events lists the kinds of event to post; with none, level-ups,
+	// deaths, rare drops, and logins.
+	Events []string `json:"events"`
+
+	// Secret, if set, signs each post: the X-Mud-Signature header holds
+	// "sha256=" and the hex HMAC-SHA256 of the body under it.
+	Secret string `json:"secret"`
+
+	// Format is "json" for the event as the feed has it, or "discord"
+	// for a line of text a Discord webhook shows as a message.
+	Format string `json:"format"`
+}
+
+// defaultWebhookEvents are the kinds of event a webhook posts unless it
+// says otherwise.
+var defaultWebhookEvents = []string{eventLevelUp, eventDeath, eventRareDrop, eventLogin}
+
+// webhookTries is how many times a post is tried before it is given up.
+const webhookTries = 3
+
+var webhookClient = &http.Client{Timeout: 10 * time.Second}
+
+// startWebhooks checks the config's webhooks and starts posting the
+// host's events to them, each from its own goroutine so that a slow
+// receiver holds up only itself.
+func (h *host) startWebhooks(hooks []webhookConfig) error {
+	known := make(map[string]bool)
+	for _, kind := range feedKinds {
+		known[kind] = true
+	}
+	for i, hook := range hooks {
+		u, err := url.Parse(hook.URL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("webhook %d: %q is not an http or https URL", i+1, hook.URL)
+		}
+		if hook.Format != "" && hook.Format != "json" && hook.Format != "discord" {
+			return fmt.Errorf("webhook %d: unknown format %q", i+1, hook.Format)
+		}
+		for _, kind := range hook.Events {
+			if !known[kind] {
+				return fmt.Errorf("webhook %d: unknown event %q", i+1, kind)
+			}
+		}
+	}
+	for _, hook := range hooks {
+		events, _ := h.feed.watch()
+		go hook.run(events)
+	}
+	return nil
+}
+
+// run posts the events the webhook wants, for as long as the server runs.
+func (hook webhookConfig) run(events <-chan feedEvent) {
+	kinds := hook.Events
+	if len(kinds) == 0 {
+		kinds = defaultWebhookEvents
+	}
+	wanted := make(map[string]bool)
+	for _, kind := range kinds {
+		wanted[kind] = true
+	}
+	for e := range events {
+		if wanted[e.Kind] {
+			hook.post(e)
+		}
+	}
+}
+
+// post sends one event, trying again after a failure with a growing
+// pause. An event that can't be delivered is logged and dropped.
+func (hook webhookConfig) post(e feedEvent) {
+	var body []byte
+	var err error
+	if hook.Format == "discord" {
+		body, err = json.Marshal(map[string]string{"content": e.describe()})
+	} else {
+		body, err = json.Marshal(e)
+	}
+	if err != nil {
+		log.Printf("webhook %s: %v", hook.URL, err)
+		return
+	}
+	pause := time.Second
+	for try := 1; ; try++ {
+		err = hook.send(body)
+		if err == nil {
+			return
+		}
+		if try == webhookTries {
+			log.Printf("webhook %s: giving up on a %s event: %v", hook.URL, e.Kind, err)
+			return
+		}
+		time.Sleep(pause)
+		pause *= 2
+	}
+}
+
+// send makes one post of the body.
+func (hook webhookConfig) send(body []byte) error {
+	req, err := http.NewRequest(http.MethodPost, hook.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if hook.Secret != "" {
+		mac := hmac.New(sha256.New, []byte(hook.Secret))
+		mac.Write(body)
+		req.Header.Set("X-Mud-Signature", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	}
+	resp, err := webhookClient.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("the receiver answered %s", resp.Status)
+	}
+	return nil
+}
+
+// describe puts an event into a line of text, for chat integrations.
+func (e feedEvent) describe() string {
+	var text string
+	switch e.Kind {
+	case eventLevelUp:
+		text = fmt.Sprintf("%s reached level %d.", e.Player, e.Amount)
+	case eventDeath:
+		text = fmt.Sprintf("%s was slain by %s.", e.Player, e.Killer)
+	case eventRareDrop:
+		text = fmt.Sprintf("%s found %s!", e.Player, e.Item)
+	case eventLogin:
+		text = fmt.Sprintf("%s has entered the game.", e.Player)
+	case eventKill:
+		if e.Victim != "" {
+			text = fmt.Sprintf("%s killed %s.", e.Player, e.Victim)
+		} else {
+			text = fmt.Sprintf("%s killed %s.", e.Player, e.NPC)
+		}
+	case eventGold:
+		text = fmt.Sprintf("%s gained %d gold.", e.Player, e.Amount)
+	case eventEnterRoom:
+		text = fmt.Sprintf("%s entered %s.", e.Player, e.Room)
+	default:
+		text = fmt.Sprintf("%s: %s", e.Kind, e.Player)
+	}
+	return fmt.Sprintf("[%s] %s", e.World, text)
+}
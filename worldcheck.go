@@ -0,0 +1,140 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// checkWorld looks for mistakes in the loaded world: exits to missing rooms,
+// exits with no way back, rooms sharing a map position, vehicles and NPCs
+// stopping or spawning in missing rooms, broken NPC dialogue, NPC pronouns
+// that aren't known, quests asking for things that don't exist, treasure
+// maps to missing loot, holidays decorating missing rooms, achievement
+// rewards that don't exist, dyes of unknown colors, and rooms that can't be
+// walked to from the start room. It returns a description of each problem
+// found.
+func (m *mud) checkWorld() []string {
+	var problems []string
+	interiors := make(map[string]bool)
+	for _, v := range m.vehicles {
+		interiors[v.interior.id] = true
+	}
+
+	// exits to missing rooms in area and world files are left out as they
+	// load, so the rest of the game never sees them
+	for _, e := range m.danglingExits {
+		problems = append(problems, fmt.Sprintf("%s: the %s exit leads to missing room %s", e.from, e.dir, e.to))
+	}
+	for _, id := range sortedKeys(m.rooms) {
+		// vehicles are boarded with enter, so their way out has no way back
+		if interiors[id] {
+			continue
+		}
+		r := m.rooms[id]
+		for _, dir := range sortedKeys(r.exits) {
+			to, ok := m.rooms[r.exits[dir]]
+			if !ok {
+				problems = append(problems, fmt.Sprintf("%s: the %s exit leads to missing room %s", id, dir, r.exits[dir]))
+				continue
+			}
+			back := false
+			for _, from := range to.exits {
+				if from == id {
+					back = true
+					break
+				}
+			}
+			if !back {
+				problems = append(problems, fmt.Sprintf("%s: the %s exit to %s has no way back", id, dir, to.id))
+			}
+		}
+	}
+
+	positions := make(map[[2]int][]string)
+	for _, id := range sortedKeys(m.rooms) {
+		if r := m.rooms[id]; r.mapped {
+			pos := [2]int{r.x, r.y}
+			positions[pos] = append(positions[pos], id)
+		}
+	}
+	var shared []string
+	for pos, ids := range positions {
+		if len(ids) > 1 {
+			shared = append(shared, fmt.Sprintf("%s share the map position %d,%d", strings.Join(ids, ", "), pos[0], pos[1]))
+		}
+	}
+	sort.Strings(shared)
+	problems = append(problems, shared...)
+
+	// a UID set by hand in an area file can be copied along with a room
+	uids := make(map[string]string)
+	for _, id := range sortedKeys(m.rooms) {
+		r := m.rooms[id]
+		if other, ok := uids[r.uid]; ok {
+			problems = append(problems, fmt.Sprintf("%s and %s share the UID %s", other, id, r.uid))
+			continue
+		}
+		uids[r.uid] = id
+	}
+
+	for _, v := range m.vehicles {
+		for _, stop := range v.Stops {
+			if m.roomFor(stop.Room) == nil {
+				problems = append(problems, fmt.Sprintf("vehicle %s stops at missing room %v", v.ID, stop.Room))
+			}
+		}
+	}
+	for _, id := range sortedKeys(m.npcTemplates) {
+		for _, ref := range m.npcTemplates[id].Spawns {
+			if m.roomFor(ref) == nil {
+				problems = append(problems, fmt.Sprintf("NPC %s spawns in missing room %v", id, ref))
+			}
+		}
+		if b := m.npcTemplates[id].Boss; b != nil {
+			problems = append(problems, m.checkBoss(id, b)...)
+		}
+		if d := m.npcTemplates[id].Dialogue; d != nil {
+			problems = append(problems, m.checkDialogue(id, d)...)
+		}
+		if set := m.npcTemplates[id].Pronouns; set != "" {
+			if _, ok := parsePronouns(set); !ok {
+				problems = append(problems, fmt.Sprintf("NPC %s has unknown pronouns %q", id, set))
+			}
+		}
+	}
+
+	problems = append(problems, m.checkGraveyards()...)
+	problems = append(problems, m.checkQuests()...)
+	problems = append(problems, m.checkCutscenes()...)
+	problems = append(problems, m.checkTreasure()...)
+	problems = append(problems, m.checkHolidays()...)
+	problems = append(problems, m.checkCosmetics()...)
+
+	// the jail and vehicles are reached by other means than walking
+	starts := m.startRooms()
+	reachable := m.reachableRooms(starts...)
+	for _, id := range sortedKeys(m.rooms) {
+		if !reachable[id] && id != jailRoom && !interiors[id] {
+			problems = append(problems, fmt.Sprintf("%s can't be reached from %s", id, strings.Join(starts, " or ")))
+		}
+	}
+	return problems
+}
+
+// worldcheckCommand shows staff the problems checkWorld finds.
+func (m *mud) worldcheckCommand(c *connection) {
+	if !c.player.admin {
+		c.write(c.tr("command.unknown"))
+		return
+	}
+	problems := m.checkWorld()
+	if len(problems) == 0 {
+		c.write(c.tr("worldcheck.world_checks_out", "rooms", len(m.rooms)))
+		return
+	}
+	c.write(c.tr("worldcheck.world_check_found", "count", len(problems)))
+	for _, p := range problems {
+		c.write(fmt.Sprintf("  %s\n", p))
+	}
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"time"
+)
+
+// world event types
+const (
+	worldEventInvasion = "invasion"
+	worldEventXP       = "xp"
+	worldEventSpawn    = "spawn"
+)
+
+// worldEvent is a scripted event defined in the world event data file. Every
+// few minutes it has a chance to start, and it ends after its duration.
+type worldEvent struct {
+	ID       string  `json:"id"`
+	Name     string  `json:"name"`
+	Type     string  `json:"type"`
+	Every    int     `json:"every"`
+	Chance   float64 `json:"chance"`
+	Duration int     `json:"duration"`
+	Start    string  `json:"start"`
+	End      string  `json:"end"`
+
+	// npc spawning for invasion and spawn events
+	NPC          string  `json:"npc"`
+	Room         roomRef `json:"room"`
+	Waves        int     `json:"waves"`
+	WaveSize     int     `json:"waveSize"`
+	WaveInterval int     `json:"waveInterval"`
+
+	// experience bonus for xp events
+	Multiplier int `json:"multiplier"`
+}
+
+// activeWorldEvent tracks a running world event and what it has spawned.
+type activeWorldEvent struct {
+	def     *worldEvent
+	spawned []*npc
+	tasks   []*task
+}
+
+// scheduleWorldEvents registers a recurring trigger check for every world event.
+func (m *mud) scheduleWorldEvents() {
+	for _, ev := range m.worldEvents {
+		if ev.Every <= 0 {
+			continue
+		}
+		ev := ev
+		m.scheduler.every("world event: "+ev.ID, time.Duration(ev.Every)*time.Minute, func() {
+			if _, active := m.activeEvents[ev.ID]; !active && rand.Float64() < ev.Chance {
+				m.startWorldEvent(ev)
+			}
+		})
+	}
+}
+
+// findWorldEvent returns the world event with the given id.
+func (m *mud) findWorldEvent(id string) *worldEvent {
+	for _, ev := range m.worldEvents {
+		if ev.ID == id {
+			return ev
+		}
+	}
+	return nil
+}
+
+// startWorldEvent announces and begins a world event.
+func (m *mud) startWorldEvent(ev *worldEvent) {
+	a := &activeWorldEvent{def: ev}
+	m.activeEvents[ev.ID] = a
+	if ev.Start != "" {
+		m.announceNow(ev.Start)
+	}
+
+	switch ev.Type {
+	case worldEventInvasion:
+		m.spawnEventNPCs(a, ev.WaveSize)
+		interval := time.Duration(ev.WaveInterval) * time.Second
+		for wave := 1; wave < ev.Waves; wave++ {
+			a.tasks = append(a.tasks, m.scheduler.after(fmt.Sprintf("world event: %s wave %d", ev.ID, wave+1), time.Duration(wave)*interval, func() {
+				m.spawnEventNPCs(a, ev.WaveSize)
+			}))
+		}
+	case worldEventSpawn:
+		m.spawnEventNPCs(a, 1)
+	}
+
+	if ev.Duration > 0 {
+		a.tasks = append(a.tasks, m.scheduler.after("world event: "+ev.ID+" end", time.Duration(ev.Duration)*time.Minute, func() {
+			m.endWorldEvent(ev.ID)
+		}))
+	}
+}
+
+// spawnEventNPCs places count copies of the event's NPC in its room.
+func (m *mud) spawnEventNPCs(a *activeWorldEvent, count int) {
+	t, ok := m.npcTemplates[a.def.NPC]
+	r := m.roomFor(a.def.Room)
+	if !ok || r == nil {
+		return
+	}
+	for i := 0; i < count; i++ {
+		n := newNPC(t)
+		r.npcs = append(r.npcs, n)
+		a.spawned = append(a.spawned, n)
+	}
+	m.send(inRoom(r.id), rendered((*connection).locale, func(conn *connection) string {
+		return conn.tr("event.arrives", "name", capitalize(t.Name))
+	}).asAside(nil))
+}
+
+// endWorldEvent stops a running world event, removing anything it spawned.
+func (m *mud) endWorldEvent(id string) bool {
+	a, ok := m.activeEvents[id]
+	if !ok {
+		return false
+	}
+	delete(m.activeEvents, id)
+	for _, t := range a.tasks {
+		m.scheduler.cancel(t.id)
+	}
+	for _, n := range a.spawned {
+		for _, r := range m.rooms {
+			r.removeNPC(n)
+		}
+	}
+	if a.def.End != "" {
+		m.announceNow(a.def.End)
+	}
+	return true
+}
+
+// xpMultiplier returns the experience multiplier from active world events.
+func (m *mud) xpMultiplier() int {
+	mult := 1
+	for _, a := range m.activeEvents {
+		if a.def.Type == worldEventXP && a.def.Multiplier > mult {
+			mult = a.def.Multiplier
+		}
+	}
+	return mult
+}
+
+// worldEventCommand lets staff list, start, and stop world events.
+func (m *mud) worldEventCommand(c *connection, args []string) {
+	if !c.player.admin {
+		c.write(c.tr("command.unknown"))
+		return
+	}
+	if len(args) == 0 || args[0] == "list" {
+		c.write(c.tr("event.world_events"))
+		for _, ev := range m.worldEvents {
+			status := "idle"
+			if _, ok := m.activeEvents[ev.ID]; ok {
+				status = "active"
+			}
+			c.write(c.tableRow("  %-12s %-20s %s\n", nil, ev.ID, ev.Name, status))
+		}
+		return
+	}
+	if len(args) < 2 {
+		c.write(c.tr("event.usage"))
+		return
+	}
+	switch args[0] {
+	case "start":
+		ev := m.findWorldEvent(args[1])
+		if ev == nil {
+			c.write(c.tr("event.no_such_world"))
+			return
+		}
+		if _, ok := m.activeEvents[ev.ID]; ok {
+			c.write(c.tr("event.event_already_running"))
+			return
+		}
+		m.startWorldEvent(ev)
+		c.write(c.tr("event.started", "event", ev.Name))
+	case "stop":
+		if !m.endWorldEvent(args[1]) {
+			c.write(c.tr("event.event_not_running"))
+			return
+		}
+		c.write(c.tr("event.stopped"))
+	default:
+		c.write(c.tr("event.usage"))
+	}
+}
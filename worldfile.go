@@ -0,0 +1,153 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+)
+
+// A world file holds the whole base world in the area format, so that
+// outside editors and tools can build worlds for the server. It is a JSON
+// object with these fields:
+//
+//	name    the world's name
+//	rooms   every room, each with an id, name, and description, and
+//	        optionally a position [x, y] on the map, exits from direction
+//	        to room id, doors {dir, name, key, closed, locked, hidden},
+//	        cliffs (the directions that need climbing), flags, the ids of
+//	        items lying in it, and a capacity if only so many players fit
+//	npcs    NPC templates, as in data/npcs.json
+//	items   item templates, as in data/items.json
+//	starts  where players start: each a room, and the tutorial room that
+//	        ends a rule for players who reach it, if any
+//
+// The world must have a security_office room, where players are jailed,
+// and a mall_entrance room unless its starts or the world's config name
+// another to start in. The -export-world flag writes the current
+// world, and the worldFile setting loads one in place of the built-in
+// map. Templates in a world file replace data templates of the same ID.
+
+// exportWorld describes the rooms, NPCs, and items of the base world. The
+// rooms and templates of areas are left out, as are exits leading to them,
+// since the areas load on their own.
+func (m *mud) exportWorld() *area {
+	inArea := make(map[string]bool)
+	for _, a := range m.areas {
+		for _, ar := range a.Rooms {
+			inArea[ar.ID] = true
+		}
+		for _, t := range a.NPCs {
+			inArea[t.ID] = true
+		}
+		for _, t := range a.Items {
+			inArea[t.ID] = true
+		}
+	}
+
+	w := &area{Name: "world", Starts: m.worldStarts}
+	for _, id := range sortedKeys(m.rooms) {
+		if !inArea[id] {
+			w.Rooms = append(w.Rooms, exportRoom(m.rooms[id], inArea))
+		}
+	}
+	for _, id := range sortedKeys(m.npcTemplates) {
+		if !inArea[id] {
+			w.NPCs = append(w.NPCs, m.npcTemplates[id])
+		}
+	}
+	for _, id := range sortedKeys(m.itemTemplates) {
+		if !inArea[id] {
+			w.Items = append(w.Items, m.itemTemplates[id])
+		}
+	}
+	return w
+}
+
+// exportRoom describes a room in the area format, leaving out exits into
+// the skipped rooms.
+func exportRoom(r *room, skip map[string]bool) *areaRoom {
+	ar := &areaRoom{ID: r.id, UID: r.uid, Name: r.name, Description: r.description, Capacity: r.capacity}
+	if r.mapped {
+		ar.Position = &[2]int{r.x, r.y}
+	}
+	for _, dir := range sortedKeys(r.exits) {
+		if skip[r.exits[dir]] {
+			continue
+		}
+		if ar.Exits == nil {
+			ar.Exits = make(map[string]string)
+		}
+		ar.Exits[dir] = r.exits[dir]
+		if d, ok := r.doors[dir]; ok {
+			ar.Doors = append(ar.Doors, areaDoor{
+				Dir:    dir,
+				Name:   d.name,
+				Key:    d.key,
+				Closed: d.closed,
+				Locked: d.locked,
+				Hidden: d.hidden,
+			})
+		}
+		if r.cliffs[dir] {
+			ar.Cliffs = append(ar.Cliffs, dir)
+		}
+	}
+	for _, flag := range sortedKeys(r.flags) {
+		if r.flags[flag] {
+			ar.Flags = append(ar.Flags, flag)
+		}
+	}
+	for _, it := range r.items {
+		ar.Items = append(ar.Items, it.id)
+	}
+	return ar
+}
+
+// sortedKeys returns the keys of a map in order.
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// exportWorldFile writes the base world to a world file.
+func (m *mud) exportWorldFile(path string) error {
+	data, err := json.MarshalIndent(m.exportWorld(), "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+// loadWorld builds the base world from a world file.
+func (m *mud) loadWorld(path string) error {
+	w := &area{}
+	if err := loadJSON(path, w); err != nil {
+		return fmt.Errorf("%s: %v", path, err)
+	}
+	for _, t := range w.Items {
+		m.itemTemplates[t.ID] = t
+	}
+	for _, t := range w.NPCs {
+		m.npcTemplates[t.ID] = t
+	}
+	for id, t := range w.Loot {
+		m.lootTables[id] = t
+	}
+	m.shopConfigs = append(m.shopConfigs, w.Shops...)
+	for _, ar := range w.Rooms {
+		m.addAreaRoom(ar)
+	}
+	if _, ok := m.rooms[jailRoom]; !ok {
+		return fmt.Errorf("%s: the world has no %s room", path, jailRoom)
+	}
+	m.worldStarts = w.Starts
+	if err := m.buildAreas([]*area{w}); err != nil {
+		return fmt.Errorf("%s: %v", path, err)
+	}
+	return nil
+}
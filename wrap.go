@@ -0,0 +1,178 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// defaultWidth is the width output is wrapped to when the client doesn't
+// say how wide its window is.
+const defaultWidth = 80
+
+// the narrowest and widest a player may set their width
+const (
+	minWidth = 20
+	maxWidth = 250
+)
+
+// wrapWidth returns the width the connection's output is wrapped to: the
+// player's choice, or else their client's window width.
+func (c *connection) wrapWidth() int {
+	if c.player != nil && c.player.width > 0 {
+		return c.player.width
+	}
+	if c.clientWidth > 0 {
+		return c.clientWidth
+	}
+	return defaultWidth
+}
+
+// word is a run of output between visible spaces, and how many columns
+// it takes up.
+type word struct {
+	text  string
+	width int
+}
+
+// splitWords splits a line of output into words at its visible spaces.
+// Color escapes take up no columns, and neither do MXP tags, which are
+// only sent in secure mode; their entities take up one.
+func splitWords(line string) []word {
+	var words []word
+	var cur strings.Builder
+	width := 0
+	secure, inTag, inEntity := false, false, false
+	for i := 0; i < len(line); {
+		if line[i] == '\x1b' {
+			// an escape runs to its final letter
+			end := i + 1
+			for end < len(line) && (end == i+1 || line[end] < 0x40 || line[end] > 0x7e) {
+				end++
+			}
+			if end < len(line) {
+				end++
+			}
+			esc := line[i:end]
+			switch esc {
+			case mxpSecureLine:
+				secure = true
+			case mxpLockedLine:
+				secure = false
+			}
+			cur.WriteString(esc)
+			i = end
+			continue
+		}
+		r, size := utf8.DecodeRuneInString(line[i:])
+		i += size
+		switch {
+		case inTag:
+			inTag = r != '>'
+		case inEntity:
+			inEntity = r != ';'
+		case secure && r == '<':
+			inTag = true
+		case secure && r == '&':
+			inEntity = true
+			width++
+		case r == ' ':
+			words = append(words, word{cur.String(), width})
+			cur.Reset()
+			width = 0
+			continue
+		default:
+			width++
+		}
+		cur.WriteRune(r)
+	}
+	return append(words, word{cur.String(), width})
+}
+
+// wrapLine breaks a line of output between words so that no piece is
+// wider than width, indenting each piece as far as the line is. A word
+// wider than width gets a piece of its own.
+func wrapLine(line string, width int) string {
+	words := splitWords(line)
+	total := len(words) - 1
+	for _, w := range words {
+		total += w.width
+	}
+	if total <= width {
+		return line
+	}
+	indent := 0
+	for indent < len(words)-1 && words[indent].text == "" {
+		indent++
+	}
+	prefix := strings.Repeat(" ", indent)
+	if indent >= width/2 {
+		prefix = ""
+	}
+	var b strings.Builder
+	b.WriteString(prefix)
+	col := len(prefix)
+	fresh := true
+	for _, w := range words[indent:] {
+		switch {
+		case fresh:
+		case col+1+w.width <= width:
+			b.WriteByte(' ')
+			col++
+		default:
+			b.WriteString("\n" + prefix)
+			col = len(prefix)
+		}
+		b.WriteString(w.text)
+		col += w.width
+		fresh = false
+	}
+	return b.String()
+}
+
+// wrapText wraps every line of output to the width.
+func wrapText(msg string, width int) string {
+	lines := strings.Split(msg, "\n")
+	for i, line := range lines {
+		lines[i] = wrapLine(line, width)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// nawsSubnegotiation takes the window size a client reports over NAWS.
+func (c *connection) nawsSubnegotiation(data []byte) {
+	if len(data) != 4 {
+		return
+	}
+	if w := int(data[0])<<8 | int(data[1]); w >= minWidth {
+		c.clientWidth = w
+	}
+}
+
+// widthCommand shows the width the player's output is wrapped to, or sets
+// it, overriding their client's window width.
+func (m *mud) widthCommand(c *connection, args []string) {
+	p := c.player
+	if len(args) == 0 {
+		how := "your client's window width"
+		switch {
+		case p.width > 0:
+			how = "as you set it"
+		case c.clientWidth == 0:
+			how = "the default"
+		}
+		c.write(c.tr("width.current", "width", c.wrapWidth(), "how", how))
+		c.write(c.tr("width.use_width_columns"))
+		return
+	}
+	if strings.EqualFold(args[0], "auto") {
+		p.width = 0
+	} else if n, err := strconv.Atoi(args[0]); err == nil && n >= minWidth && n <= maxWidth {
+		p.width = n
+	} else {
+		c.write(c.tr("width.usage_width_auto", "min", minWidth, "max", maxWidth))
+		return
+	}
+	m.savePlayer(c)
+	c.write(c.tr("width.changed", "width", c.wrapWidth()))
+}
@@ -0,0 +1,277 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// zonesDir is where the state of paged-out zones is kept, under the
+// world's state directory.
+const zonesDir = "zones"
+
+// zone is an area, whose rooms can be paged out while no one is in them
+// for worlds too large to keep whole in memory. The rooms themselves stay,
+// so exits, maps, and paths still work, but their descriptions are dropped
+// and read back from the area file, and their NPCs and items are written
+// to the state directory until someone comes back.
+type zone struct {
+	area      *area
+	path      string
+	loaded    bool
+	idleSince time.Time
+}
+
+// pagedZone is the state of a zone's rooms while it is paged out.
+type pagedZone struct {
+	NPCs  []pagedNPC              `json:"npcs,omitempty"`
+	Items map[string][]itemRecord `json:"items,omitempty"`
+}
+
+// pagedNPC is an NPC waiting in a paged-out zone, with whatever it has
+// come to differ from its template by, such as a boss's enraged damage.
+type pagedNPC struct {
+	ID        string       `json:"id"`
+	Room      string       `json:"room"`
+	Health    int          `json:"health"`
+	Damage    int          `json:"damage,omitempty"`
+	Phase     int          `json:"phase,omitempty"`
+	Rounds    int          `json:"rounds,omitempty"`
+	Inventory []itemRecord `json:"inventory,omitempty"`
+}
+
+// pagedNPCOf records an NPC in the given room to be paged out.
+func pagedNPCOf(n *npc, room string) pagedNPC {
+	pn := pagedNPC{ID: n.id, Room: room, Health: n.health, Damage: n.damage, Phase: n.phase, Rounds: n.rounds}
+	for _, it := range n.inventory {
+		pn.Inventory = append(pn.Inventory, it.record())
+	}
+	return pn
+}
+
+// npc rebuilds the paged-out NPC from its template.
+func (pn pagedNPC) npc(t *npcTemplate) *npc {
+	n := newNPC(t)
+	n.health = pn.Health
+	// zones paged out before damage was kept have none recorded
+	if pn.Damage > 0 {
+		n.damage = pn.Damage
+	}
+	n.phase, n.rounds = pn.Phase, pn.Rounds
+	for _, rec := range pn.Inventory {
+		n.inventory = append(n.inventory, itemFromRecord(rec))
+	}
+	return n
+}
+
+// addZones makes zones of the areas loaded from the given files.
+func (m *mud) addZones(areas []*area, paths []string) {
+	now := time.Now()
+	for i, a := range areas {
+		z := &zone{area: a, path: paths[i], loaded: true, idleSince: now}
+		for _, ar := range a.Rooms {
+			m.roomZones[ar.ID] = z
+		}
+		m.zones = append(m.zones, z)
+	}
+}
+
+// statePath returns where the zone's state is kept while it is paged out.
+func (z *zone) statePath(m *mud) string {
+	return m.statePath(filepath.Join(zonesDir, filepath.Base(z.path)))
+}
+
+// wakeRoom pages in the zone the room belongs to, if it is paged out.
+func (m *mud) wakeRoom(id string) {
+	if z := m.roomZones[id]; z != nil && !z.loaded {
+		m.pageIn(z)
+	}
+}
+
+// pageIn reads a zone's descriptions back from its area file and restores
+// its NPCs and items.
+func (m *mud) pageIn(z *zone) {
+	z.loaded, z.idleSince = true, time.Now()
+	m.zonePageIns++
+
+	var a area
+	if err := loadJSON(z.path, &a); err != nil {
+		log.Printf("error reading zone %s: %v", z.area.Name, err)
+	}
+	descriptions := make(map[string]string)
+	for _, ar := range a.Rooms {
+		descriptions[ar.ID] = ar.Description
+	}
+	for _, ar := range z.area.Rooms {
+		ar.Description = descriptions[ar.ID]
+		if r := m.rooms[ar.ID]; r != nil {
+			r.description = ar.Description
+		}
+	}
+
+	var paged pagedZone
+	path := z.statePath(m)
+	if err := loadJSON(path, &paged); err != nil {
+		log.Printf("error reading zone %s: %v", z.area.Name, err)
+		return
+	}
+	for _, pn := range paged.NPCs {
+		t, ok := m.npcTemplates[pn.ID]
+		r := m.rooms[pn.Room]
+		if !ok || r == nil {
+			continue
+		}
+		r.npcs = append(r.npcs, pn.npc(t))
+	}
+	for _, id := range sortedKeys(paged.Items) {
+		if r := m.rooms[id]; r != nil {
+			for _, rec := range paged.Items[id] {
+				r.items = append(r.items, itemFromRecord(rec))
+			}
+		}
+	}
+	if err := os.Remove(path); err != nil {
+		log.Printf("error removing %s: %v", path, err)
+	}
+}
+
+// pageOut writes a zone's NPCs and items to the state directory, then
+// drops them and its descriptions. Trapped containers stay, as their traps
+// hold on to them.
+func (m *mud) pageOut(z *zone) {
+	trapped := make(map[*item]bool)
+	for _, t := range m.traps {
+		if t.container != nil {
+			trapped[t.container] = true
+		}
+	}
+	paged := pagedZone{Items: make(map[string][]itemRecord)}
+	for _, ar := range z.area.Rooms {
+		r := m.rooms[ar.ID]
+		if r == nil {
+			continue
+		}
+		for _, n := range r.npcs {
+			paged.NPCs = append(paged.NPCs, pagedNPCOf(n, r.id))
+		}
+		for _, it := range r.items {
+			if !trapped[it] {
+				paged.Items[r.id] = append(paged.Items[r.id], it.record())
+			}
+		}
+	}
+	data, err := json.MarshalIndent(paged, "", "  ")
+	if err == nil {
+		path := z.statePath(m)
+		if err = os.MkdirAll(filepath.Dir(path), 0755); err == nil {
+			err = os.WriteFile(path, data, 0644)
+		}
+	}
+	if err != nil {
+		// keep the zone rather than lose what is in it
+		log.Printf("error paging out zone %s: %v", z.area.Name, err)
+		return
+	}
+
+	for _, ar := range z.area.Rooms {
+		ar.Description = ""
+		r := m.rooms[ar.ID]
+		if r == nil {
+			continue
+		}
+		var kept []*item
+		for _, it := range r.items {
+			if trapped[it] {
+				kept = append(kept, it)
+			}
+		}
+		r.description, r.npcs, r.items = "", nil, kept
+	}
+	z.loaded = false
+	m.zonePageOuts++
+}
+
+// zoneBusy reports whether a zone must stay loaded: someone is in it, or
+// it holds NPCs that others keep track of, such as hirelings, fighters,
+// boss adds, and those of running world events and holidays.
+func (m *mud) zoneBusy(z *zone) bool {
+	for _, c := range m.conns {
+		if c.state == statePlaying && m.roomZones[c.player.room] == z {
+			return true
+		}
+	}
+	tracked := make(map[*npc]bool)
+	for _, a := range m.activeEvents {
+		for _, n := range a.spawned {
+			tracked[n] = true
+		}
+	}
+	for _, a := range m.activeHolidays {
+		for _, n := range a.spawned {
+			tracked[n] = true
+		}
+	}
+	for _, ar := range z.area.Rooms {
+		if r := m.rooms[ar.ID]; r != nil {
+			for _, n := range r.npcs {
+				if n.master != "" || n.fighting != nil || n.summoner != nil || tracked[n] {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// pageZones pages out the zones that have gone unvisited for the config's
+// idle time, and pages in any someone has reached without being shown a
+// room.
+func (m *mud) pageZones() {
+	idle := time.Duration(m.config.ZoneIdleMinutes) * time.Minute
+	now := time.Now()
+	for _, z := range m.zones {
+		busy := m.zoneBusy(z)
+		switch {
+		case busy && !z.loaded:
+			m.pageIn(z)
+		case busy:
+			z.idleSince = now
+		case z.loaded && now.Sub(z.idleSince) >= idle:
+			m.pageOut(z)
+		}
+	}
+}
+
+// pageOutIdleZones pages out every zone no one is in, as the world starts.
+func (m *mud) pageOutIdleZones() {
+	for _, z := range m.zones {
+		if z.loaded && !m.zoneBusy(z) {
+			m.pageOut(z)
+		}
+	}
+}
+
+// zonesCommand shows staff which zones are loaded and the server's memory
+// use.
+func (m *mud) zonesCommand(c *connection) {
+	if !c.player.admin {
+		c.write(c.tr("command.unknown"))
+		return
+	}
+	wm := m.worldMetrics()
+	c.write(c.tr("zones.loaded", "rooms", wm.Rooms, "npcs", wm.NPCs, "items", wm.Items, "loaded", wm.ZonesLoaded, "zones", wm.Zones))
+	if m.config.ZoneIdleMinutes <= 0 {
+		c.write(c.tr("zones.zones_never_paged"))
+	}
+	for _, z := range m.zones {
+		status := "paged out"
+		if z.loaded {
+			status = "idle " + formatDuration(time.Since(z.idleSince))
+		}
+		c.write(c.tableRow("  %-24s %4d rooms  %s\n", []string{"", "rooms", ""}, z.area.Name, len(z.area.Rooms), status))
+	}
+	sm := readServerMetrics()
+	c.write(c.tr("zones.memory", "heap", formatBytes(sm.HeapAlloc), "system", formatBytes(sm.Sys), "collections", sm.NumGC))
+}
@@ -0,0 +1,22 @@
+package main
+
+import "testing"
+
+func TestPagedNPCKeepsRuntimeState(t *testing.T) {
+	tmpl := &npcTemplate{ID: "ogre", Name: "an ogre", Health: 100, Damage: 10}
+	n := newNPC(tmpl)
+	n.health, n.damage, n.phase, n.rounds = 60, 15, 1, 7
+
+	back := pagedNPCOf(n, "cave").npc(tmpl)
+	if back.health != 60 || back.damage != 15 || back.phase != 1 || back.rounds != 7 {
+		t.Errorf("paged back in with health %d, damage %d, phase %d, rounds %d; want 60, 15, 1, 7",
+			back.health, back.damage, back.phase, back.rounds)
+	}
+}
+
+func TestPagedNPCWithoutDamageUsesTemplate(t *testing.T) {
+	tmpl := &npcTemplate{ID: "ogre", Name: "an ogre", Health: 100, Damage: 10}
+	if n := (pagedNPC{ID: "ogre", Room: "cave", Health: 100}).npc(tmpl); n.damage != 10 {
+		t.Errorf("damage %d, want the template's 10", n.damage)
+	}
+}